@@ -1,14 +1,131 @@
 package aof
 
-import "godis/redis/protocol"
+import (
+	"errors"
+	"fmt"
+	"godis/redis/parser"
+	"godis/redis/protocol"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"sync"
+)
 
-type Persist interface {
-	Persist(args [][]byte)
+// Persister appends the write commands to the append only file, in the RESP format of the requests
+type Persister struct {
+	mu   sync.Mutex
+	file *os.File
+	// bytes of complete commands in the file, a failed write is truncated back to it
+	size int64
+	// set when data was written since the last fsync
+	dirty bool
 }
 
-type AOFPersistor struct {
-	cmdCh chan *protocol.MultiBulkReply
+// Open opens the append only file for appending, it's created if it doesn't exist
+func Open(filename string) (*Persister, error) {
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return &Persister{file: file, size: info.Size()}, nil
 }
 
-func (ap *AOFPersistor) Persist(args [][]byte) {
+// Write appends cmds with a single write
+func (p *Persister) Write(cmds ...[][]byte) error {
+	var b []byte
+	for _, cmd := range cmds {
+		b = append(b, protocol.MakeMultiBulkReply(cmd).ToBytes()...)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n, err := p.file.Write(b)
+	if err != nil {
+		// like Redis, a partial command is removed so that the file can still be loaded
+		if n > 0 {
+			if truncErr := p.file.Truncate(p.size); truncErr != nil {
+				log.Printf("could not remove a partial write from the append only file: %v", truncErr)
+			}
+		}
+		return err
+	}
+	p.size += int64(n)
+	p.dirty = true
+	return nil
+}
+
+// Sync flushes what was written since the last call to the disk
+func (p *Persister) Sync() error {
+	p.mu.Lock()
+	dirty := p.dirty
+	p.dirty = false
+	p.mu.Unlock()
+	if !dirty {
+		return nil
+	}
+	// writes may go on meanwhile
+	return p.file.Sync()
+}
+
+// Close flushes the file to the disk and closes it
+func (p *Persister) Close() error {
+	if err := p.Sync(); err != nil {
+		log.Printf("fsync of the append only file failed: %v", err)
+	}
+	return p.file.Close()
+}
+
+// countingReader counts the bytes read, so that the offset of the last complete command is known
+type countingReader struct {
+	reader io.Reader
+	n      int64
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.reader.Read(b)
+	c.n += int64(n)
+	return n, err
+}
+
+// Load runs exec on every command of the append only file, a missing file has no command
+// a command cut by a crash at the end of the file is dropped and the file is truncated before it,
+// like Redis does with aof-load-truncated
+func Load(filename string, exec func(args [][]byte) error) error {
+	file, err := os.Open(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := &countingReader{reader: file}
+	commands := parser.NewParser(reader)
+	// offset of the end of the last complete command
+	valid := int64(0)
+	for {
+		args, err := commands.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err == io.ErrUnexpectedEOF {
+			log.Printf("!!! Warning: short read while loading the append only file %s, truncating it to %d bytes !!!", filename, valid)
+			return os.Truncate(filename, valid)
+		}
+		if err != nil {
+			return fmt.Errorf("bad file format reading the append only file %s: %w", filename, err)
+		}
+		valid = reader.n - int64(commands.Buffered())
+		// commands may keep their arguments while the parser reuses its buffer
+		if err := exec(parser.CloneArgs(args)); err != nil {
+			return err
+		}
+	}
 }
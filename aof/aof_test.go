@@ -0,0 +1,58 @@
+package aof
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWriteAndLoad(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "appendonly.aof")
+	p, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	written := [][][]byte{
+		{[]byte("set"), []byte("a"), []byte("1\r\n2")},
+		{[]byte("del"), []byte("a")},
+	}
+	if err := p.Write(written...); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// a crash while writing leaves a partial command
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = file.WriteString("*2\r\n$3\r\ndel\r\n$1\r\n")
+	_ = file.Close()
+
+	var loaded [][][]byte
+	load := func(args [][]byte) error {
+		loaded = append(loaded, args)
+		return nil
+	}
+	if err := Load(filename, load); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, written) {
+		t.Errorf("expected %q, got %q", written, loaded)
+	}
+
+	// the partial command was truncated, the file loads the same
+	loaded = nil
+	if err := Load(filename, load); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, written) {
+		t.Errorf("expected %q after truncating, got %q", written, loaded)
+	}
+
+	if err := Load(filepath.Join(t.TempDir(), "missing.aof"), load); err != nil {
+		t.Errorf("expected a missing file to load nothing, got %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"godis/redis/parser"
+	"godis/tcp/server"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func init() {
+	// the commands log a lot
+	log.SetOutput(io.Discard)
+}
+
+func TestFormatTTY(t *testing.T) {
+	tests := []struct {
+		name     string
+		reply    any
+		expected string
+	}{
+		{"status", parser.SimpleString("OK"), "OK\n"},
+		{"bulk string", "a\"b\\\r\n\x00é", `"a\"b\\\r\n\x00\xc3\xa9"` + "\n"},
+		{"integer", int64(-3), "(integer) -3\n"},
+		{"nil", nil, "(nil)\n"},
+		{"error", parser.ReplyError("ERR failed"), "(error) ERR failed\n"},
+		{"empty array", []any{}, "(empty array)\n"},
+		{"double", 1.5, "(double) 1.5\n"},
+		{"boolean", true, "(true)\n"},
+		{"big number", big.NewInt(12), "(big number) 12\n"},
+		{"nested arrays", []any{[]any{"a", "b"}, "c", nil, int64(1), "e", "f", "g", "h", "i", []any{"j"}},
+			" 1) 1) \"a\"\n    2) \"b\"\n 2) \"c\"\n 3) (nil)\n 4) (integer) 1\n 5) \"e\"\n 6) \"f\"\n 7) \"g\"\n 8) \"h\"\n 9) \"i\"\n10) 1) \"j\"\n"},
+		{"map", map[string]any{"b": int64(2), "a": []any{"x", "y"}},
+			"1# \"a\" => 1) \"x\"\n          2) \"y\"\n2# \"b\" => (integer) 2\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatTTY(tt.reply, ""); got != tt.expected {
+				t.Errorf("expected\n%s\ngot\n%s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestFormatRaw(t *testing.T) {
+	reply := []any{"a\nb", int64(1), nil, parser.ReplyError("ERR failed"), []any{parser.SimpleString("OK")}}
+	expected := "a\nb\n1\n\nERR failed\nOK"
+	if got := formatRaw(reply); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+// newTestCli connects a cli to a handler through an in-memory connection
+func newTestCli(t *testing.T, out io.Writer) *cli {
+	handler := server.NewRedisHandler()
+	t.Cleanup(func() { _ = handler.Close() })
+	serverConn, clientConn := net.Pipe()
+	go handler.HandleF(context.Background(), serverConn)
+	c := &cli{cfg: &config{}, out: bufio.NewWriter(out)}
+	c.useConn(clientConn)
+	t.Cleanup(c.disconnect)
+	return c
+}
+
+func TestExecute(t *testing.T) {
+	var out bytes.Buffer
+	c := newTestCli(t, &out)
+	c.tty = true
+	for _, line := range []string{`RPUSH list a "b c" 'd'`, "LRANGE list 0 -1", "GET missing", "LPUSH"} {
+		args, err := parser.SplitArgs(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.execute(args); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := "(integer) 3\n1) \"a\"\n2) \"b c\"\n3) \"d\"\n(nil)\n(error) ERR wrong number of arguments for 'lpush' command\n"
+	if out.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, out.String())
+	}
+}
+
+func TestPipe(t *testing.T) {
+	c := newTestCli(t, io.Discard)
+	var input strings.Builder
+	for i := 0; i < 1000; i++ {
+		input.WriteString("*3\r\n$5\r\nRPUSH\r\n$4\r\nlist\r\n$1\r\nx\r\n")
+	}
+	// inline commands are accepted too
+	input.WriteString("GET list\r\nLLEN list\r\n")
+	var report bytes.Buffer
+	if err := c.pipe(strings.NewReader(input.String()), &report); err == nil {
+		t.Fatal("expected the failure of GET to be reported")
+	}
+	if !strings.HasSuffix(report.String(), "errors: 1, replies: 1002\n") {
+		t.Errorf("unexpected report %q", report.String())
+	}
+	reply, err := c.command([]string{"LLEN", "list"})
+	if err != nil || reply != int64(1000) {
+		t.Errorf("expected 1000 elements, got %v, %v", reply, err)
+	}
+}
+
+func TestScanKeys(t *testing.T) {
+	var out bytes.Buffer
+	c := newTestCli(t, &out)
+	for i := 0; i < 50; i++ {
+		if _, err := c.command([]string{"SET", "key:" + strconv.Itoa(i), "v"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.cfg.pattern = "key:1*"
+	c.cfg.count = 5
+	if err := c.scanKeys(); err != nil {
+		t.Fatal(err)
+	}
+	keys := strings.Fields(out.String())
+	slices.Sort(keys)
+	expected := []string{"key:1", "key:10", "key:11", "key:12", "key:13", "key:14", "key:15", "key:16", "key:17", "key:18", "key:19"}
+	if !slices.Equal(keys, expected) {
+		t.Errorf("expected %v, got %v", expected, keys)
+	}
+}
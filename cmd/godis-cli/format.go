@@ -0,0 +1,171 @@
+package main
+
+import (
+	"godis/redis/parser"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// formatTTY formats a reply like redis-cli does on a terminal, every line ends with "\n"
+// indent prefixes the lines following the first one, for the elements of nested arrays
+func formatTTY(reply any, indent string) string {
+	switch v := reply.(type) {
+	case nil:
+		return "(nil)\n"
+	case parser.ReplyError:
+		return "(error) " + string(v) + "\n"
+	case parser.SimpleString:
+		return string(v) + "\n"
+	case string:
+		return quote(v) + "\n"
+	case int64:
+		return "(integer) " + strconv.FormatInt(v, 10) + "\n"
+	case float64:
+		return "(double) " + formatDouble(v) + "\n"
+	case bool:
+		if v {
+			return "(true)\n"
+		}
+		return "(false)\n"
+	case *big.Int:
+		return "(big number) " + v.String() + "\n"
+	case parser.Push:
+		return formatElements(v, indent)
+	case []any:
+		if len(v) == 0 {
+			return "(empty array)\n"
+		}
+		return formatElements(v, indent)
+	case map[string]any:
+		if len(v) == 0 {
+			return "(empty hash)\n"
+		}
+		keys := sortedKeys(v)
+		width := len(strconv.Itoa(len(keys)))
+		var b strings.Builder
+		for i, key := range keys {
+			if i > 0 {
+				b.WriteString(indent)
+			}
+			label := padLeft(strconv.Itoa(i+1), width) + "# " + quote(key) + " => "
+			b.WriteString(label)
+			b.WriteString(formatTTY(v[key], indent+strings.Repeat(" ", len(label))))
+		}
+		return b.String()
+	}
+	return "(unknown reply)\n"
+}
+
+// formatElements numbers the elements of an array, the nested ones are aligned on their parent
+func formatElements(elements []any, indent string) string {
+	width := len(strconv.Itoa(len(elements)))
+	var b strings.Builder
+	for i, element := range elements {
+		if i > 0 {
+			b.WriteString(indent)
+		}
+		label := padLeft(strconv.Itoa(i+1), width) + ") "
+		b.WriteString(label)
+		b.WriteString(formatTTY(element, indent+strings.Repeat(" ", len(label))))
+	}
+	return b.String()
+}
+
+// formatRaw formats a reply for scripts: strings are printed as they are, array elements one per line
+func formatRaw(reply any) string {
+	switch v := reply.(type) {
+	case nil:
+		return ""
+	case parser.ReplyError:
+		return string(v)
+	case parser.SimpleString:
+		return string(v)
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return formatDouble(v)
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case *big.Int:
+		return v.String()
+	case parser.Push:
+		return formatRaw([]any(v))
+	case []any:
+		lines := make([]string, len(v))
+		for i, element := range v {
+			lines[i] = formatRaw(element)
+		}
+		return strings.Join(lines, "\n")
+	case map[string]any:
+		var lines []string
+		for _, key := range sortedKeys(v) {
+			lines = append(lines, key, formatRaw(v[key]))
+		}
+		return strings.Join(lines, "\n")
+	}
+	return ""
+}
+
+func formatDouble(f float64) string {
+	return strconv.FormatFloat(f, 'g', 17, 64)
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func padLeft(s string, width int) string {
+	if len(s) >= width {
+		return s
+	}
+	return strings.Repeat(" ", width-len(s)) + s
+}
+
+// quote quotes a string like redis-cli, escaping the quotes, backslashes and unprintable bytes
+// the result can be pasted back at the prompt
+func quote(s string) string {
+	var b strings.Builder
+	b.Grow(len(s) + 2)
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '\\', '"':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\a':
+			b.WriteString(`\a`)
+		case '\b':
+			b.WriteString(`\b`)
+		default:
+			if c < 0x20 || c > 0x7e {
+				const digits = "0123456789abcdef"
+				b.WriteString(`\x`)
+				b.WriteByte(digits[c>>4])
+				b.WriteByte(digits[c&0xf])
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
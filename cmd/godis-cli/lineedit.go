@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// errInterrupted is returned by readLine when Ctrl-C is typed
+var errInterrupted = errors.New("interrupted")
+
+const maxHistory = 1000
+
+// lineEditor reads lines from a terminal with emacs like keys and a history browsed with the arrows
+// when the input isn't a terminal, lines are read as they are
+type lineEditor struct {
+	in      *bufio.Reader
+	out     io.Writer
+	fd      int
+	history []string
+}
+
+func (e *lineEditor) addHistory(line string) {
+	if n := len(e.history); n > 0 && e.history[n-1] == line {
+		return
+	}
+	e.history = append(e.history, line)
+	if len(e.history) > maxHistory {
+		e.history = e.history[len(e.history)-maxHistory:]
+	}
+}
+
+// readLine prints prompt and returns the line typed, io.EOF once the input is closed or on Ctrl-D
+func (e *lineEditor) readLine(prompt string) (string, error) {
+	restore, err := makeRaw(e.fd)
+	if err != nil {
+		return e.readPlainLine(prompt)
+	}
+	defer restore()
+
+	var line []rune
+	pos := 0
+	// history[len(history)] is the line being edited, saved while browsing
+	historyPos, edited := len(e.history), ""
+	refresh := func() {
+		var b strings.Builder
+		b.WriteString("\r" + prompt + string(line) + "\x1b[K")
+		if back := len(line) - pos; back > 0 {
+			b.WriteString("\x1b[" + strconv.Itoa(back) + "D")
+		}
+		_, _ = io.WriteString(e.out, b.String())
+	}
+	browse := func(to int) {
+		if to < 0 || to > len(e.history) {
+			return
+		}
+		if historyPos == len(e.history) {
+			edited = string(line)
+		}
+		historyPos = to
+		if to == len(e.history) {
+			line = []rune(edited)
+		} else {
+			line = []rune(e.history[to])
+		}
+		pos = len(line)
+	}
+
+	refresh()
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			_, _ = io.WriteString(e.out, "\n")
+			return "", err
+		}
+		switch r {
+		case '\r', '\n':
+			_, _ = io.WriteString(e.out, "\n")
+			return string(line), nil
+		case 3: // Ctrl-C
+			_, _ = io.WriteString(e.out, "^C\n")
+			return "", errInterrupted
+		case 4: // Ctrl-D
+			if len(line) == 0 {
+				_, _ = io.WriteString(e.out, "\n")
+				return "", io.EOF
+			}
+			if pos < len(line) {
+				line = append(line[:pos], line[pos+1:]...)
+			}
+		case 127, 8: // Backspace
+			if pos > 0 {
+				line = append(line[:pos-1], line[pos:]...)
+				pos--
+			}
+		case 1: // Ctrl-A
+			pos = 0
+		case 5: // Ctrl-E
+			pos = len(line)
+		case 2: // Ctrl-B
+			pos = max(pos-1, 0)
+		case 6: // Ctrl-F
+			pos = min(pos+1, len(line))
+		case 11: // Ctrl-K
+			line = line[:pos]
+		case 21: // Ctrl-U
+			line = append(line[:0], line[pos:]...)
+			pos = 0
+		case 23: // Ctrl-W
+			start := pos
+			for start > 0 && line[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && line[start-1] != ' ' {
+				start--
+			}
+			line = append(line[:start], line[pos:]...)
+			pos = start
+		case 12: // Ctrl-L
+			_, _ = io.WriteString(e.out, "\x1b[H\x1b[2J")
+		case 16: // Ctrl-P
+			browse(historyPos - 1)
+		case 14: // Ctrl-N
+			browse(historyPos + 1)
+		case 27:
+			switch e.escapeSequence() {
+			case "A":
+				browse(historyPos - 1)
+			case "B":
+				browse(historyPos + 1)
+			case "C":
+				pos = min(pos+1, len(line))
+			case "D":
+				pos = max(pos-1, 0)
+			case "H", "1~", "7~":
+				pos = 0
+			case "F", "4~", "8~":
+				pos = len(line)
+			case "3~":
+				if pos < len(line) {
+					line = append(line[:pos], line[pos+1:]...)
+				}
+			}
+		default:
+			if unicode.IsPrint(r) {
+				line = append(line[:pos], append([]rune{r}, line[pos:]...)...)
+				pos++
+			}
+		}
+		refresh()
+	}
+}
+
+// escapeSequence reads the end of an escape sequence like "\x1b[A" or "\x1b[3~", without its prefix
+func (e *lineEditor) escapeSequence() string {
+	if b, err := e.in.ReadByte(); err != nil || (b != '[' && b != 'O') {
+		return ""
+	}
+	var sequence []byte
+	for {
+		b, err := e.in.ReadByte()
+		if err != nil {
+			return ""
+		}
+		sequence = append(sequence, b)
+		if b < '0' || b > '9' {
+			return string(sequence)
+		}
+	}
+}
+
+func (e *lineEditor) readPlainLine(prompt string) (string, error) {
+	_, _ = io.WriteString(e.out, prompt)
+	line, err := e.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
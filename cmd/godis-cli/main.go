@@ -0,0 +1,274 @@
+// godis-cli is a command line client compatible with redis-cli
+//
+// Usage:
+//
+//	godis-cli [options] [command [arg ...]]
+//
+// Without a command it starts an interactive prompt, with -x the last argument is read from stdin,
+// --pipe sends the commands read from stdin and --scan lists the keys matching --pattern
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"godis/redis/parser"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type config struct {
+	host     string
+	port     int
+	password string
+	resp3    bool
+	raw      bool
+	noRaw    bool
+	stdinArg bool
+	pipe     bool
+	scan     bool
+	pattern  string
+	count    int
+}
+
+func main() {
+	var cfg config
+	flag.StringVar(&cfg.host, "h", "127.0.0.1", "server hostname")
+	flag.IntVar(&cfg.port, "p", 6379, "server port")
+	flag.StringVar(&cfg.password, "a", "", "password used to connect to the server")
+	flag.BoolVar(&cfg.resp3, "3", false, "start the session in RESP3 protocol mode")
+	flag.BoolVar(&cfg.raw, "raw", false, "use raw formatting for replies, the default when stdout is not a tty")
+	flag.BoolVar(&cfg.noRaw, "no-raw", false, "force formatted output even when stdout is not a tty")
+	flag.BoolVar(&cfg.stdinArg, "x", false, "read the last argument from stdin")
+	flag.BoolVar(&cfg.pipe, "pipe", false, "transfer raw Redis protocol from stdin to the server")
+	flag.BoolVar(&cfg.scan, "scan", false, "list all keys using the SCAN command")
+	flag.StringVar(&cfg.pattern, "pattern", "", "keys pattern when using the --scan mode")
+	flag.IntVar(&cfg.count, "count", 0, "count option when using the --scan mode")
+	flag.Parse()
+
+	tty := !cfg.raw && (cfg.noRaw || isTerminal(int(os.Stdout.Fd())))
+	c := &cli{
+		addr:  net.JoinHostPort(cfg.host, strconv.Itoa(cfg.port)),
+		cfg:   &cfg,
+		tty:   tty,
+		out:   bufio.NewWriter(os.Stdout),
+		stdin: bufio.NewReader(os.Stdin),
+	}
+	defer c.out.Flush()
+	if cfg.password != "" {
+		fmt.Fprintln(os.Stderr, "Warning: Using a password with '-a' option on the command line interface may not be safe.")
+	}
+
+	args := flag.Args()
+	if cfg.stdinArg {
+		last, err := io.ReadAll(c.stdin)
+		if err != nil {
+			fatal(err)
+		}
+		args = append(args, string(last))
+	}
+
+	switch {
+	case cfg.pipe:
+		c.mustConnect()
+		fatalIf(c.pipe(c.stdin, os.Stderr))
+	case cfg.scan:
+		c.mustConnect()
+		fatalIf(c.scanKeys())
+	case len(args) > 0:
+		c.mustConnect()
+		if err := c.execute(args); err != nil {
+			fatal(err)
+		}
+	default:
+		fatalIf(c.repl())
+	}
+}
+
+func fatalIf(err error) {
+	if err != nil {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
+
+// cli is a connection to the server, dialed again after a failure
+type cli struct {
+	addr  string
+	cfg   *config
+	tty   bool
+	out   *bufio.Writer
+	stdin *bufio.Reader
+
+	conn    net.Conn
+	writer  *bufio.Writer
+	replies *parser.ReplyReader
+}
+
+// connect dials the server and sends HELLO if RESP3 or a password was asked for
+func (c *cli) connect() error {
+	conn, err := net.DialTimeout("tcp", c.addr, 5*time.Second)
+	if err != nil {
+		return fmt.Errorf("Could not connect to Redis at %s: %v", c.addr, err)
+	}
+	c.useConn(conn)
+	if !c.cfg.resp3 && c.cfg.password == "" {
+		return nil
+	}
+	hello := []string{"HELLO", "2"}
+	if c.cfg.resp3 {
+		hello[1] = "3"
+	}
+	if c.cfg.password != "" {
+		hello = append(hello, "AUTH", "default", c.cfg.password)
+	}
+	reply, err := c.command(hello)
+	if err == nil {
+		if replyErr, ok := reply.(parser.ReplyError); ok {
+			err = replyErr
+		}
+	}
+	if err != nil {
+		c.disconnect()
+		return fmt.Errorf("HELLO failed: %v", err)
+	}
+	return nil
+}
+
+func (c *cli) useConn(conn net.Conn) {
+	c.conn = conn
+	c.writer = bufio.NewWriter(conn)
+	c.replies = parser.NewReplyReader(conn)
+	c.replies.SimpleStrings = true
+}
+
+func (c *cli) mustConnect() {
+	if err := c.connect(); err != nil {
+		fatal(err)
+	}
+}
+
+func (c *cli) disconnect() {
+	if c.conn != nil {
+		_ = c.conn.Close()
+		c.conn = nil
+	}
+}
+
+// send buffers a command, encoded as an array of bulk strings
+func (c *cli) send(args []string) {
+	c.writer.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		c.writer.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n")
+		c.writer.WriteString(arg)
+		c.writer.WriteString("\r\n")
+	}
+}
+
+// command sends a command and reads its reply
+func (c *cli) command(args []string) (any, error) {
+	c.send(args)
+	if err := c.writer.Flush(); err != nil {
+		return nil, err
+	}
+	return c.replies.Next()
+}
+
+// execute sends a command and prints its reply
+// after a subscription or MONITOR, the messages are printed until the connection is closed
+func (c *cli) execute(args []string) error {
+	reply, err := c.command(args)
+	if err != nil {
+		return err
+	}
+	c.print(reply)
+	switch strings.ToLower(args[0]) {
+	case "subscribe", "psubscribe", "ssubscribe":
+		if _, ok := reply.(parser.ReplyError); ok {
+			return nil
+		}
+		// one confirmation per channel, the first one was read already
+		for i := 2; i < len(args); i++ {
+			if reply, err = c.replies.Next(); err != nil {
+				return err
+			}
+			c.print(reply)
+		}
+		if c.tty {
+			c.out.WriteString("Reading messages... (press Ctrl-C to quit)\n")
+		}
+		c.out.Flush()
+		for {
+			if reply, err = c.replies.Next(); err != nil {
+				return err
+			}
+			c.print(reply)
+		}
+	case "monitor":
+		if _, ok := reply.(parser.ReplyError); ok {
+			return nil
+		}
+		for {
+			if reply, err = c.replies.Next(); err != nil {
+				return err
+			}
+			c.print(reply)
+		}
+	}
+	return nil
+}
+
+func (c *cli) print(reply any) {
+	if c.tty {
+		c.out.WriteString(formatTTY(reply, ""))
+	} else {
+		c.out.WriteString(formatRaw(reply))
+		c.out.WriteByte('\n')
+	}
+	c.out.Flush()
+}
+
+// scanKeys prints the keys returned by SCAN, one per line
+func (c *cli) scanKeys() error {
+	cursor := "0"
+	for {
+		args := []string{"SCAN", cursor}
+		if c.cfg.pattern != "" {
+			args = append(args, "MATCH", c.cfg.pattern)
+		}
+		if c.cfg.count > 0 {
+			args = append(args, "COUNT", strconv.Itoa(c.cfg.count))
+		}
+		reply, err := c.command(args)
+		if err != nil {
+			return err
+		}
+		if replyErr, ok := reply.(parser.ReplyError); ok {
+			return replyErr
+		}
+		page, ok := reply.([]any)
+		if !ok || len(page) != 2 {
+			return fmt.Errorf("unexpected SCAN reply %v", reply)
+		}
+		next, ok := page[0].(string)
+		keys, isArray := page[1].([]any)
+		if !ok || !isArray {
+			return fmt.Errorf("unexpected SCAN reply %v", reply)
+		}
+		for _, key := range keys {
+			c.out.WriteString(formatRaw(key))
+			c.out.WriteByte('\n')
+		}
+		if cursor = next; cursor == "0" {
+			return c.out.Flush()
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"godis/redis/parser"
+	"io"
+	"strconv"
+)
+
+// pipe sends the commands read from in, in the protocol or inline, without waiting for their replies
+// the replies are counted until the one of a final PING, sent after every command
+func (c *cli) pipe(in io.Reader, report io.Writer) error {
+	commands := parser.NewParser(in)
+	sent := make(chan int, 1)
+	writeErr := make(chan error, 1)
+	go func() {
+		n := 0
+		for {
+			args, err := commands.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				writeErr <- err
+				// the connection is closed to stop waiting for replies
+				_ = c.conn.Close()
+				return
+			}
+			c.writer.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+			for _, arg := range args {
+				c.writer.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n")
+				c.writer.Write(arg)
+				c.writer.WriteString("\r\n")
+			}
+			n++
+		}
+		c.send([]string{"PING"})
+		if err := c.writer.Flush(); err != nil {
+			writeErr <- err
+			_ = c.conn.Close()
+			return
+		}
+		fmt.Fprintln(report, "All data transferred. Waiting for the last reply...")
+		sent <- n + 1
+	}()
+
+	errorCount, replies, total := 0, 0, -1
+	for {
+		if total < 0 {
+			select {
+			case total = <-sent:
+			default:
+			}
+		}
+		if replies == total {
+			break
+		}
+		reply, err := c.replies.Next()
+		if err != nil {
+			select {
+			case err = <-writeErr:
+			default:
+			}
+			return err
+		}
+		replies++
+		if replyErr, ok := reply.(parser.ReplyError); ok {
+			errorCount++
+			fmt.Fprintln(report, string(replyErr))
+		}
+	}
+	fmt.Fprintln(report, "Last reply received from server.")
+	// the PING isn't counted
+	fmt.Fprintf(report, "errors: %d, replies: %d\n", errorCount, replies-1)
+	if errorCount > 0 {
+		return errors.New("some commands failed")
+	}
+	return nil
+}
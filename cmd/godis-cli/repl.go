@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"godis/redis/parser"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// historyFile returns the file keeping the history, GODISCLI_HISTFILE overrides ~/.godiscli_history
+// the history isn't kept when it's empty or /dev/null
+func historyFile() string {
+	if path, ok := os.LookupEnv("GODISCLI_HISTFILE"); ok {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".godiscli_history")
+}
+
+func loadHistory(path string) []string {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	var history []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			history = append(history, line)
+		}
+	}
+	return history
+}
+
+func (c *cli) prompt() string {
+	if c.conn == nil {
+		return "not connected> "
+	}
+	return c.addr + "> "
+}
+
+// repl reads commands until quit, exit, Ctrl-C or Ctrl-D
+// the connection is dialed again before the next command once it's lost
+func (c *cli) repl() error {
+	editor := &lineEditor{in: c.stdin, out: os.Stdout, fd: int(os.Stdin.Fd())}
+	path := historyFile()
+	var history *os.File
+	if path != "" && path != os.DevNull {
+		editor.history = loadHistory(path)
+		if len(editor.history) > maxHistory {
+			editor.history = editor.history[len(editor.history)-maxHistory:]
+		}
+		history, _ = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	}
+	if history != nil {
+		defer history.Close()
+	}
+
+	if err := c.connect(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	defer c.disconnect()
+	for {
+		line, err := editor.readLine(c.prompt())
+		if err == io.EOF || err == errInterrupted {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		args, err := parser.SplitArgs(line)
+		if err != nil {
+			fmt.Println("Invalid argument(s)")
+			continue
+		}
+		if len(args) == 0 {
+			continue
+		}
+		editor.addHistory(line)
+		if history != nil {
+			_, _ = history.WriteString(line + "\n")
+		}
+
+		switch strings.ToLower(args[0]) {
+		case "quit", "exit":
+			return nil
+		case "clear":
+			fmt.Print("\x1b[H\x1b[2J")
+			continue
+		}
+		if c.conn == nil {
+			if err := c.connect(); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				continue
+			}
+		}
+		if err := c.execute(args); err != nil {
+			c.disconnect()
+			if err == io.EOF {
+				fmt.Fprintln(os.Stderr, "Error: Server closed the connection")
+			} else {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+			}
+		}
+	}
+}
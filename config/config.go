@@ -0,0 +1,187 @@
+// Package config loads the properties of the server from a redis.conf like file:
+// one directive per line, its name followed by its arguments, quoted like at the redis-cli prompt,
+// and comments starting with #
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"godis/redis/parser"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ServerProperties holds a value for every parameter of the registry
+// properties in use are never modified, CONFIG SET replaces them with a modified copy
+type ServerProperties struct {
+	// addresses listened on, those prefixed with "-" are skipped if they aren't available
+	Bind []string
+	Port int
+	// godis has a single keyspace, the number of databases is only checked
+	Databases int
+	// with AppendOnly set when the server starts, AppendFilename is loaded then the write commands are appended to it,
+	// AppendFsync tells when it's flushed to the disk: after every write ("always"), every second ("everysec"),
+	// or when the system does it ("no")
+	AppendOnly     bool
+	AppendFsync    string
+	AppendFilename string
+	// bytes of memory used for data, 0 for no limit, keys aren't evicted yet
+	MaxMemory int64
+	// password of the default user, clients must AUTH when it's set
+	RequirePass string
+	// working directory, where the append only file is kept
+	Dir string
+	// limits of the requests of clients
+	ProtoMaxBulkLen        int64
+	ClientQueryBufferLimit int64
+	// classes of keyspace events published, like "Kg$"
+	NotifyKeyspaceEvents string
+	// number of shards of the keyspace, rounded up to a power of two of at least 16
+	Shards int
+	// seconds waited for the replies being sent when a connection is closed
+	ClientCloseTimeout int
+	// seconds after which an idle client is disconnected, 0 to never disconnect them
+	Timeout int
+	// seconds between the TCP keepalive probes of the connections accepted, 0 to send none
+	TCPKeepAlive int
+	// number of clients connected at most, the next ones are refused
+	MaxClients int
+	// microseconds a command runs for to be recorded in the slow log, negative to record none,
+	// and number of commands kept
+	SlowlogLogSlowerThan int
+	SlowlogMaxLen        int
+	// milliseconds an operation takes to be recorded by the latency monitor, 0 to record none
+	LatencyMonitorThreshold int
+	// address of the HTTP listener serving /metrics, empty for none
+	MetricsAddr string
+
+	// path of the file the properties were loaded from, empty if none
+	ConfigFile string
+}
+
+// Properties are the properties the server was started with
+var Properties = Defaults()
+
+// Defaults returns the properties of a server started without a config file
+func Defaults() *ServerProperties {
+	p := &ServerProperties{}
+	for _, param := range registry {
+		if msg := param.Set(p, param.Default); msg != "" {
+			panic("invalid default of " + param.Name + ": " + msg)
+		}
+	}
+	return p
+}
+
+// Clone returns a copy of the properties that can be modified
+func (p *ServerProperties) Clone() *ServerProperties {
+	clone := *p
+	clone.Bind = append([]string(nil), p.Bind...)
+	return &clone
+}
+
+// Addrs returns the addresses to listen on, "*" standing for every IPv4 address and "::*" for every IPv6 one
+func (p *ServerProperties) Addrs() []string {
+	port := strconv.Itoa(p.Port)
+	addrs := make([]string, len(p.Bind))
+	for i, host := range p.Bind {
+		optional := strings.HasPrefix(host, "-")
+		host = strings.TrimPrefix(host, "-")
+		switch host {
+		case "*":
+			host = "0.0.0.0"
+		case "::*":
+			host = "::"
+		}
+		addrs[i] = net.JoinHostPort(host, port)
+		if optional {
+			addrs[i] = "-" + addrs[i]
+		}
+	}
+	return addrs
+}
+
+// LoadError tells the directive that couldn't be loaded
+type LoadError struct {
+	// number of the line in the config file, from 1, 0 for the command line
+	Line int
+	Text string
+	Msg  string
+}
+
+func (e *LoadError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("from the command line\n>>> '%s'\n%s", e.Text, e.Msg)
+	}
+	return fmt.Sprintf("at line %d\n>>> '%s'\n%s", e.Line, e.Text, e.Msg)
+}
+
+// Load applies the directives read from r on top of the properties, stopping at the first invalid one
+func (p *ServerProperties) Load(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		args, err := parser.SplitArgs(line)
+		if err != nil {
+			return &LoadError{Line: n, Text: line, Msg: "Unbalanced quotes in configuration line"}
+		}
+		if msg := p.apply(strings.ToLower(args[0]), args[1:]); msg != "" {
+			return &LoadError{Line: n, Text: line, Msg: msg}
+		}
+	}
+	return scanner.Err()
+}
+
+// apply sets a parameter from the arguments of a directive, only lists take several arguments
+func (p *ServerProperties) apply(name string, args []string) string {
+	param := Lookup(name)
+	if param == nil || len(args) == 0 || (len(args) > 1 && param.Type != TypeList) {
+		return "Bad directive or wrong number of arguments"
+	}
+	return param.Set(p, strings.Join(args, " "))
+}
+
+// LoadArgs applies directives given on the command line, like `--port 7000 --bind 127.0.0.1 ::1`
+// each directive starts with "--", the arguments following it up to the next directive are its own
+func (p *ServerProperties) LoadArgs(args []string) error {
+	for i := 0; i < len(args); {
+		if !strings.HasPrefix(args[i], "--") || len(args[i]) == 2 {
+			return &LoadError{Text: args[i], Msg: "Directives must start with --"}
+		}
+		end := i + 1
+		for end < len(args) && !strings.HasPrefix(args[end], "--") {
+			end++
+		}
+		if msg := p.apply(strings.ToLower(args[i][2:]), args[i+1:end]); msg != "" {
+			return &LoadError{Text: strings.Join(args[i:end], " "), Msg: msg}
+		}
+		i = end
+	}
+	return nil
+}
+
+// LoadFile loads a config file, "-" for the standard input
+func (p *ServerProperties) LoadFile(path string) error {
+	if path == "-" {
+		return p.Load(os.Stdin)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := p.Load(file); err != nil {
+		return err
+	}
+	// kept absolute for CONFIG REWRITE, as the server changes to dir
+	p.ConfigFile, err = filepath.Abs(path)
+	return err
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"errors"
+	"godis/redis/parser"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	p := Defaults()
+	err := p.Load(strings.NewReader(`# a comment
+bind 127.0.0.1 -::1
+PORT 7000
+
+databases 4
+appendonly yes
+appendfsync always
+maxmemory 2mb
+requirepass "a \"quoted\" password"
+proto-max-bulk-len 1gb
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.LoadArgs([]string{"--port", "7001", "--bind", "::1"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := Defaults()
+	expected.Bind = []string{"::1"}
+	expected.Port = 7001
+	expected.Databases = 4
+	expected.AppendOnly = true
+	expected.AppendFsync = "always"
+	expected.MaxMemory = 2 * 1024 * 1024
+	expected.RequirePass = `a "quoted" password`
+	expected.ProtoMaxBulkLen = 1024 * 1024 * 1024
+	if !reflect.DeepEqual(p, expected) {
+		t.Errorf("expected %+v, got %+v", expected, p)
+	}
+	if addrs := p.Addrs(); !reflect.DeepEqual(addrs, []string{"[::1]:7001"}) {
+		t.Errorf("unexpected addresses %v", addrs)
+	}
+	if addrs := Defaults().Addrs(); !reflect.DeepEqual(addrs, []string{"0.0.0.0:6379", "-[::]:6379"}) {
+		t.Errorf("unexpected default addresses %v", addrs)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		msg   string
+	}{
+		{"port 65536", "argument must be between 0 and 65535 inclusive"},
+		{"port", "Bad directive or wrong number of arguments"},
+		{"no-such-directive yes", "Bad directive or wrong number of arguments"},
+		{"appendonly maybe", "argument must be 'yes' or 'no'"},
+		{"appendfsync sometimes", "argument(s) must be one of the following: always, everysec, no"},
+		{"maxmemory lots", "argument must be a memory value"},
+		{"proto-max-bulk-len 1k", "argument must be between 1048576 and 9223372036854775807 inclusive"},
+		{"bind example.com", "Invalid bind address 'example.com'"},
+		{`requirepass "unbalanced`, "Unbalanced quotes in configuration line"},
+	}
+	for _, tt := range tests {
+		err := Defaults().Load(strings.NewReader("# first line\n" + tt.input + "\n"))
+		var loadErr *LoadError
+		if !errors.As(err, &loadErr) {
+			t.Fatalf("%q: expected a LoadError, got %v", tt.input, err)
+		}
+		if loadErr.Line != 2 || loadErr.Text != tt.input || loadErr.Msg != tt.msg {
+			t.Errorf("%q: unexpected error %+v", tt.input, loadErr)
+		}
+	}
+
+	err := Defaults().LoadArgs([]string{"port", "7000"})
+	if err == nil || err.(*LoadError).Msg != "Directives must start with --" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	p := Defaults()
+	if p.ProtoMaxBulkLen != parser.DefaultMaxBulkLen || p.ClientQueryBufferLimit != parser.DefaultMaxQueryBufferLen {
+		t.Errorf("the limits don't match the ones of the parser: %+v", p)
+	}
+	for _, param := range Params() {
+		if Lookup(param.Name) != param {
+			t.Errorf("%s: duplicate parameter", param.Name)
+		}
+		if got := param.Get(p); got != param.Default && param.Type != TypeMemory {
+			t.Errorf("%s: expected the default %q, got %q", param.Name, param.Default, got)
+		}
+	}
+}
+
+func TestKeyspaceEvents(t *testing.T) {
+	param := Lookup("notify-keyspace-events")
+	p := Defaults()
+	for value, expected := range map[string]string{"": "", "Elg": "glE", "KA": "AK", "g$lshzxetE": "AE", "A$": "A"} {
+		if msg := param.Set(p, value); msg != "" || p.NotifyKeyspaceEvents != expected {
+			t.Errorf("%q: expected %q, got %q %s", value, expected, p.NotifyKeyspaceEvents, msg)
+		}
+	}
+	if msg := param.Set(p, "Kx!"); msg != "Invalid event class character. Use 'Ag$lshzxeKEt'." {
+		t.Errorf("unexpected message %q", msg)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "redis.conf")
+	original := `# the port
+port 7000
+# kept as it is
+unknown-directive value
+port 7001
+
+maxmemory 1mb
+# Generated by CONFIG REWRITE
+appendonly yes
+`
+	if err := os.WriteFile(path, []byte(original), 0600); err != nil {
+		t.Fatal(err)
+	}
+	p := Defaults()
+	p.ConfigFile = path
+	p.Port = 7002
+	p.MaxMemory = 0
+	p.RequirePass = `with "quotes" and spaces`
+	p.Bind = []string{"127.0.0.1", "-::1"}
+	p.ProtoMaxBulkLen = 2 * 1024 * 1024
+	if err := p.Rewrite(); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `# the port
+port 7002
+# kept as it is
+unknown-directive value
+
+maxmemory 0
+appendonly no
+# Generated by CONFIG REWRITE
+bind 127.0.0.1 -::1
+requirepass "with \"quotes\" and spaces"
+proto-max-bulk-len 2mb
+`
+	if string(content) != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, content)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("the mode of the file wasn't kept: %v, %v", info.Mode(), err)
+	}
+
+	// the rewritten file loads the same properties, but for the unknown directive
+	loaded := Defaults()
+	loaded.ConfigFile = path
+	if err := loaded.Load(strings.NewReader(strings.Replace(string(content), "unknown-directive value\n", "", 1))); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, p) {
+		t.Errorf("expected %+v, got %+v", p, loaded)
+	}
+
+	if err := Defaults().Rewrite(); err != ErrNoConfigFile {
+		t.Errorf("expected ErrNoConfigFile, got %v", err)
+	}
+}
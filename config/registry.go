@@ -0,0 +1,332 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type ParamType int
+
+const (
+	TypeBool ParamType = iota
+	TypeInt
+	// bytes, set with an optional unit like 512mb
+	TypeMemory
+	// one of Values
+	TypeEnum
+	TypeString
+	// words separated by spaces, given as several arguments in a config file
+	TypeList
+)
+
+// Param is a parameter of the server, set by the config file, the command line or CONFIG SET
+type Param struct {
+	Name    string
+	Type    ParamType
+	Default string
+	// only mutable parameters may be changed by CONFIG SET
+	Mutable bool
+	// bounds of TypeInt and TypeMemory parameters
+	Min, Max int64
+	// values of TypeEnum parameters
+	Values []string
+	// validate checks a TypeString or TypeList value and returns it normalized, or an error message
+	validate func(value string) (string, string)
+	// field returns the field holding the value, a *bool, *int, *int64, *string or *[]string
+	field func(p *ServerProperties) any
+}
+
+var registry = []*Param{
+	{
+		Name: "bind", Type: TypeList, Default: "* -::*",
+		validate: validateBind,
+		field:    func(p *ServerProperties) any { return &p.Bind },
+	},
+	{
+		Name: "port", Type: TypeInt, Default: "6379", Min: 0, Max: 65535,
+		field: func(p *ServerProperties) any { return &p.Port },
+	},
+	{
+		Name: "databases", Type: TypeInt, Default: "16", Min: 1, Max: math.MaxInt32,
+		field: func(p *ServerProperties) any { return &p.Databases },
+	},
+	{
+		// enabling it at runtime would need a rewrite of the dataset into the file
+		Name: "appendonly", Type: TypeBool, Default: "no",
+		field: func(p *ServerProperties) any { return &p.AppendOnly },
+	},
+	{
+		Name: "appendfsync", Type: TypeEnum, Default: "everysec", Mutable: true,
+		Values: []string{"always", "everysec", "no"},
+		field:  func(p *ServerProperties) any { return &p.AppendFsync },
+	},
+	{
+		Name: "appendfilename", Type: TypeString, Default: "appendonly.aof",
+		validate: func(value string) (string, string) {
+			if value == "" || strings.ContainsAny(value, `/\`) {
+				return "", "appendfilename can't be a path, just a filename"
+			}
+			return value, ""
+		},
+		field: func(p *ServerProperties) any { return &p.AppendFilename },
+	},
+	{
+		Name: "maxmemory", Type: TypeMemory, Default: "0", Mutable: true, Min: 0, Max: math.MaxInt64,
+		field: func(p *ServerProperties) any { return &p.MaxMemory },
+	},
+	{
+		Name: "requirepass", Type: TypeString, Default: "", Mutable: true,
+		field: func(p *ServerProperties) any { return &p.RequirePass },
+	},
+	{
+		Name: "dir", Type: TypeString, Default: ".",
+		validate: func(value string) (string, string) {
+			info, err := os.Stat(value)
+			if err != nil {
+				return "", err.Error()
+			}
+			if !info.IsDir() {
+				return "", value + " is not a directory"
+			}
+			return value, ""
+		},
+		field: func(p *ServerProperties) any { return &p.Dir },
+	},
+	{
+		Name: "proto-max-bulk-len", Type: TypeMemory, Default: "512mb", Mutable: true, Min: 1024 * 1024, Max: math.MaxInt64,
+		field: func(p *ServerProperties) any { return &p.ProtoMaxBulkLen },
+	},
+	{
+		Name: "client-query-buffer-limit", Type: TypeMemory, Default: "1gb", Mutable: true, Min: 1024 * 1024, Max: math.MaxInt64,
+		field: func(p *ServerProperties) any { return &p.ClientQueryBufferLimit },
+	},
+	{
+		Name: "notify-keyspace-events", Type: TypeString, Default: "", Mutable: true,
+		validate: normalizeKeyspaceEvents,
+		field:    func(p *ServerProperties) any { return &p.NotifyKeyspaceEvents },
+	},
+	{
+		Name: "shards", Type: TypeInt, Default: "16", Min: 1, Max: 1 << 24,
+		field: func(p *ServerProperties) any { return &p.Shards },
+	},
+	{
+		Name: "client-close-timeout", Type: TypeInt, Default: "10", Mutable: true, Min: 0, Max: math.MaxInt32,
+		field: func(p *ServerProperties) any { return &p.ClientCloseTimeout },
+	},
+	{
+		Name: "timeout", Type: TypeInt, Default: "0", Mutable: true, Min: 0, Max: math.MaxInt32,
+		field: func(p *ServerProperties) any { return &p.Timeout },
+	},
+	{
+		Name: "tcp-keepalive", Type: TypeInt, Default: "300", Mutable: true, Min: 0, Max: math.MaxInt32,
+		field: func(p *ServerProperties) any { return &p.TCPKeepAlive },
+	},
+	{
+		Name: "maxclients", Type: TypeInt, Default: "10000", Mutable: true, Min: 1, Max: math.MaxInt32,
+		field: func(p *ServerProperties) any { return &p.MaxClients },
+	},
+	{
+		Name: "slowlog-log-slower-than", Type: TypeInt, Default: "10000", Mutable: true, Min: -1, Max: math.MaxInt64,
+		field: func(p *ServerProperties) any { return &p.SlowlogLogSlowerThan },
+	},
+	{
+		Name: "slowlog-max-len", Type: TypeInt, Default: "128", Mutable: true, Min: 0, Max: math.MaxInt32,
+		field: func(p *ServerProperties) any { return &p.SlowlogMaxLen },
+	},
+	{
+		Name: "latency-monitor-threshold", Type: TypeInt, Default: "0", Mutable: true, Min: 0, Max: math.MaxInt64,
+		field: func(p *ServerProperties) any { return &p.LatencyMonitorThreshold },
+	},
+	{
+		Name: "metrics-addr", Type: TypeString, Default: "",
+		validate: func(value string) (string, string) {
+			if value == "" {
+				return value, ""
+			}
+			if _, _, err := net.SplitHostPort(value); err != nil {
+				return "", "Invalid metrics address '" + value + "'"
+			}
+			return value, ""
+		},
+		field: func(p *ServerProperties) any { return &p.MetricsAddr },
+	},
+}
+
+// Params returns every parameter of the registry
+func Params() []*Param {
+	return registry
+}
+
+// Lookup returns the parameter named name, nil if there is none
+func Lookup(name string) *Param {
+	for _, param := range registry {
+		if param.Name == name {
+			return param
+		}
+	}
+	return nil
+}
+
+// Get returns the value of the parameter in p, as reported by CONFIG GET
+func (param *Param) Get(p *ServerProperties) string {
+	switch field := param.field(p).(type) {
+	case *bool:
+		if *field {
+			return "yes"
+		}
+		return "no"
+	case *int:
+		return strconv.Itoa(*field)
+	case *int64:
+		return strconv.FormatInt(*field, 10)
+	case *string:
+		return *field
+	case *[]string:
+		return strings.Join(*field, " ")
+	}
+	panic("unexpected field type of " + param.Name)
+}
+
+// Set checks value and sets the parameter in p, it returns an error message if value is invalid
+func (param *Param) Set(p *ServerProperties, value string) string {
+	switch param.Type {
+	case TypeBool:
+		switch strings.ToLower(value) {
+		case "yes":
+			*param.field(p).(*bool) = true
+		case "no":
+			*param.field(p).(*bool) = false
+		default:
+			return "argument must be 'yes' or 'no'"
+		}
+	case TypeInt:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "argument couldn't be parsed into an integer"
+		}
+		if msg := param.checkBounds(n); msg != "" {
+			return msg
+		}
+		*param.field(p).(*int) = int(n)
+	case TypeMemory:
+		n, ok := ParseMemory(value)
+		if !ok {
+			return "argument must be a memory value"
+		}
+		if msg := param.checkBounds(n); msg != "" {
+			return msg
+		}
+		*param.field(p).(*int64) = n
+	case TypeEnum:
+		value = strings.ToLower(value)
+		for _, v := range param.Values {
+			if v == value {
+				*param.field(p).(*string) = value
+				return ""
+			}
+		}
+		return "argument(s) must be one of the following: " + strings.Join(param.Values, ", ")
+	case TypeString, TypeList:
+		if param.validate != nil {
+			var msg string
+			if value, msg = param.validate(value); msg != "" {
+				return msg
+			}
+		}
+		if param.Type == TypeString {
+			*param.field(p).(*string) = value
+		} else {
+			*param.field(p).(*[]string) = strings.Fields(value)
+		}
+	}
+	return ""
+}
+
+func (param *Param) checkBounds(n int64) string {
+	if n < param.Min || n > param.Max {
+		return fmt.Sprintf("argument must be between %d and %d inclusive", param.Min, param.Max)
+	}
+	return ""
+}
+
+func validateBind(value string) (string, string) {
+	hosts := strings.Fields(value)
+	if len(hosts) == 0 {
+		return "", "at least one bind address is required"
+	}
+	for _, host := range hosts {
+		host = strings.TrimPrefix(host, "-")
+		if host != "*" && host != "::*" && host != "localhost" && net.ParseIP(host) == nil {
+			return "", "Invalid bind address '" + host + "'"
+		}
+	}
+	return value, ""
+}
+
+// keyspace event classes, A standing for every class but K and E
+const (
+	allEventClasses = "g$lshzxet"
+	eventChannels   = "KE"
+)
+
+// normalizeKeyspaceEvents checks the classes of notify-keyspace-events
+// and returns them in a canonical order, like CONFIG GET reports them
+func normalizeKeyspaceEvents(value string) (string, string) {
+	enabled := make(map[byte]bool)
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == 'A':
+			for j := 0; j < len(allEventClasses); j++ {
+				enabled[allEventClasses[j]] = true
+			}
+		case strings.IndexByte(allEventClasses, c) >= 0, strings.IndexByte(eventChannels, c) >= 0:
+			enabled[c] = true
+		default:
+			return "", "Invalid event class character. Use 'Ag$lshzxeKEt'."
+		}
+	}
+	var sb strings.Builder
+	all := true
+	for i := 0; i < len(allEventClasses); i++ {
+		all = all && enabled[allEventClasses[i]]
+	}
+	if all {
+		sb.WriteByte('A')
+	}
+	for _, c := range []byte(allEventClasses + eventChannels) {
+		if enabled[c] && !(all && strings.IndexByte(allEventClasses, c) >= 0) {
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String(), ""
+}
+
+// ParseMemory parses bytes with an optional unit, k is 1000 bytes and kb 1024 bytes, like in redis.conf
+func ParseMemory(value string) (int64, bool) {
+	units := []struct {
+		suffix string
+		size   int64
+	}{
+		{"kb", 1024}, {"mb", 1024 * 1024}, {"gb", 1024 * 1024 * 1024},
+		{"k", 1000}, {"m", 1000 * 1000}, {"g", 1000 * 1000 * 1000},
+	}
+	value = strings.ToLower(value)
+	size := int64(1)
+	for _, unit := range units {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSuffix(value, unit.suffix)
+			size = unit.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 || n > math.MaxInt64/size {
+		return 0, false
+	}
+	return n * size, true
+}
@@ -0,0 +1,162 @@
+package config
+
+import (
+	"errors"
+	"godis/redis/parser"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ErrNoConfigFile is returned by Rewrite when the properties weren't loaded from a file
+var ErrNoConfigFile = errors.New("The server is running without a config file")
+
+// rewriteSignature comes before the directives appended by Rewrite
+const rewriteSignature = "# Generated by CONFIG REWRITE"
+
+// Rewrite updates the config file with the values of p
+// comments, blank lines and unknown directives are kept, the directives of the parameters are updated in place,
+// extra occurrences dropped, and the parameters missing from the file appended unless they have their default value
+// the file is replaced atomically
+func (p *ServerProperties) Rewrite() error {
+	if p.ConfigFile == "" {
+		return ErrNoConfigFile
+	}
+	content, err := os.ReadFile(p.ConfigFile)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	var out []string
+	written := make(map[string]bool)
+	if len(content) > 0 {
+		for _, line := range strings.Split(strings.TrimSuffix(string(content), "\n"), "\n") {
+			trimmed := strings.TrimSpace(line)
+			if trimmed == rewriteSignature {
+				continue
+			}
+			var param *Param
+			if trimmed != "" && trimmed[0] != '#' {
+				if args, err := parser.SplitArgs(trimmed); err == nil {
+					param = Lookup(strings.ToLower(args[0]))
+				}
+			}
+			if param == nil {
+				out = append(out, line)
+				continue
+			}
+			if !written[param.Name] {
+				written[param.Name] = true
+				out = append(out, param.directive(p))
+			}
+		}
+	}
+
+	defaults := Defaults()
+	appended := false
+	for _, param := range registry {
+		if written[param.Name] || param.Get(p) == param.Get(defaults) {
+			continue
+		}
+		if !appended {
+			out = append(out, rewriteSignature)
+			appended = true
+		}
+		out = append(out, param.directive(p))
+	}
+	return writeFileAtomic(p.ConfigFile, []byte(strings.Join(out, "\n")+"\n"))
+}
+
+// directive formats the line setting the parameter to its value in p
+func (param *Param) directive(p *ServerProperties) string {
+	var args []string
+	switch param.Type {
+	case TypeList:
+		for _, word := range *param.field(p).(*[]string) {
+			args = append(args, quoteArg(word))
+		}
+	case TypeMemory:
+		args = []string{formatMemory(*param.field(p).(*int64))}
+	default:
+		args = []string{quoteArg(param.Get(p))}
+	}
+	return param.Name + " " + strings.Join(args, " ")
+}
+
+// formatMemory formats bytes with the largest unit dividing them
+func formatMemory(n int64) string {
+	switch {
+	case n == 0:
+		return "0"
+	case n%(1024*1024*1024) == 0:
+		return strconv.FormatInt(n/(1024*1024*1024), 10) + "gb"
+	case n%(1024*1024) == 0:
+		return strconv.FormatInt(n/(1024*1024), 10) + "mb"
+	case n%1024 == 0:
+		return strconv.FormatInt(n/1024, 10) + "kb"
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+// quoteArg quotes s if it can't be read back as a single argument as it is
+func quoteArg(s string) string {
+	if s != "" && !strings.ContainsFunc(s, func(r rune) bool {
+		return r <= ' ' || r == '"' || r == '\'' || r == '\\' || r == 0x7f
+	}) {
+		return s
+	}
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '\\', '"':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		default:
+			if c < ' ' || c == 0x7f {
+				sb.WriteString(`\x` + strconv.FormatUint(uint64(c>>4), 16) + strconv.FormatUint(uint64(c&0xf), 16))
+			} else {
+				sb.WriteByte(c)
+			}
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// writeFileAtomic writes a temporary file next to path and renames it, keeping the mode of path
+func writeFileAtomic(path string, content []byte) error {
+	mode := fs.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".godis-rewrite-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
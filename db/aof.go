@@ -0,0 +1,124 @@
+package db
+
+import (
+	"fmt"
+	"godis/aof"
+	"godis/redis/protocol"
+	"log"
+	"strings"
+	"time"
+)
+
+// openAOF loads the append only file, then appends the write commands to it
+// it's called before serving clients, r.aof doesn't change afterwards
+func (r *Redis) openAOF(filename string) error {
+	start := time.Now()
+	err := aof.Load(filename, func(args [][]byte) error {
+		cmd, ok := CommandMap[strings.ToLower(string(args[0]))]
+		if !ok {
+			return fmt.Errorf("unknown command '%s' reading the append only file", args[0])
+		}
+		// like Redis, the error replies of the commands loaded are ignored
+		cmd.executor(r, args[1:])
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	persister, err := aof.Open(filename)
+	if err != nil {
+		return err
+	}
+	r.aof = persister
+	r.aofDone = make(chan struct{})
+	go r.syncAOF()
+	log.Printf("DB loaded from append only file: %.3f seconds", time.Since(start).Seconds())
+	return nil
+}
+
+// syncAOF flushes the append only file to the disk every second with appendfsync everysec
+func (r *Redis) syncAOF() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.aofDone:
+			return
+		case <-ticker.C:
+			if r.Config().AppendFsync != "everysec" {
+				continue
+			}
+			if err := r.aof.Sync(); err != nil {
+				log.Printf("fsync of the append only file failed: %v", err)
+			}
+		}
+	}
+}
+
+// closeAOF flushes the append only file and stops appending to it
+func (r *Redis) closeAOF() {
+	if r.aof == nil {
+		return
+	}
+	close(r.aofDone)
+	r.aofMu.Lock()
+	defer r.aofMu.Unlock()
+	if err := r.aof.Close(); err != nil {
+		log.Printf("closing the append only file failed: %v", err)
+	}
+}
+
+// execPersisted runs a write command and appends it to the append only file, or what it propagated instead
+// the writes are serialized so that the file replays them in the order they were applied,
+// blocking commands let the other writes run while they wait, see blockUntil
+func (r *Redis) execPersisted(name string, exec Exec, args [][]byte, write bool) protocol.Reply {
+	r.aofMu.Lock()
+	defer r.aofMu.Unlock()
+	r.propagated, r.rewritten = nil, false
+	reply := exec(r, args)
+	if _, failed := reply.(protocol.ErrorReply); failed || !write {
+		return reply
+	}
+
+	cmds := r.propagated
+	if !r.rewritten {
+		cmds = [][][]byte{append([][]byte{[]byte(name)}, args...)}
+	}
+	if len(cmds) == 0 {
+		return reply
+	}
+	start := time.Now()
+	err := r.aof.Write(cmds...)
+	r.monitorEvent("aof-write", start, time.Since(start))
+	if err != nil {
+		log.Printf("writing to the append only file failed: %v", err)
+		return reply
+	}
+	if r.Config().AppendFsync == "always" {
+		start = time.Now()
+		err = r.aof.Sync()
+		r.monitorEvent("aof-fsync-always", start, time.Since(start))
+		if err != nil {
+			log.Printf("fsync of the append only file failed: %v", err)
+		}
+	}
+	return reply
+}
+
+// propagate makes the running write command appended to the append only file as cmds rather than itself,
+// for the commands whose effect depends on when they run, like XADD * or BZPOPMIN
+// without cmds, nothing is appended
+func (r *Redis) propagate(cmds ...[][]byte) {
+	// only execPersisted reads them, under aofMu
+	if !r.persisting() {
+		return
+	}
+	r.propagated = append(r.propagated, cmds...)
+	r.rewritten = true
+}
+
+// persisting reports whether the write commands are appended to the append only file,
+// for the commands to skip building what they propagate otherwise
+func (r *Redis) persisting() bool {
+	return r.aof != nil
+}
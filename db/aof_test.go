@@ -0,0 +1,153 @@
+package db
+
+import (
+	"godis/ds/stream"
+	"godis/lib/utils"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// execPersistedCmd runs a command as the clients do, through CommandMap
+func execPersistedCmd(r *Redis, args ...string) string {
+	cmd := CommandMap[strings.ToLower(args[0])]
+	return string(cmd.executor(r, utils.ToCmdLine(args[1:]...)).ToBytes())
+}
+
+// reopen closes r and loads its append only file into a new database
+func reopen(t *testing.T, r *Redis, filename string) *Redis {
+	t.Helper()
+	r.Close()
+	loaded := NewStandAloneDb()
+	if err := loaded.openAOF(filename); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(loaded.Close)
+	return loaded
+}
+
+func pendingEntries(t *testing.T, r *Redis, key string, group string) []stream.PendingEntry {
+	t.Helper()
+	s, _ := getStream(r, key)
+	if s == nil {
+		t.Fatalf("expected the stream %s", key)
+	}
+	pending, err := s.PendingRange(group, stream.MinID, stream.MaxID, 100, "", 0, time.Now().UnixMilli())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pending
+}
+
+func TestAOFKeepsConsumerGroups(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "appendonly.aof")
+	r := NewStandAloneDb()
+	if err := r.openAOF(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	execPersistedCmd(r, "XGROUP", "CREATE", "jobs", "workers", "$", "MKSTREAM")
+	for i := 0; i < 4; i++ {
+		execPersistedCmd(r, "XADD", "jobs", "*", "task", "t")
+	}
+	execPersistedCmd(r, "XREADGROUP", "GROUP", "workers", "alice", "COUNT", "3", "STREAMS", "jobs", ">")
+	execPersistedCmd(r, "XREADGROUP", "GROUP", "workers", "bob", "NOACK", "STREAMS", "jobs", ">")
+	first := pendingEntries(t, r, "jobs", "workers")[0].ID.String()
+	execPersistedCmd(r, "XACK", "jobs", "workers", first)
+	time.Sleep(5 * time.Millisecond)
+	execPersistedCmd(r, "XAUTOCLAIM", "jobs", "workers", "carol", "1", "0")
+	execPersistedCmd(r, "XGROUP", "CREATE", "jobs", "auditors", "0")
+	execPersistedCmd(r, "XREADGROUP", "GROUP", "auditors", "dave", "COUNT", "1", "STREAMS", "jobs", ">")
+	expectedPending := map[string][]stream.PendingEntry{
+		"workers":  pendingEntries(t, r, "jobs", "workers"),
+		"auditors": pendingEntries(t, r, "jobs", "auditors"),
+	}
+	expectedEntries := execPersistedCmd(r, "XRANGE", "jobs", "-", "+")
+	expectedGroups := execPersistedCmd(r, "XINFO", "GROUPS", "jobs")
+
+	loaded := reopen(t, r, filename)
+	for group, expected := range expectedPending {
+		if actual := pendingEntries(t, loaded, "jobs", group); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("expected the pending entries of %s %+v, got %+v", group, expected, actual)
+		}
+	}
+	if actual := execPersistedCmd(loaded, "XRANGE", "jobs", "-", "+"); actual != expectedEntries {
+		t.Errorf("expected the entries %q, got %q", expectedEntries, actual)
+	}
+	if actual := execPersistedCmd(loaded, "XINFO", "GROUPS", "jobs"); actual != expectedGroups {
+		t.Errorf("expected the groups %q, got %q", expectedGroups, actual)
+	}
+	// the group goes on delivering after the entries read before the restart
+	execPersistedCmd(loaded, "XADD", "jobs", "*", "task", "t")
+	reply := execPersistedCmd(loaded, "XREADGROUP", "GROUP", "workers", "alice", "STREAMS", "jobs", ">")
+	if strings.Count(reply, "task") != 1 {
+		t.Errorf("expected only the new entry, got %q", reply)
+	}
+}
+
+func TestAOFBlockingCommands(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "appendonly.aof")
+	r := NewStandAloneDb()
+	if err := r.openAOF(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	replyCh := make(chan string)
+	go func() {
+		replyCh <- execPersistedCmd(r, "BZPOPMIN", "z", "0")
+	}()
+	time.Sleep(50 * time.Millisecond)
+	// the blocked client doesn't block the writes
+	execPersistedCmd(r, "ZADD", "z", "1", "a", "2", "b")
+	select {
+	case reply := <-replyCh:
+		if reply != "*3\r\n$1\r\nz\r\n$1\r\na\r\n$1\r\n1\r\n" {
+			t.Errorf("expected a to be popped, got %q", reply)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("BZPOPMIN was not woken up by ZADD")
+	}
+	if reply := execPersistedCmd(r, "BZPOPMAX", "empty", "0.01"); reply != "*-1\r\n" {
+		t.Errorf("expected BZPOPMAX to time out, got %q", reply)
+	}
+
+	// loading the file doesn't block
+	loaded := reopen(t, r, filename)
+	if reply := execPersistedCmd(loaded, "ZRANGE", "z", "0", "-1"); reply != "*1\r\n$1\r\nb\r\n" {
+		t.Errorf("expected only b left, got %q", reply)
+	}
+}
+
+func TestAOFBlockingTimeoutWithConcurrentWrite(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "appendonly.aof")
+	r := NewStandAloneDb()
+	if err := r.openAOF(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	replyCh := make(chan string)
+	go func() {
+		replyCh <- execPersistedCmd(r, "BZPOPMIN", "z", "0.2")
+	}()
+	time.Sleep(50 * time.Millisecond)
+	execPersistedCmd(r, "XADD", "s", "*", "f", "v")
+	if reply := <-replyCh; reply != "*-1\r\n" {
+		t.Fatalf("expected BZPOPMIN to time out, got %q", reply)
+	}
+	r.Close()
+
+	// the timed out command appends nothing, not what the write ran while waiting propagated
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(content), "xadd"); n != 1 {
+		t.Errorf("expected XADD to be appended once, got %d times in %q", n, content)
+	}
+	if strings.Contains(strings.ToLower(string(content)), "zpop") {
+		t.Errorf("expected no pop appended, got %q", content)
+	}
+}
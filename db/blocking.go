@@ -0,0 +1,138 @@
+package db
+
+import (
+	"godis/redis/protocol"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// blockingKeys keeps the clients blocked on keys, e.g. by BZPOPMIN
+// writers call signal() after pushing data into a key to wake them up
+type blockingKeys struct {
+	mu      sync.Mutex
+	waiters map[string]map[chan struct{}]struct{}
+	// number of waiters, i.e. blocked clients
+	blocked int
+}
+
+func newBlockingKeys() *blockingKeys {
+	return &blockingKeys{
+		waiters: make(map[string]map[chan struct{}]struct{}),
+	}
+}
+
+// watch registers a waiter on all the given keys
+func (b *blockingKeys) watch(keys []string) chan struct{} {
+	// buffered so that signal() never blocks on a busy waiter
+	ch := make(chan struct{}, 1)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.blocked++
+	for _, key := range keys {
+		waiters, ok := b.waiters[key]
+		if !ok {
+			waiters = make(map[chan struct{}]struct{})
+			b.waiters[key] = waiters
+		}
+		waiters[ch] = struct{}{}
+	}
+	return ch
+}
+
+func (b *blockingKeys) unwatch(keys []string, ch chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.blocked--
+	for _, key := range keys {
+		waiters, ok := b.waiters[key]
+		if !ok {
+			continue
+		}
+		delete(waiters, ch)
+		if len(waiters) == 0 {
+			delete(b.waiters, key)
+		}
+	}
+}
+
+// clients returns the number of clients blocked
+func (b *blockingKeys) clients() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.blocked
+}
+
+// signal wakes up every client blocked on key
+func (b *blockingKeys) signal(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.waiters[key] {
+		select {
+		case ch <- struct{}{}:
+		default:
+			// already has a pending wake-up
+		}
+	}
+}
+
+// parseBlockingTimeout parses the timeout of blocking commands, in seconds
+// 0 means blocking forever
+func parseBlockingTimeout(arg []byte) (time.Duration, *protocol.StandardErrReply) {
+	seconds, err := strconv.ParseFloat(string(arg), 64)
+	if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return 0, protocol.MakeErrReply("ERR timeout is not a float or out of range")
+	}
+	if seconds < 0 {
+		return 0, protocol.MakeErrReply("ERR timeout is negative")
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// blockUntil calls try until it returns a reply, waiting for one of keys to be signaled in between
+// returns nil if timed out, a zero timeout blocks forever
+func (r *Redis) blockUntil(keys []string, timeout time.Duration, try func() protocol.Reply) protocol.Reply {
+	if reply := try(); reply != nil {
+		return reply
+	}
+
+	ch := r.blocking.watch(keys)
+	defer r.blocking.unwatch(keys, ch)
+
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for {
+		// check again after watching, the key may have been written in between
+		if reply := try(); reply != nil {
+			return reply
+		}
+		// the other writes go on while waiting, see execPersisted,
+		// they overwrite what this command propagated so far
+		var propagated [][][]byte
+		var rewritten bool
+		if r.persisting() {
+			propagated, rewritten = r.propagated, r.rewritten
+			r.aofMu.Unlock()
+		}
+		timedOut := false
+		select {
+		case <-ch:
+		case <-deadline:
+			timedOut = true
+		}
+		if r.persisting() {
+			r.aofMu.Lock()
+			r.propagated, r.rewritten = propagated, rewritten
+		}
+		if timedOut {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,401 @@
+package db
+
+import (
+	"fmt"
+	"godis/interfaces"
+	"godis/redis/protocol"
+	"godis/tracking"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// clientPause suspends the commands of the clients, see CLIENT PAUSE
+type clientPause struct {
+	mu sync.Mutex
+	// closed when the pause ends
+	done chan struct{}
+	// when the pauses of every command and of the writes end, like in Redis they overlap
+	allUntil   time.Time
+	writeUntil time.Time
+	// checked before taking the lock, most of the time nothing is paused
+	active atomic.Bool
+}
+
+// pause suspends every command or only the writes for timeout, a pause of the same kind ending later is kept
+func (p *clientPause) pause(timeout time.Duration, all bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.active.Load() {
+		p.done = make(chan struct{})
+		p.active.Store(true)
+	}
+	until := time.Now().Add(timeout)
+	deadline := &p.writeUntil
+	if all {
+		deadline = &p.allUntil
+	}
+	if until.After(*deadline) {
+		*deadline = until
+	}
+}
+
+func (p *clientPause) unpause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.active.Load() {
+		p.end()
+	}
+}
+
+// end ends the pause, called with the lock held
+func (p *clientPause) end() {
+	close(p.done)
+	p.allUntil, p.writeUntil = time.Time{}, time.Time{}
+	p.active.Store(false)
+}
+
+// wait returns once a command may run, write is whether it's a write command
+func (p *clientPause) wait(write bool) {
+	for p.active.Load() {
+		p.mu.Lock()
+		if !p.active.Load() {
+			p.mu.Unlock()
+			return
+		}
+		now := time.Now()
+		if !now.Before(p.allUntil) && !now.Before(p.writeUntil) {
+			p.end()
+			p.mu.Unlock()
+			return
+		}
+		until := p.allUntil
+		if write && p.writeUntil.After(until) {
+			until = p.writeUntil
+		}
+		remaining := until.Sub(now)
+		if remaining <= 0 {
+			p.mu.Unlock()
+			return
+		}
+		done := p.done
+		p.mu.Unlock()
+
+		// the pause may be extended in the meantime, it's checked again
+		timer := time.NewTimer(remaining)
+		select {
+		case <-done:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// pausedByWrite reports whether the command named cmdName is paused by CLIENT PAUSE WRITE
+func pausedByWrite(cmdName string) bool {
+	if cmdName == "publish" || cmdName == "spublish" {
+		return true
+	}
+	cmd, ok := CommandMap[cmdName]
+	return ok && cmd.flags&flagWrite != 0
+}
+
+// clientType returns the type of conn matched by the TYPE filters, godis has no master nor replica
+func clientType(conn interfaces.Connection) string {
+	if conn.SubsCount() > 0 {
+		return "pubsub"
+	}
+	return "normal"
+}
+
+// clientInfo describes conn in the format of CLIENT LIST, without the trailing newline
+func (r *Redis) clientInfo(conn interfaces.Connection) string {
+	now := time.Now()
+	flags := ""
+	if conn.Monitoring() {
+		flags += "O"
+	}
+	if conn.SubsCount() > 0 {
+		flags += "P"
+	}
+	redirect := r.tracking.Redirect(conn)
+	if redirect >= 0 {
+		flags += "t"
+	}
+	if conn.NoEvict() {
+		flags += "e"
+	}
+	if flags == "" {
+		flags = "N"
+	}
+	lastCommand, lastInteraction := conn.LastCommand()
+	query, output, queued := conn.Buffers()
+	return fmt.Sprintf("id=%d addr=%s laddr=%s name=%s age=%d idle=%d flags=%s db=0 sub=%d psub=%d ssub=%d multi=-1 "+
+		"qbuf=%d obl=%d oll=%d omem=%d events=r cmd=%s user=default redir=%d resp=%d",
+		conn.ID(), conn.RemoteAddr(), conn.LocalAddr(), conn.Name(),
+		int64(now.Sub(conn.CreatedAt()).Seconds()), int64(now.Sub(lastInteraction).Seconds()), flags,
+		len(conn.Channels()), len(conn.Patterns()), len(conn.ShardChannels()),
+		query, output, queued, output, lastCommand, redirect, conn.ProtocolVersion())
+}
+
+// sortedClients returns the connected clients by id
+func (r *Redis) sortedClients() []interfaces.Connection {
+	var clients []interfaces.Connection
+	r.clients.Range(func(_, value any) bool {
+		clients = append(clients, value.(interfaces.Connection))
+		return true
+	})
+	sort.Slice(clients, func(i, j int) bool { return clients[i].ID() < clients[j].ID() })
+	return clients
+}
+
+// execClient runs the subcommands of CLIENT, which act on the connections
+func (r *Redis) execClient(conn interfaces.Connection, args [][]byte) protocol.Reply {
+	if len(args) < 1 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for 'client' command")
+	}
+
+	subCmd := strings.ToUpper(string(args[0]))
+	switch subCmd {
+	case "ID":
+		if len(args) != 1 {
+			return protocol.MakeErrReply("ERR wrong number of arguments for 'client|id' command")
+		}
+		return protocol.MakeIntReply(conn.ID())
+	case "INFO":
+		if len(args) != 1 {
+			return protocol.MakeErrReply("ERR wrong number of arguments for 'client|info' command")
+		}
+		return protocol.MakeVerbatimReply("txt", []byte(r.clientInfo(conn)+"\n"))
+	case "LIST":
+		return r.clientList(args[1:])
+	case "SETNAME":
+		if len(args) != 2 {
+			return protocol.MakeErrReply("ERR wrong number of arguments for 'client|setname' command")
+		}
+		name := string(args[1])
+		if !validClientName(name) {
+			return protocol.MakeErrReply("ERR Client names cannot contain spaces, newlines or special characters.")
+		}
+		conn.SetName(name)
+		return protocol.MakeOkReply()
+	case "GETNAME":
+		if len(args) != 1 {
+			return protocol.MakeErrReply("ERR wrong number of arguments for 'client|getname' command")
+		}
+		if conn.Name() == "" {
+			return protocol.MakeNullBulkReply()
+		}
+		return protocol.MakeBulkReply([]byte(conn.Name()))
+	case "KILL":
+		return r.clientKill(conn, args[1:])
+	case "PAUSE":
+		if len(args) != 2 && len(args) != 3 {
+			return protocol.MakeErrReply("ERR wrong number of arguments for 'client|pause' command")
+		}
+		timeout, err := strconv.ParseInt(string(args[1]), 10, 64)
+		if err != nil {
+			return protocol.MakeErrReply("ERR timeout is not an integer or out of range")
+		}
+		if timeout < 0 {
+			return protocol.MakeErrReply("ERR timeout is negative")
+		}
+		all := true
+		if len(args) == 3 {
+			switch strings.ToUpper(string(args[2])) {
+			case "ALL":
+			case "WRITE":
+				all = false
+			default:
+				return protocol.MakeErrReply("ERR syntax error")
+			}
+		}
+		r.pause.pause(time.Duration(timeout)*time.Millisecond, all)
+		return protocol.MakeOkReply()
+	case "UNPAUSE":
+		if len(args) != 1 {
+			return protocol.MakeErrReply("ERR wrong number of arguments for 'client|unpause' command")
+		}
+		r.pause.unpause()
+		return protocol.MakeOkReply()
+	case "NO-EVICT":
+		if len(args) != 2 {
+			return protocol.MakeErrReply("ERR wrong number of arguments for 'client|no-evict' command")
+		}
+		switch strings.ToUpper(string(args[1])) {
+		case "ON":
+			conn.SetNoEvict(true)
+		case "OFF":
+			conn.SetNoEvict(false)
+		default:
+			return protocol.MakeErrReply("ERR syntax error")
+		}
+		return protocol.MakeOkReply()
+	case "REPLY":
+		if len(args) != 2 {
+			return protocol.MakeErrReply("ERR wrong number of arguments for 'client|reply' command")
+		}
+		mode := strings.ToLower(string(args[1]))
+		if mode != "on" && mode != "off" && mode != "skip" {
+			return protocol.MakeErrReply("ERR syntax error")
+		}
+		conn.SetReplyMode(mode)
+		return protocol.MakeOkReply()
+	case "TRACKING":
+		return tracking.Tracking(r.tracking, conn, args[1:])
+	case "CACHING":
+		return tracking.Caching(r.tracking, conn, args[1:])
+	case "GETREDIR":
+		if len(args) != 1 {
+			return protocol.MakeErrReply("ERR wrong number of arguments for 'client|getredir' command")
+		}
+		return tracking.GetRedir(r.tracking, conn)
+	}
+	return protocol.MakeErrReply("ERR unknown subcommand '" + string(args[0]) + "'. Try CLIENT HELP.")
+}
+
+// clientList describes the clients, filtered by type or ids
+// `CLIENT LIST [TYPE normal|master|replica|pubsub] [ID client-id [client-id ...]]`
+func (r *Redis) clientList(args [][]byte) protocol.Reply {
+	typ := ""
+	var ids map[int64]bool
+	if len(args) >= 2 {
+		switch strings.ToUpper(string(args[0])) {
+		case "TYPE":
+			if len(args) != 2 {
+				return protocol.MakeErrReply("ERR syntax error")
+			}
+			typ = strings.ToLower(string(args[1]))
+			if typ == "slave" {
+				typ = "replica"
+			}
+			if typ != "normal" && typ != "master" && typ != "replica" && typ != "pubsub" {
+				return protocol.MakeErrReply("ERR Unknown client type '" + string(args[1]) + "'")
+			}
+		case "ID":
+			ids = make(map[int64]bool, len(args)-1)
+			for _, arg := range args[1:] {
+				id, err := strconv.ParseInt(string(arg), 10, 64)
+				if err != nil || id <= 0 {
+					return protocol.MakeErrReply("ERR Invalid client ID")
+				}
+				ids[id] = true
+			}
+		default:
+			return protocol.MakeErrReply("ERR syntax error")
+		}
+	} else if len(args) != 0 {
+		return protocol.MakeErrReply("ERR syntax error")
+	}
+
+	var b strings.Builder
+	for _, client := range r.sortedClients() {
+		if (typ != "" && clientType(client) != typ) || (ids != nil && !ids[client.ID()]) {
+			continue
+		}
+		b.WriteString(r.clientInfo(client))
+		b.WriteByte('\n')
+	}
+	return protocol.MakeVerbatimReply("txt", []byte(b.String()))
+}
+
+// clientKill disconnects the clients matching every filter, the client itself is disconnected after its reply
+// `CLIENT KILL ip:port`
+// `CLIENT KILL [ID client-id] [TYPE normal|master|replica|pubsub] [USER username] [ADDR ip:port] [LADDR ip:port] [SKIPME yes|no] [MAXAGE maxage]`
+func (r *Redis) clientKill(conn interfaces.Connection, args [][]byte) protocol.Reply {
+	if len(args) == 0 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for 'client|kill' command")
+	}
+	// the old form, by address
+	if len(args) == 1 {
+		for _, client := range r.sortedClients() {
+			if client.RemoteAddr() == string(args[0]) {
+				r.kill(conn, client)
+				return protocol.MakeOkReply()
+			}
+		}
+		return protocol.MakeErrReply("ERR No such client")
+	}
+	if len(args)%2 != 0 {
+		return protocol.MakeErrReply("ERR syntax error")
+	}
+
+	var filters []func(client interfaces.Connection) bool
+	skipMe := true
+	for i := 0; i < len(args); i += 2 {
+		value := string(args[i+1])
+		switch strings.ToUpper(string(args[i])) {
+		case "ID":
+			id, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || id <= 0 {
+				return protocol.MakeErrReply("ERR client-id should be greater than 0")
+			}
+			filters = append(filters, func(client interfaces.Connection) bool { return client.ID() == id })
+		case "TYPE":
+			typ := strings.ToLower(value)
+			if typ == "slave" {
+				typ = "replica"
+			}
+			if typ != "normal" && typ != "master" && typ != "replica" && typ != "pubsub" {
+				return protocol.MakeErrReply("ERR Unknown client type '" + value + "'")
+			}
+			filters = append(filters, func(client interfaces.Connection) bool { return clientType(client) == typ })
+		case "USER":
+			// default is the only user of godis
+			if value != "default" {
+				return protocol.MakeErrReply("ERR No such user '" + value + "'")
+			}
+		case "ADDR":
+			filters = append(filters, func(client interfaces.Connection) bool { return client.RemoteAddr() == value })
+		case "LADDR":
+			filters = append(filters, func(client interfaces.Connection) bool { return client.LocalAddr() == value })
+		case "SKIPME":
+			switch strings.ToLower(value) {
+			case "yes":
+				skipMe = true
+			case "no":
+				skipMe = false
+			default:
+				return protocol.MakeErrReply("ERR syntax error")
+			}
+		case "MAXAGE":
+			maxAge, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || maxAge <= 0 {
+				return protocol.MakeErrReply("ERR syntax error")
+			}
+			filters = append(filters, func(client interfaces.Connection) bool {
+				return time.Since(client.CreatedAt()) >= time.Duration(maxAge)*time.Second
+			})
+		default:
+			return protocol.MakeErrReply("ERR syntax error")
+		}
+	}
+
+	killed := 0
+	for _, client := range r.sortedClients() {
+		if skipMe && client.ID() == conn.ID() {
+			continue
+		}
+		matched := true
+		for _, filter := range filters {
+			matched = matched && filter(client)
+		}
+		if matched {
+			r.kill(conn, client)
+			killed++
+		}
+	}
+	return protocol.MakeIntReply(int64(killed))
+}
+
+// kill disconnects client, killed by conn
+func (r *Redis) kill(conn, client interfaces.Connection) {
+	if client.ID() == conn.ID() {
+		client.CloseAfterReply()
+		return
+	}
+	_ = client.Close()
+}
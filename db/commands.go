@@ -19,6 +19,7 @@ const (
 	TypeHash
 	TypeSet
 	TypeZset
+	TypeStream
 )
 
 type DataEntity struct {
@@ -29,6 +30,30 @@ type DataEntity struct {
 type cmd struct {
 	name     string
 	executor Exec
+	// flagWrite or flagReadOnly, for commands working on keys
+	flags int
+	// finds the keys in the arguments, nil for commands without keys
+	keys keysFunc
+}
+
+// write marks the command as modifying the keys found by keys
+func (c *cmd) write(keys keysFunc) *cmd {
+	c.flags |= flagWrite
+	c.keys = keys
+	return c
+}
+
+// blocking marks the command as possibly waiting for other clients
+func (c *cmd) blocking() *cmd {
+	c.flags |= flagBlocking
+	return c
+}
+
+// readOnly marks the command as only reading the keys found by keys
+func (c *cmd) readOnly(keys keysFunc) *cmd {
+	c.flags |= flagReadOnly
+	c.keys = keys
+	return c
 }
 
 // Exec is the function for executing the corresponding command
@@ -37,19 +62,18 @@ type Exec func(db interfaces.DB, args [][]byte) protocol.Reply
 func Register(cmdN string, cmdF Exec, ifPersist bool) *cmd {
 	name := strings.ToLower(cmdN)
 
-	executePersist := func(db interfaces.DB, args [][]byte) protocol.Reply {
-		reply := cmdF(db, args)
-		// if the server creahed during the execution
-		// the command then will not be appended to the file
-		if ifPersist {
-			// TODO: persist AOF
-		}
-		return reply
-	}
-
 	cmd := &cmd{
-		name:     name,
-		executor: executePersist,
+		name: name,
+	}
+	// the write commands are appended to the AOF once they ran, if the server crashes
+	// during the execution the command isn't in the file
+	// the blocking ones go through execPersisted too, as they wait without blocking the writes
+	cmd.executor = func(db interfaces.DB, args [][]byte) protocol.Reply {
+		redis, ok := db.(*Redis)
+		if !ifPersist || !ok || redis.aof == nil || cmd.flags&(flagWrite|flagBlocking) == 0 {
+			return cmdF(db, args)
+		}
+		return redis.execPersisted(name, cmdF, args, cmd.flags&flagWrite != 0)
 	}
 	CommandMap[name] = cmd
 	return cmd
@@ -88,6 +112,7 @@ func Del(db interfaces.DB, args [][]byte) protocol.Reply {
 			log.Printf("failed to delete key %s", key)
 			continue
 		}
+		redis.notifyKeyspaceEvent(notifyGeneric, "del", key)
 		counter++
 	}
 
@@ -102,43 +127,84 @@ func Del(db interfaces.DB, args [][]byte) protocol.Reply {
 // init() will be called before main() after the package is loaded
 func init() {
 	Register("PING", Ping, true)
-	Register("DEL", Del, true)
+	Register("DEL", Del, true).write(allKeys)
+	Register("CONFIG", Config, false)
+	Register("INFO", Info, false)
+	Register("SLOWLOG", SlowLog, false)
+	Register("LATENCY", Latency, false)
+	Register("SCAN", Scan, false)
 
 	// string commands
-	Register("SET", Set, true)
-	Register("GET", Get, true)
+	Register("SET", Set, true).write(firstKey)
+	Register("GET", Get, true).readOnly(firstKey)
 
 	// list commands
-	Register("LPUSH", LPush, true)
-	Register("RPUSH", RPush, true)
-	Register("LPOP", LPop, true)
-	Register("RPOP", RPop, true)
-	Register("LLEN", LLen, true)
-	Register("LINDEX", LIndex, true)
-	Register("LRANGE", LRange, true)
+	Register("LPUSH", LPush, true).write(firstKey)
+	Register("RPUSH", RPush, true).write(firstKey)
+	Register("LPOP", LPop, true).write(firstKey)
+	Register("RPOP", RPop, true).write(firstKey)
+	Register("LLEN", LLen, true).readOnly(firstKey)
+	Register("LINDEX", LIndex, true).readOnly(firstKey)
+	Register("LRANGE", LRange, true).readOnly(firstKey)
 
 	// hash commands
-	Register("HSET", HSet, true)
-	Register("HGET", HGet, true)
-	Register("HDEL", HDel, true)
-	Register("HGETALL", HGetAll, true)
-	Register("HEXISTS", HExists, true)
-	Register("HLEN", HLen, true)
+	Register("HSET", HSet, true).write(firstKey)
+	Register("HGET", HGet, true).readOnly(firstKey)
+	Register("HDEL", HDel, true).write(firstKey)
+	Register("HGETALL", HGetAll, true).readOnly(firstKey)
+	Register("HEXISTS", HExists, true).readOnly(firstKey)
+	Register("HLEN", HLen, true).readOnly(firstKey)
 
 	// set commands
-	Register("SADD", SAdd, true)
-	Register("SREM", SRem, true)
-	Register("SISMEMBER", SIsMember, true)
-	Register("SMEMBERS", SMembers, true)
-	Register("SCARD", SCard, true)
-	Register("SINTER", SInter, true)
-	Register("SUNION", SUnion, true)
-	Register("SDIFF", SDiff, true)
-
-	Register("ZADD", ZAdd, true)
-	Register("ZREM", ZRemove, true)
-	Register("ZRANGE", ZRange, true)
-	Register("ZCARD", ZCard, true)
-	Register("ZSCORE", ZScore, true)
-	Register("ZRANK", ZRank, true)
+	Register("SADD", SAdd, true).write(firstKey)
+	Register("SREM", SRem, true).write(firstKey)
+	Register("SISMEMBER", SIsMember, true).readOnly(firstKey)
+	Register("SMEMBERS", SMembers, true).readOnly(firstKey)
+	Register("SCARD", SCard, true).readOnly(firstKey)
+	Register("SINTER", SInter, true).readOnly(allKeys)
+	Register("SUNION", SUnion, true).readOnly(allKeys)
+	Register("SDIFF", SDiff, true).readOnly(allKeys)
+
+	Register("ZADD", ZAdd, true).write(firstKey)
+	Register("ZREM", ZRemove, true).write(firstKey)
+	Register("ZRANGE", ZRange, true).readOnly(firstKey)
+	Register("ZCARD", ZCard, true).readOnly(firstKey)
+	Register("ZSCORE", ZScore, true).readOnly(firstKey)
+	Register("ZRANK", ZRank, true).readOnly(firstKey)
+	Register("ZUNION", ZUnion, true).readOnly(numKeysAt(0))
+	Register("ZINTER", ZInter, true).readOnly(numKeysAt(0))
+	Register("ZDIFF", ZDiff, true).readOnly(numKeysAt(0))
+	Register("ZUNIONSTORE", ZUnionStore, true).write(firstKey)
+	Register("ZINTERSTORE", ZInterStore, true).write(firstKey)
+	Register("ZDIFFSTORE", ZDiffStore, true).write(firstKey)
+	Register("ZPOPMIN", ZPopMin, true).write(firstKey)
+	Register("ZPOPMAX", ZPopMax, true).write(firstKey)
+	Register("BZPOPMIN", BZPopMin, true).write(keyRange(0, -2, 1)).blocking()
+	Register("BZPOPMAX", BZPopMax, true).write(keyRange(0, -2, 1)).blocking()
+	Register("ZMPOP", ZMPop, true).write(numKeysAt(0))
+	Register("ZRANDMEMBER", ZRandMember, true).readOnly(firstKey)
+
+	// geo commands
+	Register("GEOADD", GeoAdd, true).write(firstKey)
+	Register("GEOPOS", GeoPos, true).readOnly(firstKey)
+	Register("GEODIST", GeoDist, true).readOnly(firstKey)
+	Register("GEOHASH", GeoHash, true).readOnly(firstKey)
+	Register("GEOSEARCH", GeoSearch, true).readOnly(firstKey)
+	Register("GEOSEARCHSTORE", GeoSearchStore, true).write(firstKey)
+
+	// stream commands
+	Register("XADD", XAdd, true).write(firstKey)
+	Register("XTRIM", XTrim, true).write(firstKey)
+	Register("XRANGE", XRange, true).readOnly(firstKey)
+	Register("XREVRANGE", XRevRange, true).readOnly(firstKey)
+	Register("XLEN", XLen, true).readOnly(firstKey)
+	Register("XDEL", XDel, true).write(firstKey)
+	Register("XREAD", XRead, true).readOnly(streamKeys).blocking()
+	Register("XGROUP", XGroup, true).write(keyRange(1, 1, 1))
+	Register("XREADGROUP", XReadGroup, true).write(streamKeys).blocking()
+	Register("XACK", XAck, true).write(firstKey)
+	Register("XPENDING", XPending, true).readOnly(firstKey)
+	Register("XCLAIM", XClaim, true).write(firstKey)
+	Register("XAUTOCLAIM", XAutoClaim, true).write(firstKey)
+	Register("XINFO", XInfo, true).readOnly(keyRange(1, 1, 1))
 }
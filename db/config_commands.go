@@ -0,0 +1,107 @@
+package db
+
+import (
+	"godis/config"
+	"godis/interfaces"
+	"godis/lib/utils"
+	"godis/redis/protocol"
+	"log"
+	"strings"
+)
+
+// Config gets, sets and persists the parameters of config.Params
+// `CONFIG GET parameter [parameter ...]` / `CONFIG SET parameter value [parameter value ...]`
+// `CONFIG REWRITE` / `CONFIG RESETSTAT`
+func Config(db interfaces.DB, args [][]byte) protocol.Reply {
+	if len(args) < 1 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for 'config' command")
+	}
+
+	redis, _ := db.(*Redis)
+	switch strings.ToUpper(string(args[0])) {
+	case "GET":
+		if len(args) < 2 {
+			return protocol.MakeErrReply("ERR wrong number of arguments for 'config|get' command")
+		}
+		return redis.configGet(args[1:])
+	case "SET":
+		if len(args) < 3 || len(args)%2 != 1 {
+			return protocol.MakeErrReply("ERR wrong number of arguments for 'config|set' command")
+		}
+		return redis.configSet(args[1:])
+	case "REWRITE":
+		if len(args) != 1 {
+			return protocol.MakeErrReply("ERR wrong number of arguments for 'config|rewrite' command")
+		}
+		if err := redis.Config().Rewrite(); err != nil {
+			log.Printf("CONFIG REWRITE failed: %v", err)
+			if err == config.ErrNoConfigFile {
+				return protocol.MakeErrReply("ERR " + err.Error())
+			}
+			return protocol.MakeErrReply("ERR Rewriting config file: " + err.Error())
+		}
+		return protocol.MakeOkReply()
+	case "RESETSTAT":
+		if len(args) != 1 {
+			return protocol.MakeErrReply("ERR wrong number of arguments for 'config|resetstat' command")
+		}
+		redis.stats.Reset()
+		return protocol.MakeOkReply()
+	default:
+		return protocol.MakeErrReply("ERR unknown subcommand '" + string(args[0]) + "'. Try CONFIG HELP.")
+	}
+}
+
+// configGet returns the parameters matching any of the glob-style patterns, with their values
+func (r *Redis) configGet(patterns [][]byte) protocol.Reply {
+	var matchers []*utils.Pattern
+	for _, arg := range patterns {
+		pattern, err := utils.CompilePattern(strings.ToLower(string(arg)))
+		if err == nil {
+			matchers = append(matchers, pattern)
+		}
+	}
+	props := r.Config()
+	var pairs []protocol.Reply
+	for _, param := range config.Params() {
+		for _, pattern := range matchers {
+			if pattern.IsMatch(param.Name) {
+				pairs = append(pairs, protocol.MakeBulkReply([]byte(param.Name)), protocol.MakeBulkReply([]byte(param.Get(props))))
+				break
+			}
+		}
+	}
+	return protocol.MakeMapReply(pairs)
+}
+
+// configSet sets every parameter or none: they are set on a copy of the parameters in use,
+// which replaces them once every value was accepted
+func (r *Redis) configSet(args [][]byte) protocol.Reply {
+	r.configMu.Lock()
+	defer r.configMu.Unlock()
+
+	props := r.Config().Clone()
+	seen := make(map[string]bool, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		name := strings.ToLower(string(args[i]))
+		param := config.Lookup(name)
+		if param == nil {
+			return protocol.MakeErrReply("ERR Unknown option or number of arguments for CONFIG SET - '" + name + "'")
+		}
+		msg := ""
+		switch {
+		case !param.Mutable:
+			msg = "can't set immutable config"
+		case seen[name]:
+			msg = "duplicate parameter"
+		default:
+			msg = param.Set(props, string(args[i+1]))
+		}
+		if msg != "" {
+			return protocol.MakeErrReply("ERR CONFIG SET failed (possibly related to argument '" + name + "') - " + msg)
+		}
+		seen[name] = true
+	}
+	r.useConfig(props)
+	return protocol.MakeOkReply()
+}
@@ -0,0 +1,162 @@
+package db
+
+import (
+	"crypto/subtle"
+	"godis/interfaces"
+	"godis/redis/protocol"
+	"strconv"
+	"strings"
+)
+
+// RedisVersion is the version of Redis whose commands and protocol godis implements,
+// clients look at it to enable features
+const RedisVersion = "7.2.0"
+
+// validClientName reports whether name may be used with SETNAME
+func validClientName(name string) bool {
+	for i := 0; i < len(name); i++ {
+		if name[i] < '!' || name[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// password returns the password set with requirepass, empty if none is required
+func (r *Redis) password() string {
+	return r.Config().RequirePass
+}
+
+// checkPassword reports whether password is the one of the default user, the only user of godis
+// any password is accepted when none is required
+func (r *Redis) checkPassword(username, password string) bool {
+	required := r.password()
+	if username != "default" {
+		return false
+	}
+	return required == "" || subtle.ConstantTimeCompare([]byte(password), []byte(required)) == 1
+}
+
+// auth authenticates conn with the password of the default user
+// `AUTH [username] password`
+func (r *Redis) auth(conn interfaces.Connection, args [][]byte) protocol.Reply {
+	if len(args) != 1 && len(args) != 2 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for 'auth' command")
+	}
+	if len(args) == 1 && r.password() == "" {
+		return protocol.MakeErrReply("ERR AUTH <password> called without any password configured for the default user. Are you sure your configuration is correct?")
+	}
+	username, password := "default", string(args[len(args)-1])
+	if len(args) == 2 {
+		username = string(args[0])
+	}
+	if !r.checkPassword(username, password) {
+		return protocol.MakeErrReply("WRONGPASS invalid username-password pair or user is disabled.")
+	}
+	conn.SetAuthenticated(true)
+	return protocol.MakeOkReply()
+}
+
+// hello switches conn to the given protocol version and returns the properties of the server
+// `HELLO [protover [AUTH username password] [SETNAME clientname]]`
+func (r *Redis) hello(conn interfaces.Connection, args [][]byte) protocol.Reply {
+	version := conn.ProtocolVersion()
+	if len(args) > 0 {
+		v, err := strconv.Atoi(string(args[0]))
+		if err != nil {
+			return protocol.MakeErrReply("ERR Protocol version is not an integer or out of range")
+		}
+		if v != protocol.RESP2 && v != protocol.RESP3 {
+			return protocol.MakeErrReply("NOPROTO unsupported protocol version")
+		}
+		version = v
+	}
+
+	name, setName, authenticated := "", false, conn.Authenticated()
+	for i := 1; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "AUTH":
+			if i+2 >= len(args) {
+				return protocol.MakeErrReply("ERR Syntax error in HELLO option 'auth'")
+			}
+			if !r.checkPassword(string(args[i+1]), string(args[i+2])) {
+				return protocol.MakeErrReply("WRONGPASS invalid username-password pair or user is disabled.")
+			}
+			authenticated = true
+			i += 2
+		case "SETNAME":
+			if i+1 >= len(args) {
+				return protocol.MakeErrReply("ERR Syntax error in HELLO option 'setname'")
+			}
+			name, setName = string(args[i+1]), true
+			if !validClientName(name) {
+				return protocol.MakeErrReply("ERR Client names cannot contain spaces, newlines or special characters.")
+			}
+			i++
+		default:
+			return protocol.MakeErrReply("ERR Syntax error in HELLO option '" + string(args[i]) + "'")
+		}
+	}
+
+	if !authenticated {
+		return protocol.MakeErrReply("NOAUTH HELLO must be called with the client already authenticated, otherwise the HELLO <proto> AUTH <user> <pass> option can be used to authenticate the client and select the RESP protocol version at the same time")
+	}
+
+	// nothing is changed unless every option is valid
+	conn.SetAuthenticated(true)
+	conn.SetProtocolVersion(version)
+	if setName {
+		conn.SetName(name)
+	}
+	return protocol.MakeMapReply([]protocol.Reply{
+		protocol.MakeBulkReply([]byte("server")), protocol.MakeBulkReply([]byte("redis")),
+		protocol.MakeBulkReply([]byte("version")), protocol.MakeBulkReply([]byte(RedisVersion)),
+		protocol.MakeBulkReply([]byte("proto")), protocol.MakeIntReply(int64(version)),
+		protocol.MakeBulkReply([]byte("id")), protocol.MakeIntReply(conn.ID()),
+		protocol.MakeBulkReply([]byte("mode")), protocol.MakeBulkReply([]byte("standalone")),
+		protocol.MakeBulkReply([]byte("role")), protocol.MakeBulkReply([]byte("master")),
+		protocol.MakeBulkReply([]byte("modules")), protocol.MakeEmptyMultiBulkReply(),
+	})
+}
+
+// quit closes conn once OK is written
+// `QUIT`
+func (r *Redis) quit(conn interfaces.Connection, args [][]byte) protocol.Reply {
+	conn.CloseAfterReply()
+	return protocol.MakeOkReply()
+}
+
+// reset brings conn back to the state of a new connection, the subscriptions, MONITOR and tracking end
+// `RESET`
+func (r *Redis) reset(conn interfaces.Connection, args [][]byte) protocol.Reply {
+	if len(args) != 0 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for 'reset' command")
+	}
+	r.hub.UnsubscribeAll(conn)
+	r.monitors.remove(conn)
+	conn.SetMonitoring(false)
+	r.tracking.Disable(conn)
+	conn.SetReplyMode("on")
+	conn.SetProtocolVersion(protocol.RESP2)
+	conn.SetName("")
+	conn.SetNoEvict(false)
+	// like AfterClientOpen, the default user needs no AUTH without a password
+	conn.SetAuthenticated(r.password() == "")
+	return protocol.MakeStatusReply("RESET")
+}
+
+// track records the keys read by conn, or invalidates the keys it modified, for the clients tracking them
+func (r *Redis) track(conn interfaces.Connection, cmd *cmd, args [][]byte, reply protocol.Reply) {
+	defer r.tracking.ResetCaching(conn)
+	if cmd.keys == nil {
+		return
+	}
+	// a write command may have modified some keys before failing
+	if cmd.flags&flagWrite != 0 {
+		r.tracking.Invalidate(conn, cmd.keys(args))
+		return
+	}
+	if _, isErr := reply.(protocol.ErrorReply); !isErr {
+		r.tracking.Read(conn, cmd.keys(args))
+	}
+}
@@ -1,25 +1,119 @@
 package db
 
 import (
+	"godis/aof"
+	"godis/config"
 	"godis/ds"
 	"godis/interfaces"
+	"godis/pubsub"
 	"godis/redis/protocol"
+	"godis/stats"
+	"godis/tracking"
 	"log"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type Redis struct {
 	data *ds.ShardedMap
+	// clients blocked by commands like BZPOPMIN
+	blocking *blockingKeys
+	// serializes read-modify-write sequences on the same key
+	locks *keyLocks
+	// channels and patterns subscribed to
+	hub *pubsub.Hub
+	// parameters in use, replaced by CONFIG SET under configMu
+	props    atomic.Pointer[config.ServerProperties]
+	configMu sync.Mutex
+	// classes of keyspace events to publish, parsed from notify-keyspace-events
+	notifyFlags atomic.Int32
+	// connected clients by id
+	clients sync.Map
+	// keys cached by clients with CLIENT TRACKING on
+	tracking *tracking.Table
+	// counters reported by INFO
+	stats *stats.Stats
+	// the latest slow commands, read by SLOWLOG
+	slowLog slowLog
+	// the latency spikes, read by LATENCY
+	latency latencyMonitor
+	// the clients shown every command by MONITOR
+	monitors monitors
+	// set by CLIENT PAUSE
+	pause clientPause
+	// the append only file, nil unless appendonly is set when the server starts
+	aof     *aof.Persister
+	aofDone chan struct{}
+	// serializes the write commands with their appends to aof, and guards what they propagate
+	aofMu      sync.Mutex
+	propagated [][][]byte
+	rewritten  bool
 }
 
+// NewStandAloneDb returns a database configured with config.Properties
 func NewStandAloneDb() *Redis {
-	return &Redis{
-		data: ds.NewShardedMap(16),
+	props := config.Properties
+	r := &Redis{
+		data:     ds.NewShardedMap(props.Shards),
+		blocking: newBlockingKeys(),
+		locks:    newKeyLocks(1024),
+		hub:      pubsub.MakeHub(),
+		stats:    stats.New(),
 	}
+	r.tracking = tracking.MakeTable(r.client)
+	r.useConfig(props)
+	if props.AppendOnly {
+		if err := r.openAOF(props.AppendFilename); err != nil {
+			log.Fatalf("loading the append only file failed: %v", err)
+		}
+	}
+	return r
+}
+
+func (r *Redis) Stats() *stats.Stats {
+	return r.stats
+}
+
+func (r *Redis) Config() *config.ServerProperties {
+	return r.props.Load()
+}
+
+// useConfig replaces the parameters in use, after they were all checked
+func (r *Redis) useConfig(props *config.ServerProperties) {
+	flags, _ := parseKeyspaceEvents(props.NotifyKeyspaceEvents)
+	r.props.Store(props)
+	r.notifyFlags.Store(int32(flags))
+}
+
+// client returns the connected client with the given id
+func (r *Redis) client(id int64) (interfaces.Connection, bool) {
+	conn, ok := r.clients.Load(id)
+	if !ok {
+		return nil, false
+	}
+	return conn.(interfaces.Connection), true
 }
 
 func (r *Redis) Close() {
-	// Clean up
+	r.closeAOF()
+}
+
+func (r *Redis) AfterClientOpen(conn interfaces.Connection) {
+	// like the default user of Redis, clients don't need to AUTH without a password,
+	// even if one is set afterwards
+	conn.SetAuthenticated(r.password() == "")
+	r.clients.Store(conn.ID(), conn)
+	r.stats.ConnectedClients.Add(1)
+}
+
+func (r *Redis) AfterClientClose(conn interfaces.Connection) {
+	r.clients.Delete(conn.ID())
+	r.monitors.remove(conn)
+	r.stats.ConnectedClients.Add(-1)
+	r.hub.UnsubscribeAll(conn)
+	r.tracking.Disable(conn)
 }
 
 func (r *Redis) Exec(conn interfaces.Connection, cmdL [][]byte) protocol.Reply {
@@ -29,13 +123,146 @@ func (r *Redis) Exec(conn interfaces.Connection, cmdL [][]byte) protocol.Reply {
 
 	// commands are case-insensitive
 	cmdName := strings.ToLower(string(cmdL[0]))
+	if _, ok := CommandMap[cmdName]; ok || connectionCommands[cmdName] {
+		conn.SetLastCommand(fullCommandName(cmdName, cmdL[1:]))
+	}
+	// CLIENT isn't paused, so that CLIENT UNPAUSE ends a pause
+	if r.pause.active.Load() && cmdName != "client" {
+		r.pause.wait(pausedByWrite(cmdName))
+	}
+
+	start := time.Now()
+	reply, ran := r.exec(conn, cmdName, cmdL[1:])
+	_, failed := reply.(protocol.ErrorReply)
+	if ran {
+		elapsed := time.Since(start)
+		// the time blocking commands wait is included
+		r.stats.Record(cmdName, elapsed, failed)
+		r.logSlow(conn, cmdL, start, elapsed)
+		r.monitorLatency(cmdName, start, elapsed)
+		r.monitors.feed(conn, cmdL, start)
+	}
+	if failed {
+		r.stats.ErrorReplies.Add(1)
+	}
+	return reply
+}
+
+// connectionCommands act on the connection itself, they're run by exec rather than through CommandMap
+var connectionCommands = map[string]bool{
+	"auth":         true,
+	"hello":        true,
+	"quit":         true,
+	"reset":        true,
+	"client":       true,
+	"monitor":      true,
+	"subscribe":    true,
+	"unsubscribe":  true,
+	"psubscribe":   true,
+	"punsubscribe": true,
+	"publish":      true,
+	"ssubscribe":   true,
+	"sunsubscribe": true,
+	"spublish":     true,
+	"pubsub":       true,
+}
+
+// containerCommands have subcommands, named like client|list
+var containerCommands = map[string]bool{
+	"client":  true,
+	"config":  true,
+	"slowlog": true,
+	"latency": true,
+	"pubsub":  true,
+	"xinfo":   true,
+	"xgroup":  true,
+}
+
+// fullCommandName returns the name of a command shown by CLIENT LIST, including its subcommand
+func fullCommandName(cmdName string, args [][]byte) string {
+	if containerCommands[cmdName] && len(args) > 0 {
+		return cmdName + "|" + strings.ToLower(string(args[0]))
+	}
+	return cmdName
+}
+
+// exec runs a command, ran is false if the command is unknown or was rejected before running
+func (r *Redis) exec(conn interfaces.Connection, cmdName string, args [][]byte) (protocol.Reply, bool) {
+	if !conn.Authenticated() && cmdName != "auth" && cmdName != "hello" && cmdName != "quit" && cmdName != "reset" {
+		if _, ok := CommandMap[cmdName]; ok || connectionCommands[cmdName] {
+			r.stats.Command(cmdName).RejectedCalls.Add(1)
+		}
+		return protocol.MakeErrReply("NOAUTH Authentication required."), false
+	}
+
+	// pub/sub commands act on the connection itself
+	switch cmdName {
+	case "auth":
+		return r.auth(conn, args), true
+	case "hello":
+		return r.hello(conn, args), true
+	case "quit":
+		return r.quit(conn, args), true
+	case "reset":
+		return r.reset(conn, args), true
+	case "client":
+		return r.execClient(conn, args), true
+	case "monitor":
+		return r.monitor(conn, args), true
+	case "subscribe":
+		return pubsub.Subscribe(r.hub, conn, args), true
+	case "unsubscribe":
+		return pubsub.Unsubscribe(r.hub, conn, args), true
+	case "psubscribe":
+		return pubsub.PSubscribe(r.hub, conn, args), true
+	case "punsubscribe":
+		return pubsub.PUnsubscribe(r.hub, conn, args), true
+	case "publish":
+		return pubsub.Publish(r.hub, args), true
+	case "ssubscribe":
+		return pubsub.SSubscribe(r.hub, conn, args), true
+	case "sunsubscribe":
+		return pubsub.SUnsubscribe(r.hub, conn, args), true
+	case "spublish":
+		return pubsub.SPublish(r.hub, args), true
+	case "pubsub":
+		return pubsub.PubSub(r.hub, args), true
+	}
 
 	cmd, ok := CommandMap[cmdName]
 	if !ok {
 		log.Printf("ERR unknown command '%s'", cmdName)
-		return protocol.MakeErrReply("ERR unknown command '" + cmdName + "'")
+		return protocol.MakeErrReply("ERR unknown command '" + cmdName + "'"), false
+	}
+
+	if cmd.flags&flagBlocking != 0 {
+		_ = conn.Flush()
+	}
+	if cmd.flags&flagReadOnly != 0 {
+		r.countLookups(cmd.keys(args))
 	}
 
 	// Execute command
-	return cmd.executor(r, cmdL[1:])
+	reply := cmd.executor(r, args)
+	if r.tracking.Enabled() {
+		r.track(conn, cmd, args, reply)
+	}
+	return reply, true
+}
+
+// isBlocking reports whether the command named cmdName may block the client, its duration includes the wait
+func isBlocking(cmdName string) bool {
+	cmd, ok := CommandMap[cmdName]
+	return ok && cmd.flags&flagBlocking != 0
+}
+
+// countLookups counts the keys read by a read-only command as keyspace hits or misses
+func (r *Redis) countLookups(keys [][]byte) {
+	for _, key := range keys {
+		if _, ok := r.data.Get(string(key)); ok {
+			r.stats.KeyspaceHits.Add(1)
+		} else {
+			r.stats.KeyspaceMisses.Add(1)
+		}
+	}
 }
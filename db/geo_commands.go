@@ -0,0 +1,522 @@
+package db
+
+import (
+	"fmt"
+	"godis/ds/zset"
+	"godis/interfaces"
+	"godis/lib/geohash"
+	"godis/redis/protocol"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// geo commands store points in sorted sets, the score of a member is its 52-bit geohash
+// so that points close to each other have close scores
+
+// parseGeoUnit returns the number of meters in unit
+func parseGeoUnit(arg []byte) (float64, *protocol.StandardErrReply) {
+	switch strings.ToLower(string(arg)) {
+	case "m":
+		return 1, nil
+	case "km":
+		return 1000, nil
+	case "ft":
+		return 0.3048, nil
+	case "mi":
+		return 1609.34, nil
+	}
+	return 0, protocol.MakeErrReply("ERR unsupported unit provided. please use M, KM, FT, MI")
+}
+
+func parseLonLat(lonArg []byte, latArg []byte) (float64, float64, *protocol.StandardErrReply) {
+	lon, err := strconv.ParseFloat(string(lonArg), 64)
+	if err != nil {
+		return 0, 0, protocol.MakeErrReply("ERR value is not a valid float")
+	}
+	lat, err := strconv.ParseFloat(string(latArg), 64)
+	if err != nil {
+		return 0, 0, protocol.MakeErrReply("ERR value is not a valid float")
+	}
+	if lon < geohash.MinLongitude || lon > geohash.MaxLongitude || lat < geohash.MinLatitude || lat > geohash.MaxLatitude {
+		return 0, 0, protocol.MakeErrReply(fmt.Sprintf("ERR invalid longitude,latitude pair %f,%f", lon, lat))
+	}
+	return lon, lat, nil
+}
+
+// formatCoordinate renders a coordinate the same way Redis does
+func formatCoordinate(v float64) []byte {
+	s := strconv.FormatFloat(v, 'f', 17, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	return []byte(s)
+}
+
+func formatDistance(meters float64, unit float64) []byte {
+	return []byte(strconv.FormatFloat(meters/unit, 'f', 4, 64))
+}
+
+// GeoAdd adds points to the sorted set stored at key and returns the number of added members
+// with CH it returns the number of added or moved members instead
+// `GEOADD key [NX|XX] [CH] longitude latitude member [longitude latitude member ...]`
+func GeoAdd(db interfaces.DB, args [][]byte) protocol.Reply {
+	if len(args) < 4 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for 'geoadd' command")
+	}
+
+	key := string(args[0])
+	nx, xx, ch := false, false, false
+	i := 1
+	for ; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i]))
+		if option == "NX" {
+			nx = true
+		} else if option == "XX" {
+			xx = true
+		} else if option == "CH" {
+			ch = true
+		} else {
+			break
+		}
+	}
+	if nx && xx {
+		return protocol.MakeErrReply("ERR XX and NX options at the same time are not compatible")
+	}
+	points := args[i:]
+	if len(points) == 0 || len(points)%3 != 0 {
+		return protocol.MakeErrReply("ERR syntax error")
+	}
+
+	scores := make([]float64, len(points)/3)
+	for j := range scores {
+		lon, lat, errReply := parseLonLat(points[j*3], points[j*3+1])
+		if errReply != nil {
+			return errReply
+		}
+		scores[j] = float64(geohash.Encode(lon, lat))
+	}
+
+	redis, _ := db.(*Redis)
+	redis.locks.Lock(key)
+	defer redis.locks.Unlock(key)
+	zSet, errReply := getAsZSet(redis, key)
+	if errReply != nil {
+		return errReply
+	}
+
+	var added, changed int64
+	for j, score := range scores {
+		member := string(points[j*3+2])
+		old, exists := zSet.Score(member)
+		if (nx && exists) || (xx && !exists) {
+			continue
+		}
+		if !exists {
+			added++
+			changed++
+		} else if old != score {
+			changed++
+		}
+		zSet.Add(member, score)
+	}
+	if zSet.Len() == 0 {
+		// XX on a new key
+		redis.data.Del(key)
+	} else {
+		// GEOADD is a ZADD on the underlying sorted set
+		redis.notifyKeyspaceEvent(notifyZset, "zadd", key)
+		redis.blocking.signal(key)
+	}
+	if ch {
+		return protocol.MakeIntReply(changed)
+	}
+	return protocol.MakeIntReply(added)
+}
+
+// GeoPos returns the coordinates of members, nil for missing ones
+// `GEOPOS key [member [member ...]]`
+func GeoPos(db interfaces.DB, args [][]byte) protocol.Reply {
+	if len(args) < 1 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for 'geopos' command")
+	}
+
+	redis, _ := db.(*Redis)
+	zSet, errReply := getZSetIfExists(redis, string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	replies := make([]protocol.Reply, len(args)-1)
+	for i, member := range args[1:] {
+		replies[i] = protocol.MakeNullMultiBulkReply()
+		if zSet == nil {
+			continue
+		}
+		if score, ok := zSet.Score(string(member)); ok {
+			lon, lat := geohash.Decode(uint64(score))
+			replies[i] = protocol.MakeMultiBulkReply([][]byte{formatCoordinate(lon), formatCoordinate(lat)})
+		}
+	}
+	return protocol.MakeMultiRawReply(replies)
+}
+
+// GeoDist returns the distance between two members, nil if one of them is missing
+// `GEODIST key member1 member2 [M|KM|FT|MI]`
+func GeoDist(db interfaces.DB, args [][]byte) protocol.Reply {
+	if len(args) != 3 && len(args) != 4 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for 'geodist' command")
+	}
+	unit := 1.0
+	if len(args) == 4 {
+		var errReply *protocol.StandardErrReply
+		unit, errReply = parseGeoUnit(args[3])
+		if errReply != nil {
+			return errReply
+		}
+	}
+
+	redis, _ := db.(*Redis)
+	zSet, errReply := getZSetIfExists(redis, string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if zSet == nil {
+		return protocol.MakeNullBulkReply()
+	}
+	score1, ok1 := zSet.Score(string(args[1]))
+	score2, ok2 := zSet.Score(string(args[2]))
+	if !ok1 || !ok2 {
+		return protocol.MakeNullBulkReply()
+	}
+	lon1, lat1 := geohash.Decode(uint64(score1))
+	lon2, lat2 := geohash.Decode(uint64(score2))
+	return protocol.MakeBulkReply(formatDistance(geohash.Distance(lon1, lat1, lon2, lat2), unit))
+}
+
+// GeoHash returns the standard geohash strings of members, nil for missing ones
+// `GEOHASH key [member [member ...]]`
+func GeoHash(db interfaces.DB, args [][]byte) protocol.Reply {
+	if len(args) < 1 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for 'geohash' command")
+	}
+
+	redis, _ := db.(*Redis)
+	zSet, errReply := getZSetIfExists(redis, string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	replies := make([]protocol.Reply, len(args)-1)
+	for i, member := range args[1:] {
+		replies[i] = protocol.MakeNullBulkReply()
+		if zSet == nil {
+			continue
+		}
+		if score, ok := zSet.Score(string(member)); ok {
+			replies[i] = protocol.MakeBulkReply([]byte(geohash.ToString(uint64(score))))
+		}
+	}
+	return protocol.MakeMultiRawReply(replies)
+}
+
+const (
+	geoSortNone = iota
+	geoSortAsc
+	geoSortDesc
+)
+
+// geoSearchArgs holds the options of GEOSEARCH and GEOSEARCHSTORE, sizes are in meters
+type geoSearchArgs struct {
+	fromMember    string
+	hasFromMember bool
+	hasFromLonLat bool
+	lon           float64
+	lat           float64
+
+	byRadius bool
+	byBox    bool
+	radius   float64
+	width    float64
+	height   float64
+	unit     float64
+
+	sort  int
+	count int
+	any   bool
+
+	withCoord bool
+	withDist  bool
+	withHash  bool
+	storeDist bool
+}
+
+func parseGeoSearchArgs(cmdName string, args [][]byte, store bool) (*geoSearchArgs, *protocol.StandardErrReply) {
+	search := &geoSearchArgs{}
+	parseFloat := func(arg []byte) (float64, *protocol.StandardErrReply) {
+		v, err := strconv.ParseFloat(string(arg), 64)
+		if err != nil {
+			return 0, protocol.MakeErrReply("ERR value is not a valid float")
+		}
+		if v < 0 {
+			return 0, protocol.MakeErrReply("ERR radius cannot be negative")
+		}
+		return v, nil
+	}
+
+	var errReply *protocol.StandardErrReply
+	for i := 0; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i]))
+		remaining := len(args) - i - 1
+		switch {
+		case option == "FROMMEMBER" && remaining >= 1:
+			search.hasFromMember = true
+			search.fromMember = string(args[i+1])
+			i++
+		case option == "FROMLONLAT" && remaining >= 2:
+			search.hasFromLonLat = true
+			search.lon, search.lat, errReply = parseLonLat(args[i+1], args[i+2])
+			if errReply != nil {
+				return nil, errReply
+			}
+			i += 2
+		case option == "BYRADIUS" && remaining >= 2:
+			search.byRadius = true
+			if search.radius, errReply = parseFloat(args[i+1]); errReply != nil {
+				return nil, errReply
+			}
+			if search.unit, errReply = parseGeoUnit(args[i+2]); errReply != nil {
+				return nil, errReply
+			}
+			i += 2
+		case option == "BYBOX" && remaining >= 3:
+			search.byBox = true
+			if search.width, errReply = parseFloat(args[i+1]); errReply != nil {
+				return nil, errReply
+			}
+			if search.height, errReply = parseFloat(args[i+2]); errReply != nil {
+				return nil, errReply
+			}
+			if search.unit, errReply = parseGeoUnit(args[i+3]); errReply != nil {
+				return nil, errReply
+			}
+			i += 3
+		case option == "ASC":
+			search.sort = geoSortAsc
+		case option == "DESC":
+			search.sort = geoSortDesc
+		case option == "COUNT" && remaining >= 1:
+			count, err := strconv.Atoi(string(args[i+1]))
+			if err != nil || count <= 0 {
+				return nil, protocol.MakeErrReply("ERR COUNT must be > 0")
+			}
+			search.count = count
+			i++
+			if i+1 < len(args) && strings.ToUpper(string(args[i+1])) == "ANY" {
+				search.any = true
+				i++
+			}
+		case option == "WITHCOORD" && !store:
+			search.withCoord = true
+		case option == "WITHDIST" && !store:
+			search.withDist = true
+		case option == "WITHHASH" && !store:
+			search.withHash = true
+		case option == "STOREDIST" && store:
+			search.storeDist = true
+		case option == "ANY":
+			return nil, protocol.MakeErrReply("ERR the ANY argument requires COUNT argument")
+		case (option == "WITHCOORD" || option == "WITHDIST" || option == "WITHHASH") && store:
+			return nil, protocol.MakeErrReply("ERR " + cmdName + " is not compatible with WITHDIST, WITHHASH and WITHCOORD options")
+		default:
+			return nil, protocol.MakeErrReply("ERR syntax error")
+		}
+	}
+
+	if search.hasFromMember == search.hasFromLonLat {
+		return nil, protocol.MakeErrReply("ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for " + cmdName)
+	}
+	if search.byRadius == search.byBox {
+		return nil, protocol.MakeErrReply("ERR exactly one of BYRADIUS and BYBOX can be specified for " + cmdName)
+	}
+	search.radius *= search.unit
+	search.width *= search.unit
+	search.height *= search.unit
+	// COUNT without ANY needs every match sorted to return the nearest ones
+	if search.count > 0 && !search.any && search.sort == geoSortNone {
+		search.sort = geoSortAsc
+	}
+	return search, nil
+}
+
+type geoPoint struct {
+	member string
+	score  float64
+	lon    float64
+	lat    float64
+	dist   float64
+}
+
+// contains returns the distance of a point from the center, and whether it is within the search area
+func (search *geoSearchArgs) contains(lon float64, lat float64) (float64, bool) {
+	if search.byRadius {
+		dist := geohash.Distance(search.lon, search.lat, lon, lat)
+		return dist, dist <= search.radius
+	}
+	if geohash.Distance(search.lon, search.lat, lon, search.lat) > search.width/2 {
+		return 0, false
+	}
+	if geohash.Distance(search.lon, search.lat, search.lon, lat) > search.height/2 {
+		return 0, false
+	}
+	return geohash.Distance(search.lon, search.lat, lon, lat), true
+}
+
+// geoSearch returns the points of zSet within the search area
+func geoSearch(zSet *zset.ConcurrentSortedSet, search *geoSearchArgs) ([]*geoPoint, *protocol.StandardErrReply) {
+	if search.hasFromMember {
+		score, ok := zSet.Score(search.fromMember)
+		if !ok {
+			return nil, protocol.MakeErrReply("ERR could not decode requested zset member")
+		}
+		search.lon, search.lat = geohash.Decode(uint64(score))
+	}
+
+	width, height := search.width, search.height
+	if search.byRadius {
+		width, height = search.radius*2, search.radius*2
+	}
+	ranges := geohash.SearchRanges(search.lon, search.lat, width, height)
+	// the cells are scanned in score order, a small area may give the same cell twice
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].Min < ranges[j].Min
+	})
+
+	var points []*geoPoint
+	// COUNT ANY returns as soon as enough points are found
+	enough := false
+	for i, r := range ranges {
+		if enough {
+			break
+		}
+		if i > 0 && r.Min == ranges[i-1].Min {
+			continue
+		}
+		// only the members of the cell are visited
+		zSet.ForEachFrom(float64(r.Min), func(element *zset.Element) bool {
+			score := uint64(element.Score)
+			if score >= r.Max {
+				return false
+			}
+			lon, lat := geohash.Decode(score)
+			if dist, ok := search.contains(lon, lat); ok {
+				points = append(points, &geoPoint{
+					member: element.Member,
+					score:  element.Score,
+					lon:    lon,
+					lat:    lat,
+					dist:   dist,
+				})
+			}
+			enough = search.any && len(points) >= search.count
+			return !enough
+		})
+	}
+
+	if search.sort != geoSortNone {
+		sort.SliceStable(points, func(i, j int) bool {
+			if search.sort == geoSortDesc {
+				return points[i].dist > points[j].dist
+			}
+			return points[i].dist < points[j].dist
+		})
+	}
+	if search.count > 0 && len(points) > search.count {
+		points = points[:search.count]
+	}
+	return points, nil
+}
+
+func geoPointsToReply(points []*geoPoint, search *geoSearchArgs) protocol.Reply {
+	if !search.withDist && !search.withHash && !search.withCoord {
+		members := make([][]byte, len(points))
+		for i, point := range points {
+			members[i] = []byte(point.member)
+		}
+		return protocol.MakeMultiBulkReply(members)
+	}
+
+	replies := make([]protocol.Reply, len(points))
+	for i, point := range points {
+		fields := []protocol.Reply{protocol.MakeBulkReply([]byte(point.member))}
+		if search.withDist {
+			fields = append(fields, protocol.MakeBulkReply(formatDistance(point.dist, search.unit)))
+		}
+		if search.withHash {
+			fields = append(fields, protocol.MakeIntReply(int64(point.score)))
+		}
+		if search.withCoord {
+			fields = append(fields, protocol.MakeMultiBulkReply([][]byte{formatCoordinate(point.lon), formatCoordinate(point.lat)}))
+		}
+		replies[i] = protocol.MakeMultiRawReply(fields)
+	}
+	return protocol.MakeMultiRawReply(replies)
+}
+
+// GeoSearch returns the members within a circle or a box centered on a member or on coordinates
+// `GEOSEARCH key FROMMEMBER member|FROMLONLAT longitude latitude BYRADIUS radius M|KM|FT|MI|BYBOX width height M|KM|FT|MI
+// [ASC|DESC] [COUNT count [ANY]] [WITHCOORD] [WITHDIST] [WITHHASH]`
+func GeoSearch(db interfaces.DB, args [][]byte) protocol.Reply {
+	if len(args) < 6 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for 'geosearch' command")
+	}
+	search, errReply := parseGeoSearchArgs("GEOSEARCH", args[1:], false)
+	if errReply != nil {
+		return errReply
+	}
+
+	redis, _ := db.(*Redis)
+	zSet, errReply := getZSetIfExists(redis, string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if zSet == nil {
+		return protocol.MakeEmptyMultiBulkReply()
+	}
+	points, errReply := geoSearch(zSet, search)
+	if errReply != nil {
+		return errReply
+	}
+	return geoPointsToReply(points, search)
+}
+
+// GeoSearchStore is GEOSEARCH storing the members into destination with their geohash as score,
+// or their distance with STOREDIST, and returns the number of stored members
+// `GEOSEARCHSTORE destination source FROMMEMBER member|FROMLONLAT longitude latitude BYRADIUS radius M|KM|FT|MI|BYBOX width height M|KM|FT|MI
+// [ASC|DESC] [COUNT count [ANY]] [STOREDIST]`
+func GeoSearchStore(db interfaces.DB, args [][]byte) protocol.Reply {
+	if len(args) < 7 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for 'geosearchstore' command")
+	}
+	search, errReply := parseGeoSearchArgs("GEOSEARCHSTORE", args[2:], true)
+	if errReply != nil {
+		return errReply
+	}
+
+	redis, _ := db.(*Redis)
+	src, errReply := getZSetIfExists(redis, string(args[1]))
+	if errReply != nil {
+		return errReply
+	}
+	result := zset.NewConcurrentSortedSet()
+	if src != nil {
+		points, errReply := geoSearch(src, search)
+		if errReply != nil {
+			return errReply
+		}
+		for _, point := range points {
+			score := point.score
+			if search.storeDist {
+				score = point.dist / search.unit
+			}
+			result.Add(point.member, score)
+		}
+	}
+	return storeZSet(redis, string(args[0]), result, "geosearchstore")
+}
@@ -0,0 +1,187 @@
+package db
+
+import (
+	"fmt"
+	"godis/lib/utils"
+	"godis/redis/protocol"
+	"strconv"
+	"testing"
+)
+
+// expected values come from the examples of the Redis documentation
+func TestGeoCommands(t *testing.T) {
+	db := NewStandAloneDb()
+
+	tests := []struct {
+		name     string
+		cmd      Exec
+		args     []string
+		expected string
+	}{
+		{
+			name:     "geoadd",
+			cmd:      GeoAdd,
+			args:     []string{"Sicily", "13.361389", "38.115556", "Palermo", "15.087269", "37.502669", "Catania"},
+			expected: ":2\r\n",
+		},
+		{
+			name:     "geoadd invalid pair",
+			cmd:      GeoAdd,
+			args:     []string{"Sicily", "181", "38", "Nowhere"},
+			expected: "-ERR invalid longitude,latitude pair 181.000000,38.000000\r\n",
+		},
+		{
+			name:     "geoadd nx and xx",
+			cmd:      GeoAdd,
+			args:     []string{"Sicily", "NX", "XX", "13", "38", "Palermo"},
+			expected: "-ERR XX and NX options at the same time are not compatible\r\n",
+		},
+		{
+			name:     "geoadd nx ch",
+			cmd:      GeoAdd,
+			args:     []string{"Sicily", "NX", "CH", "13", "38", "Palermo"},
+			expected: ":0\r\n",
+		},
+		{
+			name:     "geodist",
+			cmd:      GeoDist,
+			args:     []string{"Sicily", "Palermo", "Catania"},
+			expected: "$11\r\n166274.1516\r\n",
+		},
+		{
+			name:     "geodist km",
+			cmd:      GeoDist,
+			args:     []string{"Sicily", "Palermo", "Catania", "km"},
+			expected: "$8\r\n166.2742\r\n",
+		},
+		{
+			name:     "geodist mi",
+			cmd:      GeoDist,
+			args:     []string{"Sicily", "Palermo", "Catania", "MI"},
+			expected: "$8\r\n103.3182\r\n",
+		},
+		{
+			name:     "geodist missing member",
+			cmd:      GeoDist,
+			args:     []string{"Sicily", "Foo", "Bar"},
+			expected: "$-1\r\n",
+		},
+		{
+			name:     "geohash",
+			cmd:      GeoHash,
+			args:     []string{"Sicily", "Palermo", "Catania", "Foo"},
+			expected: "*3\r\n$11\r\nsqc8b49rny0\r\n$11\r\nsqdtr74hyu0\r\n$-1\r\n",
+		},
+		{
+			name:     "geopos",
+			cmd:      GeoPos,
+			args:     []string{"Sicily", "Palermo", "NonExisting"},
+			expected: "*2\r\n*2\r\n$20\r\n13.36138933897018433\r\n$20\r\n38.11555639549629859\r\n*-1\r\n",
+		},
+		{
+			name:     "geosearch by radius",
+			cmd:      GeoSearch,
+			args:     []string{"Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "200", "km", "ASC"},
+			expected: "*2\r\n$7\r\nCatania\r\n$7\r\nPalermo\r\n",
+		},
+		{
+			name:     "geoadd edges",
+			cmd:      GeoAdd,
+			args:     []string{"Sicily", "12.758489", "38.788135", "edge1", "17.241510", "38.788135", "edge2"},
+			expected: ":2\r\n",
+		},
+		{
+			name:     "geosearch by radius excludes edges",
+			cmd:      GeoSearch,
+			args:     []string{"Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "200", "km", "DESC", "WITHDIST"},
+			expected: "*2\r\n*2\r\n$7\r\nPalermo\r\n$8\r\n190.4424\r\n*2\r\n$7\r\nCatania\r\n$7\r\n56.4413\r\n",
+		},
+		{
+			name: "geosearch by box",
+			cmd:  GeoSearch,
+			args: []string{"Sicily", "FROMLONLAT", "15", "37", "BYBOX", "400", "400", "km", "ASC", "WITHCOORD", "WITHDIST"},
+			expected: "*4\r\n" +
+				"*3\r\n$7\r\nCatania\r\n$7\r\n56.4413\r\n*2\r\n$20\r\n15.08726745843887329\r\n$20\r\n37.50266842333162032\r\n" +
+				"*3\r\n$7\r\nPalermo\r\n$8\r\n190.4424\r\n*2\r\n$20\r\n13.36138933897018433\r\n$20\r\n38.11555639549629859\r\n" +
+				"*3\r\n$5\r\nedge2\r\n$8\r\n279.7403\r\n*2\r\n$20\r\n17.24151045083999634\r\n$20\r\n38.78813451624225195\r\n" +
+				"*3\r\n$5\r\nedge1\r\n$8\r\n279.7405\r\n*2\r\n$19\r\n12.7584877610206604\r\n$20\r\n38.78813451624225195\r\n",
+		},
+		{
+			name:     "geosearch from member with count",
+			cmd:      GeoSearch,
+			args:     []string{"Sicily", "FROMMEMBER", "Palermo", "BYRADIUS", "500", "km", "COUNT", "2", "WITHHASH"},
+			expected: "*2\r\n*2\r\n$7\r\nPalermo\r\n:3479099956230698\r\n*2\r\n$5\r\nedge1\r\n:3479273021651468\r\n",
+		},
+		{
+			name:     "geosearch missing member",
+			cmd:      GeoSearch,
+			args:     []string{"Sicily", "FROMMEMBER", "Foo", "BYRADIUS", "500", "km"},
+			expected: "-ERR could not decode requested zset member\r\n",
+		},
+		{
+			name:     "geosearch both centers",
+			cmd:      GeoSearch,
+			args:     []string{"Sicily", "FROMMEMBER", "Palermo", "FROMLONLAT", "15", "37", "BYRADIUS", "500", "km"},
+			expected: "-ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for GEOSEARCH\r\n",
+		},
+		{
+			name:     "geosearch no shape",
+			cmd:      GeoSearch,
+			args:     []string{"Sicily", "FROMMEMBER", "Palermo", "ASC", "COUNT", "1"},
+			expected: "-ERR exactly one of BYRADIUS and BYBOX can be specified for GEOSEARCH\r\n",
+		},
+		{
+			name:     "geosearch bad unit",
+			cmd:      GeoSearch,
+			args:     []string{"Sicily", "FROMMEMBER", "Palermo", "BYRADIUS", "500", "yd"},
+			expected: "-ERR unsupported unit provided. please use M, KM, FT, MI\r\n",
+		},
+		{
+			name:     "geosearchstore storedist",
+			cmd:      GeoSearchStore,
+			args:     []string{"near", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "200", "km", "STOREDIST"},
+			expected: ":2\r\n",
+		},
+		{
+			name:     "stored distance",
+			cmd:      ZScore,
+			args:     []string{"near", "Catania"},
+			expected: "$11\r\n56.44125787\r\n",
+		},
+		{
+			name:     "geosearchstore with options",
+			cmd:      GeoSearchStore,
+			args:     []string{"near", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "200", "km", "WITHDIST"},
+			expected: "-ERR GEOSEARCHSTORE is not compatible with WITHDIST, WITHHASH and WITHCOORD options\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := string(tt.cmd(db, utils.ToCmdLine(tt.args...)).ToBytes())
+			if actual != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestGeoSearchCells(t *testing.T) {
+	db := NewStandAloneDb()
+	GeoAdd(db, utils.ToCmdLine("Sicily", "13.361389", "38.115556", "Palermo", "15.087269", "37.502669", "Catania"))
+	// points around the world, with scores below and above the cells searched
+	for lon := -170; lon <= 170; lon += 20 {
+		for lat := -80; lat <= 80; lat += 20 {
+			GeoAdd(db, utils.ToCmdLine("Sicily", strconv.Itoa(lon), strconv.Itoa(lat), fmt.Sprintf("%d,%d", lon, lat)))
+		}
+	}
+
+	expected := "*2\r\n$7\r\nCatania\r\n$7\r\nPalermo\r\n"
+	if actual := string(GeoSearch(db, utils.ToCmdLine("Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "200", "km", "ASC")).ToBytes()); actual != expected {
+		t.Errorf("expected %q, got %q", expected, actual)
+	}
+	reply, ok := GeoSearch(db, utils.ToCmdLine("Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "200", "km", "COUNT", "1", "ANY")).(*protocol.MultiBulkReply)
+	if !ok || len(reply.Args) != 1 {
+		t.Errorf("expected a single point with COUNT 1 ANY, got %v", reply)
+	}
+}
@@ -70,6 +70,7 @@ func HSet(db interfaces.DB, args [][]byte) protocol.Reply {
 			added++
 		}
 	}
+	redis.notifyKeyspaceEvent(notifyHash, "hset", key)
 
 	return protocol.MakeIntReply(added)
 }
@@ -124,6 +125,9 @@ func HDel(db interfaces.DB, args [][]byte) protocol.Reply {
 			deleted++
 		}
 	}
+	if deleted > 0 {
+		redis.notifyKeyspaceEvent(notifyHash, "hdel", key)
+	}
 
 	return protocol.MakeIntReply(deleted)
 }
@@ -149,7 +153,7 @@ func HGetAll(db interfaces.DB, args [][]byte) protocol.Reply {
 		result = append(result, []byte(field), value)
 	}
 
-	return protocol.MakeMultiBulkReply(result)
+	return protocol.MakeBulkMapReply(result)
 }
 
 // HExists returns if field is an existing field in the hash stored at key
@@ -0,0 +1,179 @@
+package db
+
+import (
+	"fmt"
+	"godis/interfaces"
+	"godis/redis/protocol"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// infoSections are the sections of INFO in the order they're rendered
+// the ones not in the default output are only rendered by "all", "everything" or by name
+var infoSections = []struct {
+	name      string
+	byDefault bool
+	render    func(r *Redis, b *strings.Builder)
+}{
+	{"server", true, (*Redis).infoServer},
+	{"clients", true, (*Redis).infoClients},
+	{"memory", true, (*Redis).infoMemory},
+	{"persistence", true, (*Redis).infoPersistence},
+	{"stats", true, (*Redis).infoStats},
+	{"replication", true, (*Redis).infoReplication},
+	{"commandstats", false, (*Redis).infoCommandStats},
+	{"keyspace", true, (*Redis).infoKeyspace},
+}
+
+// Info reports the state and the counters of the server
+// `INFO [section [section ...]]`
+func Info(db interfaces.DB, args [][]byte) protocol.Reply {
+	redis, _ := db.(*Redis)
+
+	selected := make(map[string]bool, len(args))
+	all, defaults := false, len(args) == 0
+	for _, arg := range args {
+		switch section := strings.ToLower(string(arg)); section {
+		case "all", "everything":
+			all = true
+		case "default":
+			defaults = true
+		default:
+			selected[section] = true
+		}
+	}
+
+	var b strings.Builder
+	for _, section := range infoSections {
+		if !all && !selected[section.name] && !(defaults && section.byDefault) {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString("# " + strings.ToUpper(section.name[:1]) + section.name[1:] + "\r\n")
+		section.render(redis, &b)
+	}
+	return protocol.MakeBulkReply([]byte(b.String()))
+}
+
+// infoField writes a line of INFO
+func infoField(b *strings.Builder, name string, value any) {
+	fmt.Fprintf(b, "%s:%v\r\n", name, value)
+}
+
+func (r *Redis) infoServer(b *strings.Builder) {
+	props := r.Config()
+	uptime := time.Since(r.stats.StartTime)
+	executable, _ := os.Executable()
+	infoField(b, "redis_version", RedisVersion)
+	infoField(b, "redis_mode", "standalone")
+	infoField(b, "os", runtime.GOOS+" "+runtime.GOARCH)
+	infoField(b, "arch_bits", strconv.IntSize)
+	infoField(b, "go_version", runtime.Version())
+	infoField(b, "process_id", os.Getpid())
+	infoField(b, "run_id", r.stats.RunID)
+	infoField(b, "tcp_port", props.Port)
+	infoField(b, "server_time_usec", time.Now().UnixMicro())
+	infoField(b, "uptime_in_seconds", int64(uptime.Seconds()))
+	infoField(b, "uptime_in_days", int64(uptime.Hours()/24))
+	infoField(b, "executable", executable)
+	infoField(b, "config_file", props.ConfigFile)
+}
+
+func (r *Redis) infoClients(b *strings.Builder) {
+	pubsubClients := 0
+	r.clients.Range(func(_, value any) bool {
+		if value.(interfaces.Connection).SubsCount() > 0 {
+			pubsubClients++
+		}
+		return true
+	})
+	infoField(b, "connected_clients", r.stats.ConnectedClients.Load())
+	infoField(b, "blocked_clients", r.blocking.clients())
+	infoField(b, "pubsub_clients", pubsubClients)
+	infoField(b, "tracking_clients", r.tracking.Clients())
+}
+
+func (r *Redis) infoMemory(b *strings.Builder) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	maxMemory := r.Config().MaxMemory
+	// the heap of the Go runtime stands for the memory used by the data
+	infoField(b, "used_memory", mem.HeapAlloc)
+	infoField(b, "used_memory_human", humanBytes(int64(mem.HeapAlloc)))
+	infoField(b, "used_memory_rss", mem.Sys)
+	infoField(b, "used_memory_rss_human", humanBytes(int64(mem.Sys)))
+	infoField(b, "maxmemory", maxMemory)
+	infoField(b, "maxmemory_human", humanBytes(maxMemory))
+	infoField(b, "maxmemory_policy", "noeviction")
+}
+
+func (r *Redis) infoPersistence(b *strings.Builder) {
+	aofEnabled := 0
+	if r.Config().AppendOnly {
+		aofEnabled = 1
+	}
+	infoField(b, "loading", 0)
+	infoField(b, "aof_enabled", aofEnabled)
+}
+
+func (r *Redis) infoStats(b *strings.Builder) {
+	s := r.stats
+	channels, patterns, shardChannels := r.hub.Counts()
+	infoField(b, "total_connections_received", s.ConnectionsReceived.Load())
+	infoField(b, "total_commands_processed", s.CommandsProcessed.Load())
+	infoField(b, "total_net_input_bytes", s.NetInputBytes.Load())
+	infoField(b, "total_net_output_bytes", s.NetOutputBytes.Load())
+	infoField(b, "rejected_connections", s.RejectedConnections.Load())
+	infoField(b, "expired_keys", s.ExpiredKeys.Load())
+	infoField(b, "evicted_keys", s.EvictedKeys.Load())
+	infoField(b, "keyspace_hits", s.KeyspaceHits.Load())
+	infoField(b, "keyspace_misses", s.KeyspaceMisses.Load())
+	infoField(b, "pubsub_channels", channels)
+	infoField(b, "pubsub_patterns", patterns)
+	infoField(b, "pubsubshard_channels", shardChannels)
+	infoField(b, "total_error_replies", s.ErrorReplies.Load())
+}
+
+func (r *Redis) infoReplication(b *strings.Builder) {
+	infoField(b, "role", "master")
+	infoField(b, "connected_slaves", 0)
+}
+
+func (r *Redis) infoCommandStats(b *strings.Builder) {
+	for _, name := range r.stats.CommandNames() {
+		c := r.stats.Command(name)
+		calls, usec := c.Calls.Load(), c.Usec.Load()
+		perCall := 0.0
+		if calls > 0 {
+			perCall = float64(usec) / float64(calls)
+		}
+		fmt.Fprintf(b, "cmdstat_%s:calls=%d,usec=%d,usec_per_call=%.2f,rejected_calls=%d,failed_calls=%d\r\n",
+			name, calls, usec, perCall, c.RejectedCalls.Load(), c.FailedCalls.Load())
+	}
+}
+
+// infoKeyspace reports the single keyspace of godis as db0, keys don't expire
+func (r *Redis) infoKeyspace(b *strings.Builder) {
+	if keys := r.data.Len(); keys > 0 {
+		fmt.Fprintf(b, "db0:keys=%d,expires=0,avg_ttl=0\r\n", keys)
+	}
+}
+
+// humanBytes formats n like the *_human fields of Redis, e.g. 1.50M
+func humanBytes(n int64) string {
+	const units = "BKMGTPE"
+	value, unit := float64(n), 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return strconv.FormatInt(n, 10) + "B"
+	}
+	return strconv.FormatFloat(value, 'f', 2, 64) + units[unit:unit+1]
+}
@@ -0,0 +1,84 @@
+package db
+
+import (
+	"godis/interfaces"
+	"godis/lib/utils"
+	"godis/redis/protocol"
+	"strconv"
+	"strings"
+)
+
+// typeNames are the names of the types of the values, as TYPE and SCAN ... TYPE show them
+var typeNames = map[int]string{
+	TypeString: "string",
+	TypeList:   "list",
+	TypeHash:   "hash",
+	TypeSet:    "set",
+	TypeZset:   "zset",
+	TypeStream: "stream",
+}
+
+// Scan iterates the keys, the cursor is the position of the next key to scan, see ShardedMap.Scan
+// a call looks at about COUNT keys, those returned are the ones matching the options
+// `SCAN cursor [MATCH pattern] [COUNT count] [TYPE type]`
+func Scan(db interfaces.DB, args [][]byte) protocol.Reply {
+	if len(args) < 1 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for 'scan' command")
+	}
+
+	redis, _ := db.(*Redis)
+	cursor, err := strconv.ParseUint(string(args[0]), 10, 32)
+	if err != nil {
+		return protocol.MakeErrReply("ERR invalid cursor")
+	}
+	var pattern *utils.Pattern
+	count := 10
+	typeName := ""
+	for i := 1; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return protocol.MakeErrReply("ERR syntax error")
+		}
+		value := string(args[i+1])
+		switch strings.ToUpper(string(args[i])) {
+		case "MATCH":
+			pattern, err = utils.CompilePattern(value)
+			if err != nil {
+				return protocol.MakeErrReply("ERR invalid pattern")
+			}
+		case "COUNT":
+			count, err = strconv.Atoi(value)
+			if err != nil {
+				return protocol.MakeErrReply("ERR value is not an integer or out of range")
+			}
+			if count < 1 {
+				return protocol.MakeErrReply("ERR syntax error")
+			}
+		case "TYPE":
+			typeName = strings.ToLower(value)
+			known := false
+			for _, name := range typeNames {
+				known = known || name == typeName
+			}
+			if !known {
+				return protocol.MakeErrReply("ERR unknown type name '" + value + "'")
+			}
+		default:
+			return protocol.MakeErrReply("ERR syntax error")
+		}
+	}
+
+	var keys [][]byte
+	next := redis.data.Scan(uint32(cursor), count, func(key string, val any) {
+		if pattern != nil && !pattern.IsMatch(key) {
+			return
+		}
+		if entity, ok := val.(*DataEntity); typeName != "" && (!ok || typeNames[entity.Type] != typeName) {
+			return
+		}
+		keys = append(keys, []byte(key))
+	})
+	return protocol.MakeMultiRawReply([]protocol.Reply{
+		protocol.MakeBulkReply([]byte(strconv.FormatUint(uint64(next), 10))),
+		protocol.MakeMultiBulkReply(keys),
+	})
+}
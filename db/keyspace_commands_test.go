@@ -0,0 +1,62 @@
+package db
+
+import (
+	"godis/lib/utils"
+	"godis/redis/protocol"
+	"strconv"
+	"testing"
+)
+
+// scanAll runs SCAN with args until the cursor is 0, and counts the keys returned
+func scanAll(t *testing.T, db *Redis, args ...string) map[string]int {
+	t.Helper()
+	seen := make(map[string]int)
+	cursor := "0"
+	for {
+		reply, ok := Scan(db, utils.ToCmdLine(append([]string{cursor}, args...)...)).(*protocol.MultiRawReply)
+		if !ok || len(reply.Replies) != 2 {
+			t.Fatalf("unexpected reply %v", reply)
+		}
+		cursor = string(reply.Replies[0].(*protocol.BulkReply).Arg)
+		for _, key := range reply.Replies[1].(*protocol.MultiBulkReply).Args {
+			seen[string(key)]++
+		}
+		if cursor == "0" {
+			return seen
+		}
+	}
+}
+
+func TestScan(t *testing.T) {
+	db := NewStandAloneDb()
+	for i := 0; i < 100; i++ {
+		Set(db, utils.ToCmdLine("key:"+strconv.Itoa(i), "v"))
+	}
+	SAdd(db, utils.ToCmdLine("set", "a"))
+
+	// every key is returned once
+	seen := scanAll(t, db, "COUNT", "5")
+	if len(seen) != 101 {
+		t.Errorf("expected 101 keys, got %d", len(seen))
+	}
+	for key, n := range seen {
+		if n != 1 {
+			t.Errorf("%s returned %d times", key, n)
+		}
+	}
+
+	seen = scanAll(t, db, "MATCH", "key:1?")
+	if len(seen) != 10 || seen["key:10"] != 1 {
+		t.Errorf("expected key:10 to key:19, got %v", seen)
+	}
+	seen = scanAll(t, db, "TYPE", "set")
+	if len(seen) != 1 || seen["set"] != 1 {
+		t.Errorf("expected set, got %v", seen)
+	}
+
+	for _, args := range [][]string{{"-1"}, {"x"}, {"0", "COUNT", "0"}, {"0", "TYPE", "nope"}, {"0", "MATCH"}} {
+		if _, ok := Scan(db, utils.ToCmdLine(args...)).(protocol.ErrorReply); !ok {
+			t.Errorf("expected an error for %v", args)
+		}
+	}
+}
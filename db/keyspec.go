@@ -0,0 +1,66 @@
+package db
+
+import (
+	"strconv"
+	"strings"
+)
+
+// flags of commands
+const (
+	flagWrite = 1 << iota
+	flagReadOnly
+	// may wait for other clients, the replies buffered before are flushed first
+	flagBlocking
+)
+
+// keysFunc finds the keys in the arguments of a command, the command name excluded
+type keysFunc func(args [][]byte) [][]byte
+
+// keyRange returns the keys at first, first+step... up to last, like the key specs of Redis
+// a negative last counts from the end, -1 being the last argument
+func keyRange(first int, last int, step int) keysFunc {
+	return func(args [][]byte) [][]byte {
+		end := last
+		if end < 0 {
+			end += len(args)
+		}
+		if end >= len(args) {
+			end = len(args) - 1
+		}
+		var keys [][]byte
+		for i := first; i <= end; i += step {
+			keys = append(keys, args[i])
+		}
+		return keys
+	}
+}
+
+var (
+	firstKey = keyRange(0, 0, 1)
+	allKeys  = keyRange(0, -1, 1)
+)
+
+// numKeysAt returns the keys following the numkeys argument at i, e.g. `ZUNION numkeys key [key ...]`
+func numKeysAt(i int) keysFunc {
+	return func(args [][]byte) [][]byte {
+		if i >= len(args) {
+			return nil
+		}
+		n, err := strconv.Atoi(string(args[i]))
+		if err != nil || n < 0 || i+1+n > len(args) {
+			return nil
+		}
+		return args[i+1 : i+1+n]
+	}
+}
+
+// streamKeys returns the keys of `... STREAMS key [key ...] id [id ...]`
+func streamKeys(args [][]byte) [][]byte {
+	for i, arg := range args {
+		if strings.ToUpper(string(arg)) == "STREAMS" {
+			rest := args[i+1:]
+			return rest[:len(rest)/2]
+		}
+	}
+	return nil
+}
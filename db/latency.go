@@ -0,0 +1,293 @@
+package db
+
+import (
+	"fmt"
+	"godis/interfaces"
+	"godis/redis/protocol"
+	"godis/stats"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// latencyHistoryLen is the number of samples kept by event, at most one by second
+const latencyHistoryLen = 160
+
+type latencySample struct {
+	// unix time in seconds, 0 for an unused sample
+	time int64
+	// milliseconds
+	latency int64
+}
+
+// latencyEvent keeps the latest samples of an event in a ring
+type latencyEvent struct {
+	samples [latencyHistoryLen]latencySample
+	next    int
+	// highest latency ever observed
+	max int64
+}
+
+// history returns the samples, oldest first
+func (e *latencyEvent) history() []latencySample {
+	var history []latencySample
+	for i := 0; i < latencyHistoryLen; i++ {
+		if sample := e.samples[(e.next+i)%latencyHistoryLen]; sample.time != 0 {
+			history = append(history, sample)
+		}
+	}
+	return history
+}
+
+// latencyMonitor records the operations taking longer than latency-monitor-threshold, by event:
+// "command" and "fast-command" for the commands, see monitorLatency, and "aof-write" and "aof-fsync-always"
+// for the append only file
+// the other events of Redis are missing as godis doesn't do what they measure: keys don't expire and
+// aren't evicted ("expire-cycle", "eviction-cycle", "eviction-del"), nothing is forked ("fork",
+// "rdb-unlink-temp-file"), the append only file isn't rewritten nor written by a background thread
+// ("aof-rewrite-*", "aof-write-*", "aof-fstat", "aof-rename") and memory isn't defragmented
+// ("active-defrag-cycle")
+type latencyMonitor struct {
+	mu     sync.Mutex
+	events map[string]*latencyEvent
+}
+
+// add records a sample of event at now, the highest latency is kept for samples of the same second
+func (m *latencyMonitor) add(event string, latency time.Duration, now time.Time) {
+	ms := latency.Milliseconds()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.events == nil {
+		m.events = make(map[string]*latencyEvent)
+	}
+	e, ok := m.events[event]
+	if !ok {
+		e = &latencyEvent{}
+		m.events[event] = e
+	}
+	e.max = max(e.max, ms)
+	last := &e.samples[(e.next+latencyHistoryLen-1)%latencyHistoryLen]
+	if last.time == now.Unix() {
+		last.latency = max(last.latency, ms)
+		return
+	}
+	e.samples[e.next] = latencySample{time: now.Unix(), latency: ms}
+	e.next = (e.next + 1) % latencyHistoryLen
+}
+
+// names returns the names of the events recorded, sorted
+func (m *latencyMonitor) names() []string {
+	names := make([]string, 0, len(m.events))
+	for name := range m.events {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// monitorLatency records the call of a command in the latency monitor if it ran for long enough
+// like for the slow log, the blocking commands aren't recorded
+// Redis records the commands flagged as O(1) as "fast-command", godis doesn't flag them and splits the calls
+// by slowlog-log-slower-than instead: the calls the slow log records are "command", the others "fast-command",
+// every call is "command" while the slow log is disabled
+func (r *Redis) monitorLatency(cmdName string, start time.Time, duration time.Duration) {
+	props := r.Config()
+	threshold := props.LatencyMonitorThreshold
+	if threshold == 0 || duration.Milliseconds() < int64(threshold) || isBlocking(cmdName) {
+		return
+	}
+	event := "command"
+	if props.SlowlogLogSlowerThan >= 0 && duration.Microseconds() < int64(props.SlowlogLogSlowerThan) {
+		event = "fast-command"
+	}
+	r.latency.add(event, duration, start)
+}
+
+// monitorEvent records an internal operation which started at start in the latency monitor
+// if it ran for long enough
+func (r *Redis) monitorEvent(event string, start time.Time, duration time.Duration) {
+	threshold := r.Config().LatencyMonitorThreshold
+	if threshold == 0 || duration.Milliseconds() < int64(threshold) {
+		return
+	}
+	r.latency.add(event, duration, start)
+}
+
+// Latency reads and resets the latency monitor, and the latency histograms of the commands
+// `LATENCY LATEST` / `LATENCY HISTORY event` / `LATENCY RESET [event ...]` / `LATENCY DOCTOR`
+// `LATENCY HISTOGRAM [command ...]`
+func Latency(db interfaces.DB, args [][]byte) protocol.Reply {
+	if len(args) < 1 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for 'latency' command")
+	}
+
+	redis, _ := db.(*Redis)
+	m := &redis.latency
+	switch strings.ToUpper(string(args[0])) {
+	case "LATEST":
+		if len(args) != 1 {
+			return protocol.MakeErrReply("ERR wrong number of arguments for 'latency|latest' command")
+		}
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		var replies []protocol.Reply
+		for _, name := range m.names() {
+			e := m.events[name]
+			last := e.samples[(e.next+latencyHistoryLen-1)%latencyHistoryLen]
+			replies = append(replies, protocol.MakeMultiRawReply([]protocol.Reply{
+				protocol.MakeBulkReply([]byte(name)),
+				protocol.MakeIntReply(last.time),
+				protocol.MakeIntReply(last.latency),
+				protocol.MakeIntReply(e.max),
+			}))
+		}
+		return protocol.MakeMultiRawReply(replies)
+	case "HISTORY":
+		if len(args) != 2 {
+			return protocol.MakeErrReply("ERR wrong number of arguments for 'latency|history' command")
+		}
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		var replies []protocol.Reply
+		if e, ok := m.events[string(args[1])]; ok {
+			for _, sample := range e.history() {
+				replies = append(replies, protocol.MakeMultiRawReply([]protocol.Reply{
+					protocol.MakeIntReply(sample.time),
+					protocol.MakeIntReply(sample.latency),
+				}))
+			}
+		}
+		return protocol.MakeMultiRawReply(replies)
+	case "RESET":
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if len(args) == 1 {
+			n := len(m.events)
+			clear(m.events)
+			return protocol.MakeIntReply(int64(n))
+		}
+		n := 0
+		for _, arg := range args[1:] {
+			if _, ok := m.events[string(arg)]; ok {
+				delete(m.events, string(arg))
+				n++
+			}
+		}
+		return protocol.MakeIntReply(int64(n))
+	case "DOCTOR":
+		if len(args) != 1 {
+			return protocol.MakeErrReply("ERR wrong number of arguments for 'latency|doctor' command")
+		}
+		return protocol.MakeVerbatimReply("txt", []byte(redis.latencyReport()))
+	case "HISTOGRAM":
+		return redis.latencyHistograms(args[1:])
+	default:
+		return protocol.MakeErrReply("ERR unknown subcommand '" + string(args[0]) + "'. Try LATENCY HELP.")
+	}
+}
+
+// latencyReport describes the events recorded, like LATENCY DOCTOR of Redis
+func (r *Redis) latencyReport() string {
+	if r.Config().LatencyMonitorThreshold == 0 {
+		return "I'm sorry, Dave, I can't do that. Latency monitoring is disabled in this Redis instance. " +
+			"You may use \"CONFIG SET latency-monitor-threshold <milliseconds>.\" in order to enable it.\n"
+	}
+
+	m := &r.latency
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.events) == 0 {
+		return "Dave, no latency spike was observed during the lifetime of this Redis instance, not in the slightest bit. " +
+			"I honestly think you ought to sleep tonight.\n"
+	}
+
+	var b strings.Builder
+	b.WriteString("Dave, I have observed latency spikes in this Redis instance. You don't mind talking about it, do you Dave?\n\n")
+	for i, name := range m.names() {
+		e := m.events[name]
+		history := e.history()
+		sum := int64(0)
+		for _, sample := range history {
+			sum += sample.latency
+		}
+		avg := float64(sum) / float64(len(history))
+		deviation := 0.0
+		for _, sample := range history {
+			deviation += math.Abs(float64(sample.latency) - avg)
+		}
+		deviation /= float64(len(history))
+		period := 0.0
+		if len(history) > 1 {
+			period = float64(history[len(history)-1].time-history[0].time) / float64(len(history)-1)
+		}
+		fmt.Fprintf(&b, "%d. %s: %d latency spikes (average %.0fms, mean deviation %.0fms, period %.2f sec). Worst all time event %dms.\n",
+			i+1, name, len(history), avg, deviation, period, e.max)
+	}
+
+	b.WriteString("\nI have a few advices for you:\n\n")
+	_, slow := m.events["command"]
+	_, fast := m.events["fast-command"]
+	if slow {
+		b.WriteString("- Check your Slow Log to understand what are the commands you are running which are too slow to execute. " +
+			"Please check https://redis.io/commands/slowlog for more information.\n")
+	}
+	if fast {
+		b.WriteString("- Some commands faster than slowlog-log-slower-than had latency spikes anyway, " +
+			"this may come from the system rather than from the commands, like the process being swapped out.\n")
+	}
+	if slow || fast {
+		b.WriteString("- The latency of the commands is shown by LATENCY HISTOGRAM, by command.\n")
+	}
+	internal := false
+	for name := range m.events {
+		internal = internal || (name != "command" && name != "fast-command")
+	}
+	if internal {
+		b.WriteString("- The events other than command come from internal operations, like writing the append only file.\n")
+	}
+	return b.String()
+}
+
+// latencyHistograms returns the calls of the commands named, or of every command called, by duration:
+// the calls of at most each power of two of microseconds
+func (r *Redis) latencyHistograms(args [][]byte) protocol.Reply {
+	var names []string
+	if len(args) == 0 {
+		names = r.stats.CommandNames()
+	}
+	seen := make(map[string]bool, len(args))
+	for _, arg := range args {
+		name := strings.ToLower(string(arg))
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	var pairs []protocol.Reply
+	for _, name := range names {
+		c, ok := r.stats.LoadCommand(name)
+		if !ok || c.Calls.Load() == 0 {
+			continue
+		}
+		// cumulative counts of the buckets adding calls, like the HDR histograms of Redis
+		var buckets []protocol.Reply
+		total := int64(0)
+		for i := 0; i < stats.LatencyBuckets; i++ {
+			if n := c.Latency[i].Load(); n > 0 {
+				total += n
+				buckets = append(buckets, protocol.MakeIntReply(int64(1)<<i), protocol.MakeIntReply(total))
+			}
+		}
+		pairs = append(pairs,
+			protocol.MakeBulkReply([]byte(name)),
+			protocol.MakeMapReply([]protocol.Reply{
+				protocol.MakeBulkReply([]byte("calls")), protocol.MakeIntReply(c.Calls.Load()),
+				protocol.MakeBulkReply([]byte("histogram_usec")), protocol.MakeMapReply(buckets),
+			}))
+	}
+	return protocol.MakeMapReply(pairs)
+}
@@ -0,0 +1,106 @@
+package db
+
+import (
+	"godis/lib/utils"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLatencyEvents(t *testing.T) {
+	db := NewStandAloneDb()
+	now := time.Unix(1000, 0)
+	db.latency.add("command", 20*time.Millisecond, now)
+	// the highest latency of a second is kept
+	db.latency.add("command", 50*time.Millisecond, now.Add(100*time.Millisecond))
+	db.latency.add("command", 10*time.Millisecond, now.Add(200*time.Millisecond))
+	db.latency.add("command", 30*time.Millisecond, now.Add(2*time.Second))
+	db.latency.add("aof-fsync-always", 5*time.Millisecond, now)
+
+	expected := "*2\r\n*4\r\n$16\r\naof-fsync-always\r\n:1000\r\n:5\r\n:5\r\n*4\r\n$7\r\ncommand\r\n:1002\r\n:30\r\n:50\r\n"
+	if got := string(Latency(db, utils.ToCmdLine("LATEST")).ToBytes()); got != expected {
+		t.Errorf("LATEST: expected %q, got %q", expected, got)
+	}
+	expected = "*2\r\n*2\r\n:1000\r\n:50\r\n*2\r\n:1002\r\n:30\r\n"
+	if got := string(Latency(db, utils.ToCmdLine("HISTORY", "command")).ToBytes()); got != expected {
+		t.Errorf("HISTORY: expected %q, got %q", expected, got)
+	}
+
+	// the oldest samples are dropped
+	for i := 0; i < latencyHistoryLen; i++ {
+		db.latency.add("command", time.Millisecond, now.Add(time.Duration(10+i)*time.Second))
+	}
+	history := db.latency.events["command"].history()
+	if len(history) != latencyHistoryLen || history[0].time != 1010 || db.latency.events["command"].max != 50 {
+		t.Errorf("unexpected history of %d samples from %d", len(history), history[0].time)
+	}
+
+	if got := string(Latency(db, utils.ToCmdLine("DOCTOR")).ToBytes()); !strings.Contains(got, "Latency monitoring is disabled") {
+		t.Errorf("unexpected report %q", got)
+	}
+	props := db.Config().Clone()
+	props.LatencyMonitorThreshold = 1
+	db.useConfig(props)
+	report := string(Latency(db, utils.ToCmdLine("DOCTOR")).ToBytes())
+	if !strings.Contains(report, "1. aof-fsync-always: 1 latency spikes (average 5ms, mean deviation 0ms, period 0.00 sec). Worst all time event 5ms.") ||
+		!strings.Contains(report, "2. command: 160 latency spikes") {
+		t.Errorf("unexpected report %q", report)
+	}
+
+	if got := string(Latency(db, utils.ToCmdLine("RESET", "command", "nosuchevent")).ToBytes()); got != ":1\r\n" {
+		t.Errorf("unexpected reply %q", got)
+	}
+	if got := string(Latency(db, utils.ToCmdLine("RESET")).ToBytes()); got != ":1\r\n" {
+		t.Errorf("unexpected reply %q", got)
+	}
+	if got := string(Latency(db, utils.ToCmdLine("LATEST")).ToBytes()); got != "*0\r\n" {
+		t.Errorf("unexpected reply %q", got)
+	}
+}
+
+func TestLatencyHistogram(t *testing.T) {
+	db := NewStandAloneDb()
+	db.stats.Record("set", time.Microsecond, false)
+	db.stats.Record("set", 3*time.Microsecond, false)
+	db.stats.Record("set", 4*time.Microsecond, false)
+	db.stats.Record("set", 1500*time.Microsecond, false)
+	db.stats.Record("get", 2*time.Microsecond, false)
+
+	expected := "*2\r\n$3\r\nset\r\n*4\r\n$5\r\ncalls\r\n:4\r\n$14\r\nhistogram_usec\r\n*6\r\n:1\r\n:1\r\n:4\r\n:3\r\n:2048\r\n:4\r\n"
+	if got := string(Latency(db, utils.ToCmdLine("HISTOGRAM", "SET", "set", "nosuchcommand")).ToBytes()); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+	if got := string(Latency(db, utils.ToCmdLine("HISTOGRAM")).ToBytes()); !strings.HasPrefix(got, "*4\r\n$3\r\nget\r\n") {
+		t.Errorf("unexpected histograms %q", got)
+	}
+}
+
+func TestMonitorLatencyEvents(t *testing.T) {
+	db := NewStandAloneDb()
+	props := db.Config().Clone()
+	props.LatencyMonitorThreshold = 1
+	props.SlowlogLogSlowerThan = 100000
+	db.useConfig(props)
+
+	now := time.Unix(1000, 0)
+	db.monitorLatency("get", now, 5*time.Millisecond)
+	db.monitorLatency("sunion", now, 200*time.Millisecond)
+	// below latency-monitor-threshold
+	db.monitorLatency("get", now.Add(time.Second), time.Microsecond)
+	db.monitorLatency("bzpopmin", now.Add(time.Second), time.Second)
+	if fast, slow := db.latency.events["fast-command"], db.latency.events["command"]; fast == nil || fast.max != 5 || slow == nil || slow.max != 200 || len(db.latency.events) != 2 {
+		t.Fatalf("unexpected events %v", db.latency.names())
+	}
+	if got := string(Latency(db, utils.ToCmdLine("DOCTOR")).ToBytes()); !strings.Contains(got, "faster than slowlog-log-slower-than") {
+		t.Errorf("unexpected report %q", got)
+	}
+
+	// every call is a command without the slow log
+	props = db.Config().Clone()
+	props.SlowlogLogSlowerThan = -1
+	db.useConfig(props)
+	db.monitorLatency("get", now.Add(2*time.Second), 7*time.Millisecond)
+	if history := db.latency.events["command"].history(); len(history) != 2 || history[1].latency != 7 {
+		t.Errorf("unexpected history %v", history)
+	}
+}
@@ -51,6 +51,7 @@ func LPush(db interfaces.DB, args [][]byte) protocol.Reply {
 	for i := 0; i < len(values); i++ {
 		list.InsertAt(0, values[i])
 	}
+	redis.notifyKeyspaceEvent(notifyList, "lpush", key)
 	return protocol.MakeIntReply(int64(list.Len()))
 }
 
@@ -73,6 +74,7 @@ func RPush(db interfaces.DB, args [][]byte) protocol.Reply {
 	for _, value := range values {
 		list.InsertAt(list.Len(), value)
 	}
+	redis.notifyKeyspaceEvent(notifyList, "rpush", key)
 	return protocol.MakeIntReply(int64(list.Len()))
 }
 
@@ -104,6 +106,7 @@ func LPop(db interfaces.DB, args [][]byte) protocol.Reply {
 	if value == nil {
 		return protocol.MakeNullBulkReply()
 	}
+	redis.notifyKeyspaceEvent(notifyList, "lpop", key)
 	return protocol.MakeBulkReply(value)
 }
 
@@ -135,6 +138,7 @@ func RPop(db interfaces.DB, args [][]byte) protocol.Reply {
 	if value == nil {
 		return protocol.MakeNullBulkReply()
 	}
+	redis.notifyKeyspaceEvent(notifyList, "rpop", key)
 	return protocol.MakeBulkReply(value)
 }
 
@@ -0,0 +1,33 @@
+package db
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+// keyLocks serializes read-modify-write sequences on the same key
+// e.g. popping the last member of a sorted set and then removing the key
+// keys are hashed onto a fixed table of mutexes
+type keyLocks struct {
+	table []sync.Mutex
+}
+
+func newKeyLocks(size int) *keyLocks {
+	return &keyLocks{
+		table: make([]sync.Mutex, size),
+	}
+}
+
+func (l *keyLocks) locate(key string) *sync.Mutex {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return &l.table[h.Sum32()%uint32(len(l.table))]
+}
+
+func (l *keyLocks) Lock(key string) {
+	l.locate(key).Lock()
+}
+
+func (l *keyLocks) Unlock(key string) {
+	l.locate(key).Unlock()
+}
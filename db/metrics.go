@@ -0,0 +1,111 @@
+package db
+
+import (
+	"godis/metrics"
+	"godis/stats"
+	"runtime"
+	"sort"
+	"time"
+)
+
+// latencyBounds are the buckets of Command.Latency reported by the command duration histograms:
+// every other power of two of microseconds, from 8µs to about 8.6s
+var latencyBounds = []int{3, 5, 7, 9, 11, 13, 15, 17, 19, 21, 23}
+
+// Collect writes the metrics served on /metrics
+func (r *Redis) Collect(w *metrics.Writer) {
+	s := r.stats
+	w.Gauge("godis_uptime_seconds", "Seconds since the server started.", time.Since(s.StartTime).Seconds())
+
+	// clients
+	w.Gauge("godis_connected_clients", "Clients connected.", float64(s.ConnectedClients.Load()))
+	w.Gauge("godis_blocked_clients", "Clients blocked by commands like BLPOP.", float64(r.blocking.clients()))
+	w.Gauge("godis_tracking_clients", "Clients tracking keys.", float64(r.tracking.Clients()))
+	w.Counter("godis_connections_received_total", "Connections accepted.", float64(s.ConnectionsReceived.Load()))
+	w.Counter("godis_rejected_connections_total", "Connections refused.", float64(s.RejectedConnections.Load()))
+	w.Counter("godis_net_input_bytes_total", "Bytes read from clients.", float64(s.NetInputBytes.Load()))
+	w.Counter("godis_net_output_bytes_total", "Bytes written to clients.", float64(s.NetOutputBytes.Load()))
+
+	// keyspace, godis has a single database
+	w.Metric("godis_db_keys", "gauge", "Keys of each database.")
+	w.Sample("godis_db_keys", float64(r.data.Len()), "db", "db0")
+	w.Counter("godis_keyspace_hits_total", "Keys found by read-only commands.", float64(s.KeyspaceHits.Load()))
+	w.Counter("godis_keyspace_misses_total", "Keys not found by read-only commands.", float64(s.KeyspaceMisses.Load()))
+	w.Counter("godis_expired_keys_total", "Keys expired.", float64(s.ExpiredKeys.Load()))
+	w.Counter("godis_evicted_keys_total", "Keys evicted.", float64(s.EvictedKeys.Load()))
+	channels, patterns, shardChannels := r.hub.Counts()
+	w.Gauge("godis_pubsub_channels", "Channels with subscribers.", float64(channels))
+	w.Gauge("godis_pubsub_patterns", "Patterns with subscribers.", float64(patterns))
+	w.Gauge("godis_pubsub_shard_channels", "Shard channels with subscribers.", float64(shardChannels))
+
+	// persistence
+	props := r.Config()
+	aofEnabled := 0.0
+	if props.AppendOnly {
+		aofEnabled = 1
+	}
+	w.Gauge("godis_aof_enabled", "Whether the append only file is enabled.", aofEnabled)
+	w.Gauge("godis_loading", "Whether the data is being loaded.", 0)
+
+	// memory, the heap of the Go runtime stands for the memory used by the data
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	w.Gauge("godis_memory_used_bytes", "Bytes of heap allocated.", float64(mem.HeapAlloc))
+	w.Gauge("godis_memory_sys_bytes", "Bytes of memory obtained from the system.", float64(mem.Sys))
+	w.Gauge("godis_memory_max_bytes", "The maxmemory limit, 0 for none.", float64(props.MaxMemory))
+
+	// commands
+	w.Counter("godis_commands_processed_total", "Commands run.", float64(s.CommandsProcessed.Load()))
+	w.Counter("godis_error_replies_total", "Error replies sent.", float64(s.ErrorReplies.Load()))
+	r.collectCommands(w)
+}
+
+// collectCommands writes the metrics of every command of CommandMap, including the ones never called
+func (r *Redis) collectCommands(w *metrics.Writer) {
+	names := make([]string, 0, len(CommandMap))
+	for name := range CommandMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bounds := make([]float64, len(latencyBounds))
+	for i, exp := range latencyBounds {
+		bounds[i] = float64(int64(1)<<exp) / 1e6
+	}
+	w.Metric("godis_command_duration_seconds", "histogram", "Duration of the calls of each command.")
+	for _, name := range names {
+		counts := make([]int64, len(latencyBounds)+1)
+		sum := 0.0
+		if c, ok := r.stats.LoadCommand(name); ok {
+			// cumulative counts of the buckets reported, the last one counts every call
+			total, next := int64(0), 0
+			for i := 0; i < stats.LatencyBuckets; i++ {
+				total += c.Latency[i].Load()
+				if next < len(latencyBounds) && i == latencyBounds[next] {
+					counts[next] = total
+					next++
+				}
+			}
+			counts[len(counts)-1] = total
+			sum = float64(c.Usec.Load()) / 1e6
+		}
+		w.Histogram("godis_command_duration_seconds", bounds, counts, sum, "cmd", name)
+	}
+
+	w.Metric("godis_command_failed_calls_total", "counter", "Calls of each command replying an error.")
+	for _, name := range names {
+		failed := int64(0)
+		if c, ok := r.stats.LoadCommand(name); ok {
+			failed = c.FailedCalls.Load()
+		}
+		w.Sample("godis_command_failed_calls_total", float64(failed), "cmd", name)
+	}
+	w.Metric("godis_command_rejected_calls_total", "counter", "Calls of each command refused before running.")
+	for _, name := range names {
+		rejected := int64(0)
+		if c, ok := r.stats.LoadCommand(name); ok {
+			rejected = c.RejectedCalls.Load()
+		}
+		w.Sample("godis_command_rejected_calls_total", float64(rejected), "cmd", name)
+	}
+}
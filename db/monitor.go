@@ -0,0 +1,118 @@
+package db
+
+import (
+	"fmt"
+	"godis/interfaces"
+	"godis/redis/protocol"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// unmonitoredCommands aren't shown to monitors, like the admin commands of Redis,
+// AUTH, HELLO and CONFIG SET may hold passwords
+var unmonitoredCommands = map[string]bool{
+	"auth":    true,
+	"hello":   true,
+	"config":  true,
+	"slowlog": true,
+	"latency": true,
+	"monitor": true,
+}
+
+// monitors are the connections which ran MONITOR, they're shown every command executed
+type monitors struct {
+	mu    sync.RWMutex
+	conns map[int64]interfaces.Connection
+	// checked before taking the lock, most of the time no one is monitoring
+	count atomic.Int32
+}
+
+func (m *monitors) add(conn interfaces.Connection) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.conns == nil {
+		m.conns = make(map[int64]interfaces.Connection)
+	}
+	m.conns[conn.ID()] = conn
+	m.count.Store(int32(len(m.conns)))
+}
+
+func (m *monitors) remove(conn interfaces.Connection) {
+	if m.count.Load() == 0 {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.conns, conn.ID())
+	m.count.Store(int32(len(m.conns)))
+}
+
+// feed shows a command of conn started at start to the monitors
+// lines are pushed without waiting, a monitor too slow to read them is disconnected
+func (m *monitors) feed(conn interfaces.Connection, cmdL [][]byte, start time.Time) {
+	if m.count.Load() == 0 {
+		return
+	}
+	if unmonitoredCommands[strings.ToLower(string(cmdL[0]))] {
+		return
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "+%d.%06d [0 %s]", start.Unix(), start.Nanosecond()/1000, conn.RemoteAddr())
+	for _, arg := range cmdL {
+		b.WriteByte(' ')
+		writeRepr(&b, arg)
+	}
+	b.WriteString("\r\n")
+	line := []byte(b.String())
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, monitor := range m.conns {
+		monitor.Push(line)
+	}
+}
+
+// writeRepr writes arg quoted and escaped like the arguments shown by MONITOR in Redis
+func writeRepr(b *strings.Builder, arg []byte) {
+	b.WriteByte('"')
+	for _, c := range arg {
+		switch c {
+		case '\\', '"':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\a':
+			b.WriteString(`\a`)
+		case '\b':
+			b.WriteString(`\b`)
+		default:
+			if c < ' ' || c > '~' {
+				fmt.Fprintf(b, `\x%02x`, c)
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	}
+	b.WriteByte('"')
+}
+
+// monitor makes conn a monitor
+// `MONITOR`
+func (r *Redis) monitor(conn interfaces.Connection, args [][]byte) protocol.Reply {
+	if len(args) != 0 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for 'monitor' command")
+	}
+	// pushed, so that it's written before the first command shown
+	conn.Push([]byte("+OK\r\n"))
+	conn.SetMonitoring(true)
+	r.monitors.add(conn)
+	return protocol.MakeNoReply()
+}
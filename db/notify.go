@@ -0,0 +1,75 @@
+package db
+
+// classes of keyspace events, configured with the same characters as Redis in notify-keyspace-events
+const (
+	notifyKeyspace = 1 << iota // K, __keyspace@<db>__:<key> channels
+	notifyKeyevent             // E, __keyevent@<db>__:<event> channels
+	notifyGeneric              // g, DEL and other type-independent commands
+	notifyString               // $
+	notifyList                 // l
+	notifySet                  // s
+	notifyHash                 // h
+	notifyZset                 // z
+	notifyExpired              // x
+	notifyEvicted              // e
+	notifyStream               // t
+	// A
+	notifyAll = notifyGeneric | notifyString | notifyList | notifySet | notifyHash |
+		notifyZset | notifyExpired | notifyEvicted | notifyStream
+)
+
+var notifyClassChars = []struct {
+	char  byte
+	class int
+}{
+	{'g', notifyGeneric},
+	{'$', notifyString},
+	{'l', notifyList},
+	{'s', notifySet},
+	{'h', notifyHash},
+	{'z', notifyZset},
+	{'x', notifyExpired},
+	{'e', notifyEvicted},
+	{'t', notifyStream},
+	{'K', notifyKeyspace},
+	{'E', notifyKeyevent},
+}
+
+// parseKeyspaceEvents parses a value of notify-keyspace-events, returns false on an unknown character
+func parseKeyspaceEvents(value string) (int, bool) {
+	flags := 0
+	for i := 0; i < len(value); i++ {
+		if value[i] == 'A' {
+			flags |= notifyAll
+			continue
+		}
+		found := false
+		for _, c := range notifyClassChars {
+			if c.char == value[i] {
+				flags |= c.class
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, false
+		}
+	}
+	return flags, true
+}
+
+// notifyKeyspaceEvent publishes event on key to the keyspace and keyevent channels enabled
+// by notify-keyspace-events, nothing is published if the class of the event isn't enabled
+func (r *Redis) notifyKeyspaceEvent(class int, event string, key string) {
+	flags := int(r.notifyFlags.Load())
+	if flags&class == 0 {
+		return
+	}
+	// there is a single database
+	if flags&notifyKeyspace != 0 {
+		r.hub.Publish("__keyspace@0__:"+key, []byte(event))
+	}
+	if flags&notifyKeyevent != 0 {
+		r.hub.Publish("__keyevent@0__:"+event, []byte(key))
+	}
+}
@@ -42,6 +42,9 @@ func SAdd(db interfaces.DB, args [][]byte) protocol.Reply {
 		strMembers[i] = string(m)
 	}
 	added := s.Add(strMembers...)
+	if added > 0 {
+		redis.notifyKeyspaceEvent(notifySet, "sadd", key)
+	}
 
 	return protocol.MakeIntReply(int64(added))
 }
@@ -64,6 +67,9 @@ func SRem(db interfaces.DB, args [][]byte) protocol.Reply {
 		strMembers[i] = string(m)
 	}
 	removed := s.Remove(strMembers...)
+	if removed > 0 {
+		redis.notifyKeyspaceEvent(notifySet, "srem", key)
+	}
 	return protocol.MakeIntReply(int64(removed))
 }
 
@@ -104,7 +110,7 @@ func SMembers(db interfaces.DB, args [][]byte) protocol.Reply {
 	for i, m := range members {
 		result[i] = []byte(m)
 	}
-	return protocol.MakeMultiBulkReply(result)
+	return protocol.MakeSetReply(result)
 }
 
 func SCard(db interfaces.DB, args [][]byte) protocol.Reply {
@@ -154,7 +160,7 @@ func SInter(db interfaces.DB, args [][]byte) protocol.Reply {
 	for i, m := range members {
 		reply[i] = []byte(m)
 	}
-	return protocol.MakeMultiBulkReply(reply)
+	return protocol.MakeSetReply(reply)
 }
 
 func SUnion(db interfaces.DB, args [][]byte) protocol.Reply {
@@ -188,7 +194,7 @@ func SUnion(db interfaces.DB, args [][]byte) protocol.Reply {
 	for i, m := range members {
 		reply[i] = []byte(m)
 	}
-	return protocol.MakeMultiBulkReply(reply)
+	return protocol.MakeSetReply(reply)
 }
 
 func SDiff(db interfaces.DB, args [][]byte) protocol.Reply {
@@ -222,5 +228,5 @@ func SDiff(db interfaces.DB, args [][]byte) protocol.Reply {
 	for i, m := range members {
 		reply[i] = []byte(m)
 	}
-	return protocol.MakeMultiBulkReply(reply)
+	return protocol.MakeSetReply(reply)
 }
@@ -0,0 +1,198 @@
+package db
+
+import (
+	"godis/interfaces"
+	"godis/redis/protocol"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// like Redis, the arguments of an entry are truncated to keep the log small
+	slowLogMaxArgs      = 32
+	slowLogMaxArgLength = 128
+)
+
+type slowLogEntry struct {
+	id       int64
+	start    time.Time
+	duration time.Duration
+	args     [][]byte
+	addr     string
+	name     string
+}
+
+// slowLog keeps the latest commands running for longer than slowlog-log-slower-than
+// in a ring buffer of slowlog-max-len entries
+// the ring grows as entries are added, slowlog-max-len may be up to MaxInt32
+type slowLog struct {
+	mu      sync.Mutex
+	entries []*slowLogEntry
+	// maxLen is the slowlog-max-len the ring was built for
+	maxLen int
+	// index of the next entry overwritten once entries holds maxLen entries, the oldest one
+	next   int
+	nextID int64
+}
+
+// add records a call of conn that started at start and ran for duration,
+// maxLen is slowlog-max-len, the oldest entries are dropped to keep at most maxLen of them
+func (s *slowLog) add(conn interfaces.Connection, args [][]byte, start time.Time, duration time.Duration, maxLen int) {
+	entry := &slowLogEntry{
+		start:    start,
+		duration: duration,
+		args:     truncateSlowLogArgs(args),
+		addr:     conn.RemoteAddr(),
+		name:     conn.Name(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry.id = s.nextID
+	s.nextID++
+	if maxLen != s.maxLen {
+		s.resize(maxLen)
+	}
+	if maxLen == 0 {
+		return
+	}
+	if len(s.entries) < maxLen {
+		// next stays 0 until the ring is full, the entries are in order
+		s.entries = append(s.entries, entry)
+		return
+	}
+	s.entries[s.next] = entry
+	s.next = (s.next + 1) % len(s.entries)
+}
+
+// resize keeps the latest maxLen entries, in order so that the ring grows from there
+func (s *slowLog) resize(maxLen int) {
+	latest := s.latest(maxLen)
+	s.entries = make([]*slowLogEntry, 0, len(latest))
+	for i := len(latest) - 1; i >= 0; i-- {
+		s.entries = append(s.entries, latest[i])
+	}
+	s.maxLen, s.next = maxLen, 0
+}
+
+// latest returns the n latest entries, newest first, every entry if n is negative
+func (s *slowLog) latest(n int) []*slowLogEntry {
+	if n < 0 || n > len(s.entries) {
+		n = len(s.entries)
+	}
+	entries := make([]*slowLogEntry, n)
+	for i := range entries {
+		entries[i] = s.entries[(s.next-1-i+2*len(s.entries))%len(s.entries)]
+	}
+	return entries
+}
+
+func (s *slowLog) get(n int) []*slowLogEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.latest(n)
+}
+
+func (s *slowLog) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// reset drops the entries, ids keep increasing
+func (s *slowLog) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries, s.next = nil, 0
+}
+
+// truncateSlowLogArgs keeps at most slowLogMaxArgs arguments of at most slowLogMaxArgLength bytes,
+// saying how many were left out
+func truncateSlowLogArgs(args [][]byte) [][]byte {
+	n := len(args)
+	if n > slowLogMaxArgs {
+		n = slowLogMaxArgs - 1
+	}
+	truncated := make([][]byte, 0, min(len(args), slowLogMaxArgs))
+	for _, arg := range args[:n] {
+		if len(arg) > slowLogMaxArgLength {
+			more := "... (" + strconv.Itoa(len(arg)-slowLogMaxArgLength) + " more bytes)"
+			arg = append(arg[:slowLogMaxArgLength:slowLogMaxArgLength], more...)
+		}
+		truncated = append(truncated, arg)
+	}
+	if n < len(args) {
+		truncated = append(truncated, []byte("... ("+strconv.Itoa(len(args)-n)+" more arguments)"))
+	}
+	return truncated
+}
+
+// logSlow records the call of a command in the slow log if it ran for long enough
+// AUTH and HELLO aren't recorded as their arguments hold passwords, nor the blocking commands
+// since they mostly wait
+func (r *Redis) logSlow(conn interfaces.Connection, cmdL [][]byte, start time.Time, duration time.Duration) {
+	props := r.Config()
+	if props.SlowlogLogSlowerThan < 0 || duration.Microseconds() < int64(props.SlowlogLogSlowerThan) {
+		return
+	}
+	cmdName := strings.ToLower(string(cmdL[0]))
+	if cmdName == "auth" || cmdName == "hello" {
+		return
+	}
+	if isBlocking(cmdName) {
+		return
+	}
+	r.slowLog.add(conn, cmdL, start, duration, props.SlowlogMaxLen)
+}
+
+// SlowLog reads and resets the slow log
+// `SLOWLOG GET [count]` / `SLOWLOG LEN` / `SLOWLOG RESET`
+func SlowLog(db interfaces.DB, args [][]byte) protocol.Reply {
+	if len(args) < 1 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for 'slowlog' command")
+	}
+
+	redis, _ := db.(*Redis)
+	switch strings.ToUpper(string(args[0])) {
+	case "GET":
+		if len(args) > 2 {
+			return protocol.MakeErrReply("ERR wrong number of arguments for 'slowlog|get' command")
+		}
+		count := 10
+		if len(args) == 2 {
+			n, err := strconv.Atoi(string(args[1]))
+			if err != nil || n < -1 {
+				return protocol.MakeErrReply("ERR count should be greater than or equal to -1")
+			}
+			count = n
+		}
+		entries := redis.slowLog.get(count)
+		replies := make([]protocol.Reply, len(entries))
+		for i, e := range entries {
+			replies[i] = protocol.MakeMultiRawReply([]protocol.Reply{
+				protocol.MakeIntReply(e.id),
+				protocol.MakeIntReply(e.start.Unix()),
+				protocol.MakeIntReply(e.duration.Microseconds()),
+				protocol.MakeMultiBulkReply(e.args),
+				protocol.MakeBulkReply([]byte(e.addr)),
+				protocol.MakeBulkReply([]byte(e.name)),
+			})
+		}
+		return protocol.MakeMultiRawReply(replies)
+	case "LEN":
+		if len(args) != 1 {
+			return protocol.MakeErrReply("ERR wrong number of arguments for 'slowlog|len' command")
+		}
+		return protocol.MakeIntReply(int64(redis.slowLog.len()))
+	case "RESET":
+		if len(args) != 1 {
+			return protocol.MakeErrReply("ERR wrong number of arguments for 'slowlog|reset' command")
+		}
+		redis.slowLog.reset()
+		return protocol.MakeOkReply()
+	default:
+		return protocol.MakeErrReply("ERR unknown subcommand '" + string(args[0]) + "'. Try SLOWLOG HELP.")
+	}
+}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	"godis/interfaces"
+	"godis/lib/utils"
+	"math"
+	"testing"
+	"time"
+)
+
+type slowLogConn struct {
+	interfaces.Connection
+}
+
+func (c *slowLogConn) RemoteAddr() string { return "127.0.0.1:50000" }
+
+func (c *slowLogConn) Name() string { return "" }
+
+// slowLogIDs returns the ids of the entries, newest first
+func slowLogIDs(s *slowLog) []int64 {
+	var ids []int64
+	for _, e := range s.get(-1) {
+		ids = append(ids, e.id)
+	}
+	return ids
+}
+
+func TestSlowLogRing(t *testing.T) {
+	s := &slowLog{}
+	conn := &slowLogConn{}
+	add := func(n int, maxLen int) {
+		for i := 0; i < n; i++ {
+			s.add(conn, utils.ToCmdLine("get", "k"), time.Now(), time.Second, maxLen)
+		}
+	}
+
+	// the ring isn't allocated for slowlog-max-len entries upfront
+	add(3, math.MaxInt32)
+	if got := slowLogIDs(s); len(got) != 3 || got[0] != 2 || got[2] != 0 || cap(s.entries) > 4 {
+		t.Fatalf("unexpected entries %v, capacity %d", got, cap(s.entries))
+	}
+
+	// the oldest entries are overwritten once the ring is full
+	add(4, 3)
+	if got := slowLogIDs(s); len(got) != 3 || got[0] != 6 || got[2] != 4 {
+		t.Fatalf("unexpected entries %v", got)
+	}
+
+	// the latest entries are kept when slowlog-max-len changes
+	add(1, 2)
+	if got := slowLogIDs(s); len(got) != 2 || got[0] != 7 || got[1] != 6 {
+		t.Fatalf("unexpected entries %v", got)
+	}
+	add(2, 5)
+	if got := slowLogIDs(s); len(got) != 4 || got[0] != 9 || got[3] != 6 {
+		t.Fatalf("unexpected entries %v", got)
+	}
+
+	s.reset()
+	add(1, 5)
+	if got := slowLogIDs(s); len(got) != 1 || got[0] != 10 {
+		t.Fatalf("unexpected entries %v", got)
+	}
+}
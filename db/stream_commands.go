@@ -0,0 +1,389 @@
+package db
+
+import (
+	"godis/ds/stream"
+	"godis/interfaces"
+	"godis/redis/protocol"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// getStream returns the stream stored at key without creating it
+// returns (nil, nil) if key doesn't exist
+func getStream(db *Redis, key string) (*stream.Stream, *protocol.StandardErrReply) {
+	entity, exists := db.data.Get(key)
+	if !exists {
+		return nil, nil
+	}
+
+	dataEntity, ok := entity.(*DataEntity)
+	if !ok || dataEntity.Type != TypeStream {
+		return nil, protocol.MakeErrReply("WRONGTYPE Operation against a key holding the wrong kind of value")
+	}
+	return dataEntity.Value.(*stream.Stream), nil
+}
+
+// getAsStream returns the stream stored at key, or creates a new one if it doesn't exist
+func getAsStream(db *Redis, key string) (*stream.Stream, *protocol.StandardErrReply) {
+	entity, exists := db.data.Get(key)
+	if !exists {
+		entity, _ = db.data.PutIfAbsent(key, &DataEntity{
+			Type:  TypeStream,
+			Value: stream.NewStream(),
+		})
+	}
+
+	dataEntity, ok := entity.(*DataEntity)
+	if !ok || dataEntity.Type != TypeStream {
+		return nil, protocol.MakeErrReply("WRONGTYPE Operation against a key holding the wrong kind of value")
+	}
+	return dataEntity.Value.(*stream.Stream), nil
+}
+
+// streamTrimArgs holds `MAXLEN|MINID [=|~] threshold [LIMIT count]`
+type streamTrimArgs struct {
+	byMinID bool
+	approx  bool
+	maxLen  int64
+	minID   stream.ID
+	limit   int64
+}
+
+// parseStreamTrimArgs parses the trimming options starting at args[i], which is MAXLEN or MINID
+// returns the index following the options
+func parseStreamTrimArgs(args [][]byte, i int) (*streamTrimArgs, int, *protocol.StandardErrReply) {
+	trim := &streamTrimArgs{
+		byMinID: strings.ToUpper(string(args[i])) == "MINID",
+	}
+	i++
+	if i < len(args) {
+		switch string(args[i]) {
+		case "~":
+			trim.approx = true
+			i++
+		case "=":
+			i++
+		}
+	}
+	if i >= len(args) {
+		return nil, 0, protocol.MakeErrReply("ERR syntax error")
+	}
+
+	if trim.byMinID {
+		id, err := stream.ParseID(string(args[i]), 0)
+		if err != nil {
+			return nil, 0, protocol.MakeErrReply(err.Error())
+		}
+		trim.minID = id
+	} else {
+		maxLen, err := strconv.ParseInt(string(args[i]), 10, 64)
+		if err != nil {
+			return nil, 0, protocol.MakeErrReply("ERR value is not an integer or out of range")
+		}
+		if maxLen < 0 {
+			return nil, 0, protocol.MakeErrReply("ERR The MAXLEN argument must be >= 0.")
+		}
+		trim.maxLen = maxLen
+	}
+	i++
+
+	if trim.approx {
+		// same default as Redis: 100 times the entries of a node
+		trim.limit = 100 * stream.NodeCapacity
+	}
+	if i < len(args) && strings.ToUpper(string(args[i])) == "LIMIT" {
+		if i+1 >= len(args) {
+			return nil, 0, protocol.MakeErrReply("ERR syntax error")
+		}
+		limit, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+		if err != nil || limit < 0 {
+			return nil, 0, protocol.MakeErrReply("ERR The LIMIT argument must be >= 0.")
+		}
+		if !trim.approx {
+			return nil, 0, protocol.MakeErrReply("ERR syntax error, LIMIT cannot be used without the special ~ option")
+		}
+		trim.limit = limit
+		i += 2
+	}
+	return trim, i, nil
+}
+
+func (trim *streamTrimArgs) apply(s *stream.Stream) int64 {
+	if trim.byMinID {
+		return s.TrimMinID(trim.minID, trim.approx, trim.limit)
+	}
+	return s.TrimMaxLen(trim.maxLen, trim.approx, trim.limit)
+}
+
+// parseRangeID parses a bound of XRANGE, `-` and `+` are the smallest and greatest IDs
+// and an ID prefixed by `(` is excluded from the range
+// the sequence of an incomplete start ID defaults to 0, the one of an end ID to the max
+func parseRangeID(arg string, isStart bool) (stream.ID, *protocol.StandardErrReply) {
+	switch arg {
+	case "-":
+		return stream.MinID, nil
+	case "+":
+		return stream.MaxID, nil
+	}
+
+	exclusive := strings.HasPrefix(arg, "(")
+	if exclusive {
+		arg = arg[1:]
+	}
+	missingSeq := stream.MaxID.Seq
+	if isStart {
+		missingSeq = 0
+	}
+	id, err := stream.ParseID(arg, missingSeq)
+	if err != nil {
+		return stream.ID{}, protocol.MakeErrReply(err.Error())
+	}
+	if !exclusive {
+		return id, nil
+	}
+
+	var ok bool
+	if isStart {
+		id, ok = id.Next()
+		if !ok {
+			return stream.ID{}, protocol.MakeErrReply("ERR invalid start ID for the interval")
+		}
+	} else {
+		id, ok = id.Prev()
+		if !ok {
+			return stream.ID{}, protocol.MakeErrReply("ERR invalid end ID for the interval")
+		}
+	}
+	return id, nil
+}
+
+// streamEntriesToReply renders entries as [[id, [field, value, ...]], ...]
+// an entry without fields has been deleted from the stream, its fields are rendered as nil
+func streamEntriesToReply(entries []*stream.Entry) *protocol.MultiRawReply {
+	replies := make([]protocol.Reply, len(entries))
+	for i, entry := range entries {
+		var fields protocol.Reply = protocol.MakeNullMultiBulkReply()
+		if entry.Fields != nil {
+			fields = protocol.MakeMultiBulkReply(entry.Fields)
+		}
+		replies[i] = protocol.MakeMultiRawReply([]protocol.Reply{
+			protocol.MakeBulkReply([]byte(entry.ID.String())),
+			fields,
+		})
+	}
+	return protocol.MakeMultiRawReply(replies)
+}
+
+// XAdd appends an entry to the stream stored at key and returns its ID
+// `XADD key [NOMKSTREAM] [MAXLEN|MINID [=|~] threshold [LIMIT count]] *|id field value [field value ...]`
+func XAdd(db interfaces.DB, args [][]byte) protocol.Reply {
+	if len(args) < 4 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for 'xadd' command")
+	}
+
+	key := string(args[0])
+	noMkStream := false
+	var trim *streamTrimArgs
+	i := 1
+	for ; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i]))
+		if option == "NOMKSTREAM" {
+			noMkStream = true
+		} else if option == "MAXLEN" || option == "MINID" {
+			var errReply *protocol.StandardErrReply
+			trim, i, errReply = parseStreamTrimArgs(args, i)
+			if errReply != nil {
+				return errReply
+			}
+			i--
+		} else {
+			break
+		}
+	}
+
+	if i >= len(args) {
+		return protocol.MakeErrReply("ERR syntax error")
+	}
+	spec, err := stream.ParseIDSpec(string(args[i]))
+	if err != nil {
+		return protocol.MakeErrReply(err.Error())
+	}
+	fields := args[i+1:]
+	if len(fields) == 0 || len(fields)%2 != 0 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for 'xadd' command")
+	}
+
+	redis, _ := db.(*Redis)
+	var s *stream.Stream
+	var errReply *protocol.StandardErrReply
+	if noMkStream {
+		s, errReply = getStream(redis, key)
+		if errReply == nil && s == nil {
+			return protocol.MakeNullBulkReply()
+		}
+	} else {
+		s, errReply = getAsStream(redis, key)
+	}
+	if errReply != nil {
+		return errReply
+	}
+
+	id, err := s.Add(spec, fields, uint64(time.Now().UnixMilli()))
+	if err != nil {
+		return protocol.MakeErrReply(err.Error())
+	}
+	if redis.persisting() {
+		// the ID generated from the time is appended to the AOF rather than *
+		propagated := make([][]byte, 0, len(args)+1)
+		propagated = append(propagated, []byte("xadd"))
+		propagated = append(propagated, args[:i]...)
+		propagated = append(propagated, []byte(id.String()))
+		redis.propagate(append(propagated, fields...))
+	}
+	redis.notifyKeyspaceEvent(notifyStream, "xadd", key)
+	if trim != nil && trim.apply(s) > 0 {
+		redis.notifyKeyspaceEvent(notifyStream, "xtrim", key)
+	}
+	redis.blocking.signal(key)
+	return protocol.MakeBulkReply([]byte(id.String()))
+}
+
+// XTrim trims the stream stored at key and returns the number of removed entries
+// `XTRIM key MAXLEN|MINID [=|~] threshold [LIMIT count]`
+func XTrim(db interfaces.DB, args [][]byte) protocol.Reply {
+	if len(args) < 3 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for 'xtrim' command")
+	}
+
+	option := strings.ToUpper(string(args[1]))
+	if option != "MAXLEN" && option != "MINID" {
+		return protocol.MakeErrReply("ERR syntax error")
+	}
+	trim, next, errReply := parseStreamTrimArgs(args, 1)
+	if errReply != nil {
+		return errReply
+	}
+	if next != len(args) {
+		return protocol.MakeErrReply("ERR syntax error")
+	}
+
+	redis, _ := db.(*Redis)
+	s, errReply := getStream(redis, string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return protocol.MakeIntReply(0)
+	}
+	trimmed := trim.apply(s)
+	if trimmed > 0 {
+		redis.notifyKeyspaceEvent(notifyStream, "xtrim", string(args[0]))
+	}
+	return protocol.MakeIntReply(trimmed)
+}
+
+func execXRange(db interfaces.DB, cmdName string, args [][]byte, rev bool) protocol.Reply {
+	if len(args) != 3 && len(args) != 5 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for '" + cmdName + "' command")
+	}
+
+	// XREVRANGE takes the end first
+	startArg, endArg := string(args[1]), string(args[2])
+	if rev {
+		startArg, endArg = endArg, startArg
+	}
+	start, errReply := parseRangeID(startArg, true)
+	if errReply != nil {
+		return errReply
+	}
+	end, errReply := parseRangeID(endArg, false)
+	if errReply != nil {
+		return errReply
+	}
+
+	count := 0
+	if len(args) == 5 {
+		if strings.ToUpper(string(args[3])) != "COUNT" {
+			return protocol.MakeErrReply("ERR syntax error")
+		}
+		var err error
+		count, err = strconv.Atoi(string(args[4]))
+		if err != nil {
+			return protocol.MakeErrReply("ERR value is not an integer or out of range")
+		}
+		if count <= 0 {
+			return protocol.MakeEmptyMultiBulkReply()
+		}
+	}
+
+	redis, _ := db.(*Redis)
+	s, errReply := getStream(redis, string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return protocol.MakeEmptyMultiBulkReply()
+	}
+	return streamEntriesToReply(s.Range(start, end, count, rev))
+}
+
+// XRange returns the entries with IDs within the given range
+// `XRANGE key start end [COUNT count]`
+func XRange(db interfaces.DB, args [][]byte) protocol.Reply {
+	return execXRange(db, "xrange", args, false)
+}
+
+// XRevRange is XRANGE in reverse order, which takes the end ID first
+// `XREVRANGE key end start [COUNT count]`
+func XRevRange(db interfaces.DB, args [][]byte) protocol.Reply {
+	return execXRange(db, "xrevrange", args, true)
+}
+
+// XLen returns the number of entries in the stream stored at key
+func XLen(db interfaces.DB, args [][]byte) protocol.Reply {
+	if len(args) != 1 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for 'xlen' command")
+	}
+
+	redis, _ := db.(*Redis)
+	s, errReply := getStream(redis, string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return protocol.MakeIntReply(0)
+	}
+	return protocol.MakeIntReply(s.Len())
+}
+
+// XDel removes the given entries and returns the number of entries actually deleted
+// `XDEL key id [id ...]`
+func XDel(db interfaces.DB, args [][]byte) protocol.Reply {
+	if len(args) < 2 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for 'xdel' command")
+	}
+
+	ids := make([]stream.ID, len(args)-1)
+	for i, arg := range args[1:] {
+		id, err := stream.ParseID(string(arg), 0)
+		if err != nil {
+			return protocol.MakeErrReply(err.Error())
+		}
+		ids[i] = id
+	}
+
+	redis, _ := db.(*Redis)
+	s, errReply := getStream(redis, string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return protocol.MakeIntReply(0)
+	}
+	deleted := s.Delete(ids...)
+	if deleted > 0 {
+		redis.notifyKeyspaceEvent(notifyStream, "xdel", string(args[0]))
+	}
+	return protocol.MakeIntReply(deleted)
+}
@@ -0,0 +1,355 @@
+package db
+
+import (
+	"godis/lib/utils"
+	"godis/redis/protocol"
+	"testing"
+	"time"
+)
+
+func TestStreamCommands(t *testing.T) {
+	db := NewStandAloneDb()
+
+	tests := []struct {
+		name     string
+		cmd      Exec
+		args     []string
+		expected string
+	}{
+		{
+			name:     "xadd explicit id",
+			cmd:      XAdd,
+			args:     []string{"events", "1-1", "type", "login"},
+			expected: "$3\r\n1-1\r\n",
+		},
+		{
+			name:     "xadd auto sequence",
+			cmd:      XAdd,
+			args:     []string{"events", "1-*", "type", "logout"},
+			expected: "$3\r\n1-2\r\n",
+		},
+		{
+			name:     "xadd explicit id",
+			cmd:      XAdd,
+			args:     []string{"events", "3-0", "type", "click"},
+			expected: "$3\r\n3-0\r\n",
+		},
+		{
+			name:     "xadd id too small",
+			cmd:      XAdd,
+			args:     []string{"events", "2-0", "type", "click"},
+			expected: "-ERR The ID specified in XADD is equal or smaller than the target stream top item\r\n",
+		},
+		{
+			name:     "xadd zero id",
+			cmd:      XAdd,
+			args:     []string{"other", "0-0", "a", "b"},
+			expected: "-ERR The ID specified in XADD must be greater than 0-0\r\n",
+		},
+		{
+			name:     "xadd odd fields",
+			cmd:      XAdd,
+			args:     []string{"events", "*", "type"},
+			expected: "-ERR wrong number of arguments for 'xadd' command\r\n",
+		},
+		{
+			name:     "xadd invalid id",
+			cmd:      XAdd,
+			args:     []string{"events", "abc", "a", "b"},
+			expected: "-ERR Invalid stream ID specified as stream command argument\r\n",
+		},
+		{
+			name:     "xadd nomkstream",
+			cmd:      XAdd,
+			args:     []string{"nosuchstream", "NOMKSTREAM", "*", "a", "b"},
+			expected: "$-1\r\n",
+		},
+		{
+			name:     "xlen",
+			cmd:      XLen,
+			args:     []string{"events"},
+			expected: ":3\r\n",
+		},
+		{
+			name:     "xrange everything",
+			cmd:      XRange,
+			args:     []string{"events", "-", "+"},
+			expected: "*3\r\n*2\r\n$3\r\n1-1\r\n*2\r\n$4\r\ntype\r\n$5\r\nlogin\r\n*2\r\n$3\r\n1-2\r\n*2\r\n$4\r\ntype\r\n$6\r\nlogout\r\n*2\r\n$3\r\n3-0\r\n*2\r\n$4\r\ntype\r\n$5\r\nclick\r\n",
+		},
+		{
+			name:     "xrange incomplete ids",
+			cmd:      XRange,
+			args:     []string{"events", "1", "1"},
+			expected: "*2\r\n*2\r\n$3\r\n1-1\r\n*2\r\n$4\r\ntype\r\n$5\r\nlogin\r\n*2\r\n$3\r\n1-2\r\n*2\r\n$4\r\ntype\r\n$6\r\nlogout\r\n",
+		},
+		{
+			name:     "xrange exclusive start with count",
+			cmd:      XRange,
+			args:     []string{"events", "(1-1", "+", "COUNT", "1"},
+			expected: "*1\r\n*2\r\n$3\r\n1-2\r\n*2\r\n$4\r\ntype\r\n$6\r\nlogout\r\n",
+		},
+		{
+			name:     "xrevrange",
+			cmd:      XRevRange,
+			args:     []string{"events", "+", "(1-1"},
+			expected: "*2\r\n*2\r\n$3\r\n3-0\r\n*2\r\n$4\r\ntype\r\n$5\r\nclick\r\n*2\r\n$3\r\n1-2\r\n*2\r\n$4\r\ntype\r\n$6\r\nlogout\r\n",
+		},
+		{
+			name:     "xrange exclusive max",
+			cmd:      XRange,
+			args:     []string{"events", "(18446744073709551615-18446744073709551615", "+"},
+			expected: "-ERR invalid start ID for the interval\r\n",
+		},
+		{
+			name:     "xdel",
+			cmd:      XDel,
+			args:     []string{"events", "1-2", "9-9"},
+			expected: ":1\r\n",
+		},
+		{
+			name:     "xtrim limit without approx",
+			cmd:      XTrim,
+			args:     []string{"events", "MAXLEN", "1", "LIMIT", "10"},
+			expected: "-ERR syntax error, LIMIT cannot be used without the special ~ option\r\n",
+		},
+		{
+			name:     "xtrim negative maxlen",
+			cmd:      XTrim,
+			args:     []string{"events", "MAXLEN", "-1"},
+			expected: "-ERR The MAXLEN argument must be >= 0.\r\n",
+		},
+		{
+			name:     "xtrim maxlen",
+			cmd:      XTrim,
+			args:     []string{"events", "MAXLEN", "=", "1"},
+			expected: ":1\r\n",
+		},
+		{
+			name:     "xadd with minid trimming",
+			cmd:      XAdd,
+			args:     []string{"events", "MINID", "4", "4-0", "type", "scroll"},
+			expected: "$3\r\n4-0\r\n",
+		},
+		{
+			name:     "xrange after trimming",
+			cmd:      XRange,
+			args:     []string{"events", "-", "+"},
+			expected: "*1\r\n*2\r\n$3\r\n4-0\r\n*2\r\n$4\r\ntype\r\n$6\r\nscroll\r\n",
+		},
+		{
+			name:     "xlen on wrong type",
+			cmd:      XLen,
+			args:     []string{"myset"},
+			expected: "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n",
+		},
+	}
+
+	SAdd(db, utils.ToCmdLine("myset", "a"))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := string(tt.cmd(db, utils.ToCmdLine(tt.args...)).ToBytes())
+			if actual != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestStreamConsumerGroups(t *testing.T) {
+	db := NewStandAloneDb()
+
+	tests := []struct {
+		name     string
+		cmd      Exec
+		args     []string
+		expected string
+	}{
+		{
+			name:     "xgroup create without key",
+			cmd:      XGroup,
+			args:     []string{"CREATE", "jobs", "workers", "$"},
+			expected: "-ERR The XGROUP subcommand requires the key to exist. Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.\r\n",
+		},
+		{
+			name:     "xgroup create mkstream",
+			cmd:      XGroup,
+			args:     []string{"CREATE", "jobs", "workers", "$", "MKSTREAM"},
+			expected: "+OK\r\n",
+		},
+		{
+			name:     "xgroup create existing",
+			cmd:      XGroup,
+			args:     []string{"CREATE", "jobs", "workers", "0"},
+			expected: "-BUSYGROUP Consumer Group name already exists\r\n",
+		},
+		{
+			name:     "xadd",
+			cmd:      XAdd,
+			args:     []string{"jobs", "1-0", "task", "a"},
+			expected: "$3\r\n1-0\r\n",
+		},
+		{
+			name:     "xadd",
+			cmd:      XAdd,
+			args:     []string{"jobs", "2-0", "task", "b"},
+			expected: "$3\r\n2-0\r\n",
+		},
+		{
+			name:     "xreadgroup unknown group",
+			cmd:      XReadGroup,
+			args:     []string{"GROUP", "nogroup", "alice", "STREAMS", "jobs", ">"},
+			expected: "-NOGROUP No such key 'jobs' or consumer group 'nogroup' in XREADGROUP with GROUP option\r\n",
+		},
+		{
+			name:     "xreadgroup unbalanced",
+			cmd:      XReadGroup,
+			args:     []string{"GROUP", "workers", "alice", "COUNT", "1", "STREAMS", "jobs"},
+			expected: "-ERR Unbalanced 'xreadgroup' list of streams: for each stream key an ID or '>' must be specified.\r\n",
+		},
+		{
+			name:     "xreadgroup new entries",
+			cmd:      XReadGroup,
+			args:     []string{"GROUP", "workers", "alice", "COUNT", "1", "STREAMS", "jobs", ">"},
+			expected: "*1\r\n*2\r\n$4\r\njobs\r\n*1\r\n*2\r\n$3\r\n1-0\r\n*2\r\n$4\r\ntask\r\n$1\r\na\r\n",
+		},
+		{
+			name:     "xreadgroup another consumer",
+			cmd:      XReadGroup,
+			args:     []string{"GROUP", "workers", "bob", "STREAMS", "jobs", ">"},
+			expected: "*1\r\n*2\r\n$4\r\njobs\r\n*1\r\n*2\r\n$3\r\n2-0\r\n*2\r\n$4\r\ntask\r\n$1\r\nb\r\n",
+		},
+		{
+			name:     "xreadgroup nothing new",
+			cmd:      XReadGroup,
+			args:     []string{"GROUP", "workers", "bob", "STREAMS", "jobs", ">"},
+			expected: "*-1\r\n",
+		},
+		{
+			name:     "xreadgroup history",
+			cmd:      XReadGroup,
+			args:     []string{"GROUP", "workers", "alice", "STREAMS", "jobs", "0"},
+			expected: "*1\r\n*2\r\n$4\r\njobs\r\n*1\r\n*2\r\n$3\r\n1-0\r\n*2\r\n$4\r\ntask\r\n$1\r\na\r\n",
+		},
+		{
+			name:     "xpending summary",
+			cmd:      XPending,
+			args:     []string{"jobs", "workers"},
+			expected: "*4\r\n:2\r\n$3\r\n1-0\r\n$3\r\n2-0\r\n*2\r\n*2\r\n$5\r\nalice\r\n$1\r\n1\r\n*2\r\n$3\r\nbob\r\n$1\r\n1\r\n",
+		},
+		{
+			name:     "xack",
+			cmd:      XAck,
+			args:     []string{"jobs", "workers", "1-0", "9-0"},
+			expected: ":1\r\n",
+		},
+		{
+			name:     "xreadgroup empty history",
+			cmd:      XReadGroup,
+			args:     []string{"GROUP", "workers", "alice", "STREAMS", "jobs", "0"},
+			expected: "*1\r\n*2\r\n$4\r\njobs\r\n*0\r\n",
+		},
+		{
+			name:     "xclaim justid",
+			cmd:      XClaim,
+			args:     []string{"jobs", "workers", "alice", "0", "2-0", "JUSTID"},
+			expected: "*1\r\n$3\r\n2-0\r\n",
+		},
+		{
+			name:     "xpending extended",
+			cmd:      XPending,
+			args:     []string{"jobs", "workers", "-", "+", "10", "bob"},
+			expected: "*0\r\n",
+		},
+		{
+			name:     "xclaim unknown group",
+			cmd:      XClaim,
+			args:     []string{"jobs", "nogroup", "alice", "0", "2-0"},
+			expected: "-NOGROUP No such key 'jobs' or consumer group 'nogroup'\r\n",
+		},
+		{
+			name:     "xdel pending entry",
+			cmd:      XDel,
+			args:     []string{"jobs", "2-0"},
+			expected: ":1\r\n",
+		},
+		{
+			name:     "xautoclaim deleted entry",
+			cmd:      XAutoClaim,
+			args:     []string{"jobs", "workers", "bob", "0", "-"},
+			expected: "*3\r\n$3\r\n0-0\r\n*0\r\n*1\r\n$3\r\n2-0\r\n",
+		},
+		{
+			name:     "xautoclaim bad count",
+			cmd:      XAutoClaim,
+			args:     []string{"jobs", "workers", "bob", "0", "-", "COUNT", "0"},
+			expected: "-ERR COUNT must be > 0\r\n",
+		},
+		{
+			name:     "xgroup createconsumer",
+			cmd:      XGroup,
+			args:     []string{"CREATECONSUMER", "jobs", "workers", "carol"},
+			expected: ":1\r\n",
+		},
+		{
+			name:     "xgroup delconsumer",
+			cmd:      XGroup,
+			args:     []string{"DELCONSUMER", "jobs", "workers", "bob"},
+			expected: ":0\r\n",
+		},
+		{
+			name:     "xinfo groups",
+			cmd:      XInfo,
+			args:     []string{"GROUPS", "jobs"},
+			expected: "*1\r\n*12\r\n$4\r\nname\r\n$7\r\nworkers\r\n$9\r\nconsumers\r\n:2\r\n$7\r\npending\r\n:0\r\n$17\r\nlast-delivered-id\r\n$3\r\n2-0\r\n$12\r\nentries-read\r\n:2\r\n$3\r\nlag\r\n:0\r\n",
+		},
+		{
+			name:     "xinfo missing key",
+			cmd:      XInfo,
+			args:     []string{"STREAM", "nosuchkey"},
+			expected: "-ERR no such key\r\n",
+		},
+		{
+			name:     "xgroup destroy",
+			cmd:      XGroup,
+			args:     []string{"DESTROY", "jobs", "workers"},
+			expected: ":1\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := string(tt.cmd(db, utils.ToCmdLine(tt.args...)).ToBytes())
+			if actual != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestXReadGroupWakesUpOnXAdd(t *testing.T) {
+	db := NewStandAloneDb()
+	XGroup(db, utils.ToCmdLine("CREATE", "jobs", "workers", "$", "MKSTREAM"))
+
+	replyCh := make(chan protocol.Reply)
+	go func() {
+		replyCh <- XReadGroup(db, utils.ToCmdLine("GROUP", "workers", "alice", "BLOCK", "0", "STREAMS", "jobs", ">"))
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	XAdd(db, utils.ToCmdLine("jobs", "1-0", "task", "a"))
+
+	select {
+	case reply := <-replyCh:
+		expected := "*1\r\n*2\r\n$4\r\njobs\r\n*1\r\n*2\r\n$3\r\n1-0\r\n*2\r\n$4\r\ntask\r\n$1\r\na\r\n"
+		if string(reply.ToBytes()) != expected {
+			t.Errorf("expected %q, got %q", expected, string(reply.ToBytes()))
+		}
+	case <-time.After(time.Second):
+		t.Fatal("XREADGROUP was not woken up by XADD")
+	}
+
+	reply := XRead(db, utils.ToCmdLine("BLOCK", "10", "STREAMS", "jobs", "$"))
+	if string(reply.ToBytes()) != "*-1\r\n" {
+		t.Errorf("expected XREAD to time out, got %q", string(reply.ToBytes()))
+	}
+}
@@ -0,0 +1,842 @@
+package db
+
+import (
+	"godis/ds/stream"
+	"godis/interfaces"
+	"godis/lib/utils"
+	"godis/redis/protocol"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func noGroupErr(key string, group string) *protocol.StandardErrReply {
+	return protocol.MakeErrReply("NOGROUP No such key '" + key + "' or consumer group '" + group + "'")
+}
+
+// streamReadArgs holds `[GROUP group consumer] [COUNT count] [BLOCK milliseconds] [NOACK] STREAMS key [key ...] id [id ...]`
+type streamReadArgs struct {
+	group    string
+	consumer string
+	count    int
+	block    bool
+	timeout  time.Duration
+	noAck    bool
+	keys     []string
+	ids      []string
+}
+
+// parseStreamReadArgs parses the arguments of XREAD, or XREADGROUP if withGroup is set
+func parseStreamReadArgs(cmdName string, args [][]byte, withGroup bool) (*streamReadArgs, *protocol.StandardErrReply) {
+	parsed := &streamReadArgs{}
+	i := 0
+	for ; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i]))
+		if option == "STREAMS" {
+			break
+		}
+		switch {
+		case option == "COUNT" && i+1 < len(args):
+			count, err := strconv.Atoi(string(args[i+1]))
+			if err != nil {
+				return nil, protocol.MakeErrReply("ERR value is not an integer or out of range")
+			}
+			parsed.count = count
+			i++
+		case option == "BLOCK" && i+1 < len(args):
+			ms, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil {
+				return nil, protocol.MakeErrReply("ERR timeout is not an integer or out of range")
+			}
+			if ms < 0 {
+				return nil, protocol.MakeErrReply("ERR timeout is negative")
+			}
+			parsed.block = true
+			parsed.timeout = time.Duration(ms) * time.Millisecond
+			i++
+		case option == "GROUP" && withGroup && i+2 < len(args):
+			parsed.group = string(args[i+1])
+			parsed.consumer = string(args[i+2])
+			i += 2
+		case option == "NOACK" && withGroup:
+			parsed.noAck = true
+		default:
+			return nil, protocol.MakeErrReply("ERR syntax error")
+		}
+	}
+
+	if withGroup && parsed.group == "" {
+		return nil, protocol.MakeErrReply("ERR Missing GROUP option for XREADGROUP")
+	}
+	streams := args[min(i+1, len(args)):]
+	if i == len(args) || len(streams) == 0 || len(streams)%2 != 0 {
+		placeholder := "'$'"
+		if withGroup {
+			placeholder = "'>'"
+		}
+		return nil, protocol.MakeErrReply("ERR Unbalanced '" + cmdName + "' list of streams: for each stream key an ID or " + placeholder + " must be specified.")
+	}
+	half := len(streams) / 2
+	for j := 0; j < half; j++ {
+		parsed.keys = append(parsed.keys, string(streams[j]))
+		parsed.ids = append(parsed.ids, string(streams[half+j]))
+	}
+	return parsed, nil
+}
+
+// streamReadToReply renders [[key, entries], ...]
+func streamReadToReply(keys []string, entries [][]*stream.Entry) protocol.Reply {
+	replies := make([]protocol.Reply, len(keys))
+	for i, key := range keys {
+		replies[i] = protocol.MakeMultiRawReply([]protocol.Reply{
+			protocol.MakeBulkReply([]byte(key)),
+			streamEntriesToReply(entries[i]),
+		})
+	}
+	return protocol.MakeMultiRawReply(replies)
+}
+
+// XRead returns the entries with IDs greater than the given ones, `$` being the last ID of the stream
+// with BLOCK it waits for new entries if there are none, BLOCK 0 waits forever
+// `XREAD [COUNT count] [BLOCK milliseconds] STREAMS key [key ...] id [id ...]`
+func XRead(db interfaces.DB, args [][]byte) protocol.Reply {
+	if len(args) < 3 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for 'xread' command")
+	}
+	parsed, errReply := parseStreamReadArgs("xread", args, false)
+	if errReply != nil {
+		return errReply
+	}
+
+	redis, _ := db.(*Redis)
+	// `$` is resolved once, so that blocking waits for entries added after this call
+	after := make([]stream.ID, len(parsed.keys))
+	for i, key := range parsed.keys {
+		s, errReply := getStream(redis, key)
+		if errReply != nil {
+			return errReply
+		}
+		if parsed.ids[i] == "$" {
+			if s != nil {
+				after[i] = s.LastID()
+			}
+			continue
+		}
+		id, err := stream.ParseID(parsed.ids[i], 0)
+		if err != nil {
+			return protocol.MakeErrReply(err.Error())
+		}
+		after[i] = id
+	}
+
+	try := func() protocol.Reply {
+		var keys []string
+		var entries [][]*stream.Entry
+		for i, key := range parsed.keys {
+			s, errReply := getStream(redis, key)
+			if errReply != nil {
+				return errReply
+			}
+			start, ok := after[i].Next()
+			if s == nil || !ok {
+				continue
+			}
+			if read := s.Range(start, stream.MaxID, parsed.count, false); len(read) > 0 {
+				keys = append(keys, key)
+				entries = append(entries, read)
+			}
+		}
+		if len(keys) == 0 {
+			return nil
+		}
+		return streamReadToReply(keys, entries)
+	}
+
+	var reply protocol.Reply
+	if parsed.block {
+		reply = redis.blockUntil(parsed.keys, parsed.timeout, try)
+	} else {
+		reply = try()
+	}
+	if reply == nil {
+		return protocol.MakeNullMultiBulkReply()
+	}
+	return reply
+}
+
+// XReadGroup reads entries as a consumer of a group
+// `>` delivers entries never delivered to the group and adds them to the consumer's pending entries,
+// any other ID returns the consumer's pending entries after that ID
+// `XREADGROUP GROUP group consumer [COUNT count] [BLOCK milliseconds] [NOACK] STREAMS key [key ...] id [id ...]`
+func XReadGroup(db interfaces.DB, args [][]byte) protocol.Reply {
+	if len(args) < 6 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for 'xreadgroup' command")
+	}
+	parsed, errReply := parseStreamReadArgs("xreadgroup", args, true)
+	if errReply != nil {
+		return errReply
+	}
+
+	after := make([]stream.ID, len(parsed.keys))
+	history := false
+	for i, id := range parsed.ids {
+		if id == ">" {
+			continue
+		}
+		parsedID, err := stream.ParseID(id, 0)
+		if err != nil {
+			return protocol.MakeErrReply(err.Error())
+		}
+		after[i] = parsedID
+		history = true
+	}
+
+	redis, _ := db.(*Redis)
+	// the entries delivered by key, for the AOF
+	delivered := make([][]*stream.Entry, len(parsed.keys))
+	try := func() protocol.Reply {
+		var keys []string
+		var entries [][]*stream.Entry
+		for i, key := range parsed.keys {
+			s, errReply := getStream(redis, key)
+			if errReply != nil {
+				return errReply
+			}
+			if s == nil {
+				return protocol.MakeErrReply("NOGROUP No such key '" + key + "' or consumer group '" + parsed.group + "' in XREADGROUP with GROUP option")
+			}
+			newOnly := parsed.ids[i] == ">"
+			read, err := s.ReadGroup(parsed.group, parsed.consumer, after[i], newOnly, parsed.count, parsed.noAck, time.Now().UnixMilli())
+			if err != nil {
+				return protocol.MakeErrReply("NOGROUP No such key '" + key + "' or consumer group '" + parsed.group + "' in XREADGROUP with GROUP option")
+			}
+			delivered[i] = read
+			// reading the history always replies, even with no pending entries
+			if len(read) > 0 || !newOnly {
+				keys = append(keys, key)
+				entries = append(entries, read)
+			}
+		}
+		if len(keys) == 0 {
+			return nil
+		}
+		return streamReadToReply(keys, entries)
+	}
+
+	var reply protocol.Reply
+	if parsed.block && !history {
+		reply = redis.blockUntil(parsed.keys, parsed.timeout, try)
+	} else {
+		reply = try()
+	}
+	if redis.persisting() {
+		redis.propagateReadGroup(parsed, delivered)
+	}
+	if reply == nil {
+		return protocol.MakeNullMultiBulkReply()
+	}
+	return reply
+}
+
+// claimCmdLine is appended to the AOF for a delivery or a claim, it sets the pending entry as it is
+// rather than depending on the time the command runs
+// the seen and active times of the consumers aren't kept
+func claimCmdLine(key string, group string, pe stream.PendingEntry) [][]byte {
+	return utils.ToCmdLine("xclaim", key, group, pe.Consumer, "0", pe.ID.String(),
+		"TIME", strconv.FormatInt(pe.DeliveryTime, 10), "RETRYCOUNT", strconv.FormatInt(pe.DeliveryCount, 10), "FORCE", "JUSTID")
+}
+
+// propagateClaims appends to the AOF the pending entries of ids now owned by consumer,
+// and the acknowledgement of those removed from the PEL as they were deleted from the stream
+func (r *Redis) propagateClaims(s *stream.Stream, key string, group string, consumer string, claimed []*stream.Entry, deleted []stream.ID) {
+	cmds := [][][]byte{utils.ToCmdLine("xgroup", "createconsumer", key, group, consumer)}
+	for _, entry := range claimed {
+		if pe, ok := s.Pending(group, entry.ID); ok {
+			cmds = append(cmds, claimCmdLine(key, group, pe))
+		}
+	}
+	if len(deleted) > 0 {
+		ack := utils.ToCmdLine("xack", key, group)
+		for _, id := range deleted {
+			ack = append(ack, []byte(id.String()))
+		}
+		cmds = append(cmds, ack)
+	}
+	r.propagate(cmds...)
+}
+
+// propagateReadGroup appends to the AOF what XREADGROUP changed in the groups: the consumer, the entries
+// delivered to it and the last delivered ID, loading the file mustn't block nor depend on the time
+func (r *Redis) propagateReadGroup(parsed *streamReadArgs, delivered [][]*stream.Entry) {
+	var cmds [][][]byte
+	for i, key := range parsed.keys {
+		s, _ := getStream(r, key)
+		if s == nil {
+			continue
+		}
+		cmds = append(cmds, utils.ToCmdLine("xgroup", "createconsumer", key, parsed.group, parsed.consumer))
+		// reading the history changes nothing else
+		if parsed.ids[i] != ">" || len(delivered[i]) == 0 {
+			continue
+		}
+		if !parsed.noAck {
+			for _, entry := range delivered[i] {
+				if pe, ok := s.Pending(parsed.group, entry.ID); ok {
+					cmds = append(cmds, claimCmdLine(key, parsed.group, pe))
+				}
+			}
+		}
+		lastID, entriesRead, err := s.LastDelivered(parsed.group)
+		if err == nil {
+			cmds = append(cmds, utils.ToCmdLine("xgroup", "setid", key, parsed.group, lastID.String(),
+				"ENTRIESREAD", strconv.FormatInt(entriesRead, 10)))
+		}
+	}
+	r.propagate(cmds...)
+}
+
+// parseGroupStartID parses the ID of XGROUP CREATE/SETID and the optional ENTRIESREAD
+// `$` means the last ID of the stream
+func parseGroupStartID(args [][]byte) (stream.ID, bool, int64, *protocol.StandardErrReply) {
+	entriesRead := int64(-1)
+	for i := 1; i < len(args); i++ {
+		if strings.ToUpper(string(args[i])) == "ENTRIESREAD" && i+1 < len(args) {
+			n, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil || n < -1 {
+				return stream.ID{}, false, 0, protocol.MakeErrReply("ERR value for ENTRIESREAD must be positive or -1")
+			}
+			entriesRead = n
+			i++
+		} else if strings.ToUpper(string(args[i])) != "MKSTREAM" {
+			return stream.ID{}, false, 0, protocol.MakeErrReply("ERR syntax error")
+		}
+	}
+	if string(args[0]) == "$" {
+		return stream.ID{}, true, entriesRead, nil
+	}
+	id, err := stream.ParseID(string(args[0]), 0)
+	if err != nil {
+		return stream.ID{}, false, 0, protocol.MakeErrReply(err.Error())
+	}
+	return id, false, entriesRead, nil
+}
+
+// XGroup manages consumer groups
+// `XGROUP CREATE key group id|$ [MKSTREAM] [ENTRIESREAD entries-read]`
+// `XGROUP SETID key group id|$ [ENTRIESREAD entries-read]`
+// `XGROUP DESTROY key group`
+// `XGROUP CREATECONSUMER key group consumer`
+// `XGROUP DELCONSUMER key group consumer`
+func XGroup(db interfaces.DB, args [][]byte) protocol.Reply {
+	if len(args) < 3 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for 'xgroup' command")
+	}
+
+	subCmd := strings.ToUpper(string(args[0]))
+	key := string(args[1])
+	group := string(args[2])
+	redis, _ := db.(*Redis)
+
+	s, errReply := getStream(redis, key)
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		mkStream := false
+		for _, arg := range args[3:] {
+			if strings.ToUpper(string(arg)) == "MKSTREAM" {
+				mkStream = true
+			}
+		}
+		if subCmd != "CREATE" || !mkStream {
+			return protocol.MakeErrReply("ERR The XGROUP subcommand requires the key to exist. Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.")
+		}
+		s, errReply = getAsStream(redis, key)
+		if errReply != nil {
+			return errReply
+		}
+	}
+
+	switch subCmd {
+	case "CREATE", "SETID":
+		if len(args) < 4 {
+			return protocol.MakeErrReply("ERR wrong number of arguments for 'xgroup|" + strings.ToLower(subCmd) + "' command")
+		}
+		id, useLast, entriesRead, errReply := parseGroupStartID(args[3:])
+		if errReply != nil {
+			return errReply
+		}
+		var err error
+		if subCmd == "CREATE" {
+			err = s.CreateGroup(group, id, useLast, entriesRead)
+		} else {
+			err = s.SetGroupID(group, id, useLast, entriesRead)
+		}
+		if err == stream.ErrNoGroup {
+			return protocol.MakeErrReply("NOGROUP No such consumer group '" + group + "' for key name '" + key + "'")
+		} else if err != nil {
+			return protocol.MakeErrReply(err.Error())
+		}
+		return protocol.MakeOkReply()
+	case "DESTROY":
+		if len(args) != 3 {
+			return protocol.MakeErrReply("ERR wrong number of arguments for 'xgroup|destroy' command")
+		}
+		if s.DestroyGroup(group) {
+			return protocol.MakeIntReply(1)
+		}
+		return protocol.MakeIntReply(0)
+	case "CREATECONSUMER", "DELCONSUMER":
+		if len(args) != 4 {
+			return protocol.MakeErrReply("ERR wrong number of arguments for 'xgroup|" + strings.ToLower(subCmd) + "' command")
+		}
+		consumer := string(args[3])
+		var result int64
+		var err error
+		if subCmd == "CREATECONSUMER" {
+			var created bool
+			created, err = s.CreateConsumer(group, consumer, time.Now().UnixMilli())
+			if created {
+				result = 1
+			}
+		} else {
+			result, err = s.DeleteConsumer(group, consumer)
+		}
+		if err != nil {
+			return protocol.MakeErrReply("NOGROUP No such consumer group '" + group + "' for key name '" + key + "'")
+		}
+		return protocol.MakeIntReply(result)
+	}
+	return protocol.MakeErrReply("ERR unknown subcommand '" + string(args[0]) + "'. Try XGROUP HELP.")
+}
+
+// XAck acknowledges pending entries of a group and returns the number of acknowledged entries
+// `XACK key group id [id ...]`
+func XAck(db interfaces.DB, args [][]byte) protocol.Reply {
+	if len(args) < 3 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for 'xack' command")
+	}
+
+	ids := make([]stream.ID, len(args)-2)
+	for i, arg := range args[2:] {
+		id, err := stream.ParseID(string(arg), 0)
+		if err != nil {
+			return protocol.MakeErrReply(err.Error())
+		}
+		ids[i] = id
+	}
+
+	redis, _ := db.(*Redis)
+	s, errReply := getStream(redis, string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return protocol.MakeIntReply(0)
+	}
+	acked, err := s.Ack(string(args[1]), ids)
+	if err != nil {
+		return protocol.MakeIntReply(0)
+	}
+	return protocol.MakeIntReply(acked)
+}
+
+// XPending inspects the pending entries of a group
+// without a range it returns a summary, otherwise every pending entry with its owner, idle time and delivery count
+// `XPENDING key group [[IDLE min-idle-time] start end count [consumer]]`
+func XPending(db interfaces.DB, args [][]byte) protocol.Reply {
+	if len(args) < 2 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for 'xpending' command")
+	}
+
+	key, group := string(args[0]), string(args[1])
+	redis, _ := db.(*Redis)
+	s, errReply := getStream(redis, key)
+	if errReply != nil {
+		return errReply
+	}
+
+	if len(args) == 2 {
+		if s == nil {
+			return noGroupErr(key, group)
+		}
+		summary, err := s.PendingSummary(group)
+		if err != nil {
+			return noGroupErr(key, group)
+		}
+		if summary.Count == 0 {
+			return protocol.MakeMultiRawReply([]protocol.Reply{
+				protocol.MakeIntReply(0),
+				protocol.MakeNullBulkReply(),
+				protocol.MakeNullBulkReply(),
+				protocol.MakeNullMultiBulkReply(),
+			})
+		}
+		names := make([]string, 0, len(summary.Consumers))
+		for name := range summary.Consumers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		consumers := make([]protocol.Reply, len(names))
+		for i, name := range names {
+			consumers[i] = protocol.MakeMultiBulkReply([][]byte{
+				[]byte(name),
+				[]byte(strconv.FormatInt(summary.Consumers[name], 10)),
+			})
+		}
+		return protocol.MakeMultiRawReply([]protocol.Reply{
+			protocol.MakeIntReply(summary.Count),
+			protocol.MakeBulkReply([]byte(summary.MinID.String())),
+			protocol.MakeBulkReply([]byte(summary.MaxID.String())),
+			protocol.MakeMultiRawReply(consumers),
+		})
+	}
+
+	rest := args[2:]
+	var minIdle int64
+	if strings.ToUpper(string(rest[0])) == "IDLE" {
+		if len(rest) < 2 {
+			return protocol.MakeErrReply("ERR syntax error")
+		}
+		var err error
+		minIdle, err = strconv.ParseInt(string(rest[1]), 10, 64)
+		if err != nil {
+			return protocol.MakeErrReply("ERR value is not an integer or out of range")
+		}
+		rest = rest[2:]
+	}
+	if len(rest) != 3 && len(rest) != 4 {
+		return protocol.MakeErrReply("ERR syntax error")
+	}
+	start, errReply := parseRangeID(string(rest[0]), true)
+	if errReply != nil {
+		return errReply
+	}
+	end, errReply := parseRangeID(string(rest[1]), false)
+	if errReply != nil {
+		return errReply
+	}
+	count, err := strconv.Atoi(string(rest[2]))
+	if err != nil {
+		return protocol.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	consumer := ""
+	if len(rest) == 4 {
+		consumer = string(rest[3])
+	}
+
+	if s == nil {
+		return noGroupErr(key, group)
+	}
+	if count <= 0 {
+		return protocol.MakeEmptyMultiBulkReply()
+	}
+	nowMs := time.Now().UnixMilli()
+	pending, err := s.PendingRange(group, start, end, count, consumer, minIdle, nowMs)
+	if err != nil {
+		return noGroupErr(key, group)
+	}
+	replies := make([]protocol.Reply, len(pending))
+	for i, pe := range pending {
+		replies[i] = protocol.MakeMultiRawReply([]protocol.Reply{
+			protocol.MakeBulkReply([]byte(pe.ID.String())),
+			protocol.MakeBulkReply([]byte(pe.Consumer)),
+			protocol.MakeIntReply(nowMs - pe.DeliveryTime),
+			protocol.MakeIntReply(pe.DeliveryCount),
+		})
+	}
+	return protocol.MakeMultiRawReply(replies)
+}
+
+func streamIDsToReply(entries []*stream.Entry) protocol.Reply {
+	ids := make([][]byte, len(entries))
+	for i, entry := range entries {
+		ids[i] = []byte(entry.ID.String())
+	}
+	return protocol.MakeMultiBulkReply(ids)
+}
+
+// XClaim changes the owner of pending entries idle for at least min-idle-time milliseconds
+// `XCLAIM key group consumer min-idle-time id [id ...] [IDLE ms] [TIME unix-time-milliseconds] [RETRYCOUNT count] [FORCE] [JUSTID] [LASTID lastid]`
+func XClaim(db interfaces.DB, args [][]byte) protocol.Reply {
+	if len(args) < 5 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for 'xclaim' command")
+	}
+
+	key, group, consumer := string(args[0]), string(args[1]), string(args[2])
+	minIdle, err := strconv.ParseInt(string(args[3]), 10, 64)
+	if err != nil {
+		return protocol.MakeErrReply("ERR Invalid min-idle-time argument for XCLAIM")
+	}
+
+	// IDs are followed by the options
+	i := 4
+	var ids []stream.ID
+	for ; i < len(args); i++ {
+		id, err := stream.ParseID(string(args[i]), 0)
+		if err != nil {
+			break
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return protocol.MakeErrReply("ERR Invalid stream ID specified as stream command argument")
+	}
+
+	opts := stream.ClaimOptions{
+		Idle:       -1,
+		Time:       -1,
+		RetryCount: -1,
+	}
+	for ; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i]))
+		switch option {
+		case "FORCE":
+			opts.Force = true
+		case "JUSTID":
+			opts.JustID = true
+		case "IDLE", "TIME", "RETRYCOUNT", "LASTID":
+			if i+1 >= len(args) {
+				return protocol.MakeErrReply("ERR syntax error")
+			}
+			i++
+			if option == "LASTID" {
+				if _, err := stream.ParseID(string(args[i]), 0); err != nil {
+					return protocol.MakeErrReply(err.Error())
+				}
+				continue
+			}
+			n, err := strconv.ParseInt(string(args[i]), 10, 64)
+			if err != nil {
+				return protocol.MakeErrReply("ERR Invalid " + option + " option argument for XCLAIM")
+			}
+			n = max(n, 0)
+			switch option {
+			case "IDLE":
+				opts.Idle = n
+			case "TIME":
+				opts.Time = n
+			default:
+				opts.RetryCount = n
+			}
+		default:
+			return protocol.MakeErrReply("ERR Unrecognized XCLAIM option '" + string(args[i]) + "'")
+		}
+	}
+
+	redis, _ := db.(*Redis)
+	s, errReply := getStream(redis, key)
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return noGroupErr(key, group)
+	}
+	claimed, err := s.Claim(group, consumer, max(minIdle, 0), ids, opts, time.Now().UnixMilli())
+	if err != nil {
+		return noGroupErr(key, group)
+	}
+	if redis.persisting() {
+		// the IDs not pending anymore were removed as deleted from the stream,
+		// acknowledging those which weren't pending changes nothing
+		var deleted []stream.ID
+		claimedIDs := make(map[stream.ID]bool, len(claimed))
+		for _, entry := range claimed {
+			claimedIDs[entry.ID] = true
+		}
+		for _, id := range ids {
+			if _, pending := s.Pending(group, id); !pending && !claimedIDs[id] {
+				deleted = append(deleted, id)
+			}
+		}
+		redis.propagateClaims(s, key, group, consumer, claimed, deleted)
+	}
+	if opts.JustID {
+		return streamIDsToReply(claimed)
+	}
+	return streamEntriesToReply(claimed)
+}
+
+// XAutoClaim scans the pending entries from start and claims those idle for at least min-idle-time milliseconds
+// it returns the cursor to continue the scan with, the claimed entries and the IDs deleted from the stream
+// `XAUTOCLAIM key group consumer min-idle-time start [COUNT count] [JUSTID]`
+func XAutoClaim(db interfaces.DB, args [][]byte) protocol.Reply {
+	if len(args) < 5 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for 'xautoclaim' command")
+	}
+
+	key, group, consumer := string(args[0]), string(args[1]), string(args[2])
+	minIdle, err := strconv.ParseInt(string(args[3]), 10, 64)
+	if err != nil {
+		return protocol.MakeErrReply("ERR Invalid min-idle-time argument for XAUTOCLAIM")
+	}
+	start, errReply := parseRangeID(string(args[4]), true)
+	if errReply != nil {
+		return errReply
+	}
+
+	count := 100
+	justID := false
+	for i := 5; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "COUNT":
+			if i+1 >= len(args) {
+				return protocol.MakeErrReply("ERR syntax error")
+			}
+			count, err = strconv.Atoi(string(args[i+1]))
+			if err != nil || count < 1 {
+				return protocol.MakeErrReply("ERR COUNT must be > 0")
+			}
+			i++
+		case "JUSTID":
+			justID = true
+		default:
+			return protocol.MakeErrReply("ERR syntax error")
+		}
+	}
+
+	redis, _ := db.(*Redis)
+	s, errReply := getStream(redis, key)
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return noGroupErr(key, group)
+	}
+	next, claimed, deleted, err := s.AutoClaim(group, consumer, max(minIdle, 0), start, count, justID, time.Now().UnixMilli())
+	if err != nil {
+		return noGroupErr(key, group)
+	}
+	if redis.persisting() {
+		redis.propagateClaims(s, key, group, consumer, claimed, deleted)
+	}
+
+	var claimedReply protocol.Reply
+	if justID {
+		claimedReply = streamIDsToReply(claimed)
+	} else {
+		claimedReply = streamEntriesToReply(claimed)
+	}
+	deletedIDs := make([][]byte, len(deleted))
+	for i, id := range deleted {
+		deletedIDs[i] = []byte(id.String())
+	}
+	return protocol.MakeMultiRawReply([]protocol.Reply{
+		protocol.MakeBulkReply([]byte(next.String())),
+		claimedReply,
+		protocol.MakeMultiBulkReply(deletedIDs),
+	})
+}
+
+func streamEntryOrNil(entry *stream.Entry) protocol.Reply {
+	if entry == nil {
+		return protocol.MakeNullBulkReply()
+	}
+	return streamEntriesToReply([]*stream.Entry{entry}).Replies[0]
+}
+
+// XInfo reports about a stream, its consumer groups or the consumers of a group
+// `XINFO STREAM key`
+// `XINFO GROUPS key`
+// `XINFO CONSUMERS key group`
+func XInfo(db interfaces.DB, args [][]byte) protocol.Reply {
+	if len(args) < 2 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for 'xinfo' command")
+	}
+
+	subCmd := strings.ToUpper(string(args[0]))
+	key := string(args[1])
+	redis, _ := db.(*Redis)
+	s, errReply := getStream(redis, key)
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return protocol.MakeErrReply("ERR no such key")
+	}
+
+	switch subCmd {
+	case "STREAM":
+		if len(args) != 2 {
+			return protocol.MakeErrReply("ERR syntax error")
+		}
+		first := s.First()
+		recordedFirst := stream.MinID
+		if first != nil {
+			recordedFirst = first.ID
+		}
+		return protocol.MakeMultiRawReply([]protocol.Reply{
+			protocol.MakeBulkReply([]byte("length")),
+			protocol.MakeIntReply(s.Len()),
+			protocol.MakeBulkReply([]byte("last-generated-id")),
+			protocol.MakeBulkReply([]byte(s.LastID().String())),
+			protocol.MakeBulkReply([]byte("max-deleted-entry-id")),
+			protocol.MakeBulkReply([]byte(s.MaxDeletedID().String())),
+			protocol.MakeBulkReply([]byte("entries-added")),
+			protocol.MakeIntReply(int64(s.EntriesAdded())),
+			protocol.MakeBulkReply([]byte("recorded-first-entry-id")),
+			protocol.MakeBulkReply([]byte(recordedFirst.String())),
+			protocol.MakeBulkReply([]byte("groups")),
+			protocol.MakeIntReply(int64(s.GroupCount())),
+			protocol.MakeBulkReply([]byte("first-entry")),
+			streamEntryOrNil(first),
+			protocol.MakeBulkReply([]byte("last-entry")),
+			streamEntryOrNil(s.Last()),
+		})
+	case "GROUPS":
+		if len(args) != 2 {
+			return protocol.MakeErrReply("ERR syntax error")
+		}
+		groups := s.GroupsInfo()
+		replies := make([]protocol.Reply, len(groups))
+		for i, g := range groups {
+			var entriesRead, lag protocol.Reply = protocol.MakeNullBulkReply(), protocol.MakeNullBulkReply()
+			if g.EntriesRead >= 0 {
+				entriesRead = protocol.MakeIntReply(g.EntriesRead)
+			}
+			if g.Lag >= 0 {
+				lag = protocol.MakeIntReply(g.Lag)
+			}
+			replies[i] = protocol.MakeMultiRawReply([]protocol.Reply{
+				protocol.MakeBulkReply([]byte("name")),
+				protocol.MakeBulkReply([]byte(g.Name)),
+				protocol.MakeBulkReply([]byte("consumers")),
+				protocol.MakeIntReply(g.Consumers),
+				protocol.MakeBulkReply([]byte("pending")),
+				protocol.MakeIntReply(g.Pending),
+				protocol.MakeBulkReply([]byte("last-delivered-id")),
+				protocol.MakeBulkReply([]byte(g.LastDeliveredID.String())),
+				protocol.MakeBulkReply([]byte("entries-read")),
+				entriesRead,
+				protocol.MakeBulkReply([]byte("lag")),
+				lag,
+			})
+		}
+		return protocol.MakeMultiRawReply(replies)
+	case "CONSUMERS":
+		if len(args) != 3 {
+			return protocol.MakeErrReply("ERR wrong number of arguments for 'xinfo|consumers' command")
+		}
+		group := string(args[2])
+		consumers, err := s.ConsumersInfo(group, time.Now().UnixMilli())
+		if err != nil {
+			return protocol.MakeErrReply("NOGROUP No such consumer group '" + group + "' for key name '" + key + "'")
+		}
+		replies := make([]protocol.Reply, len(consumers))
+		for i, c := range consumers {
+			replies[i] = protocol.MakeMultiRawReply([]protocol.Reply{
+				protocol.MakeBulkReply([]byte("name")),
+				protocol.MakeBulkReply([]byte(c.Name)),
+				protocol.MakeBulkReply([]byte("pending")),
+				protocol.MakeIntReply(c.Pending),
+				protocol.MakeBulkReply([]byte("idle")),
+				protocol.MakeIntReply(c.Idle),
+				protocol.MakeBulkReply([]byte("inactive")),
+				protocol.MakeIntReply(c.Inactive),
+			})
+		}
+		return protocol.MakeMultiRawReply(replies)
+	}
+	return protocol.MakeErrReply("ERR unknown subcommand '" + string(args[0]) + "'. Try XINFO HELP.")
+}
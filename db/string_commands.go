@@ -19,6 +19,7 @@ func Set(db interfaces.DB, args [][]byte) protocol.Reply {
 		Type:  TypeString,
 		Value: value,
 	})
+	redis.notifyKeyspaceEvent(notifyString, "set", key)
 
 	return protocol.MakeOkReply()
 }
@@ -1,33 +1,36 @@
 package db
 
 import (
+	"godis/ds/set"
 	"godis/ds/zset"
 	"godis/interfaces"
+	"godis/lib/utils"
 	"godis/redis/protocol"
 	"log"
+	"math"
 	"strconv"
+	"strings"
 )
 
 func init() {
 	log.SetFlags(log.LstdFlags | log.Llongfile)
 }
 
-func getAsZSet(db *Redis, key string) (*zset.SortedSet, *protocol.StandardErrReply) {
+func getAsZSet(db *Redis, key string) (*zset.ConcurrentSortedSet, *protocol.StandardErrReply) {
 	entity, exists := db.data.Get(key)
 	if !exists {
-		newZSet := zset.NewSortedSet()
-		db.data.Put(key, &DataEntity{
+		// another client may be creating the same key concurrently
+		entity, _ = db.data.PutIfAbsent(key, &DataEntity{
 			Type:  TypeZset,
-			Value: newZSet,
+			Value: zset.NewConcurrentSortedSet(),
 		})
-		return newZSet, nil
 	}
 
 	dataEntity, ok := entity.(*DataEntity)
 	if !ok || dataEntity.Type != TypeZset {
 		return nil, protocol.MakeErrReply("WRONGTYPE Operation against a key holding the wrong kind of value")
 	}
-	return dataEntity.Value.(*zset.SortedSet), nil
+	return dataEntity.Value.(*zset.ConcurrentSortedSet), nil
 }
 
 // ZAdd adds the specified members with scores to the sorted set stored at key.
@@ -39,6 +42,9 @@ func ZAdd(db interfaces.DB, args [][]byte) protocol.Reply {
 
 	key := string(args[0])
 	redis, _ := db.(*Redis)
+	// a concurrent pop must not remove the key while members are added
+	redis.locks.Lock(key)
+	defer redis.locks.Unlock(key)
 	zSet, errReply := getAsZSet(redis, key)
 	if errReply != nil {
 		return errReply
@@ -56,6 +62,8 @@ func ZAdd(db interfaces.DB, args [][]byte) protocol.Reply {
 			added++
 		}
 	}
+	redis.notifyKeyspaceEvent(notifyZset, "zadd", key)
+	redis.blocking.signal(key)
 	return protocol.MakeIntReply(added)
 }
 
@@ -77,6 +85,9 @@ func ZRemove(db interfaces.DB, args [][]byte) protocol.Reply {
 			count++
 		}
 	}
+	if count > 0 {
+		redis.notifyKeyspaceEvent(notifyZset, "zrem", key)
+	}
 
 	return protocol.MakeIntReply(int64(count))
 }
@@ -188,8 +199,7 @@ func ZScore(db interfaces.DB, args [][]byte) protocol.Reply {
 		log.Printf("member %s not found in zset %s", member, key)
 		return protocol.MakeNullBulkReply()
 	}
-	scoreStr := strconv.FormatFloat(score, 'g', 10, 64)
-	return protocol.MakeBulkReply([]byte(scoreStr))
+	return protocol.MakeDoubleReply(score)
 }
 
 // ZRank returns the rank of member in the sorted set stored at key, with scores ordered from low to high.
@@ -218,3 +228,617 @@ func ZRank(db interfaces.DB, args [][]byte) protocol.Reply {
 	}
 	return protocol.MakeIntReply(rank)
 }
+
+const (
+	aggregateSum = iota
+	aggregateMin
+	aggregateMax
+)
+
+// zsetAlgebraArgs holds the parsed arguments of the ZUNION/ZINTER/ZDIFF family
+type zsetAlgebraArgs struct {
+	keys       []string
+	weights    []float64
+	aggregate  int
+	withScores bool
+}
+
+// formatScore formats a score the same way as ZSCORE does
+func formatScore(score float64) []byte {
+	if math.IsInf(score, 1) {
+		return []byte("inf")
+	} else if math.IsInf(score, -1) {
+		return []byte("-inf")
+	}
+	return []byte(strconv.FormatFloat(score, 'g', 10, 64))
+}
+
+// getAsScoredMembers reads the sorted set or set stored at key without creating it
+// members of a plain set are scored 1, a missing key reads as an empty set
+func getAsScoredMembers(db *Redis, key string) (map[string]float64, *protocol.StandardErrReply) {
+	entity, exists := db.data.Get(key)
+	if !exists {
+		return map[string]float64{}, nil
+	}
+
+	dataEntity, ok := entity.(*DataEntity)
+	if !ok {
+		return nil, protocol.MakeErrReply("WRONGTYPE Operation against a key holding the wrong kind of value")
+	}
+
+	switch dataEntity.Type {
+	case TypeZset:
+		zSet := dataEntity.Value.(*zset.ConcurrentSortedSet)
+		members := make(map[string]float64, zSet.Len())
+		zSet.ForEach(false, func(element *zset.Element) bool {
+			members[element.Member] = element.Score
+			return true
+		})
+		return members, nil
+	case TypeSet:
+		s := dataEntity.Value.(*set.ConcurrentSet)
+		members := make(map[string]float64, s.Cardinality())
+		s.ForEach(func(member string) bool {
+			members[member] = 1
+			return true
+		})
+		return members, nil
+	}
+	return nil, protocol.MakeErrReply("WRONGTYPE Operation against a key holding the wrong kind of value")
+}
+
+// parseZSetAlgebraArgs parses `numkeys key [key ...] [WEIGHTS weight ...] [AGGREGATE SUM|MIN|MAX] [WITHSCORES]`
+// WEIGHTS and AGGREGATE are rejected for the ZDIFF family, WITHSCORES for the store variants
+func parseZSetAlgebraArgs(cmdName string, args [][]byte, withWeights bool, withScores bool) (*zsetAlgebraArgs, *protocol.StandardErrReply) {
+	if len(args) < 2 {
+		return nil, protocol.MakeErrReply("ERR wrong number of arguments for '" + cmdName + "' command")
+	}
+
+	numKeys, err := strconv.Atoi(string(args[0]))
+	if err != nil {
+		return nil, protocol.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	if numKeys <= 0 {
+		return nil, protocol.MakeErrReply("ERR at least 1 input key is needed for '" + cmdName + "' command")
+	}
+	if numKeys > len(args)-1 {
+		return nil, protocol.MakeErrReply("ERR syntax error")
+	}
+
+	parsed := &zsetAlgebraArgs{
+		keys:      make([]string, numKeys),
+		aggregate: aggregateSum,
+	}
+	for i := 0; i < numKeys; i++ {
+		parsed.keys[i] = string(args[i+1])
+	}
+
+	for i := numKeys + 1; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i]))
+		switch {
+		case option == "WEIGHTS" && withWeights:
+			if i+numKeys >= len(args) {
+				return nil, protocol.MakeErrReply("ERR syntax error")
+			}
+			parsed.weights = make([]float64, numKeys)
+			for j := 0; j < numKeys; j++ {
+				weight, err := strconv.ParseFloat(string(args[i+1+j]), 64)
+				if err != nil {
+					return nil, protocol.MakeErrReply("ERR weight value is not a float")
+				}
+				parsed.weights[j] = weight
+			}
+			i += numKeys
+		case option == "AGGREGATE" && withWeights:
+			if i+1 >= len(args) {
+				return nil, protocol.MakeErrReply("ERR syntax error")
+			}
+			switch strings.ToUpper(string(args[i+1])) {
+			case "SUM":
+				parsed.aggregate = aggregateSum
+			case "MIN":
+				parsed.aggregate = aggregateMin
+			case "MAX":
+				parsed.aggregate = aggregateMax
+			default:
+				return nil, protocol.MakeErrReply("ERR syntax error")
+			}
+			i++
+		case option == "WITHSCORES" && withScores:
+			parsed.withScores = true
+		default:
+			return nil, protocol.MakeErrReply("ERR syntax error")
+		}
+	}
+	return parsed, nil
+}
+
+// loadScoredMembers reads every input key of a ZUNION/ZINTER/ZDIFF command
+func loadScoredMembers(db *Redis, keys []string) ([]map[string]float64, *protocol.StandardErrReply) {
+	sets := make([]map[string]float64, 0, len(keys))
+	for _, key := range keys {
+		members, errReply := getAsScoredMembers(db, key)
+		if errReply != nil {
+			return nil, errReply
+		}
+		sets = append(sets, members)
+	}
+	return sets, nil
+}
+
+// weightedScore multiplies score by the weight of the i-th input set
+// NaN (e.g. 0 * inf) is treated as 0 like Redis does
+func weightedScore(score float64, weights []float64, i int) float64 {
+	if weights == nil {
+		return score
+	}
+	result := score * weights[i]
+	if math.IsNaN(result) {
+		return 0
+	}
+	return result
+}
+
+func aggregateScore(aggregate int, acc float64, score float64) float64 {
+	switch aggregate {
+	case aggregateMin:
+		return math.Min(acc, score)
+	case aggregateMax:
+		return math.Max(acc, score)
+	default:
+		sum := acc + score
+		// inf + -inf
+		if math.IsNaN(sum) {
+			return 0
+		}
+		return sum
+	}
+}
+
+func zsetUnion(sets []map[string]float64, weights []float64, aggregate int) *zset.ConcurrentSortedSet {
+	scores := make(map[string]float64)
+	for i, members := range sets {
+		for member, score := range members {
+			score = weightedScore(score, weights, i)
+			if acc, ok := scores[member]; ok {
+				scores[member] = aggregateScore(aggregate, acc, score)
+			} else {
+				scores[member] = score
+			}
+		}
+	}
+
+	result := zset.NewConcurrentSortedSet()
+	for member, score := range scores {
+		result.Add(member, score)
+	}
+	return result
+}
+
+func zsetInter(sets []map[string]float64, weights []float64, aggregate int) *zset.ConcurrentSortedSet {
+	result := zset.NewConcurrentSortedSet()
+	for member, score := range sets[0] {
+		acc := weightedScore(score, weights, 0)
+		inAll := true
+		for i := 1; i < len(sets); i++ {
+			other, ok := sets[i][member]
+			if !ok {
+				inAll = false
+				break
+			}
+			acc = aggregateScore(aggregate, acc, weightedScore(other, weights, i))
+		}
+		if inAll {
+			result.Add(member, acc)
+		}
+	}
+	return result
+}
+
+func zsetDiff(sets []map[string]float64) *zset.ConcurrentSortedSet {
+	result := zset.NewConcurrentSortedSet()
+	for member, score := range sets[0] {
+		found := false
+		for _, other := range sets[1:] {
+			if _, ok := other[member]; ok {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result.Add(member, score)
+		}
+	}
+	return result
+}
+
+// zsetToReply renders members in ascending order, followed by their scores if withScores is set
+func zsetToReply(zSet *zset.ConcurrentSortedSet, withScores bool) protocol.Reply {
+	if zSet.Len() == 0 {
+		return protocol.MakeEmptyMultiBulkReply()
+	}
+	size := zSet.Len()
+	if withScores {
+		size *= 2
+	}
+	result := make([][]byte, 0, size)
+	zSet.ForEach(false, func(element *zset.Element) bool {
+		result = append(result, []byte(element.Member))
+		if withScores {
+			result = append(result, formatScore(element.Score))
+		}
+		return true
+	})
+	return protocol.MakeMultiBulkReply(result)
+}
+
+// storeZSet replaces whatever is stored at dest with zSet
+// an empty result deletes dest, event is the keyspace event of the storing command
+func storeZSet(db *Redis, dest string, zSet *zset.ConcurrentSortedSet, event string) protocol.Reply {
+	db.locks.Lock(dest)
+	defer db.locks.Unlock(dest)
+	if zSet.Len() == 0 {
+		if db.data.Del(dest) {
+			db.notifyKeyspaceEvent(notifyGeneric, "del", dest)
+		}
+		return protocol.MakeIntReply(0)
+	}
+	db.data.Put(dest, &DataEntity{
+		Type:  TypeZset,
+		Value: zSet,
+	})
+	db.notifyKeyspaceEvent(notifyZset, event, dest)
+	db.blocking.signal(dest)
+	return protocol.MakeIntReply(zSet.Len())
+}
+
+// computeZSetAlgebra runs one of the ZUNION/ZINTER/ZDIFF operations over args
+// args starts with numkeys, the destination key of the store variants is already stripped
+func computeZSetAlgebra(db interfaces.DB, cmdName string, args [][]byte, store bool) (*zset.ConcurrentSortedSet, *zsetAlgebraArgs, protocol.Reply) {
+	isDiff := strings.HasPrefix(cmdName, "zdiff")
+	parsed, errReply := parseZSetAlgebraArgs(cmdName, args, !isDiff, !store)
+	if errReply != nil {
+		return nil, nil, errReply
+	}
+
+	redis, _ := db.(*Redis)
+	sets, errReply := loadScoredMembers(redis, parsed.keys)
+	if errReply != nil {
+		return nil, nil, errReply
+	}
+
+	var result *zset.ConcurrentSortedSet
+	switch {
+	case strings.HasPrefix(cmdName, "zunion"):
+		result = zsetUnion(sets, parsed.weights, parsed.aggregate)
+	case strings.HasPrefix(cmdName, "zinter"):
+		result = zsetInter(sets, parsed.weights, parsed.aggregate)
+	default:
+		result = zsetDiff(sets)
+	}
+	return result, parsed, nil
+}
+
+func execZSetAlgebra(db interfaces.DB, cmdName string, args [][]byte) protocol.Reply {
+	result, parsed, errReply := computeZSetAlgebra(db, cmdName, args, false)
+	if errReply != nil {
+		return errReply
+	}
+	return zsetToReply(result, parsed.withScores)
+}
+
+func execZSetAlgebraStore(db interfaces.DB, cmdName string, args [][]byte) protocol.Reply {
+	if len(args) < 3 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for '" + cmdName + "' command")
+	}
+	result, _, errReply := computeZSetAlgebra(db, cmdName, args[1:], true)
+	if errReply != nil {
+		return errReply
+	}
+	redis, _ := db.(*Redis)
+	return storeZSet(redis, string(args[0]), result, cmdName)
+}
+
+// ZUnion returns the union of the sorted sets (or sets) given by
+// `ZUNION numkeys key [key ...] [WEIGHTS weight ...] [AGGREGATE SUM|MIN|MAX] [WITHSCORES]`
+func ZUnion(db interfaces.DB, args [][]byte) protocol.Reply {
+	return execZSetAlgebra(db, "zunion", args)
+}
+
+// ZInter returns the intersection of the sorted sets (or sets) given by
+// `ZINTER numkeys key [key ...] [WEIGHTS weight ...] [AGGREGATE SUM|MIN|MAX] [WITHSCORES]`
+func ZInter(db interfaces.DB, args [][]byte) protocol.Reply {
+	return execZSetAlgebra(db, "zinter", args)
+}
+
+// ZDiff returns the members of the first sorted set that are not in the following ones
+// `ZDIFF numkeys key [key ...] [WITHSCORES]`
+func ZDiff(db interfaces.DB, args [][]byte) protocol.Reply {
+	return execZSetAlgebra(db, "zdiff", args)
+}
+
+// ZUnionStore is ZUNION storing the result in destination, it returns the cardinality of the result
+// `ZUNIONSTORE destination numkeys key [key ...] [WEIGHTS weight ...] [AGGREGATE SUM|MIN|MAX]`
+func ZUnionStore(db interfaces.DB, args [][]byte) protocol.Reply {
+	return execZSetAlgebraStore(db, "zunionstore", args)
+}
+
+// ZInterStore is ZINTER storing the result in destination, it returns the cardinality of the result
+// `ZINTERSTORE destination numkeys key [key ...] [WEIGHTS weight ...] [AGGREGATE SUM|MIN|MAX]`
+func ZInterStore(db interfaces.DB, args [][]byte) protocol.Reply {
+	return execZSetAlgebraStore(db, "zinterstore", args)
+}
+
+// ZDiffStore is ZDIFF storing the result in destination, it returns the cardinality of the result
+// `ZDIFFSTORE destination numkeys key [key ...]`
+func ZDiffStore(db interfaces.DB, args [][]byte) protocol.Reply {
+	return execZSetAlgebraStore(db, "zdiffstore", args)
+}
+
+// getZSetIfExists returns the sorted set stored at key without creating it
+// returns (nil, nil) if key doesn't exist
+func getZSetIfExists(db *Redis, key string) (*zset.ConcurrentSortedSet, *protocol.StandardErrReply) {
+	entity, exists := db.data.Get(key)
+	if !exists {
+		return nil, nil
+	}
+
+	dataEntity, ok := entity.(*DataEntity)
+	if !ok || dataEntity.Type != TypeZset {
+		return nil, protocol.MakeErrReply("WRONGTYPE Operation against a key holding the wrong kind of value")
+	}
+	return dataEntity.Value.(*zset.ConcurrentSortedSet), nil
+}
+
+// popFromZSet pops up to count members with the lowest (or highest if max is set) scores from key
+// the key is removed once its sorted set becomes empty
+func popFromZSet(db *Redis, key string, count int, max bool) ([]*zset.Element, *protocol.StandardErrReply) {
+	db.locks.Lock(key)
+	defer db.locks.Unlock(key)
+	zSet, errReply := getZSetIfExists(db, key)
+	if errReply != nil || zSet == nil {
+		return nil, errReply
+	}
+
+	var popped []*zset.Element
+	event := "zpopmin"
+	if max {
+		popped = zSet.PopMax(count)
+		event = "zpopmax"
+	} else {
+		popped = zSet.PopMin(count)
+	}
+	if len(popped) > 0 {
+		db.notifyKeyspaceEvent(notifyZset, event, key)
+	}
+	if zSet.Len() == 0 {
+		db.data.Del(key)
+		db.notifyKeyspaceEvent(notifyGeneric, "del", key)
+	}
+	return popped, nil
+}
+
+func elementsToReply(elements []*zset.Element) [][]byte {
+	result := make([][]byte, 0, len(elements)*2)
+	for _, element := range elements {
+		result = append(result, []byte(element.Member), formatScore(element.Score))
+	}
+	return result
+}
+
+func execZPop(db interfaces.DB, cmdName string, args [][]byte, max bool) protocol.Reply {
+	if len(args) != 1 && len(args) != 2 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for '" + cmdName + "' command")
+	}
+
+	count := 1
+	if len(args) == 2 {
+		var err error
+		count, err = strconv.Atoi(string(args[1]))
+		if err != nil || count < 0 {
+			return protocol.MakeErrReply("ERR value is out of range, must be positive")
+		}
+	}
+
+	redis, _ := db.(*Redis)
+	popped, errReply := popFromZSet(redis, string(args[0]), count, max)
+	if errReply != nil {
+		return errReply
+	}
+	if len(popped) == 0 {
+		return protocol.MakeEmptyMultiBulkReply()
+	}
+	return protocol.MakeMultiBulkReply(elementsToReply(popped))
+}
+
+func execBZPop(db interfaces.DB, cmdName string, args [][]byte, max bool) protocol.Reply {
+	if len(args) < 2 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for '" + cmdName + "' command")
+	}
+
+	timeout, errReply := parseBlockingTimeout(args[len(args)-1])
+	if errReply != nil {
+		return errReply
+	}
+	keys := make([]string, len(args)-1)
+	for i, arg := range args[:len(args)-1] {
+		keys[i] = string(arg)
+	}
+
+	redis, _ := db.(*Redis)
+	reply := redis.blockUntil(keys, timeout, func() protocol.Reply {
+		// keys are checked in the order given
+		for _, key := range keys {
+			popped, errReply := popFromZSet(redis, key, 1, max)
+			if errReply != nil {
+				return errReply
+			}
+			if len(popped) > 0 {
+				// appended to the AOF as the pop that happened, loading it mustn't block
+				redis.propagate(utils.ToCmdLine(strings.TrimPrefix(cmdName, "b"), key))
+				result := append([][]byte{[]byte(key)}, elementsToReply(popped)...)
+				return protocol.MakeMultiBulkReply(result)
+			}
+		}
+		return nil
+	})
+	if reply == nil {
+		// timed out, nothing changed
+		redis.propagate()
+		return protocol.MakeNullMultiBulkReply()
+	}
+	return reply
+}
+
+// ZPopMin removes and returns up to count members with the lowest scores in the sorted set stored at key
+// `ZPOPMIN key [count]`
+func ZPopMin(db interfaces.DB, args [][]byte) protocol.Reply {
+	return execZPop(db, "zpopmin", args, false)
+}
+
+// ZPopMax removes and returns up to count members with the highest scores in the sorted set stored at key
+// `ZPOPMAX key [count]`
+func ZPopMax(db interfaces.DB, args [][]byte) protocol.Reply {
+	return execZPop(db, "zpopmax", args, true)
+}
+
+// BZPopMin is the blocking variant of ZPOPMIN, it pops from the first non-empty sorted set
+// or blocks until one of the keys is written by ZADD, a timeout of 0 blocks forever
+// `BZPOPMIN key [key ...] timeout`
+func BZPopMin(db interfaces.DB, args [][]byte) protocol.Reply {
+	return execBZPop(db, "bzpopmin", args, false)
+}
+
+// BZPopMax is the blocking variant of ZPOPMAX
+// `BZPOPMAX key [key ...] timeout`
+func BZPopMax(db interfaces.DB, args [][]byte) protocol.Reply {
+	return execBZPop(db, "bzpopmax", args, true)
+}
+
+// ZMPop pops up to count members from the first non-empty sorted set among the given keys
+// it returns the key and the popped members with their scores
+// `ZMPOP numkeys key [key ...] MIN|MAX [COUNT count]`
+func ZMPop(db interfaces.DB, args [][]byte) protocol.Reply {
+	if len(args) < 3 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for 'zmpop' command")
+	}
+
+	numKeys, err := strconv.Atoi(string(args[0]))
+	if err != nil {
+		return protocol.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	if numKeys <= 0 {
+		return protocol.MakeErrReply("ERR numkeys should be greater than 0")
+	}
+	if numKeys+2 > len(args) {
+		return protocol.MakeErrReply("ERR syntax error")
+	}
+
+	var max bool
+	switch strings.ToUpper(string(args[numKeys+1])) {
+	case "MIN":
+		max = false
+	case "MAX":
+		max = true
+	default:
+		return protocol.MakeErrReply("ERR syntax error")
+	}
+
+	count := 1
+	rest := args[numKeys+2:]
+	if len(rest) != 0 {
+		if len(rest) != 2 || strings.ToUpper(string(rest[0])) != "COUNT" {
+			return protocol.MakeErrReply("ERR syntax error")
+		}
+		count, err = strconv.Atoi(string(rest[1]))
+		if err != nil || count <= 0 {
+			return protocol.MakeErrReply("ERR count should be greater than 0")
+		}
+	}
+
+	redis, _ := db.(*Redis)
+	for _, arg := range args[1 : numKeys+1] {
+		key := string(arg)
+		popped, errReply := popFromZSet(redis, key, count, max)
+		if errReply != nil {
+			return errReply
+		}
+		if len(popped) == 0 {
+			continue
+		}
+		elements := make([]protocol.Reply, len(popped))
+		for i, element := range popped {
+			elements[i] = protocol.MakeMultiBulkReply([][]byte{[]byte(element.Member), formatScore(element.Score)})
+		}
+		return protocol.MakeMultiRawReply([]protocol.Reply{
+			protocol.MakeBulkReply([]byte(key)),
+			protocol.MakeMultiRawReply(elements),
+		})
+	}
+	return protocol.MakeNullMultiBulkReply()
+}
+
+// maxRandomMembers bounds the members ZRANDMEMBER returns with a negative count,
+// they're picked and replied at once, unlike Redis which streams them
+const maxRandomMembers = 1 << 20
+
+// ZRandMember returns random members from the sorted set stored at key
+// a positive count returns distinct members, a negative count may return the same member multiple times
+// `ZRANDMEMBER key [count [WITHSCORES]]`
+func ZRandMember(db interfaces.DB, args [][]byte) protocol.Reply {
+	if len(args) < 1 || len(args) > 3 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for 'zrandmember' command")
+	}
+
+	var count int64
+	withScores := false
+	if len(args) >= 2 {
+		var err error
+		count, err = strconv.ParseInt(string(args[1]), 10, 64)
+		if err != nil {
+			return protocol.MakeErrReply("ERR value is not an integer or out of range")
+		}
+		if count < -maxRandomMembers {
+			return protocol.MakeErrReply("ERR value is out of range")
+		}
+	}
+	if len(args) == 3 {
+		if strings.ToUpper(string(args[2])) != "WITHSCORES" {
+			return protocol.MakeErrReply("ERR syntax error")
+		}
+		withScores = true
+	}
+
+	redis, _ := db.(*Redis)
+	zSet, errReply := getZSetIfExists(redis, string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+
+	if len(args) == 1 {
+		if zSet == nil {
+			return protocol.MakeNullBulkReply()
+		}
+		elements := zSet.RandomMembers(1)
+		if len(elements) == 0 {
+			return protocol.MakeNullBulkReply()
+		}
+		return protocol.MakeBulkReply([]byte(elements[0].Member))
+	}
+	if zSet == nil {
+		return protocol.MakeEmptyMultiBulkReply()
+	}
+
+	elements := zSet.RandomMembers(count)
+	if len(elements) == 0 {
+		return protocol.MakeEmptyMultiBulkReply()
+	}
+	if withScores {
+		return protocol.MakeMultiBulkReply(elementsToReply(elements))
+	}
+	result := make([][]byte, len(elements))
+	for i, element := range elements {
+		result[i] = []byte(element.Member)
+	}
+	return protocol.MakeMultiBulkReply(result)
+}
@@ -0,0 +1,349 @@
+package db
+
+import (
+	"fmt"
+	"godis/lib/utils"
+	"godis/redis/protocol"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestZSetAlgebra(t *testing.T) {
+	db := NewStandAloneDb()
+
+	ZAdd(db, utils.ToCmdLine("z1", "1", "a", "2", "b", "3", "c"))
+	ZAdd(db, utils.ToCmdLine("z2", "10", "b", "20", "c", "30", "d"))
+	SAdd(db, utils.ToCmdLine("s1", "c", "e"))
+
+	tests := []struct {
+		name     string
+		cmd      Exec
+		args     []string
+		expected string
+	}{
+		{
+			name:     "zunion with scores",
+			cmd:      ZUnion,
+			args:     []string{"2", "z1", "z2", "WITHSCORES"},
+			expected: "*8\r\n$1\r\na\r\n$1\r\n1\r\n$1\r\nb\r\n$2\r\n12\r\n$1\r\nc\r\n$2\r\n23\r\n$1\r\nd\r\n$2\r\n30\r\n",
+		},
+		{
+			name:     "zunion with weights and max",
+			cmd:      ZUnion,
+			args:     []string{"2", "z1", "z2", "WEIGHTS", "10", "1", "AGGREGATE", "MAX", "WITHSCORES"},
+			expected: "*8\r\n$1\r\na\r\n$2\r\n10\r\n$1\r\nb\r\n$2\r\n20\r\n$1\r\nc\r\n$2\r\n30\r\n$1\r\nd\r\n$2\r\n30\r\n",
+		},
+		{
+			name:     "zinter with min",
+			cmd:      ZInter,
+			args:     []string{"2", "z1", "z2", "AGGREGATE", "MIN", "WITHSCORES"},
+			expected: "*4\r\n$1\r\nb\r\n$1\r\n2\r\n$1\r\nc\r\n$1\r\n3\r\n",
+		},
+		{
+			name:     "zinter with a plain set",
+			cmd:      ZInter,
+			args:     []string{"2", "z2", "s1", "WITHSCORES"},
+			expected: "*2\r\n$1\r\nc\r\n$2\r\n21\r\n",
+		},
+		{
+			name:     "zdiff",
+			cmd:      ZDiff,
+			args:     []string{"3", "z1", "z2", "nosuchkey"},
+			expected: "*1\r\n$1\r\na\r\n",
+		},
+		{
+			name:     "zdiff rejects weights",
+			cmd:      ZDiff,
+			args:     []string{"2", "z1", "z2", "WEIGHTS", "1", "1"},
+			expected: "-ERR syntax error\r\n",
+		},
+		{
+			name:     "numkeys larger than keys",
+			cmd:      ZUnion,
+			args:     []string{"3", "z1", "z2"},
+			expected: "-ERR syntax error\r\n",
+		},
+		{
+			name:     "zero numkeys",
+			cmd:      ZInter,
+			args:     []string{"0", "z1"},
+			expected: "-ERR at least 1 input key is needed for 'zinter' command\r\n",
+		},
+		{
+			name:     "invalid weight",
+			cmd:      ZUnion,
+			args:     []string{"1", "z1", "WEIGHTS", "x"},
+			expected: "-ERR weight value is not a float\r\n",
+		},
+		{
+			name:     "zunionstore",
+			cmd:      ZUnionStore,
+			args:     []string{"dest", "3", "z1", "z2", "s1"},
+			expected: ":5\r\n",
+		},
+		{
+			name:     "zunionstore rejects withscores",
+			cmd:      ZUnionStore,
+			args:     []string{"dest", "1", "z1", "WITHSCORES"},
+			expected: "-ERR syntax error\r\n",
+		},
+		{
+			name:     "zinterstore",
+			cmd:      ZInterStore,
+			args:     []string{"inter", "2", "z1", "z2", "WEIGHTS", "2", "0.5"},
+			expected: ":2\r\n",
+		},
+		{
+			name:     "zdiffstore with empty result",
+			cmd:      ZDiffStore,
+			args:     []string{"z1", "2", "s1", "z2"},
+			expected: ":1\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := string(tt.cmd(db, utils.ToCmdLine(tt.args...)).ToBytes())
+			if actual != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, actual)
+			}
+		})
+	}
+
+	// stored results are regular sorted sets
+	actual := string(ZScore(db, utils.ToCmdLine("dest", "c")).ToBytes())
+	if actual != "$2\r\n24\r\n" {
+		t.Errorf("ZSCORE dest c expected 24, got %q", actual)
+	}
+	actual = string(ZScore(db, utils.ToCmdLine("inter", "b")).ToBytes())
+	if actual != "$1\r\n9\r\n" {
+		t.Errorf("ZSCORE inter b expected 9, got %q", actual)
+	}
+
+	// storing an empty result removes the destination
+	ZDiffStore(db, utils.ToCmdLine("dest", "2", "z1", "z1"))
+	if _, ok := db.data.Get("dest"); ok {
+		t.Errorf("expected dest to be deleted after storing an empty result")
+	}
+}
+
+func TestZSetPops(t *testing.T) {
+	db := NewStandAloneDb()
+	ZAdd(db, utils.ToCmdLine("pq", "1", "a", "2", "b", "3", "c", "4", "d"))
+
+	tests := []struct {
+		name     string
+		cmd      Exec
+		args     []string
+		expected string
+	}{
+		{
+			name:     "zpopmin",
+			cmd:      ZPopMin,
+			args:     []string{"pq"},
+			expected: "*2\r\n$1\r\na\r\n$1\r\n1\r\n",
+		},
+		{
+			name:     "zpopmax with count",
+			cmd:      ZPopMax,
+			args:     []string{"pq", "2"},
+			expected: "*4\r\n$1\r\nd\r\n$1\r\n4\r\n$1\r\nc\r\n$1\r\n3\r\n",
+		},
+		{
+			name:     "zpopmin negative count",
+			cmd:      ZPopMin,
+			args:     []string{"pq", "-1"},
+			expected: "-ERR value is out of range, must be positive\r\n",
+		},
+		{
+			name:     "zmpop skips missing keys",
+			cmd:      ZMPop,
+			args:     []string{"2", "nosuchkey", "pq", "MIN", "COUNT", "10"},
+			expected: "*2\r\n$2\r\npq\r\n*1\r\n*2\r\n$1\r\nb\r\n$1\r\n2\r\n",
+		},
+		{
+			name:     "zmpop on empty keys",
+			cmd:      ZMPop,
+			args:     []string{"1", "pq", "MAX"},
+			expected: "*-1\r\n",
+		},
+		{
+			name:     "zmpop invalid count",
+			cmd:      ZMPop,
+			args:     []string{"1", "pq", "MAX", "COUNT", "0"},
+			expected: "-ERR count should be greater than 0\r\n",
+		},
+		{
+			name:     "zrandmember on missing key",
+			cmd:      ZRandMember,
+			args:     []string{"pq"},
+			expected: "$-1\r\n",
+		},
+		{
+			name:     "bzpopmin timed out",
+			cmd:      BZPopMin,
+			args:     []string{"pq", "0.01"},
+			expected: "*-1\r\n",
+		},
+		{
+			name:     "bzpopmin negative timeout",
+			cmd:      BZPopMin,
+			args:     []string{"pq", "-1"},
+			expected: "-ERR timeout is negative\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := string(tt.cmd(db, utils.ToCmdLine(tt.args...)).ToBytes())
+			if actual != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, actual)
+			}
+		})
+	}
+
+	// emptied sorted sets are removed
+	if _, ok := db.data.Get("pq"); ok {
+		t.Errorf("expected pq to be removed once empty")
+	}
+}
+
+func TestZRandMember(t *testing.T) {
+	db := NewStandAloneDb()
+	ZAdd(db, utils.ToCmdLine("z", "1", "a", "2", "b", "3", "c"))
+
+	reply, ok := ZRandMember(db, utils.ToCmdLine("z", "2")).(*protocol.MultiBulkReply)
+	if !ok || len(reply.Args) != 2 || string(reply.Args[0]) == string(reply.Args[1]) {
+		t.Errorf("expected 2 distinct members, got %v", reply)
+	}
+
+	reply, ok = ZRandMember(db, utils.ToCmdLine("z", "10", "WITHSCORES")).(*protocol.MultiBulkReply)
+	if !ok || len(reply.Args) != 6 {
+		t.Errorf("expected every member with its score, got %v", reply)
+	}
+
+	reply, ok = ZRandMember(db, utils.ToCmdLine("z", "-5")).(*protocol.MultiBulkReply)
+	if !ok || len(reply.Args) != 5 {
+		t.Errorf("expected 5 members with repetitions, got %v", reply)
+	}
+
+	errReply, ok := ZRandMember(db, utils.ToCmdLine("z", strconv.FormatInt(math.MinInt64, 10))).(*protocol.StandardErrReply)
+	if !ok || errReply.Error() != "ERR value is out of range" {
+		t.Errorf("expected an out of range error, got %v", errReply)
+	}
+	errReply, ok = ZRandMember(db, utils.ToCmdLine("z", "-1000000000000")).(*protocol.StandardErrReply)
+	if !ok || errReply.Error() != "ERR value is out of range" {
+		t.Errorf("expected an out of range error, got %v", errReply)
+	}
+	// only the members picked are allocated, not -count of them
+	reply, ok = ZRandMember(db, utils.ToCmdLine("z", "-100000")).(*protocol.MultiBulkReply)
+	if !ok || len(reply.Args) != 100000 {
+		t.Errorf("expected 100000 members with repetitions, got %d", len(reply.Args))
+	}
+}
+
+func TestBZPopMinWakesUpOnZAdd(t *testing.T) {
+	db := NewStandAloneDb()
+
+	replyCh := make(chan protocol.Reply)
+	go func() {
+		replyCh <- BZPopMin(db, utils.ToCmdLine("q1", "q2", "0"))
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	ZAdd(db, utils.ToCmdLine("q2", "5", "job"))
+
+	select {
+	case reply := <-replyCh:
+		expected := "*3\r\n$2\r\nq2\r\n$3\r\njob\r\n$1\r\n5\r\n"
+		if string(reply.ToBytes()) != expected {
+			t.Errorf("expected %q, got %q", expected, string(reply.ToBytes()))
+		}
+	case <-time.After(time.Second):
+		t.Fatal("BZPOPMIN was not woken up by ZADD")
+	}
+}
+
+// run with -race to detect unsynchronized access to the sorted sets
+func TestConcurrentZSetOperations(t *testing.T) {
+	db := NewStandAloneDb()
+	key := "concurrent_zset"
+	var wg sync.WaitGroup
+	numGoroutines := 10
+	opsPerGoroutine := 100
+
+	// Concurrent ZADD operations racing with readers
+	wg.Add(numGoroutines * 2)
+	for i := 0; i < numGoroutines; i++ {
+		go func(id int) {
+			defer wg.Done()
+			for j := 0; j < opsPerGoroutine; j++ {
+				member := fmt.Sprintf("member_%d_%d", id, j)
+				ZAdd(db, utils.ToCmdLine(key, strconv.Itoa(j), member))
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < opsPerGoroutine; j++ {
+				ZRange(db, utils.ToCmdLine(key, "0", "-1"))
+				ZCard(db, utils.ToCmdLine(key))
+				ZRandMember(db, utils.ToCmdLine(key, "3"))
+			}
+		}()
+	}
+	wg.Wait()
+
+	result := string(ZCard(db, utils.ToCmdLine(key)).ToBytes())
+	expectedMembers := numGoroutines * opsPerGoroutine
+	if result != fmt.Sprintf(":%d\r\n", expectedMembers) {
+		t.Errorf("Expected %d members after concurrent ZADD, got: %s", expectedMembers, result)
+	}
+
+	// Concurrent ZREM, ZADD and ZPOPMIN operations
+	var popped int64
+	wg.Add(numGoroutines * 3)
+	for i := 0; i < numGoroutines; i++ {
+		go func(id int) {
+			defer wg.Done()
+			for j := 0; j < opsPerGoroutine; j++ {
+				member := fmt.Sprintf("member_%d_%d", id, j)
+				ZRemove(db, utils.ToCmdLine(key, member))
+			}
+		}(i)
+		go func(id int) {
+			defer wg.Done()
+			for j := 0; j < opsPerGoroutine; j++ {
+				member := fmt.Sprintf("new_member_%d_%d", id, j)
+				ZAdd(db, utils.ToCmdLine("queue", strconv.Itoa(j), member))
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < opsPerGoroutine; j++ {
+				reply := ZPopMin(db, utils.ToCmdLine("queue"))
+				if multi, ok := reply.(*protocol.MultiBulkReply); ok && len(multi.Args) == 2 {
+					atomic.AddInt64(&popped, 1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	result = string(ZCard(db, utils.ToCmdLine(key)).ToBytes())
+	if result != ":0\r\n" {
+		t.Errorf("Expected every member to be removed, got: %s", result)
+	}
+
+	// nothing is lost between ZADD and a ZPOPMIN emptying the key
+	remaining := int64(0)
+	if reply, ok := ZCard(db, utils.ToCmdLine("queue")).(*protocol.IntReply); ok {
+		remaining = reply.Code
+	}
+	if popped+remaining != int64(expectedMembers) {
+		t.Errorf("Expected popped + remaining = %d, got %d + %d", expectedMembers, popped, remaining)
+	}
+}
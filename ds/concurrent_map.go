@@ -1,8 +1,10 @@
 package ds
 
 import (
+	"container/heap"
 	"log"
 	"math"
+	"math/bits"
 	"sync"
 	"sync/atomic"
 )
@@ -124,6 +126,25 @@ func (m *ShardedMap) Put(key string, val any) bool {
 	return true
 }
 
+// PutIfAbsent inserts the key/value pair only if key doesn't exist
+// returns the value stored at key after the call and whether val was inserted
+func (m *ShardedMap) PutIfAbsent(key string, val any) (any, bool) {
+	if m == nil {
+		panic("map is nil")
+	}
+	shard := m.locate(m.spread(fnv32(key)))
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if existing, ok := shard.m[key]; ok {
+		return existing, false
+	}
+	shard.m[key] = val
+	m.increCount()
+	return val, true
+}
+
 func (m *ShardedMap) Del(key string) bool {
 	if m == nil {
 		panic("map is nil")
@@ -187,6 +208,84 @@ func (m *ShardedMap) ForEach(consumer func(key string, val any) bool) {
 	}
 }
 
+// Scan calls consumer on about count pairs from cursor, and returns the cursor to go on from, 0 once every pair was seen
+// the pairs are visited in the order of their position, their hash code with the bits locating the shard first,
+// so a key present during the whole scan is seen exactly once. the keys sharing a position are seen by the same call,
+// which may call consumer on a few more than count pairs
+func (m *ShardedMap) Scan(cursor uint32, count int, consumer func(key string, val any)) uint32 {
+	if m == nil {
+		panic("map is nil")
+	}
+
+	shift := bits.TrailingZeros(uint(len(m.table)))
+	position := func(key string) uint32 {
+		return bits.RotateLeft32(fnv32(key), -shift)
+	}
+	from := cursor
+	for index := int(m.spread(bits.RotateLeft32(cursor, shift))); index < len(m.table); index++ {
+		seen, last, full := m.table[index].scan(from, count, position, consumer)
+		if full {
+			if last == math.MaxUint32 {
+				return 0
+			}
+			return last + 1
+		}
+		count -= seen
+		// the first position of the next shard
+		from = uint32(index+1) << (32 - shift)
+	}
+	return 0
+}
+
+// scan calls consumer on the pairs of the shard from the position from, up to the count lowest positions
+// returns the number of pairs seen, and whether count was reached with the last position seen
+func (s *Shard) scan(from uint32, count int, position func(key string) uint32, consumer func(key string, val any)) (int, uint32, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// the count lowest positions, the highest on top
+	lowest := &positionHeap{}
+	for k := range s.m {
+		pos := position(k)
+		if pos < from {
+			continue
+		}
+		if lowest.Len() < count {
+			heap.Push(lowest, pos)
+		} else if pos < (*lowest)[0] {
+			(*lowest)[0] = pos
+			heap.Fix(lowest, 0)
+		}
+	}
+	full := lowest.Len() == count
+	last := uint32(math.MaxUint32)
+	if full {
+		last = (*lowest)[0]
+	}
+	seen := 0
+	for k, v := range s.m {
+		if pos := position(k); pos >= from && pos <= last {
+			consumer(k, v)
+			seen++
+		}
+	}
+	return seen, last, full
+}
+
+// positionHeap is a max-heap of the positions of keys
+type positionHeap []uint32
+
+func (h positionHeap) Len() int           { return len(h) }
+func (h positionHeap) Less(i, j int) bool { return h[i] > h[j] }
+func (h positionHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *positionHeap) Push(x any)        { *h = append(*h, x.(uint32)) }
+func (h *positionHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
 func (m *ShardedMap) Keys() []string {
 	results := make([]string, 0, m.Len())
 	m.ForEach(func(key string, val any) bool {
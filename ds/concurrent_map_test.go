@@ -4,6 +4,7 @@ import (
 	"math/rand"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -113,3 +114,66 @@ func TestRandomOpsConcurrently(t *testing.T) {
 		t.Logf("%s: %d", op, count)
 	}
 }
+
+func TestConcurrentPutIfAbsent(t *testing.T) {
+	d := NewShardedMap(16)
+	count := 100
+	var inserted int32
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			val, ok := d.PutIfAbsent("k", i)
+			if ok {
+				atomic.AddInt32(&inserted, 1)
+			}
+			if stored, _ := d.Get("k"); stored != val {
+				t.Errorf("PutIfAbsent returned %v, but %v is stored", val, stored)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if inserted != 1 {
+		t.Errorf("expected exactly one insertion, got %d", inserted)
+	}
+	if d.Len() != 1 {
+		t.Errorf("expected length 1, got %d", d.Len())
+	}
+}
+
+func TestScan(t *testing.T) {
+	d := NewShardedMap(16)
+	for i := 0; i < 10000; i++ {
+		d.Put("k"+strconv.Itoa(i), i)
+	}
+
+	seen := make(map[string]int)
+	cursor := uint32(0)
+	for calls := 0; ; calls++ {
+		n := 0
+		cursor = d.Scan(cursor, 10, func(key string, val any) {
+			seen[key]++
+			n++
+		})
+		// only the keys sharing a position go beyond count
+		if n > 12 {
+			t.Fatalf("expected about 10 keys a call, got %d", n)
+		}
+		if cursor == 0 {
+			break
+		}
+		if calls > 10000 {
+			t.Fatal("the scan doesn't end")
+		}
+	}
+	if len(seen) != 10000 {
+		t.Errorf("expected 10000 keys, got %d", len(seen))
+	}
+	for key, n := range seen {
+		if n != 1 {
+			t.Errorf("%s seen %d times", key, n)
+		}
+	}
+}
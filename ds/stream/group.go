@@ -0,0 +1,591 @@
+package stream
+
+import (
+	"errors"
+	"sort"
+)
+
+var (
+	ErrGroupExists = errors.New("BUSYGROUP Consumer Group name already exists")
+	// ErrNoGroup is returned if the consumer group doesn't exist, callers add the key and group to the message
+	ErrNoGroup = errors.New("NOGROUP")
+)
+
+// PendingEntry is an entry delivered to a consumer of a group but not acknowledged yet
+type PendingEntry struct {
+	ID       ID
+	Consumer string
+	// unix time in milliseconds of the last delivery
+	DeliveryTime  int64
+	DeliveryCount int64
+}
+
+// Consumer is a member of a consumer group
+type Consumer struct {
+	Name string
+	// unix time in milliseconds of the last interaction, and of the last successful read or claim
+	// ActiveTime is -1 if the consumer never read anything
+	SeenTime   int64
+	ActiveTime int64
+	pending    map[ID]*PendingEntry
+}
+
+// Group is a consumer group, it keeps the last delivered ID and the pending entries list (PEL)
+type Group struct {
+	Name            string
+	LastDeliveredID ID
+	// number of entries read by the group, -1 if unknown
+	EntriesRead int64
+	pel         map[ID]*PendingEntry
+	// IDs of pel in ascending order
+	pelIDs    []ID
+	consumers map[string]*Consumer
+}
+
+// ClaimOptions holds the options of XCLAIM
+// Idle, Time and RetryCount are ignored if negative
+type ClaimOptions struct {
+	Idle       int64
+	Time       int64
+	RetryCount int64
+	Force      bool
+	JustID     bool
+}
+
+// PendingSummary is the summary form of XPENDING
+type PendingSummary struct {
+	Count     int64
+	MinID     ID
+	MaxID     ID
+	Consumers map[string]int64
+}
+
+// GroupInfo is reported by XINFO GROUPS
+type GroupInfo struct {
+	Name            string
+	Consumers       int64
+	Pending         int64
+	LastDeliveredID ID
+	EntriesRead     int64
+	Lag             int64
+}
+
+// ConsumerInfo is reported by XINFO CONSUMERS, idle times are in milliseconds
+type ConsumerInfo struct {
+	Name     string
+	Pending  int64
+	Idle     int64
+	Inactive int64
+}
+
+func (g *Group) addPending(pe *PendingEntry) {
+	if _, ok := g.pel[pe.ID]; !ok {
+		i := sort.Search(len(g.pelIDs), func(i int) bool {
+			return !g.pelIDs[i].Less(pe.ID)
+		})
+		g.pelIDs = append(g.pelIDs, ID{})
+		copy(g.pelIDs[i+1:], g.pelIDs[i:])
+		g.pelIDs[i] = pe.ID
+	}
+	g.pel[pe.ID] = pe
+}
+
+func (g *Group) removePending(id ID) *PendingEntry {
+	pe, ok := g.pel[id]
+	if !ok {
+		return nil
+	}
+	delete(g.pel, id)
+	i := sort.Search(len(g.pelIDs), func(i int) bool {
+		return !g.pelIDs[i].Less(id)
+	})
+	g.pelIDs = append(g.pelIDs[:i], g.pelIDs[i+1:]...)
+	if consumer, ok := g.consumers[pe.Consumer]; ok {
+		delete(consumer.pending, id)
+	}
+	return pe
+}
+
+// consumer returns the named consumer, it is created if it doesn't exist
+func (g *Group) consumer(name string, nowMs int64) *Consumer {
+	c, ok := g.consumers[name]
+	if !ok {
+		c = &Consumer{
+			Name:       name,
+			ActiveTime: -1,
+			pending:    make(map[ID]*PendingEntry),
+		}
+		g.consumers[name] = c
+	}
+	c.SeenTime = nowMs
+	return c
+}
+
+// deliver makes consumer the owner of the pending entry of id
+func (g *Group) deliver(consumer *Consumer, id ID, nowMs int64) *PendingEntry {
+	pe, ok := g.pel[id]
+	if ok {
+		if owner, ok := g.consumers[pe.Consumer]; ok {
+			delete(owner.pending, id)
+		}
+	} else {
+		pe = &PendingEntry{
+			ID: id,
+		}
+		g.addPending(pe)
+	}
+	pe.Consumer = consumer.Name
+	pe.DeliveryTime = nowMs
+	consumer.pending[id] = pe
+	return pe
+}
+
+func (s *Stream) group(name string) (*Group, error) {
+	g, ok := s.groups[name]
+	if !ok {
+		return nil, ErrNoGroup
+	}
+	return g, nil
+}
+
+// get returns the entry of id, or nil if it doesn't exist
+func (s *Stream) get(id ID) *Entry {
+	nodeIdx, entryIdx := s.search(func(other ID) bool {
+		return !other.Less(id)
+	})
+	if nodeIdx == len(s.nodes) {
+		return nil
+	}
+	entries := s.nodes[nodeIdx].entries
+	if entryIdx == len(entries) || entries[entryIdx].ID != id {
+		return nil
+	}
+	return entries[entryIdx]
+}
+
+// resolveGroupID returns the last ID of the stream if useLast is set, i.e. `$`
+func (s *Stream) resolveGroupID(id ID, useLast bool, entriesRead int64) (ID, int64) {
+	if useLast {
+		return s.lastID, int64(s.entriesAdded)
+	}
+	if entriesRead < 0 && id == MinID {
+		entriesRead = 0
+	}
+	return id, entriesRead
+}
+
+// CreateGroup creates a consumer group which delivers entries after id, or after the last entry if useLast is set
+// entriesRead is the number of entries already read by the group, -1 if unknown
+func (s *Stream) CreateGroup(name string, id ID, useLast bool, entriesRead int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.groups[name]; ok {
+		return ErrGroupExists
+	}
+	if s.groups == nil {
+		s.groups = make(map[string]*Group)
+	}
+	lastDelivered, read := s.resolveGroupID(id, useLast, entriesRead)
+	s.groups[name] = &Group{
+		Name:            name,
+		LastDeliveredID: lastDelivered,
+		EntriesRead:     read,
+		pel:             make(map[ID]*PendingEntry),
+		consumers:       make(map[string]*Consumer),
+	}
+	return nil
+}
+
+// SetGroupID sets the last delivered ID of a consumer group
+func (s *Stream) SetGroupID(name string, id ID, useLast bool, entriesRead int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g, err := s.group(name)
+	if err != nil {
+		return err
+	}
+	g.LastDeliveredID, g.EntriesRead = s.resolveGroupID(id, useLast, entriesRead)
+	return nil
+}
+
+// DestroyGroup removes a consumer group, returns false if it doesn't exist
+func (s *Stream) DestroyGroup(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.groups[name]; !ok {
+		return false
+	}
+	delete(s.groups, name)
+	return true
+}
+
+// CreateConsumer returns whether the consumer was created
+func (s *Stream) CreateConsumer(group string, consumer string, nowMs int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g, err := s.group(group)
+	if err != nil {
+		return false, err
+	}
+	if _, ok := g.consumers[consumer]; ok {
+		return false, nil
+	}
+	g.consumer(consumer, nowMs)
+	return true, nil
+}
+
+// DeleteConsumer removes a consumer and its pending entries
+// returns the number of pending entries it had
+func (s *Stream) DeleteConsumer(group string, consumer string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g, err := s.group(group)
+	if err != nil {
+		return 0, err
+	}
+	c, ok := g.consumers[consumer]
+	if !ok {
+		return 0, nil
+	}
+	pending := int64(len(c.pending))
+	for id := range c.pending {
+		g.removePending(id)
+	}
+	delete(g.consumers, consumer)
+	return pending, nil
+}
+
+// ReadGroup reads entries for a consumer of a group, at most count entries if count > 0
+// with newOnly set (the `>` ID), entries never delivered to the group are returned and added
+// to the PEL of the consumer unless noAck is set
+// otherwise the pending entries of the consumer with ID greater than after are returned,
+// an entry deleted from the stream since then is returned with nil Fields
+func (s *Stream) ReadGroup(group string, consumer string, after ID, newOnly bool, count int, noAck bool, nowMs int64) ([]*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g, err := s.group(group)
+	if err != nil {
+		return nil, err
+	}
+	c := g.consumer(consumer, nowMs)
+
+	result := make([]*Entry, 0)
+	if !newOnly {
+		ids := make([]ID, 0, len(c.pending))
+		for id := range c.pending {
+			if after.Less(id) {
+				ids = append(ids, id)
+			}
+		}
+		sort.Slice(ids, func(i, j int) bool {
+			return ids[i].Less(ids[j])
+		})
+		for _, id := range ids {
+			if count > 0 && len(result) >= count {
+				break
+			}
+			entry := s.get(id)
+			if entry == nil {
+				entry = &Entry{ID: id}
+			}
+			result = append(result, entry)
+		}
+		return result, nil
+	}
+
+	start, ok := g.LastDeliveredID.Next()
+	if !ok {
+		return result, nil
+	}
+	for _, entry := range s.rangeEntries(start, MaxID, count, false) {
+		g.LastDeliveredID = entry.ID
+		if g.EntriesRead >= 0 {
+			g.EntriesRead++
+		}
+		if !noAck {
+			pe := g.deliver(c, entry.ID, nowMs)
+			pe.DeliveryCount = 1
+		}
+		result = append(result, entry)
+	}
+	if len(result) > 0 {
+		c.ActiveTime = nowMs
+	}
+	return result, nil
+}
+
+// Ack removes the given IDs from the PEL of the group, returns the number of acknowledged entries
+func (s *Stream) Ack(group string, ids []ID) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g, err := s.group(group)
+	if err != nil {
+		return 0, err
+	}
+	var acked int64
+	for _, id := range ids {
+		if g.removePending(id) != nil {
+			acked++
+		}
+	}
+	return acked, nil
+}
+
+// Pending returns the pending entry of id in the group
+func (s *Stream) Pending(group string, id ID) (PendingEntry, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	g, err := s.group(group)
+	if err != nil {
+		return PendingEntry{}, false
+	}
+	pe, ok := g.pel[id]
+	if !ok {
+		return PendingEntry{}, false
+	}
+	return *pe, true
+}
+
+// LastDelivered returns the last delivered ID of the group and the number of entries it read
+func (s *Stream) LastDelivered(group string) (ID, int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	g, err := s.group(group)
+	if err != nil {
+		return ID{}, 0, err
+	}
+	return g.LastDeliveredID, g.EntriesRead, nil
+}
+
+// PendingSummary returns the number of pending entries, their ID range, and the count per consumer
+func (s *Stream) PendingSummary(group string) (*PendingSummary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	g, err := s.group(group)
+	if err != nil {
+		return nil, err
+	}
+	summary := &PendingSummary{
+		Count:     int64(len(g.pelIDs)),
+		Consumers: make(map[string]int64),
+	}
+	if len(g.pelIDs) > 0 {
+		summary.MinID = g.pelIDs[0]
+		summary.MaxID = g.pelIDs[len(g.pelIDs)-1]
+	}
+	for _, c := range g.consumers {
+		if len(c.pending) > 0 {
+			summary.Consumers[c.Name] = int64(len(c.pending))
+		}
+	}
+	return summary, nil
+}
+
+// PendingRange returns up to count pending entries within [start, end] that are idle for at least minIdle ms
+// only the entries of consumer are returned if it isn't empty
+func (s *Stream) PendingRange(group string, start ID, end ID, count int, consumer string, minIdle int64, nowMs int64) ([]PendingEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	g, err := s.group(group)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]PendingEntry, 0)
+	i := sort.Search(len(g.pelIDs), func(i int) bool {
+		return !g.pelIDs[i].Less(start)
+	})
+	for ; i < len(g.pelIDs) && len(result) < count; i++ {
+		id := g.pelIDs[i]
+		if end.Less(id) {
+			break
+		}
+		pe := g.pel[id]
+		if consumer != "" && pe.Consumer != consumer {
+			continue
+		}
+		if nowMs-pe.DeliveryTime < minIdle {
+			continue
+		}
+		result = append(result, *pe)
+	}
+	return result, nil
+}
+
+// claim transfers the pending entry of id to consumer if it is idle for at least minIdle ms
+// returns nil if nothing is claimed
+func (s *Stream) claim(g *Group, c *Consumer, id ID, minIdle int64, opts *ClaimOptions, nowMs int64) *Entry {
+	pe, ok := g.pel[id]
+	entry := s.get(id)
+	if !ok {
+		if !opts.Force || entry == nil {
+			return nil
+		}
+	} else if entry == nil {
+		// deleted from the stream, there is nothing left to process
+		g.removePending(id)
+		return nil
+	} else if minIdle > 0 && nowMs-pe.DeliveryTime < minIdle {
+		return nil
+	}
+
+	pe = g.deliver(c, id, nowMs)
+	if opts.Idle >= 0 {
+		pe.DeliveryTime = nowMs - opts.Idle
+	} else if opts.Time >= 0 {
+		pe.DeliveryTime = opts.Time
+	}
+	if opts.RetryCount >= 0 {
+		pe.DeliveryCount = opts.RetryCount
+	} else if !opts.JustID {
+		pe.DeliveryCount++
+	}
+	if opts.JustID {
+		return &Entry{ID: id}
+	}
+	return entry
+}
+
+// Claim changes the ownership of pending entries idle for at least minIdle ms to consumer
+// entries deleted from the stream are removed from the PEL instead
+func (s *Stream) Claim(group string, consumer string, minIdle int64, ids []ID, opts ClaimOptions, nowMs int64) ([]*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g, err := s.group(group)
+	if err != nil {
+		return nil, err
+	}
+	c := g.consumer(consumer, nowMs)
+	result := make([]*Entry, 0)
+	for _, id := range ids {
+		if entry := s.claim(g, c, id, minIdle, &opts, nowMs); entry != nil {
+			result = append(result, entry)
+		}
+	}
+	if len(result) > 0 {
+		c.ActiveTime = nowMs
+	}
+	return result, nil
+}
+
+// AutoClaim claims up to count pending entries idle for at least minIdle ms, scanning the PEL from start
+// returns the ID to start the next scan from (0-0 once the whole PEL has been scanned),
+// the claimed entries and the IDs deleted from the stream which were removed from the PEL
+func (s *Stream) AutoClaim(group string, consumer string, minIdle int64, start ID, count int, justID bool, nowMs int64) (ID, []*Entry, []ID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g, err := s.group(group)
+	if err != nil {
+		return ID{}, nil, nil, err
+	}
+	c := g.consumer(consumer, nowMs)
+	opts := &ClaimOptions{
+		Idle:       -1,
+		Time:       -1,
+		RetryCount: -1,
+		JustID:     justID,
+	}
+
+	claimed := make([]*Entry, 0)
+	deleted := make([]ID, 0)
+	// bound the work done by a single call like Redis does
+	attempts := count * 10
+	i := sort.Search(len(g.pelIDs), func(i int) bool {
+		return !g.pelIDs[i].Less(start)
+	})
+	for i < len(g.pelIDs) && len(claimed) < count && attempts > 0 {
+		attempts--
+		id := g.pelIDs[i]
+		if s.get(id) == nil {
+			g.removePending(id)
+			deleted = append(deleted, id)
+			continue
+		}
+		if entry := s.claim(g, c, id, minIdle, opts, nowMs); entry != nil {
+			claimed = append(claimed, entry)
+		}
+		i++
+	}
+
+	next := MinID
+	if i < len(g.pelIDs) {
+		next = g.pelIDs[i]
+	}
+	if len(claimed) > 0 {
+		c.ActiveTime = nowMs
+	}
+	return next, claimed, deleted, nil
+}
+
+// GroupsInfo returns the consumer groups sorted by name
+func (s *Stream) GroupsInfo() []GroupInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]GroupInfo, 0, len(s.groups))
+	for _, g := range s.groups {
+		lag := int64(-1)
+		if g.EntriesRead >= 0 {
+			lag = int64(s.entriesAdded) - g.EntriesRead
+		}
+		result = append(result, GroupInfo{
+			Name:            g.Name,
+			Consumers:       int64(len(g.consumers)),
+			Pending:         int64(len(g.pelIDs)),
+			LastDeliveredID: g.LastDeliveredID,
+			EntriesRead:     g.EntriesRead,
+			Lag:             lag,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// ConsumersInfo returns the consumers of a group sorted by name
+func (s *Stream) ConsumersInfo(group string, nowMs int64) ([]ConsumerInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	g, err := s.group(group)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]ConsumerInfo, 0, len(g.consumers))
+	for _, c := range g.consumers {
+		inactive := int64(-1)
+		if c.ActiveTime >= 0 {
+			inactive = nowMs - c.ActiveTime
+		}
+		result = append(result, ConsumerInfo{
+			Name:     c.Name,
+			Pending:  int64(len(c.pending)),
+			Idle:     nowMs - c.SeenTime,
+			Inactive: inactive,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+// GroupCount returns the number of consumer groups
+func (s *Stream) GroupCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.groups)
+}
@@ -0,0 +1,91 @@
+package stream
+
+import (
+	"testing"
+)
+
+func TestGroupReadAndAck(t *testing.T) {
+	s := NewStream()
+	addN(s, 5)
+	if err := s.CreateGroup("g", MinID, false, -1); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CreateGroup("g", MinID, false, -1); err != ErrGroupExists {
+		t.Errorf("Expected ErrGroupExists, got %v", err)
+	}
+	if _, err := s.ReadGroup("nogroup", "alice", MinID, true, 0, false, 0); err != ErrNoGroup {
+		t.Errorf("Expected ErrNoGroup, got %v", err)
+	}
+
+	entries, _ := s.ReadGroup("g", "alice", MinID, true, 2, false, 100)
+	if len(entries) != 2 || entries[1].ID != (ID{Ms: 1000, Seq: 1}) {
+		t.Fatalf("Expected 2 entries up to 1000-1, got %v", entries)
+	}
+	// new entries are never delivered twice to the group
+	entries, _ = s.ReadGroup("g", "bob", MinID, true, 0, false, 100)
+	if len(entries) != 3 || entries[0].ID != (ID{Ms: 1000, Seq: 2}) {
+		t.Fatalf("Expected 3 entries from 1000-2, got %v", entries)
+	}
+
+	summary, _ := s.PendingSummary("g")
+	if summary.Count != 5 || summary.Consumers["alice"] != 2 || summary.Consumers["bob"] != 3 {
+		t.Errorf("Unexpected pending summary %+v", summary)
+	}
+
+	acked, _ := s.Ack("g", []ID{{Ms: 1000, Seq: 0}, {Ms: 1000, Seq: 0}, {Ms: 9}})
+	if acked != 1 {
+		t.Errorf("Expected 1 acknowledged entry, got %d", acked)
+	}
+
+	// the history of a consumer returns deleted entries without fields
+	s.Delete(ID{Ms: 1000, Seq: 1})
+	entries, _ = s.ReadGroup("g", "alice", MinID, false, 0, false, 200)
+	if len(entries) != 1 || entries[0].ID != (ID{Ms: 1000, Seq: 1}) || entries[0].Fields != nil {
+		t.Errorf("Expected deleted entry 1000-1, got %v", entries)
+	}
+
+	groups := s.GroupsInfo()
+	if len(groups) != 1 || groups[0].Pending != 4 || groups[0].Lag != 0 {
+		t.Errorf("Unexpected groups info %+v", groups)
+	}
+}
+
+func TestGroupClaim(t *testing.T) {
+	s := NewStream()
+	addN(s, 3)
+	_ = s.CreateGroup("g", MinID, false, 0)
+	_, _ = s.ReadGroup("g", "alice", MinID, true, 0, false, 100)
+
+	opts := ClaimOptions{Idle: -1, Time: -1, RetryCount: -1}
+	claimed, _ := s.Claim("g", "bob", 50, []ID{{Ms: 1000, Seq: 0}}, opts, 120)
+	if len(claimed) != 0 {
+		t.Errorf("Expected nothing claimed before min idle time, got %v", claimed)
+	}
+	claimed, _ = s.Claim("g", "bob", 50, []ID{{Ms: 1000, Seq: 0}}, opts, 200)
+	if len(claimed) != 1 {
+		t.Fatalf("Expected 1000-0 claimed, got %v", claimed)
+	}
+	pending, _ := s.PendingRange("g", MinID, MaxID, 10, "bob", 0, 200)
+	if len(pending) != 1 || pending[0].DeliveryCount != 2 || pending[0].DeliveryTime != 200 {
+		t.Errorf("Unexpected pending entries of bob %+v", pending)
+	}
+
+	s.Delete(ID{Ms: 1000, Seq: 1})
+	next, claimed, deleted, _ := s.AutoClaim("g", "carol", 50, MinID, 1, false, 300)
+	if next != (ID{Ms: 1000, Seq: 1}) || len(claimed) != 1 || len(deleted) != 0 {
+		t.Errorf("Unexpected autoclaim result %v %v %v", next, claimed, deleted)
+	}
+	// the deleted entry is removed from the PEL while scanning
+	next, claimed, deleted, _ = s.AutoClaim("g", "carol", 50, next, 10, true, 300)
+	if next != MinID || len(claimed) != 1 || claimed[0].Fields != nil || len(deleted) != 1 {
+		t.Errorf("Unexpected autoclaim result %v %v %v", next, claimed, deleted)
+	}
+
+	consumers, _ := s.ConsumersInfo("g", 300)
+	if len(consumers) != 3 || consumers[2].Name != "carol" || consumers[2].Pending != 2 {
+		t.Errorf("Unexpected consumers info %+v", consumers)
+	}
+	if removed, _ := s.DeleteConsumer("g", "carol"); removed != 2 {
+		t.Errorf("Expected 2 pending entries removed, got %d", removed)
+	}
+}
@@ -0,0 +1,112 @@
+package stream
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidID = errors.New("ERR Invalid stream ID specified as stream command argument")
+)
+
+// ID identifies a stream entry, entries are ordered by milliseconds then sequence
+type ID struct {
+	Ms  uint64
+	Seq uint64
+}
+
+var (
+	MinID = ID{Ms: 0, Seq: 0}
+	MaxID = ID{Ms: math.MaxUint64, Seq: math.MaxUint64}
+)
+
+func (id ID) String() string {
+	return strconv.FormatUint(id.Ms, 10) + "-" + strconv.FormatUint(id.Seq, 10)
+}
+
+// Compare returns -1, 0 or 1 if id is less than, equal to or greater than other
+func (id ID) Compare(other ID) int {
+	switch {
+	case id.Ms < other.Ms:
+		return -1
+	case id.Ms > other.Ms:
+		return 1
+	case id.Seq < other.Seq:
+		return -1
+	case id.Seq > other.Seq:
+		return 1
+	}
+	return 0
+}
+
+func (id ID) Less(other ID) bool {
+	return id.Compare(other) < 0
+}
+
+// Next returns the smallest ID greater than id
+// returns false if id is already the max ID
+func (id ID) Next() (ID, bool) {
+	if id.Seq < math.MaxUint64 {
+		return ID{Ms: id.Ms, Seq: id.Seq + 1}, true
+	}
+	if id.Ms < math.MaxUint64 {
+		return ID{Ms: id.Ms + 1, Seq: 0}, true
+	}
+	return id, false
+}
+
+// Prev returns the greatest ID less than id
+// returns false if id is already the min ID
+func (id ID) Prev() (ID, bool) {
+	if id.Seq > 0 {
+		return ID{Ms: id.Ms, Seq: id.Seq - 1}, true
+	}
+	if id.Ms > 0 {
+		return ID{Ms: id.Ms - 1, Seq: math.MaxUint64}, true
+	}
+	return id, false
+}
+
+// ParseID parses `<ms>-<seq>`, or `<ms>` whose sequence is then set to missingSeq
+func ParseID(s string, missingSeq uint64) (ID, error) {
+	msPart, seqPart, hasSeq := strings.Cut(s, "-")
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return ID{}, ErrInvalidID
+	}
+	if !hasSeq {
+		return ID{Ms: ms, Seq: missingSeq}, nil
+	}
+	seq, err := strconv.ParseUint(seqPart, 10, 64)
+	if err != nil {
+		return ID{}, ErrInvalidID
+	}
+	return ID{Ms: ms, Seq: seq}, nil
+}
+
+// IDSpec is the ID argument of XADD: `*`, `<ms>-*` or an explicit ID
+type IDSpec struct {
+	ID
+	AutoMs  bool
+	AutoSeq bool
+}
+
+func ParseIDSpec(s string) (IDSpec, error) {
+	if s == "*" {
+		return IDSpec{AutoMs: true, AutoSeq: true}, nil
+	}
+	if msPart, found := strings.CutSuffix(s, "-*"); found {
+		ms, err := strconv.ParseUint(msPart, 10, 64)
+		if err != nil {
+			return IDSpec{}, ErrInvalidID
+		}
+		return IDSpec{ID: ID{Ms: ms}, AutoSeq: true}, nil
+	}
+	id, err := ParseID(s, 0)
+	if err != nil {
+		return IDSpec{}, err
+	}
+	return IDSpec{ID: id}, nil
+}
@@ -0,0 +1,334 @@
+package stream
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+// NodeCapacity is the number of entries appended into a node before a new one is started
+// approximate trimming (`~`) only removes whole nodes
+const NodeCapacity = 100
+
+var (
+	ErrIDTooSmall = errors.New("ERR The ID specified in XADD is equal or smaller than the target stream top item")
+	ErrIDZero     = errors.New("ERR The ID specified in XADD must be greater than 0-0")
+	ErrExhausted  = errors.New("ERR The stream has exhausted the last possible ID, unable to add more items")
+)
+
+// Entry is a stream entry, Fields holds field value pairs
+type Entry struct {
+	ID     ID
+	Fields [][]byte
+}
+
+// node holds up to NodeCapacity entries sorted by ID
+// deleted entries are removed from the node, an empty node is removed from the stream
+type node struct {
+	entries []*Entry
+	// number of entries appended into this node, it is sealed once it reaches NodeCapacity
+	appended int
+}
+
+func (n *node) first() *Entry {
+	return n.entries[0]
+}
+
+func (n *node) last() *Entry {
+	return n.entries[len(n.entries)-1]
+}
+
+// Stream is an append-only log of entries ordered by ID
+// entries are grouped into nodes kept in ID order, so lookups are a binary search
+// over the nodes followed by one inside the node, similar to the radix tree of
+// listpacks Redis uses
+type Stream struct {
+	mu     sync.RWMutex
+	nodes  []*node
+	length int64
+	lastID ID
+	// number of entries ever added, including deleted ones
+	entriesAdded uint64
+	maxDeletedID ID
+	// consumer groups by name
+	groups map[string]*Group
+}
+
+func NewStream() *Stream {
+	return &Stream{}
+}
+
+// Add appends an entry whose ID is generated from spec, nowMs is used for `*`
+func (s *Stream) Add(spec IDSpec, fields [][]byte, nowMs uint64) (ID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, err := s.nextID(spec, nowMs)
+	if err != nil {
+		return ID{}, err
+	}
+
+	var tail *node
+	if len(s.nodes) > 0 {
+		tail = s.nodes[len(s.nodes)-1]
+	}
+	if tail == nil || tail.appended >= NodeCapacity {
+		tail = &node{
+			entries: make([]*Entry, 0, NodeCapacity),
+		}
+		s.nodes = append(s.nodes, tail)
+	}
+	tail.entries = append(tail.entries, &Entry{
+		ID:     id,
+		Fields: fields,
+	})
+	tail.appended++
+
+	s.length++
+	s.lastID = id
+	s.entriesAdded++
+	return id, nil
+}
+
+func (s *Stream) nextID(spec IDSpec, nowMs uint64) (ID, error) {
+	last := s.lastID
+	switch {
+	case spec.AutoMs:
+		if nowMs > last.Ms {
+			return ID{Ms: nowMs}, nil
+		}
+		next, ok := last.Next()
+		if !ok {
+			return ID{}, ErrExhausted
+		}
+		return next, nil
+	case spec.AutoSeq:
+		if spec.Ms < last.Ms {
+			return ID{}, ErrIDTooSmall
+		}
+		if spec.Ms > last.Ms {
+			return ID{Ms: spec.Ms}, nil
+		}
+		next, ok := last.Next()
+		if !ok || next.Ms != spec.Ms {
+			return ID{}, ErrIDTooSmall
+		}
+		return next, nil
+	default:
+		if spec.ID == MinID {
+			return ID{}, ErrIDZero
+		}
+		if !last.Less(spec.ID) {
+			return ID{}, ErrIDTooSmall
+		}
+		return spec.ID, nil
+	}
+}
+
+// search returns the position of the first entry for which pred is true
+// pred must be false for a prefix of the stream and true for the rest
+func (s *Stream) search(pred func(id ID) bool) (int, int) {
+	nodeIdx := sort.Search(len(s.nodes), func(i int) bool {
+		return pred(s.nodes[i].last().ID)
+	})
+	if nodeIdx == len(s.nodes) {
+		return nodeIdx, 0
+	}
+	entries := s.nodes[nodeIdx].entries
+	entryIdx := sort.Search(len(entries), func(i int) bool {
+		return pred(entries[i].ID)
+	})
+	return nodeIdx, entryIdx
+}
+
+// Range returns the entries whose ID is within [start, end], at most count entries if count > 0
+// entries are returned from end to start if rev is set
+func (s *Stream) Range(start ID, end ID, count int, rev bool) []*Entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.rangeEntries(start, end, count, rev)
+}
+
+func (s *Stream) rangeEntries(start ID, end ID, count int, rev bool) []*Entry {
+	result := make([]*Entry, 0)
+	if end.Less(start) {
+		return result
+	}
+
+	if !rev {
+		nodeIdx, entryIdx := s.search(func(id ID) bool {
+			return !id.Less(start)
+		})
+		for ; nodeIdx < len(s.nodes); nodeIdx++ {
+			entries := s.nodes[nodeIdx].entries
+			for ; entryIdx < len(entries); entryIdx++ {
+				if end.Less(entries[entryIdx].ID) {
+					return result
+				}
+				result = append(result, entries[entryIdx])
+				if count > 0 && len(result) >= count {
+					return result
+				}
+			}
+			entryIdx = 0
+		}
+		return result
+	}
+
+	// start right after the last entry <= end
+	nodeIdx, entryIdx := s.search(func(id ID) bool {
+		return end.Less(id)
+	})
+	for {
+		if entryIdx == 0 {
+			if nodeIdx == 0 {
+				return result
+			}
+			nodeIdx--
+			entryIdx = len(s.nodes[nodeIdx].entries)
+		}
+		entryIdx--
+		entry := s.nodes[nodeIdx].entries[entryIdx]
+		if entry.ID.Less(start) {
+			return result
+		}
+		result = append(result, entry)
+		if count > 0 && len(result) >= count {
+			return result
+		}
+	}
+}
+
+// Delete removes the entries with given IDs and returns the number of entries actually deleted
+func (s *Stream) Delete(ids ...ID) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for _, id := range ids {
+		nodeIdx, entryIdx := s.search(func(other ID) bool {
+			return !other.Less(id)
+		})
+		if nodeIdx == len(s.nodes) {
+			continue
+		}
+		n := s.nodes[nodeIdx]
+		if entryIdx == len(n.entries) || n.entries[entryIdx].ID != id {
+			continue
+		}
+		n.entries = append(n.entries[:entryIdx], n.entries[entryIdx+1:]...)
+		if len(n.entries) == 0 {
+			s.nodes = append(s.nodes[:nodeIdx], s.nodes[nodeIdx+1:]...)
+		}
+		s.length--
+		deleted++
+		if s.maxDeletedID.Less(id) {
+			s.maxDeletedID = id
+		}
+	}
+	return deleted
+}
+
+// TrimMaxLen removes the oldest entries until at most maxLen entries remain
+// with approx set only whole nodes are removed, so a few more entries may be kept
+// limit caps the number of removed entries, 0 means no limit
+// returns the number of removed entries
+func (s *Stream) TrimMaxLen(maxLen int64, approx bool, limit int64) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.trim(approx, limit, func(e *Entry, remaining int64) bool {
+		return remaining > maxLen
+	})
+}
+
+// TrimMinID removes the entries whose ID is less than minID
+// approx and limit work the same as TrimMaxLen
+func (s *Stream) TrimMinID(minID ID, approx bool, limit int64) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.trim(approx, limit, func(e *Entry, remaining int64) bool {
+		return e.ID.Less(minID)
+	})
+}
+
+// trim removes entries from the head while removable is true
+// remaining is the stream length before removing e
+func (s *Stream) trim(approx bool, limit int64, removable func(e *Entry, remaining int64) bool) int64 {
+	var removed int64
+	for len(s.nodes) > 0 {
+		n := s.nodes[0]
+		size := int64(len(n.entries))
+		// a node can be dropped as a whole if its last entry is removable
+		// once every entry before it is gone
+		if removable(n.last(), s.length-size+1) {
+			if limit > 0 && removed+size > limit {
+				break
+			}
+			s.nodes = s.nodes[1:]
+			s.length -= size
+			removed += size
+			continue
+		}
+		if approx {
+			break
+		}
+
+		i := 0
+		for i < len(n.entries) && removable(n.entries[i], s.length) {
+			if limit > 0 && removed >= limit {
+				break
+			}
+			i++
+			s.length--
+			removed++
+		}
+		n.entries = n.entries[i:]
+		break
+	}
+	return removed
+}
+
+func (s *Stream) Len() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.length
+}
+
+// LastID returns the ID of the last entry ever added, it may have been deleted since
+func (s *Stream) LastID() ID {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lastID
+}
+
+// First returns the first entry, or nil if the stream is empty
+func (s *Stream) First() *Entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.nodes) == 0 {
+		return nil
+	}
+	return s.nodes[0].first()
+}
+
+// Last returns the last entry, or nil if the stream is empty
+func (s *Stream) Last() *Entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.nodes) == 0 {
+		return nil
+	}
+	return s.nodes[len(s.nodes)-1].last()
+}
+
+func (s *Stream) EntriesAdded() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.entriesAdded
+}
+
+func (s *Stream) MaxDeletedID() ID {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.maxDeletedID
+}
@@ -0,0 +1,117 @@
+package stream
+
+import (
+	"testing"
+)
+
+func addN(s *Stream, n int) {
+	for i := 0; i < n; i++ {
+		_, _ = s.Add(IDSpec{AutoMs: true, AutoSeq: true}, [][]byte{[]byte("f"), []byte("v")}, 1000)
+	}
+}
+
+func TestStreamAddIDs(t *testing.T) {
+	s := NewStream()
+
+	id, err := s.Add(IDSpec{AutoMs: true, AutoSeq: true}, nil, 5)
+	if err != nil || id != (ID{Ms: 5, Seq: 0}) {
+		t.Errorf("Expected 5-0, got %v %v", id, err)
+	}
+	// the clock went backwards, the sequence is incremented
+	id, err = s.Add(IDSpec{AutoMs: true, AutoSeq: true}, nil, 3)
+	if err != nil || id != (ID{Ms: 5, Seq: 1}) {
+		t.Errorf("Expected 5-1, got %v %v", id, err)
+	}
+	id, err = s.Add(IDSpec{ID: ID{Ms: 5}, AutoSeq: true}, nil, 0)
+	if err != nil || id != (ID{Ms: 5, Seq: 2}) {
+		t.Errorf("Expected 5-2, got %v %v", id, err)
+	}
+	if _, err = s.Add(IDSpec{ID: ID{Ms: 5, Seq: 2}}, nil, 0); err != ErrIDTooSmall {
+		t.Errorf("Expected ErrIDTooSmall, got %v", err)
+	}
+	if _, err = s.Add(IDSpec{ID: ID{Ms: 4}, AutoSeq: true}, nil, 0); err != ErrIDTooSmall {
+		t.Errorf("Expected ErrIDTooSmall, got %v", err)
+	}
+	if _, err = NewStream().Add(IDSpec{}, nil, 0); err != ErrIDZero {
+		t.Errorf("Expected ErrIDZero, got %v", err)
+	}
+	id, _ = NewStream().Add(IDSpec{AutoSeq: true}, nil, 0)
+	if id != (ID{Ms: 0, Seq: 1}) {
+		t.Errorf("Expected 0-1, got %v", id)
+	}
+}
+
+func TestStreamRangeAcrossNodes(t *testing.T) {
+	s := NewStream()
+	addN(s, NodeCapacity*2+10)
+
+	entries := s.Range(MinID, MaxID, 0, false)
+	if int64(len(entries)) != s.Len() {
+		t.Fatalf("Expected %d entries, got %d", s.Len(), len(entries))
+	}
+	for i := 1; i < len(entries); i++ {
+		if !entries[i-1].ID.Less(entries[i].ID) {
+			t.Fatalf("Entries are not ordered: %v then %v", entries[i-1].ID, entries[i].ID)
+		}
+	}
+
+	entries = s.Range(ID{Ms: 1000, Seq: 95}, ID{Ms: 1000, Seq: 105}, 0, false)
+	if len(entries) != 11 || entries[0].ID.Seq != 95 || entries[10].ID.Seq != 105 {
+		t.Errorf("Expected entries 95 to 105, got %d entries", len(entries))
+	}
+
+	entries = s.Range(ID{Ms: 1000, Seq: 95}, ID{Ms: 1000, Seq: 105}, 3, true)
+	if len(entries) != 3 || entries[0].ID.Seq != 105 || entries[2].ID.Seq != 103 {
+		t.Errorf("Expected entries 105 to 103, got %v", entries)
+	}
+
+	entries = s.Range(ID{Ms: 2000}, MaxID, 0, true)
+	if len(entries) != 0 {
+		t.Errorf("Expected no entries, got %d", len(entries))
+	}
+}
+
+func TestStreamDelete(t *testing.T) {
+	s := NewStream()
+	addN(s, NodeCapacity+1)
+
+	deleted := s.Delete(ID{Ms: 1000, Seq: 0}, ID{Ms: 1000, Seq: 0}, ID{Ms: 1000, Seq: NodeCapacity}, ID{Ms: 9})
+	if deleted != 2 {
+		t.Errorf("Expected 2 deletions, got %d", deleted)
+	}
+	if s.Len() != NodeCapacity-1 {
+		t.Errorf("Expected length %d, got %d", NodeCapacity-1, s.Len())
+	}
+	if s.First().ID.Seq != 1 || s.Last().ID.Seq != NodeCapacity-1 {
+		t.Errorf("Unexpected first %v or last %v", s.First().ID, s.Last().ID)
+	}
+	if s.LastID() != (ID{Ms: 1000, Seq: NodeCapacity}) {
+		t.Errorf("Expected last ID to be kept after deletion, got %v", s.LastID())
+	}
+}
+
+func TestStreamTrim(t *testing.T) {
+	s := NewStream()
+	addN(s, NodeCapacity*3)
+
+	// approximate trimming only removes whole nodes
+	removed := s.TrimMaxLen(NodeCapacity*2-10, true, 0)
+	if removed != NodeCapacity || s.Len() != NodeCapacity*2 {
+		t.Errorf("Expected one node trimmed, removed %d, length %d", removed, s.Len())
+	}
+
+	removed = s.TrimMaxLen(NodeCapacity*2-10, false, 0)
+	if removed != 10 || s.Len() != NodeCapacity*2-10 {
+		t.Errorf("Expected 10 entries trimmed, removed %d, length %d", removed, s.Len())
+	}
+
+	removed = s.TrimMinID(ID{Ms: 1000, Seq: NodeCapacity * 2}, true, 10)
+	if removed != 0 {
+		t.Errorf("Expected LIMIT to prevent trimming, removed %d", removed)
+	}
+
+	removed = s.TrimMinID(ID{Ms: 1000, Seq: NodeCapacity*2 + 5}, false, 0)
+	if removed != NodeCapacity-10+5 || s.First().ID.Seq != NodeCapacity*2+5 {
+		t.Errorf("Expected entries before %d trimmed, removed %d, first %v", NodeCapacity*2+5, removed, s.First().ID)
+	}
+}
@@ -0,0 +1,98 @@
+package zset
+
+import "sync"
+
+// ConcurrentSortedSet is a SortedSet guarded by a RWMutex
+// so that multiple readers may access it while writers are serialized
+type ConcurrentSortedSet struct {
+	mu sync.RWMutex
+	ss *SortedSet
+}
+
+func NewConcurrentSortedSet() *ConcurrentSortedSet {
+	return &ConcurrentSortedSet{
+		ss: NewSortedSet(),
+	}
+}
+
+func (cs *ConcurrentSortedSet) Add(member string, score float64) bool {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.ss.Add(member, score)
+}
+
+func (cs *ConcurrentSortedSet) Len() int64 {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.ss.Len()
+}
+
+func (cs *ConcurrentSortedSet) Get(member string) (*Element, bool) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.ss.Get(member)
+}
+
+func (cs *ConcurrentSortedSet) Remove(member string) bool {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.ss.Remove(member)
+}
+
+func (cs *ConcurrentSortedSet) Score(member string) (float64, bool) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.ss.Score(member)
+}
+
+func (cs *ConcurrentSortedSet) GetRank(member string, desc bool) int64 {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.ss.GetRank(member, desc)
+}
+
+func (cs *ConcurrentSortedSet) GetByRank(rank int64) *Element {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.ss.GetByRank(rank)
+}
+
+func (cs *ConcurrentSortedSet) Range(start Border, stop Border, byScore bool) []*Element {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.ss.Range(start, stop, byScore)
+}
+
+// ForEach holds the read lock during the whole iteration
+// consumer must not call back into the set
+func (cs *ConcurrentSortedSet) ForEach(desc bool, consumer func(element *Element) bool) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	cs.ss.ForEach(desc, consumer)
+}
+
+// ForEachFrom holds the read lock during the whole iteration
+// consumer must not call back into the set
+func (cs *ConcurrentSortedSet) ForEachFrom(min float64, consumer func(element *Element) bool) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	cs.ss.ForEachFrom(min, consumer)
+}
+
+func (cs *ConcurrentSortedSet) PopMin(count int) []*Element {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.ss.PopMin(count)
+}
+
+func (cs *ConcurrentSortedSet) PopMax(count int) []*Element {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.ss.PopMax(count)
+}
+
+func (cs *ConcurrentSortedSet) RandomMembers(count int64) []*Element {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.ss.RandomMembers(count)
+}
@@ -243,6 +243,27 @@ func (sl *skiplist) forEach(desc bool, consumer func(element *Element) bool) {
 	}
 }
 
+// firstFrom returns the first node whose score is min or more, nil if there is none
+// like zslFirstInRange, it descends the levels rather than walking from the lowest score
+func (sl *skiplist) firstFrom(min float64) *node {
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && x.level[i].forward.Score < min {
+			x = x.level[i].forward
+		}
+	}
+	return x.level[0].forward
+}
+
+// forEachFrom visits the nodes in ascending order from the first one whose score is min or more
+func (sl *skiplist) forEachFrom(min float64, consumer func(element *Element) bool) {
+	for current := sl.firstFrom(min); current != nil; current = current.level[0].forward {
+		if !consumer(&current.Element) {
+			break
+		}
+	}
+}
+
 func (sl *skiplist) String() string {
 	var str string
 	str += "level " + strconv.Itoa(int(sl.level)) + " length " + strconv.Itoa(int(sl.length)) + "\n"
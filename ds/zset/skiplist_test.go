@@ -148,3 +148,32 @@ func TestForEachFunc(t *testing.T) {
 		return true
 	})
 }
+
+func TestSkiplistForEachFrom(t *testing.T) {
+	skl := newSkiplist()
+	for i := 0; i < 100; i++ {
+		skl.insert(fmt.Sprint(i), float64(i*2))
+	}
+
+	if n := skl.firstFrom(41); n == nil || n.Score != 42 {
+		t.Errorf("expected the first node from 41 to have score 42, got %+v", n)
+	}
+	if n := skl.firstFrom(42); n == nil || n.Score != 42 {
+		t.Errorf("expected the first node from 42 to have score 42, got %+v", n)
+	}
+	if n := skl.firstFrom(-1); n == nil || n.Score != 0 {
+		t.Errorf("expected the first node from -1 to have score 0, got %+v", n)
+	}
+	if n := skl.firstFrom(199); n != nil {
+		t.Errorf("expected no node from 199, got %+v", n)
+	}
+
+	var scores []float64
+	skl.forEachFrom(190, func(element *Element) bool {
+		scores = append(scores, element.Score)
+		return element.Score < 194
+	})
+	if fmt.Sprint(scores) != "[190 192 194]" {
+		t.Errorf("unexpected scores %v", scores)
+	}
+}
@@ -1,5 +1,7 @@
 package zset
 
+import "math/rand"
+
 // SortedSet is a set which keys sorted by bound score
 type SortedSet struct {
 	dict     map[string]*Element
@@ -139,3 +141,95 @@ func (ss *SortedSet) Range(start Border, stop Border, byScore bool) []*Element {
 	}
 	return results
 }
+
+// ForEach visits members in score order, ascending unless desc is set
+// if consumer returns false, the iteration stops
+func (ss *SortedSet) ForEach(desc bool, consumer func(element *Element) bool) {
+	ss.skiplist.forEach(desc, consumer)
+}
+
+// ForEachFrom visits members in ascending score order from the first one whose score is min or more
+// if consumer returns false, the iteration stops
+func (ss *SortedSet) ForEachFrom(min float64, consumer func(element *Element) bool) {
+	ss.skiplist.forEachFrom(min, consumer)
+}
+
+// GetByRank returns the member at the given rank, sort by ascending order, rank starts from 0
+// returns nil if rank is out of range
+func (ss *SortedSet) GetByRank(rank int64) *Element {
+	if rank < 0 || rank >= ss.skiplist.length {
+		return nil
+	}
+	n := ss.skiplist.getByRank(rank + 1)
+	if n == nil {
+		return nil
+	}
+	return &n.Element
+}
+
+// PopMin removes and returns up to count members with the lowest scores
+func (ss *SortedSet) PopMin(count int) []*Element {
+	return ss.pop(count, false)
+}
+
+// PopMax removes and returns up to count members with the highest scores
+func (ss *SortedSet) PopMax(count int) []*Element {
+	return ss.pop(count, true)
+}
+
+func (ss *SortedSet) pop(count int, desc bool) []*Element {
+	popped := make([]*Element, 0, min(int64(count), ss.Len()))
+	ss.skiplist.forEach(desc, func(element *Element) bool {
+		if len(popped) >= count {
+			return false
+		}
+		popped = append(popped, &Element{
+			Member: element.Member,
+			Score:  element.Score,
+		})
+		return true
+	})
+	for _, element := range popped {
+		ss.Remove(element.Member)
+	}
+	return popped
+}
+
+// RandomMembers returns count random members, each picked by rank in O(log(n))
+// a positive count returns distinct members, a negative count may return the same member multiple times
+func (ss *SortedSet) RandomMembers(count int64) []*Element {
+	size := ss.skiplist.length
+	if size == 0 || count == 0 {
+		return []*Element{}
+	}
+
+	if count < 0 {
+		// the count comes from the client, the members are appended as they're picked
+		elements := make([]*Element, 0, min(-count, size))
+		for i := int64(0); i < -count; i++ {
+			elements = append(elements, &ss.skiplist.getByRank(rand.Int63n(size)+1).Element)
+		}
+		return elements
+	}
+
+	if count >= size {
+		elements := make([]*Element, 0, size)
+		ss.skiplist.forEach(false, func(element *Element) bool {
+			elements = append(elements, element)
+			return true
+		})
+		return elements
+	}
+
+	picked := make(map[int64]struct{}, count)
+	elements := make([]*Element, 0, count)
+	for int64(len(elements)) < count {
+		rank := rand.Int63n(size) + 1
+		if _, ok := picked[rank]; ok {
+			continue
+		}
+		picked[rank] = struct{}{}
+		elements = append(elements, &ss.skiplist.getByRank(rank).Element)
+	}
+	return elements
+}
@@ -129,3 +129,31 @@ func TestSortedSetRangeByScore(t *testing.T) {
 		}
 	}
 }
+
+func TestSortedSetPopAndGetByRank(t *testing.T) {
+	zset := NewSortedSet()
+	zset.Add("a", 1)
+	zset.Add("b", 2)
+	zset.Add("c", 3)
+	zset.Add("d", 4)
+
+	element := zset.GetByRank(1)
+	if element == nil || element.Member != "b" {
+		t.Errorf("Expected member at rank 1 to be b, got: %v", element)
+	}
+	if zset.GetByRank(4) != nil || zset.GetByRank(-1) != nil {
+		t.Errorf("Expected nil for out of range ranks")
+	}
+
+	popped := zset.PopMin(2)
+	if len(popped) != 2 || popped[0].Member != "a" || popped[1].Member != "b" {
+		t.Errorf("Expected PopMin(2) to return [a b], got: %v", popped)
+	}
+	popped = zset.PopMax(5)
+	if len(popped) != 2 || popped[0].Member != "d" || popped[1].Member != "c" {
+		t.Errorf("Expected PopMax(5) to return [d c], got: %v", popped)
+	}
+	if zset.Len() != 0 {
+		t.Errorf("Expected empty zset after popping everything, got length %d", zset.Len())
+	}
+}
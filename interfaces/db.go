@@ -1,10 +1,64 @@
 package interfaces
 
 import (
+	"godis/config"
+	"godis/metrics"
 	"godis/redis/protocol"
+	"godis/stats"
+	"time"
 )
 
 type Connection interface {
+	ID() int64
+	// ProtocolVersion returns the RESP version negotiated with HELLO, 2 by default
+	ProtocolVersion() int
+	SetProtocolVersion(version int)
+	// RemoteAddr returns the address of the client, like 127.0.0.1:50000
+	RemoteAddr() string
+	LocalAddr() string
+	Name() string
+	SetName(name string)
+	CreatedAt() time.Time
+	// LastCommand returns the name of the last command run, like client|list, and when it was received
+	LastCommand() (string, time.Time)
+	SetLastCommand(name string)
+	// Buffers returns the bytes of the query buffer, of the replies buffered, and the number of writes queued
+	Buffers() (query int, output int, queued int)
+	// NoEvict reports whether the keys of the client are kept from eviction, set with CLIENT NO-EVICT
+	NoEvict() bool
+	SetNoEvict(noEvict bool)
+	// Monitoring reports whether the client ran MONITOR
+	Monitoring() bool
+	SetMonitoring(monitoring bool)
+	// SetReplyMode sets whether the replies are sent: "on", "off", or "skip" for the next command only
+	SetReplyMode(mode string)
+	// Close disconnects the client, like CLIENT KILL
+	Close() error
+	// CloseAfterReply disconnects the client once the reply of its current command is written
+	CloseAfterReply()
+	// Authenticated reports whether the client may run commands, it must AUTH first when a password is required
+	Authenticated() bool
+	SetAuthenticated(authenticated bool)
+
+	Write(b []byte) (int, error)
+	// Flush writes the replies buffered by the handler
+	Flush() error
+	// Push writes b asynchronously, used to deliver messages to subscribers
+	Push(b []byte) bool
+
+	// pub/sub
+	Subscribe(channel string)
+	Unsubscribe(channel string)
+	Channels() []string
+	// Subscribed reports whether the connection is subscribed to channel, not counting the patterns
+	Subscribed(channel string) bool
+	PSubscribe(pattern string)
+	PUnsubscribe(pattern string)
+	Patterns() []string
+	SSubscribe(channel string)
+	SUnsubscribe(channel string)
+	ShardChannels() []string
+	SubsCount() int
 }
 
 type DB interface {
@@ -12,4 +66,14 @@ type DB interface {
 	// Exec() of a DB implementation should be called in:
 	// a implementation of a ExecF() of a command
 	Exec(conn Connection, cmdL [][]byte) protocol.Reply
+	// AfterClientOpen is called once a connection is accepted, before its first command
+	AfterClientOpen(conn Connection)
+	// AfterClientClose releases what the DB keeps for a closed connection
+	AfterClientClose(conn Connection)
+	// Config returns the parameters in use, changed by CONFIG SET, the properties returned are never modified
+	Config() *config.ServerProperties
+	// Stats returns the counters reported by INFO, the handler counts the connections and the bytes exchanged
+	Stats() *stats.Stats
+	// Collect writes the metrics served on /metrics
+	Collect(w *metrics.Writer)
 }
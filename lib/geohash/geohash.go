@@ -0,0 +1,291 @@
+// Package geohash implements the 52-bit interleaved geohash used by Redis for geo commands,
+// so coordinates stored as sorted set scores are compatible with Redis
+package geohash
+
+import (
+	"math"
+)
+
+const (
+	// Step is the precision of scores, 26 bits for each coordinate
+	Step = 26
+
+	MinLongitude = -180.0
+	MaxLongitude = 180.0
+	// latitudes beyond these can't be represented in EPSG:3785 (web mercator)
+	MinLatitude = -85.05112878
+	MaxLatitude = 85.05112878
+
+	// EarthRadius in meters, the same value as Redis
+	EarthRadius = 6372797.560856
+	mercatorMax = 20037726.37
+
+	base32 = "0123456789bcdefghjkmnpqrstuvwxyz"
+)
+
+// Area is the cell covered by a geohash
+type Area struct {
+	MinLongitude float64
+	MaxLongitude float64
+	MinLatitude  float64
+	MaxLatitude  float64
+}
+
+func (a Area) Center() (float64, float64) {
+	lon := math.Max(MinLongitude, math.Min(MaxLongitude, (a.MinLongitude+a.MaxLongitude)/2))
+	lat := math.Max(MinLatitude, math.Min(MaxLatitude, (a.MinLatitude+a.MaxLatitude)/2))
+	return lon, lat
+}
+
+// spread moves the lower 32 bits of v to the even bits
+func spread(v uint64) uint64 {
+	v &= 0xFFFFFFFF
+	v = (v | (v << 16)) & 0x0000FFFF0000FFFF
+	v = (v | (v << 8)) & 0x00FF00FF00FF00FF
+	v = (v | (v << 4)) & 0x0F0F0F0F0F0F0F0F
+	v = (v | (v << 2)) & 0x3333333333333333
+	v = (v | (v << 1)) & 0x5555555555555555
+	return v
+}
+
+// squash is the reverse of spread
+func squash(v uint64) uint64 {
+	v &= 0x5555555555555555
+	v = (v | (v >> 1)) & 0x3333333333333333
+	v = (v | (v >> 2)) & 0x0F0F0F0F0F0F0F0F
+	v = (v | (v >> 4)) & 0x00FF00FF00FF00FF
+	v = (v | (v >> 8)) & 0x0000FFFF0000FFFF
+	v = (v | (v >> 16)) & 0x00000000FFFFFFFF
+	return v
+}
+
+// interleave puts latitude bits on even positions and longitude bits on odd ones
+func interleave(latBits uint64, lonBits uint64) uint64 {
+	return spread(latBits) | (spread(lonBits) << 1)
+}
+
+func deinterleave(hash uint64) (latBits uint64, lonBits uint64) {
+	return squash(hash), squash(hash >> 1)
+}
+
+func encode(lon float64, lat float64, minLat float64, maxLat float64, step uint) uint64 {
+	latOffset := (lat - minLat) / (maxLat - minLat)
+	lonOffset := (lon - MinLongitude) / (MaxLongitude - MinLongitude)
+	cells := float64(uint64(1) << step)
+	return interleave(uint64(latOffset*cells), uint64(lonOffset*cells))
+}
+
+// EncodeWithStep returns the geohash with step bits for each coordinate
+func EncodeWithStep(lon float64, lat float64, step uint) uint64 {
+	return encode(lon, lat, MinLatitude, MaxLatitude, step)
+}
+
+// Encode returns the 52-bit geohash stored as score
+func Encode(lon float64, lat float64) uint64 {
+	return EncodeWithStep(lon, lat, Step)
+}
+
+// DecodeWithStep returns the cell covered by a geohash of step bits for each coordinate
+func DecodeWithStep(hash uint64, step uint) Area {
+	latBits, lonBits := deinterleave(hash)
+	cells := float64(uint64(1) << step)
+	latScale := MaxLatitude - MinLatitude
+	lonScale := MaxLongitude - MinLongitude
+	return Area{
+		MinLatitude:  MinLatitude + float64(latBits)/cells*latScale,
+		MaxLatitude:  MinLatitude + float64(latBits+1)/cells*latScale,
+		MinLongitude: MinLongitude + float64(lonBits)/cells*lonScale,
+		MaxLongitude: MinLongitude + float64(lonBits+1)/cells*lonScale,
+	}
+}
+
+// Decode returns the longitude and latitude at the center of a 52-bit geohash
+func Decode(hash uint64) (float64, float64) {
+	return DecodeWithStep(hash, Step).Center()
+}
+
+// ToString returns the standard 11 characters geohash, as GEOHASH does
+// the standard geohash covers latitudes from -90 to 90 instead of the mercator range
+func ToString(hash uint64) string {
+	lon, lat := Decode(hash)
+	bits := encode(lon, lat, -90, 90, Step)
+	buf := make([]byte, 11)
+	for i := 0; i < 11; i++ {
+		idx := 0
+		// there are only 52 bits, the last character is always 0
+		if i < 10 {
+			idx = int((bits >> (52 - (i+1)*5)) & 0x1f)
+		}
+		buf[i] = base32[idx]
+	}
+	return string(buf)
+}
+
+func degRad(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func radDeg(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
+// Distance returns the distance in meters between two points with the haversine formula
+func Distance(lon1 float64, lat1 float64, lon2 float64, lat2 float64) float64 {
+	lat1r, lat2r := degRad(lat1), degRad(lat2)
+	v := math.Sin((degRad(lon2) - degRad(lon1)) / 2)
+	if v == 0 {
+		// same meridian
+		return EarthRadius * math.Abs(lat2r-lat1r)
+	}
+	u := math.Sin((lat2r - lat1r) / 2)
+	a := u*u + math.Cos(lat1r)*math.Cos(lat2r)*v*v
+	return 2 * EarthRadius * math.Asin(math.Sqrt(a))
+}
+
+// ScoreRange is a range of scores [Min, Max) covering a geohash cell
+type ScoreRange struct {
+	Min uint64
+	Max uint64
+}
+
+// estimateStep returns the largest step whose cells are bigger than radius meters at the given latitude
+func estimateStep(radius float64, lat float64) uint {
+	if radius == 0 {
+		return Step
+	}
+	step := 1
+	for radius < mercatorMax {
+		radius *= 2
+		step++
+	}
+	// cells are smaller near the poles
+	step -= 2
+	if lat > 66 || lat < -66 {
+		step--
+		if lat > 80 || lat < -80 {
+			step--
+		}
+	}
+	return uint(max(1, min(Step, step)))
+}
+
+// boundingBox returns the box containing a rectangle of width and height meters centered on a point
+func boundingBox(lon float64, lat float64, width float64, height float64) Area {
+	latDelta := radDeg(height / 2 / EarthRadius)
+	lonDeltaTop := radDeg(width / 2 / EarthRadius / math.Cos(degRad(lat+latDelta)))
+	lonDeltaBottom := radDeg(width / 2 / EarthRadius / math.Cos(degRad(lat-latDelta)))
+	// the widest side is the one closer to the equator
+	lonDelta := lonDeltaTop
+	if lat < 0 {
+		lonDelta = lonDeltaBottom
+	}
+	return Area{
+		MinLongitude: lon - lonDelta,
+		MaxLongitude: lon + lonDelta,
+		MinLatitude:  lat - latDelta,
+		MaxLatitude:  lat + latDelta,
+	}
+}
+
+// cell is a geohash at a given step, split into its coordinate indexes
+type cell struct {
+	lonIdx int64
+	latIdx int64
+	step   uint
+}
+
+func (c cell) hash() uint64 {
+	return interleave(uint64(c.latIdx), uint64(c.lonIdx))
+}
+
+func (c cell) area() Area {
+	return DecodeWithStep(c.hash(), c.step)
+}
+
+// move returns the neighbour cell, longitudes wrap around while latitudes don't
+func (c cell) move(dLon int64, dLat int64) (cell, bool) {
+	cells := int64(1) << c.step
+	latIdx := c.latIdx + dLat
+	if latIdx < 0 || latIdx >= cells {
+		return cell{}, false
+	}
+	lonIdx := (c.lonIdx + dLon + cells) % cells
+	return cell{lonIdx: lonIdx, latIdx: latIdx, step: c.step}, true
+}
+
+func (c cell) scoreRange() ScoreRange {
+	shift := 2 * (Step - c.step)
+	return ScoreRange{
+		Min: c.hash() << shift,
+		Max: (c.hash() + 1) << shift,
+	}
+}
+
+// SearchRanges returns the score ranges to scan to find every point within a rectangle of width
+// and height meters centered on a point, a circle is searched with its enclosing square
+// like Redis, the cell containing the center and its 8 neighbours are chosen with a size
+// big enough to cover the search area, and neighbours outside of it are skipped
+func SearchRanges(lon float64, lat float64, width float64, height float64) []ScoreRange {
+	bounds := boundingBox(lon, lat, width, height)
+	radius := math.Sqrt(width*width+height*height) / 2
+	step := estimateStep(radius, lat)
+
+	makeCenter := func(step uint) cell {
+		latBits, lonBits := deinterleave(EncodeWithStep(lon, lat, step))
+		return cell{lonIdx: int64(lonBits), latIdx: int64(latBits), step: step}
+	}
+	center := makeCenter(step)
+
+	// the estimated step may be too fine for the area to be covered by the neighbours
+	if step > 1 {
+		decrease := false
+		if north, ok := center.move(0, 1); ok && north.area().MaxLatitude < bounds.MaxLatitude {
+			decrease = true
+		}
+		if south, ok := center.move(0, -1); ok && south.area().MinLatitude > bounds.MinLatitude {
+			decrease = true
+		}
+		if east, _ := center.move(1, 0); east.area().MaxLongitude < bounds.MaxLongitude {
+			decrease = true
+		}
+		if west, _ := center.move(-1, 0); west.area().MinLongitude > bounds.MinLongitude {
+			decrease = true
+		}
+		if decrease {
+			step--
+			center = makeCenter(step)
+		}
+	}
+
+	area := center.area()
+	var ranges []ScoreRange
+	for dLat := int64(-1); dLat <= 1; dLat++ {
+		for dLon := int64(-1); dLon <= 1; dLon++ {
+			if step >= 2 {
+				if (dLat < 0 && area.MinLatitude < bounds.MinLatitude) ||
+					(dLat > 0 && area.MaxLatitude > bounds.MaxLatitude) ||
+					(dLon < 0 && area.MinLongitude < bounds.MinLongitude) ||
+					(dLon > 0 && area.MaxLongitude > bounds.MaxLongitude) {
+					continue
+				}
+			}
+			neighbour, ok := center.move(dLon, dLat)
+			if !ok {
+				continue
+			}
+			r := neighbour.scoreRange()
+			// with a coarse step neighbours may wrap onto the same cell
+			duplicated := false
+			for _, other := range ranges {
+				if other == r {
+					duplicated = true
+					break
+				}
+			}
+			if !duplicated {
+				ranges = append(ranges, r)
+			}
+		}
+	}
+	return ranges
+}
@@ -0,0 +1,49 @@
+package geohash
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	// values from the Redis documentation
+	hash := Encode(13.361389, 38.115556)
+	if hash != 3479099956230698 {
+		t.Errorf("Expected 3479099956230698, got %d", hash)
+	}
+	lon, lat := Decode(hash)
+	if math.Abs(lon-13.361389338970184) > 1e-12 || math.Abs(lat-38.1155563954963) > 1e-12 {
+		t.Errorf("Unexpected coordinates %v %v", lon, lat)
+	}
+	if s := ToString(hash); s != "sqc8b49rny0" {
+		t.Errorf("Expected sqc8b49rny0, got %s", s)
+	}
+	if s := ToString(Encode(15.087269, 37.502669)); s != "sqdtr74hyu0" {
+		t.Errorf("Expected sqdtr74hyu0, got %s", s)
+	}
+}
+
+func TestDistance(t *testing.T) {
+	lon1, lat1 := Decode(Encode(13.361389, 38.115556))
+	lon2, lat2 := Decode(Encode(15.087269, 37.502669))
+	if d := Distance(lon1, lat1, lon2, lat2); math.Abs(d-166274.1516) > 1e-4 {
+		t.Errorf("Expected 166274.1516, got %f", d)
+	}
+}
+
+func TestSearchRanges(t *testing.T) {
+	points := [][2]float64{{13.361389, 38.115556}, {15.087269, 37.502669}, {13.583333, 37.316667}}
+	ranges := SearchRanges(15, 37, 400000, 400000)
+	for _, p := range points {
+		score := Encode(p[0], p[1])
+		found := false
+		for _, r := range ranges {
+			if r.Min <= score && score < r.Max {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Point %v is not covered by %v", p, ranges)
+		}
+	}
+}
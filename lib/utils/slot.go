@@ -0,0 +1,49 @@
+package utils
+
+// SlotCount is the number of hash slots of a Redis cluster
+const SlotCount = 16384
+
+var crc16Table [256]uint16
+
+func init() {
+	// CRC16-CCITT (XMODEM), polynomial 0x1021, as used by Redis cluster
+	for i := 0; i < 256; i++ {
+		crc := uint16(i) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+		crc16Table[i] = crc
+	}
+}
+
+func CRC16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc = crc<<8 ^ crc16Table[byte(crc>>8)^b]
+	}
+	return crc
+}
+
+// KeySlot returns the cluster slot of a key or channel
+// if it contains a non-empty {hashtag}, only the hashtag is hashed
+func KeySlot(key string) uint16 {
+	for i := 0; i < len(key); i++ {
+		if key[i] != '{' {
+			continue
+		}
+		for j := i + 1; j < len(key); j++ {
+			if key[j] == '}' {
+				if j > i+1 {
+					key = key[i+1 : j]
+				}
+				return CRC16([]byte(key)) % SlotCount
+			}
+		}
+		break
+	}
+	return CRC16([]byte(key)) % SlotCount
+}
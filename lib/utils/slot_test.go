@@ -0,0 +1,27 @@
+package utils
+
+import "testing"
+
+func TestKeySlot(t *testing.T) {
+	if crc := CRC16([]byte("123456789")); crc != 0x31C3 {
+		t.Errorf("Expected 0x31C3, got %#x", crc)
+	}
+	tests := []struct {
+		key  string
+		slot uint16
+	}{
+		{"foo", 12182},
+		{"{user1000}.following", 3443},
+		{"{user1000}.followers", 3443},
+		{"user1000", 3443},
+		// only the first hashtag counts
+		{"foo{bar}{zap}", KeySlot("bar")},
+		// an empty hashtag hashes the whole key
+		{"foo{}{bar}", CRC16([]byte("foo{}{bar}")) % SlotCount},
+	}
+	for _, tt := range tests {
+		if slot := KeySlot(tt.key); slot != tt.slot {
+			t.Errorf("%s: expected slot %d, got %d", tt.key, tt.slot, slot)
+		}
+	}
+}
@@ -14,6 +14,17 @@ const (
 	IntReply       = ':'
 	BulkReply      = '$'
 	MultiBulkReply = '*'
+
+	// RESP3
+	NullReply      = '_'
+	DoubleReply    = ','
+	BooleanReply   = '#'
+	BigNumberReply = '('
+	VerbatimReply  = '='
+	MapReply       = '%'
+	SetReply       = '~'
+	PushReply      = '>'
+	AttributeReply = '|'
 )
 
 // ParseRESP reads and parses a RESP response from the reader
@@ -38,6 +49,25 @@ func ParseRESP(reader *bufio.Reader) (string, error) {
 		return ParseBulkString(reader, response)
 	case MultiBulkReply:
 		return ParseMultiBulk(reader, response)
+	case NullReply, DoubleReply, BooleanReply, BigNumberReply:
+		return response, nil
+	case VerbatimReply:
+		return ParseBulkString(reader, response)
+	case SetReply, PushReply:
+		return parseAggregate(reader, response, 1)
+	case MapReply:
+		return parseAggregate(reader, response, 2)
+	case AttributeReply:
+		// the attributes are followed by the reply they describe
+		attributes, err := parseAggregate(reader, response, 2)
+		if err != nil {
+			return "", err
+		}
+		reply, err := ParseRESP(reader)
+		if err != nil {
+			return "", err
+		}
+		return attributes + reply, nil
 	default:
 		return "", fmt.Errorf("unknown RESP type: %c", line[0])
 	}
@@ -109,25 +139,43 @@ func ParseMultiBulk(reader *bufio.Reader, initial string) (string, error) {
 	if count == 0 {
 		return "*0\r\n", nil
 	}
+	if count == -1 { // null array
+		return initial, nil
+	}
 	if count < 0 {
 		return "", fmt.Errorf("invalid multi-bulk count: %d", count)
 	}
 
 	response := initial
-	// Each element in the multi-bulk reply has two lines:
-	// 1. Length prefix ($...)
-	// 2. Actual data
-	for i := 0; i < count*2; i++ {
-		line, _, err := reader.ReadLine()
+	// elements may be of any type, including nested arrays
+	for i := 0; i < count; i++ {
+		element, err := ParseRESP(reader)
 		if err != nil {
 			return "", err
 		}
-		response += string(line) + "\r\n"
+		response += element
 	}
 
 	return response, nil
 }
 
+// parseAggregate handles RESP3 aggregates of count * perElement replies, like maps or sets
+func parseAggregate(reader *bufio.Reader, initial string, perElement int) (string, error) {
+	count, err := strconv.Atoi(strings.TrimSuffix(initial[1:], "\r\n"))
+	if err != nil || count < 0 {
+		return "", fmt.Errorf("invalid aggregate count: %q", initial)
+	}
+	response := initial
+	for i := 0; i < count*perElement; i++ {
+		element, err := ParseRESP(reader)
+		if err != nil {
+			return "", err
+		}
+		response += element
+	}
+	return response, nil
+}
+
 // Helper function to extract the integer value from an int reply
 func ExtractInt(response string) (int64, error) {
 	if len(response) < 3 || response[0] != IntReply {
@@ -2,18 +2,78 @@ package main
 
 import (
 	"fmt"
+	"godis/config"
+	"godis/db"
+	"godis/metrics"
+	"godis/tcp/server"
 	"log"
+	"os"
+	"strings"
 )
 
 func init() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 }
 
+func usage() {
+	fmt.Fprintln(os.Stderr, `Usage: ./godis [/path/to/redis.conf] [options]
+       ./godis - (read config from stdin)
+       ./godis -v or --version
+       ./godis -h or --help
+
+Examples:
+       ./godis (run the server with the default configuration)
+       ./godis /etc/redis/6379.conf
+       ./godis --port 7777
+       ./godis /etc/myredis.conf --bind 127.0.0.1 --requirepass secret`)
+}
+
+// loadConfig loads the config file given as the first argument, then the directives following it
+func loadConfig(args []string) (*config.ServerProperties, error) {
+	properties := config.Defaults()
+	if len(args) > 0 && (args[0] == "-" || !strings.HasPrefix(args[0], "-")) {
+		if err := properties.LoadFile(args[0]); err != nil {
+			return nil, err
+		}
+		args = args[1:]
+	}
+	if err := properties.LoadArgs(args); err != nil {
+		return nil, err
+	}
+	return properties, nil
+}
+
 func main() {
-	// 	handler := server.NewEchoHandler()
+	args := os.Args[1:]
+	if len(args) == 1 {
+		switch args[0] {
+		case "-v", "--version":
+			fmt.Println("godis server v=" + db.RedisVersion)
+			return
+		case "-h", "--help":
+			usage()
+			return
+		}
+	}
+
+	properties, err := loadConfig(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\n*** FATAL CONFIG FILE ERROR (godis %s) ***\n", db.RedisVersion)
+		fmt.Fprintf(os.Stderr, "Reading the configuration file, %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.Chdir(properties.Dir); err != nil {
+		log.Fatalf("can't chdir to '%s': %v", properties.Dir, err)
+	}
+	config.Properties = properties
 
-	// 	if err := server.Serve(":8080", handler); err != nil {
-	// 		log.Fatal(err)
-	// 	}
-	fmt.Printf("%v", uint(1)<<uint(16))
+	handler := server.NewRedisHandler()
+	if properties.MetricsAddr != "" {
+		if _, err := metrics.Serve(properties.MetricsAddr, handler); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := server.ServeAll(properties.Addrs(), handler); err != nil {
+		log.Fatal(err)
+	}
 }
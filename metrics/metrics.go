@@ -0,0 +1,127 @@
+// Package metrics serves the metrics of the server on /metrics, in the text format of Prometheus
+package metrics
+
+import (
+	"bytes"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ContentType is the content type of the text format of Prometheus
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Collector writes metrics when they're scraped
+type Collector interface {
+	Collect(w *Writer)
+}
+
+// Writer writes metrics in the text format of Prometheus
+// a metric is written with Metric, followed by its samples
+type Writer struct {
+	buf bytes.Buffer
+}
+
+// Bytes returns what was written
+func (w *Writer) Bytes() []byte {
+	return w.buf.Bytes()
+}
+
+// Metric writes the HELP and TYPE lines of a metric, typ is counter, gauge or histogram
+func (w *Writer) Metric(name, typ, help string) {
+	w.buf.WriteString("# HELP " + name + " " + strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help) + "\n")
+	w.buf.WriteString("# TYPE " + name + " " + typ + "\n")
+}
+
+// Sample writes a sample of a metric, labels are pairs of names and values
+func (w *Writer) Sample(name string, value float64, labels ...string) {
+	w.buf.WriteString(name)
+	if len(labels) > 0 {
+		w.buf.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				w.buf.WriteByte(',')
+			}
+			w.buf.WriteString(labels[i] + `="` + escapeLabel(labels[i+1]) + `"`)
+		}
+		w.buf.WriteByte('}')
+	}
+	w.buf.WriteString(" " + formatValue(value) + "\n")
+}
+
+// Counter writes a metric of a single counter
+func (w *Writer) Counter(name, help string, value float64) {
+	w.Metric(name, "counter", help)
+	w.Sample(name, value)
+}
+
+// Gauge writes a metric of a single gauge
+func (w *Writer) Gauge(name, help string, value float64) {
+	w.Metric(name, "gauge", help)
+	w.Sample(name, value)
+}
+
+// Histogram writes the samples of a histogram: the cumulative counts of the observations up to each bound,
+// then of all of them, their sum and their count
+// counts has one more element than bounds, the count of every observation
+func (w *Writer) Histogram(name string, bounds []float64, counts []int64, sum float64, labels ...string) {
+	bucketLabels := append(append([]string(nil), labels...), "le", "")
+	for i, count := range counts {
+		le := math.Inf(1)
+		if i < len(bounds) {
+			le = bounds[i]
+		}
+		bucketLabels[len(bucketLabels)-1] = formatValue(le)
+		w.Sample(name+"_bucket", float64(count), bucketLabels...)
+	}
+	w.Sample(name+"_sum", sum, labels...)
+	w.Sample(name+"_count", float64(counts[len(counts)-1]), labels...)
+}
+
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// Handler serves the metrics of c
+func Handler(c Collector) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		w := &Writer{}
+		c.Collect(w)
+		rw.Header().Set("Content-Type", ContentType)
+		_, _ = rw.Write(w.Bytes())
+	})
+}
+
+// Serve listens on addr and serves the metrics of c on /metrics in the background
+// the error of listening is returned, the ones of serving are logged
+func Serve(addr string, c Collector) (net.Listener, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("metrics: bind: %s, serving /metrics", addr)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler(c))
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			log.Printf("metrics: %v", err)
+		}
+	}()
+	return listener, nil
+}
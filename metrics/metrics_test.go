@@ -0,0 +1,32 @@
+package metrics
+
+import (
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	w := &Writer{}
+	w.Counter("calls_total", "Calls.\nAll of them.", 42)
+	w.Metric("keys", "gauge", "Keys.")
+	w.Sample("keys", 1.5, "db", `a "quoted\" name`, "shard", "1")
+	w.Metric("duration_seconds", "histogram", "Durations.")
+	w.Histogram("duration_seconds", []float64{0.001, 0.5}, []int64{1, 3, 4}, 2.25, "cmd", "get")
+
+	expected := `# HELP calls_total Calls.\nAll of them.
+# TYPE calls_total counter
+calls_total 42
+# HELP keys Keys.
+# TYPE keys gauge
+keys{db="a \"quoted\\\" name",shard="1"} 1.5
+# HELP duration_seconds Durations.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{cmd="get",le="0.001"} 1
+duration_seconds_bucket{cmd="get",le="0.5"} 3
+duration_seconds_bucket{cmd="get",le="+Inf"} 4
+duration_seconds_sum{cmd="get"} 2.25
+duration_seconds_count{cmd="get"} 4
+`
+	if got := string(w.Bytes()); got != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, got)
+	}
+}
@@ -0,0 +1,155 @@
+package pubsub
+
+import (
+	"godis/interfaces"
+	"godis/lib/utils"
+	"sync"
+)
+
+type subscribers map[interfaces.Connection]struct{}
+
+type patternSubscribers struct {
+	pattern *utils.Pattern
+	subs    subscribers
+}
+
+// Hub keeps the subscribers of channels and patterns
+// subscription replies are pushed while holding the lock, so that a subscriber never
+// receives a message before the confirmation of its subscription
+type Hub struct {
+	mu       sync.RWMutex
+	channels map[string]subscribers
+	patterns map[string]*patternSubscribers
+	// shard channels, with a lock of their own
+	shards *shardRegistry
+}
+
+func MakeHub() *Hub {
+	return &Hub{
+		channels: make(map[string]subscribers),
+		patterns: make(map[string]*patternSubscribers),
+		shards:   makeShardRegistry(),
+	}
+}
+
+// subscribe adds conn to the subscribers of channel, returns false if it already is one
+func (h *Hub) subscribe(conn interfaces.Connection, channel string) bool {
+	subs, ok := h.channels[channel]
+	if !ok {
+		subs = make(subscribers)
+		h.channels[channel] = subs
+	}
+	if _, ok := subs[conn]; ok {
+		return false
+	}
+	subs[conn] = struct{}{}
+	conn.Subscribe(channel)
+	return true
+}
+
+func (h *Hub) unsubscribe(conn interfaces.Connection, channel string) {
+	conn.Unsubscribe(channel)
+	subs, ok := h.channels[channel]
+	if !ok {
+		return
+	}
+	delete(subs, conn)
+	if len(subs) == 0 {
+		delete(h.channels, channel)
+	}
+}
+
+func (h *Hub) psubscribe(conn interfaces.Connection, pattern string) bool {
+	ps, ok := h.patterns[pattern]
+	if !ok {
+		compiled, err := utils.CompilePattern(pattern)
+		if err != nil {
+			// an invalid pattern never matches
+			compiled = nil
+		}
+		ps = &patternSubscribers{
+			pattern: compiled,
+			subs:    make(subscribers),
+		}
+		h.patterns[pattern] = ps
+	}
+	if _, ok := ps.subs[conn]; ok {
+		return false
+	}
+	ps.subs[conn] = struct{}{}
+	conn.PSubscribe(pattern)
+	return true
+}
+
+func (h *Hub) punsubscribe(conn interfaces.Connection, pattern string) {
+	conn.PUnsubscribe(pattern)
+	ps, ok := h.patterns[pattern]
+	if !ok {
+		return
+	}
+	delete(ps.subs, conn)
+	if len(ps.subs) == 0 {
+		delete(h.patterns, pattern)
+	}
+}
+
+// Publish delivers message to the subscribers of channel and of the matching patterns
+// returns the number of clients that received it
+func (h *Hub) Publish(channel string, message []byte) int64 {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var receivers int64
+	if subs, ok := h.channels[channel]; ok {
+		msg := makeMessage(channel, message)
+		for conn := range subs {
+			if conn.Push(msg.bytes(conn.ProtocolVersion())) {
+				receivers++
+			}
+		}
+	}
+	for pattern, ps := range h.patterns {
+		if ps.pattern == nil || !ps.pattern.IsMatch(channel) {
+			continue
+		}
+		msg := makePMessage(pattern, channel, message)
+		for conn := range ps.subs {
+			if conn.Push(msg.bytes(conn.ProtocolVersion())) {
+				receivers++
+			}
+		}
+	}
+	return receivers
+}
+
+// UnsubscribeAll removes conn from every channel, pattern and shard channel, called once the client is closed
+func (h *Hub) UnsubscribeAll(conn interfaces.Connection) {
+	h.mu.Lock()
+	for _, channel := range conn.Channels() {
+		h.unsubscribe(conn, channel)
+	}
+	for _, pattern := range conn.Patterns() {
+		h.punsubscribe(conn, pattern)
+	}
+	h.mu.Unlock()
+
+	h.shards.mu.Lock()
+	defer h.shards.mu.Unlock()
+	for _, channel := range conn.ShardChannels() {
+		h.shards.unsubscribe(conn, channel)
+	}
+}
+
+// Counts returns the number of channels, patterns and shard channels with subscribers
+func (h *Hub) Counts() (channels, patterns, shardChannels int) {
+	h.mu.RLock()
+	channels, patterns = len(h.channels), len(h.patterns)
+	h.mu.RUnlock()
+
+	h.shards.mu.RLock()
+	defer h.shards.mu.RUnlock()
+	for _, slot := range h.shards.slots {
+		shardChannels += len(slot)
+	}
+	return channels, patterns, shardChannels
+}
@@ -0,0 +1,226 @@
+package pubsub
+
+import (
+	"godis/interfaces"
+	"godis/lib/utils"
+	"godis/redis/protocol"
+	"sort"
+	"strings"
+)
+
+// encodedMessage caches the encoding of a message for each protocol version,
+// so that it's marshalled at most twice whatever the number of receivers
+type encodedMessage struct {
+	reply   protocol.Reply
+	encoded [protocol.RESP3 + 1][]byte
+}
+
+func (m *encodedMessage) bytes(version int) []byte {
+	if m.encoded[version] == nil {
+		m.encoded[version] = protocol.Encode(m.reply, version)
+	}
+	return m.encoded[version]
+}
+
+// push sends reply to conn, encoded for the protocol version of conn
+func push(conn interfaces.Connection, reply protocol.Reply) bool {
+	return conn.Push(protocol.Encode(reply, conn.ProtocolVersion()))
+}
+
+func makeBulkPush(args ...[]byte) *encodedMessage {
+	replies := make([]protocol.Reply, len(args))
+	for i, arg := range args {
+		replies[i] = protocol.MakeBulkReply(arg)
+	}
+	return &encodedMessage{reply: protocol.MakePushReply(replies)}
+}
+
+func makeMessage(channel string, message []byte) *encodedMessage {
+	return makeBulkPush([]byte("message"), []byte(channel), message)
+}
+
+func makePMessage(pattern string, channel string, message []byte) *encodedMessage {
+	return makeBulkPush([]byte("pmessage"), []byte(pattern), []byte(channel), message)
+}
+
+// makeSubsReply renders the confirmation of (un)subscribing, count is the number of remaining subscriptions
+func makeSubsReply(kind string, channel string, count int) protocol.Reply {
+	var channelReply protocol.Reply = protocol.MakeBulkReply([]byte(channel))
+	if channel == "" {
+		channelReply = protocol.MakeNullBulkReply()
+	}
+	return protocol.MakePushReply([]protocol.Reply{
+		protocol.MakeBulkReply([]byte(kind)),
+		channelReply,
+		protocol.MakeIntReply(int64(count)),
+	})
+}
+
+// Subscribe subscribes conn to the given channels, one confirmation is pushed per channel
+// `SUBSCRIBE channel [channel ...]`
+func Subscribe(hub *Hub, conn interfaces.Connection, args [][]byte) protocol.Reply {
+	if len(args) < 1 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for 'subscribe' command")
+	}
+
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	for _, arg := range args {
+		channel := string(arg)
+		hub.subscribe(conn, channel)
+		push(conn, makeSubsReply("subscribe", channel, conn.SubsCount()))
+	}
+	return protocol.MakeNoReply()
+}
+
+// Unsubscribe unsubscribes conn from the given channels, or from all of them without arguments
+// `UNSUBSCRIBE [channel [channel ...]]`
+func Unsubscribe(hub *Hub, conn interfaces.Connection, args [][]byte) protocol.Reply {
+	channels := make([]string, len(args))
+	for i, arg := range args {
+		channels[i] = string(arg)
+	}
+
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if len(channels) == 0 {
+		channels = conn.Channels()
+		sort.Strings(channels)
+	}
+	if len(channels) == 0 {
+		push(conn, makeSubsReply("unsubscribe", "", conn.SubsCount()))
+		return protocol.MakeNoReply()
+	}
+	for _, channel := range channels {
+		hub.unsubscribe(conn, channel)
+		push(conn, makeSubsReply("unsubscribe", channel, conn.SubsCount()))
+	}
+	return protocol.MakeNoReply()
+}
+
+// PSubscribe subscribes conn to the channels matching the given glob-style patterns
+// `PSUBSCRIBE pattern [pattern ...]`
+func PSubscribe(hub *Hub, conn interfaces.Connection, args [][]byte) protocol.Reply {
+	if len(args) < 1 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for 'psubscribe' command")
+	}
+
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	for _, arg := range args {
+		pattern := string(arg)
+		hub.psubscribe(conn, pattern)
+		push(conn, makeSubsReply("psubscribe", pattern, conn.SubsCount()))
+	}
+	return protocol.MakeNoReply()
+}
+
+// PUnsubscribe unsubscribes conn from the given patterns, or from all of them without arguments
+// `PUNSUBSCRIBE [pattern [pattern ...]]`
+func PUnsubscribe(hub *Hub, conn interfaces.Connection, args [][]byte) protocol.Reply {
+	patterns := make([]string, len(args))
+	for i, arg := range args {
+		patterns[i] = string(arg)
+	}
+
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if len(patterns) == 0 {
+		patterns = conn.Patterns()
+		sort.Strings(patterns)
+	}
+	if len(patterns) == 0 {
+		push(conn, makeSubsReply("punsubscribe", "", conn.SubsCount()))
+		return protocol.MakeNoReply()
+	}
+	for _, pattern := range patterns {
+		hub.punsubscribe(conn, pattern)
+		push(conn, makeSubsReply("punsubscribe", pattern, conn.SubsCount()))
+	}
+	return protocol.MakeNoReply()
+}
+
+// Publish posts message to channel and returns the number of clients that received it
+// `PUBLISH channel message`
+func Publish(hub *Hub, args [][]byte) protocol.Reply {
+	if len(args) != 2 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for 'publish' command")
+	}
+	return protocol.MakeIntReply(hub.Publish(string(args[0]), args[1]))
+}
+
+// PubSub inspects the state of the hub
+// `PUBSUB CHANNELS [pattern]`
+// `PUBSUB NUMSUB [channel [channel ...]]`
+// `PUBSUB NUMPAT`
+// `PUBSUB SHARDCHANNELS [pattern]`
+// `PUBSUB SHARDNUMSUB [shardchannel [shardchannel ...]]`
+func PubSub(hub *Hub, args [][]byte) protocol.Reply {
+	if len(args) < 1 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for 'pubsub' command")
+	}
+
+	subCmd := strings.ToUpper(string(args[0]))
+	switch subCmd {
+	case "SHARDCHANNELS":
+		if len(args) > 2 {
+			return protocol.MakeErrReply("ERR wrong number of arguments for 'pubsub|shardchannels' command")
+		}
+		hub.shards.mu.RLock()
+		defer hub.shards.mu.RUnlock()
+		return matchChannels(hub.shards.channels(), args[1:])
+	case "SHARDNUMSUB":
+		hub.shards.mu.RLock()
+		defer hub.shards.mu.RUnlock()
+		return numSub(hub.shards.channels(), args[1:])
+	}
+
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+	switch subCmd {
+	case "CHANNELS":
+		if len(args) > 2 {
+			return protocol.MakeErrReply("ERR wrong number of arguments for 'pubsub|channels' command")
+		}
+		return matchChannels(hub.channels, args[1:])
+	case "NUMSUB":
+		return numSub(hub.channels, args[1:])
+	case "NUMPAT":
+		if len(args) != 1 {
+			return protocol.MakeErrReply("ERR wrong number of arguments for 'pubsub|numpat' command")
+		}
+		return protocol.MakeIntReply(int64(len(hub.patterns)))
+	}
+	return protocol.MakeErrReply("ERR unknown subcommand '" + string(args[0]) + "'. Try PUBSUB HELP.")
+}
+
+// matchChannels returns the channels with at least one subscriber, filtered by the optional pattern
+func matchChannels(channels map[string]subscribers, args [][]byte) protocol.Reply {
+	var pattern *utils.Pattern
+	if len(args) == 1 {
+		var err error
+		pattern, err = utils.CompilePattern(string(args[0]))
+		if err != nil {
+			return protocol.MakeEmptyMultiBulkReply()
+		}
+	}
+	result := make([][]byte, 0, len(channels))
+	for channel := range channels {
+		if pattern == nil || pattern.IsMatch(channel) {
+			result = append(result, []byte(channel))
+		}
+	}
+	return protocol.MakeMultiBulkReply(result)
+}
+
+// numSub returns [channel, count, ...] with the number of subscribers of each channel
+func numSub(channels map[string]subscribers, args [][]byte) protocol.Reply {
+	replies := make([]protocol.Reply, 0, len(args)*2)
+	for _, arg := range args {
+		replies = append(replies,
+			protocol.MakeBulkReply(arg),
+			protocol.MakeIntReply(int64(len(channels[string(arg)]))),
+		)
+	}
+	return protocol.MakeMultiRawReply(replies)
+}
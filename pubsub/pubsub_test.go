@@ -0,0 +1,235 @@
+package pubsub
+
+import (
+	"godis/lib/utils"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeConn records what is written to it
+type fakeConn struct {
+	mu       sync.Mutex
+	out      []string
+	channels map[string]struct{}
+	patterns map[string]struct{}
+	shards   map[string]struct{}
+	// a slow client refuses pushed messages
+	slow    bool
+	version int
+}
+
+func newFakeConn() *fakeConn {
+	return &fakeConn{
+		version:  2,
+		channels: make(map[string]struct{}),
+		patterns: make(map[string]struct{}),
+		shards:   make(map[string]struct{}),
+	}
+}
+
+func (c *fakeConn) ID() int64                      { return 0 }
+func (c *fakeConn) ProtocolVersion() int           { return c.version }
+func (c *fakeConn) SetProtocolVersion(version int) { c.version = version }
+func (c *fakeConn) RemoteAddr() string             { return "" }
+func (c *fakeConn) LocalAddr() string              { return "" }
+func (c *fakeConn) Name() string                   { return "" }
+func (c *fakeConn) SetName(name string)            {}
+func (c *fakeConn) Authenticated() bool            { return true }
+func (c *fakeConn) SetAuthenticated(bool)          {}
+func (c *fakeConn) CreatedAt() time.Time           { return time.Time{} }
+func (c *fakeConn) LastCommand() (string, time.Time) {
+	return "", time.Time{}
+}
+func (c *fakeConn) SetLastCommand(string)    {}
+func (c *fakeConn) Buffers() (int, int, int) { return 0, 0, 0 }
+func (c *fakeConn) NoEvict() bool            { return false }
+func (c *fakeConn) SetNoEvict(bool)          {}
+func (c *fakeConn) Monitoring() bool         { return false }
+func (c *fakeConn) SetMonitoring(bool)       {}
+func (c *fakeConn) SetReplyMode(string)      {}
+func (c *fakeConn) Close() error             { return nil }
+func (c *fakeConn) CloseAfterReply()         {}
+
+func (c *fakeConn) Flush() error { return nil }
+
+func (c *fakeConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.out = append(c.out, string(b))
+	return len(b), nil
+}
+
+func (c *fakeConn) Push(b []byte) bool {
+	if c.slow {
+		return false
+	}
+	_, _ = c.Write(b)
+	return true
+}
+
+func (c *fakeConn) Subscribe(channel string)   { c.channels[channel] = struct{}{} }
+func (c *fakeConn) Unsubscribe(channel string) { delete(c.channels, channel) }
+func (c *fakeConn) PSubscribe(pattern string)  { c.patterns[pattern] = struct{}{} }
+func (c *fakeConn) PUnsubscribe(pattern string) {
+	delete(c.patterns, pattern)
+}
+func (c *fakeConn) SSubscribe(channel string)   { c.shards[channel] = struct{}{} }
+func (c *fakeConn) SUnsubscribe(channel string) { delete(c.shards, channel) }
+func (c *fakeConn) SubsCount() int              { return len(c.channels) + len(c.patterns) + len(c.shards) }
+func (c *fakeConn) Subscribed(channel string) bool {
+	_, ok := c.channels[channel]
+	return ok
+}
+
+func (c *fakeConn) Channels() []string {
+	var result []string
+	for channel := range c.channels {
+		result = append(result, channel)
+	}
+	return result
+}
+
+func (c *fakeConn) ShardChannels() []string {
+	var result []string
+	for channel := range c.shards {
+		result = append(result, channel)
+	}
+	return result
+}
+
+func (c *fakeConn) Patterns() []string {
+	var result []string
+	for pattern := range c.patterns {
+		result = append(result, pattern)
+	}
+	return result
+}
+
+func (c *fakeConn) output() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := strings.Join(c.out, "")
+	c.out = nil
+	return out
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	hub := MakeHub()
+	alice, bob := newFakeConn(), newFakeConn()
+
+	Subscribe(hub, alice, utils.ToCmdLine("news", "sport"))
+	expected := "*3\r\n$9\r\nsubscribe\r\n$4\r\nnews\r\n:1\r\n*3\r\n$9\r\nsubscribe\r\n$5\r\nsport\r\n:2\r\n"
+	if out := alice.output(); out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+	PSubscribe(hub, bob, utils.ToCmdLine("n*"))
+	bob.output()
+
+	reply := Publish(hub, utils.ToCmdLine("news", "hello"))
+	if string(reply.ToBytes()) != ":2\r\n" {
+		t.Errorf("expected 2 receivers, got %q", string(reply.ToBytes()))
+	}
+	expected = "*3\r\n$7\r\nmessage\r\n$4\r\nnews\r\n$5\r\nhello\r\n"
+	if out := alice.output(); out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+	expected = "*4\r\n$8\r\npmessage\r\n$2\r\nn*\r\n$4\r\nnews\r\n$5\r\nhello\r\n"
+	if out := bob.output(); out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+
+	reply = PubSub(hub, utils.ToCmdLine("NUMSUB", "news", "nothing"))
+	if string(reply.ToBytes()) != "*4\r\n$4\r\nnews\r\n:1\r\n$7\r\nnothing\r\n:0\r\n" {
+		t.Errorf("unexpected NUMSUB reply %q", string(reply.ToBytes()))
+	}
+	reply = PubSub(hub, utils.ToCmdLine("CHANNELS", "s*"))
+	if string(reply.ToBytes()) != "*1\r\n$5\r\nsport\r\n" {
+		t.Errorf("unexpected CHANNELS reply %q", string(reply.ToBytes()))
+	}
+	reply = PubSub(hub, utils.ToCmdLine("NUMPAT"))
+	if string(reply.ToBytes()) != ":1\r\n" {
+		t.Errorf("unexpected NUMPAT reply %q", string(reply.ToBytes()))
+	}
+
+	Unsubscribe(hub, alice, nil)
+	expected = "*3\r\n$11\r\nunsubscribe\r\n$4\r\nnews\r\n:1\r\n*3\r\n$11\r\nunsubscribe\r\n$5\r\nsport\r\n:0\r\n"
+	if out := alice.output(); out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+	Unsubscribe(hub, alice, nil)
+	expected = "*3\r\n$11\r\nunsubscribe\r\n$-1\r\n:0\r\n"
+	if out := alice.output(); out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+
+	hub.UnsubscribeAll(bob)
+	reply = Publish(hub, utils.ToCmdLine("news", "bye"))
+	if string(reply.ToBytes()) != ":0\r\n" {
+		t.Errorf("expected no receivers, got %q", string(reply.ToBytes()))
+	}
+}
+
+func TestSlowSubscriberIsNotCounted(t *testing.T) {
+	hub := MakeHub()
+	fast, slow := newFakeConn(), newFakeConn()
+	Subscribe(hub, fast, utils.ToCmdLine("news"))
+	Subscribe(hub, slow, utils.ToCmdLine("news"))
+	slow.slow = true
+
+	reply := Publish(hub, utils.ToCmdLine("news", "hello"))
+	if string(reply.ToBytes()) != ":1\r\n" {
+		t.Errorf("expected 1 receiver, got %q", string(reply.ToBytes()))
+	}
+}
+
+func TestShardedPubSub(t *testing.T) {
+	hub := MakeHub()
+	alice, bob := newFakeConn(), newFakeConn()
+
+	SSubscribe(hub, alice, utils.ToCmdLine("{user1}.orders", "{user1}.payments"))
+	expected := "*3\r\n$10\r\nssubscribe\r\n$14\r\n{user1}.orders\r\n:1\r\n*3\r\n$10\r\nssubscribe\r\n$16\r\n{user1}.payments\r\n:2\r\n"
+	if out := alice.output(); out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+	Subscribe(hub, bob, utils.ToCmdLine("{user1}.orders"))
+	bob.output()
+
+	// classic and shard channels don't see each other's messages
+	reply := SPublish(hub, utils.ToCmdLine("{user1}.orders", "paid"))
+	if string(reply.ToBytes()) != ":1\r\n" {
+		t.Errorf("expected 1 receiver, got %q", string(reply.ToBytes()))
+	}
+	expected = "*3\r\n$8\r\nsmessage\r\n$14\r\n{user1}.orders\r\n$4\r\npaid\r\n"
+	if out := alice.output(); out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+	if out := bob.output(); out != "" {
+		t.Errorf("expected nothing for a classic subscriber, got %q", out)
+	}
+	reply = Publish(hub, utils.ToCmdLine("{user1}.payments", "paid"))
+	if string(reply.ToBytes()) != ":0\r\n" {
+		t.Errorf("expected no receivers, got %q", string(reply.ToBytes()))
+	}
+
+	reply = PubSub(hub, utils.ToCmdLine("SHARDCHANNELS", "*orders"))
+	if string(reply.ToBytes()) != "*1\r\n$14\r\n{user1}.orders\r\n" {
+		t.Errorf("unexpected SHARDCHANNELS reply %q", string(reply.ToBytes()))
+	}
+	reply = PubSub(hub, utils.ToCmdLine("SHARDNUMSUB", "{user1}.payments", "none"))
+	if string(reply.ToBytes()) != "*4\r\n$16\r\n{user1}.payments\r\n:1\r\n$4\r\nnone\r\n:0\r\n" {
+		t.Errorf("unexpected SHARDNUMSUB reply %q", string(reply.ToBytes()))
+	}
+
+	SUnsubscribe(hub, alice, utils.ToCmdLine("{user1}.orders"))
+	expected = "*3\r\n$12\r\nsunsubscribe\r\n$14\r\n{user1}.orders\r\n:1\r\n"
+	if out := alice.output(); out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+	hub.UnsubscribeAll(alice)
+	reply = PubSub(hub, utils.ToCmdLine("SHARDCHANNELS"))
+	if string(reply.ToBytes()) != "*0\r\n" {
+		t.Errorf("expected no shard channels, got %q", string(reply.ToBytes()))
+	}
+}
@@ -0,0 +1,141 @@
+package pubsub
+
+import (
+	"godis/interfaces"
+	"godis/lib/utils"
+	"godis/redis/protocol"
+	"sort"
+	"sync"
+)
+
+// shardRegistry keeps the subscribers of shard channels, grouped by the cluster slot of the channel
+// it's separate from classic channels: PUBLISH doesn't reach SSUBSCRIBE clients and vice versa
+type shardRegistry struct {
+	mu    sync.RWMutex
+	slots map[uint16]map[string]subscribers
+}
+
+func makeShardRegistry() *shardRegistry {
+	return &shardRegistry{
+		slots: make(map[uint16]map[string]subscribers),
+	}
+}
+
+func (r *shardRegistry) subscribe(conn interfaces.Connection, channel string) {
+	slot := utils.KeySlot(channel)
+	channels, ok := r.slots[slot]
+	if !ok {
+		channels = make(map[string]subscribers)
+		r.slots[slot] = channels
+	}
+	subs, ok := channels[channel]
+	if !ok {
+		subs = make(subscribers)
+		channels[channel] = subs
+	}
+	subs[conn] = struct{}{}
+	conn.SSubscribe(channel)
+}
+
+func (r *shardRegistry) unsubscribe(conn interfaces.Connection, channel string) {
+	conn.SUnsubscribe(channel)
+	slot := utils.KeySlot(channel)
+	channels, ok := r.slots[slot]
+	if !ok {
+		return
+	}
+	subs, ok := channels[channel]
+	if !ok {
+		return
+	}
+	delete(subs, conn)
+	if len(subs) == 0 {
+		delete(channels, channel)
+		if len(channels) == 0 {
+			delete(r.slots, slot)
+		}
+	}
+}
+
+func (r *shardRegistry) publish(channel string, message []byte) int64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	subs := r.slots[utils.KeySlot(channel)][channel]
+	if len(subs) == 0 {
+		return 0
+	}
+	msg := makeBulkPush([]byte("smessage"), []byte(channel), message)
+	var receivers int64
+	for conn := range subs {
+		if conn.Push(msg.bytes(conn.ProtocolVersion())) {
+			receivers++
+		}
+	}
+	return receivers
+}
+
+// channels returns every shard channel with at least one subscriber
+func (r *shardRegistry) channels() map[string]subscribers {
+	result := make(map[string]subscribers)
+	for _, channels := range r.slots {
+		for channel, subs := range channels {
+			result[channel] = subs
+		}
+	}
+	return result
+}
+
+// SSubscribe subscribes conn to the given shard channels
+// the count in confirmations only includes shard channels
+// `SSUBSCRIBE shardchannel [shardchannel ...]`
+func SSubscribe(hub *Hub, conn interfaces.Connection, args [][]byte) protocol.Reply {
+	if len(args) < 1 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for 'ssubscribe' command")
+	}
+
+	r := hub.shards
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, arg := range args {
+		channel := string(arg)
+		r.subscribe(conn, channel)
+		push(conn, makeSubsReply("ssubscribe", channel, len(conn.ShardChannels())))
+	}
+	return protocol.MakeNoReply()
+}
+
+// SUnsubscribe unsubscribes conn from the given shard channels, or from all of them without arguments
+// `SUNSUBSCRIBE [shardchannel [shardchannel ...]]`
+func SUnsubscribe(hub *Hub, conn interfaces.Connection, args [][]byte) protocol.Reply {
+	channels := make([]string, len(args))
+	for i, arg := range args {
+		channels[i] = string(arg)
+	}
+
+	r := hub.shards
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(channels) == 0 {
+		channels = conn.ShardChannels()
+		sort.Strings(channels)
+	}
+	if len(channels) == 0 {
+		push(conn, makeSubsReply("sunsubscribe", "", 0))
+		return protocol.MakeNoReply()
+	}
+	for _, channel := range channels {
+		r.unsubscribe(conn, channel)
+		push(conn, makeSubsReply("sunsubscribe", channel, len(conn.ShardChannels())))
+	}
+	return protocol.MakeNoReply()
+}
+
+// SPublish posts message to a shard channel and returns the number of clients that received it
+// `SPUBLISH shardchannel message`
+func SPublish(hub *Hub, args [][]byte) protocol.Reply {
+	if len(args) != 2 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for 'spublish' command")
+	}
+	return protocol.MakeIntReply(hub.shards.publish(string(args[0]), args[1]))
+}
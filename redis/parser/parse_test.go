@@ -2,7 +2,9 @@ package parser
 
 import (
 	"bytes"
+	"io"
 	"net"
+	"strings"
 	"testing"
 	"time"
 )
@@ -102,3 +104,41 @@ func TestParseDirectly(t *testing.T) {
 		})
 	}
 }
+
+func TestParseStreamLimits(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{
+			name:  "bulk too long",
+			input: "$1000000000\r\n",
+		},
+		{
+			name:  "array too long",
+			input: "*100000000\r\n",
+		},
+		{
+			name:  "argument too long",
+			input: "*1\r\n$1000000000\r\n",
+		},
+		{
+			// the announced length isn't allocated before the data comes
+			name:  "bulk cut",
+			input: "$100000000\r\nhello",
+		},
+		{
+			name:  "line too long",
+			input: strings.Repeat("a", DefaultMaxInlineLen+1),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := <-ParseStream(bytes.NewBufferString(tt.input))
+			if payload.Err == nil || payload.Err == io.EOF {
+				t.Fatalf("expected an error, got %v", payload)
+			}
+		})
+	}
+}
@@ -32,7 +32,7 @@ func parse0(rawReader io.Reader, ch chan<- *Payload) {
 	}()
 	reader := bufio.NewReader(rawReader)
 	for {
-		line, err := reader.ReadBytes('\n')
+		line, err := readLine(reader)
 		if err != nil {
 			ch <- &Payload{Err: err}
 			close(ch)
@@ -95,9 +95,45 @@ func parse0(rawReader io.Reader, ch chan<- *Payload) {
 	}
 }
 
+// readLine reads a line of at most DefaultMaxInlineLen bytes, like the inline commands of Parser
+func readLine(reader *bufio.Reader) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		line = append(line, chunk...)
+		if len(line) > DefaultMaxInlineLen {
+			return nil, errors.New("protocol error: too big inline request")
+		}
+		if err != bufio.ErrBufferFull {
+			return line, err
+		}
+	}
+}
+
+// readBulk reads n bytes followed by CRLF if withCRLF is set
+// the length comes from the client, so the body grows with the data received rather than being
+// allocated upfront, and it's bounded by DefaultMaxBulkLen
+func readBulk(reader *bufio.Reader, n int64, withCRLF bool) ([]byte, error) {
+	if n > DefaultMaxBulkLen {
+		return nil, errors.New("protocol error: invalid bulk length")
+	}
+	size := n
+	if withCRLF {
+		size += 2
+	}
+	var body bytes.Buffer
+	if _, err := io.CopyN(&body, reader, size); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return body.Bytes()[:n], nil
+}
+
 // there is no CRLF between RDB and following AOF, therefore it needs to be treated differently
 func parseRDBBulkString(reader *bufio.Reader, ch chan<- *Payload) error {
-	header, err := reader.ReadBytes('\n')
+	header, err := readLine(reader)
 	if err != nil {
 		return errors.New("failed to read bytes")
 	}
@@ -109,8 +145,7 @@ func parseRDBBulkString(reader *bufio.Reader, ch chan<- *Payload) error {
 	if err != nil || strLen <= 0 {
 		return errors.New("illegal bulk header: " + string(header))
 	}
-	body := make([]byte, strLen)
-	_, err = io.ReadFull(reader, body)
+	body, err := readBulk(reader, strLen, false)
 	if err != nil {
 		return err
 	}
@@ -137,13 +172,12 @@ func parseBulkString(header []byte, reader *bufio.Reader, ch chan<- *Payload) er
 		}
 		return nil
 	}
-	body := make([]byte, strLen+2)
-	_, err = io.ReadFull(reader, body)
+	body, err := readBulk(reader, strLen, true)
 	if err != nil {
 		return err
 	}
 	ch <- &Payload{
-		Data: protocol.MakeBulkReply(body[:len(body)-2]),
+		Data: protocol.MakeBulkReply(body),
 	}
 	return nil
 }
@@ -158,11 +192,14 @@ func parseArray(header []byte, reader *bufio.Reader, ch chan<- *Payload) error {
 			Data: protocol.MakeEmptyMultiBulkReply(),
 		}
 		return nil
+	} else if nStrs > DefaultMaxArrayLen {
+		return errors.New("protocol error: invalid multibulk length")
 	}
-	lines := make([][]byte, 0, nStrs)
+	// the arguments are appended as they're received
+	lines := make([][]byte, 0, min(nStrs, 64))
 	for i := int64(0); i < nStrs; i++ {
 		var line []byte
-		line, err = reader.ReadBytes('\n')
+		line, err = readLine(reader)
 		if err != nil {
 			return err
 		}
@@ -178,12 +215,11 @@ func parseArray(header []byte, reader *bufio.Reader, ch chan<- *Payload) error {
 		} else if strLen == -1 {
 			lines = append(lines, []byte{})
 		} else {
-			body := make([]byte, strLen+2)
-			_, err := io.ReadFull(reader, body)
+			body, err := readBulk(reader, strLen, true)
 			if err != nil {
 				return err
 			}
-			lines = append(lines, body[:len(body)-2])
+			lines = append(lines, body)
 		}
 	}
 	ch <- &Payload{
@@ -0,0 +1,395 @@
+package parser
+
+import (
+	"bytes"
+	"io"
+)
+
+const (
+	// DefaultMaxBulkLen is the default length limit of a bulk string, proto-max-bulk-len in Redis
+	DefaultMaxBulkLen = 512 * 1024 * 1024
+	// DefaultMaxArrayLen is the default limit of the number of arguments of a command
+	DefaultMaxArrayLen = 1024 * 1024
+	// DefaultMaxQueryBufferLen is the default limit of the data buffered for a command, client-query-buffer-limit in Redis
+	DefaultMaxQueryBufferLen = 1024 * 1024 * 1024
+	// DefaultMaxInlineLen is the default length limit of inline commands and of the headers of multi bulk commands
+	DefaultMaxInlineLen = 64 * 1024
+
+	defaultBufferSize = 16 * 1024
+)
+
+// ProtocolError is returned by Parser.Next for a malformed or oversized request
+// the input can't be parsed any further after it, the connection should be closed
+type ProtocolError struct {
+	Msg string
+}
+
+func (e *ProtocolError) Error() string {
+	return "Protocol error: " + e.Msg
+}
+
+func protocolErr(msg string) *ProtocolError {
+	return &ProtocolError{Msg: msg}
+}
+
+var errQueryBufferLimit = protocolErr("query buffer limit reached")
+
+// Parser reads commands from a stream synchronously, without a goroutine nor a copy of each argument
+// commands are either multi bulk arrays `*<n>\r\n$<len>\r\n<arg>\r\n...` or inline `arg arg "quoted arg"\r\n`
+// nothing is allocated for a request exceeding the limits, Next returns a ProtocolError instead
+type Parser struct {
+	reader io.Reader
+	buf    []byte
+	// unparsed data is buf[r:w]
+	r, w int
+
+	// state of the command being parsed, kept while waiting for more data
+	// pos is the offset of the next unparsed byte from r, offsets are relative to r too
+	pos     int
+	want    int
+	offsets [][2]int
+	args    [][]byte
+
+	MaxBulkLen        int64
+	MaxArrayLen       int64
+	MaxInlineLen      int
+	MaxQueryBufferLen int64
+}
+
+func NewParser(reader io.Reader) *Parser {
+	return &Parser{
+		reader:            reader,
+		buf:               make([]byte, defaultBufferSize),
+		want:              -1,
+		MaxBulkLen:        DefaultMaxBulkLen,
+		MaxArrayLen:       DefaultMaxArrayLen,
+		MaxInlineLen:      DefaultMaxInlineLen,
+		MaxQueryBufferLen: DefaultMaxQueryBufferLen,
+	}
+}
+
+// Buffered returns the number of bytes received and not returned by Next yet
+func (p *Parser) Buffered() int {
+	return p.w - p.r
+}
+
+// Next returns the arguments of the next command, empty commands are skipped
+// the arguments point into the buffer of the parser, they're only valid until the next call to Next
+// it returns io.EOF once the input ends between commands, io.ErrUnexpectedEOF in the middle of one
+func (p *Parser) Next() ([][]byte, error) {
+	for {
+		complete, err := p.parse()
+		if err != nil {
+			return nil, err
+		}
+		if !complete {
+			if err := p.fill(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		p.args = p.args[:0]
+		for _, offset := range p.offsets {
+			p.args = append(p.args, p.buf[p.r+offset[0]:p.r+offset[1]:p.r+offset[1]])
+		}
+		p.r += p.pos
+		p.pos = 0
+		p.want = -1
+		p.offsets = p.offsets[:0]
+		if len(p.args) == 0 {
+			continue
+		}
+		return p.args, nil
+	}
+}
+
+// fill reads more data, compacting or growing the buffer if it's full
+func (p *Parser) fill() error {
+	if p.r > 0 {
+		n := copy(p.buf, p.buf[p.r:p.w])
+		p.r, p.w = 0, n
+		if n == 0 && len(p.buf) > 4*defaultBufferSize {
+			// don't keep the memory of a large request
+			p.buf = make([]byte, defaultBufferSize)
+		}
+	}
+	if p.w == len(p.buf) {
+		if int64(p.w) >= p.MaxQueryBufferLen {
+			return errQueryBufferLimit
+		}
+		// grows with the data actually received, not with the announced lengths
+		grown := make([]byte, min(2*int64(len(p.buf)), p.MaxQueryBufferLen))
+		copy(grown, p.buf[:p.w])
+		p.buf = grown
+	}
+
+	n, err := p.reader.Read(p.buf[p.w:])
+	p.w += n
+	if n > 0 {
+		return nil
+	}
+	if err == nil {
+		err = io.ErrNoProgress
+	}
+	if err == io.EOF && p.w > p.r {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// line returns the offsets of the end of the line starting at p.pos, "\r\n" excluded, and of the next line
+// false is returned if the line isn't complete yet
+func (p *Parser) line() (int, int, bool, error) {
+	data := p.buf[p.r+p.pos : p.w]
+	i := bytes.IndexByte(data, '\n')
+	if i < 0 {
+		if len(data) > p.MaxInlineLen {
+			return 0, 0, false, protocolErr("too big inline request")
+		}
+		return 0, 0, false, nil
+	}
+	if i > p.MaxInlineLen {
+		return 0, 0, false, protocolErr("too big inline request")
+	}
+	end := i
+	if end > 0 && data[end-1] == '\r' {
+		end--
+	}
+	return p.pos + end, p.pos + i + 1, true, nil
+}
+
+// parseLength parses the number of a `*<n>` or `$<len>` header without allocating
+func parseLength(b []byte) (int64, bool) {
+	if len(b) == 0 || len(b) > 19 {
+		return 0, false
+	}
+	neg := false
+	if b[0] == '-' {
+		neg = true
+		b = b[1:]
+		if len(b) == 0 {
+			return 0, false
+		}
+	}
+	var n int64
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + int64(c-'0')
+	}
+	if neg {
+		n = -n
+	}
+	return n, true
+}
+
+// parse carries on parsing the current command, it returns true once it's complete
+func (p *Parser) parse() (bool, error) {
+	if p.want < 0 {
+		if p.r == p.w {
+			return false, nil
+		}
+		if p.buf[p.r] != '*' {
+			return p.parseInline()
+		}
+		end, next, ok, err := p.line()
+		if !ok {
+			return false, err
+		}
+		n, ok := parseLength(p.buf[p.r+1 : p.r+end])
+		if !ok || n > p.MaxArrayLen {
+			return false, protocolErr("invalid multibulk length")
+		}
+		p.pos = next
+		if n <= 0 {
+			return true, nil
+		}
+		p.want = int(n)
+	}
+
+	for len(p.offsets) < p.want {
+		start := p.pos
+		if p.r+start == p.w {
+			return false, nil
+		}
+		if c := p.buf[p.r+start]; c != '$' {
+			return false, protocolErr("expected '$', got '" + string(c) + "'")
+		}
+		end, body, ok, err := p.line()
+		if !ok {
+			return false, err
+		}
+		n, ok := parseLength(p.buf[p.r+start+1 : p.r+end])
+		if !ok || n < 0 || n > p.MaxBulkLen {
+			return false, protocolErr("invalid bulk length")
+		}
+		if int64(body)+n+2 > p.MaxQueryBufferLen {
+			// the command could never be buffered whole, no need to receive it
+			return false, errQueryBufferLimit
+		}
+		if int64(p.w-p.r-body) < n+2 {
+			// the header is parsed again once the whole argument is received
+			return false, nil
+		}
+		bodyEnd := body + int(n)
+		if p.buf[p.r+bodyEnd] != '\r' || p.buf[p.r+bodyEnd+1] != '\n' {
+			return false, protocolErr("invalid bulk length")
+		}
+		p.offsets = append(p.offsets, [2]int{body, bodyEnd})
+		p.pos = bodyEnd + 2
+	}
+	return true, nil
+}
+
+// parseInline parses an inline command, quoted arguments are unescaped in place
+func (p *Parser) parseInline() (bool, error) {
+	end, next, ok, err := p.line()
+	if !ok {
+		return false, err
+	}
+	p.offsets, err = splitInline(p.buf[p.r:p.r+end], p.offsets)
+	if err != nil {
+		return false, err
+	}
+	p.pos = next
+	return true, nil
+}
+
+// SplitArgs splits a line into arguments like redis-cli and inline commands do:
+// arguments are separated by spaces, "double quoted" ones may contain escapes like \n or \x41
+func SplitArgs(line string) ([]string, error) {
+	buf := []byte(line)
+	offsets, err := splitInline(buf, nil)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, len(offsets))
+	for i, offset := range offsets {
+		args[i] = string(buf[offset[0]:offset[1]])
+	}
+	return args, nil
+}
+
+// splitInline splits line like a shell would, appending the offsets of the arguments to offsets
+// the line is rewritten from its start, unescaped arguments are never longer than their source
+func splitInline(line []byte, offsets [][2]int) ([][2]int, error) {
+	out := 0
+	i := 0
+	for {
+		for i < len(line) && isSpace(line[i]) {
+			i++
+		}
+		if i == len(line) {
+			break
+		}
+		start := out
+		switch line[i] {
+		case '"':
+			i++
+			closed := false
+			for i < len(line) {
+				c := line[i]
+				if c == '\\' && i+3 < len(line) && line[i+1] == 'x' && isHex(line[i+2]) && isHex(line[i+3]) {
+					c = hexValue(line[i+2])<<4 | hexValue(line[i+3])
+					i += 3
+				} else if c == '\\' && i+1 < len(line) {
+					i++
+					c = unescape(line[i])
+				} else if c == '"' {
+					closed = true
+					i++
+					break
+				}
+				line[out] = c
+				out++
+				i++
+			}
+			if !closed || i < len(line) && !isSpace(line[i]) {
+				return nil, protocolErr("unbalanced quotes in request")
+			}
+		case '\'':
+			i++
+			closed := false
+			for i < len(line) {
+				c := line[i]
+				if c == '\\' && i+1 < len(line) && line[i+1] == '\'' {
+					i++
+					c = '\''
+				} else if c == '\'' {
+					closed = true
+					i++
+					break
+				}
+				line[out] = c
+				out++
+				i++
+			}
+			if !closed || i < len(line) && !isSpace(line[i]) {
+				return nil, protocolErr("unbalanced quotes in request")
+			}
+		default:
+			for i < len(line) && !isSpace(line[i]) {
+				line[out] = line[i]
+				out++
+				i++
+			}
+		}
+		offsets = append(offsets, [2]int{start, out})
+		// keep a separator, so that an argument never runs into the next one
+		out++
+	}
+	return offsets, nil
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\v' || c == '\f'
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func hexValue(c byte) byte {
+	switch {
+	case c <= '9':
+		return c - '0'
+	case c <= 'F':
+		return c - 'A' + 10
+	}
+	return c - 'a' + 10
+}
+
+func unescape(c byte) byte {
+	switch c {
+	case 'n':
+		return '\n'
+	case 'r':
+		return '\r'
+	case 't':
+		return '\t'
+	case 'b':
+		return '\b'
+	case 'a':
+		return '\a'
+	}
+	return c
+}
+
+// CloneArgs copies args out of the buffer of a Parser, for callers keeping them after the next call to Next
+// all the arguments share a single allocation
+func CloneArgs(args [][]byte) [][]byte {
+	size := 0
+	for _, arg := range args {
+		size += len(arg)
+	}
+	data := make([]byte, 0, size)
+	cloned := make([][]byte, len(args))
+	for i, arg := range args {
+		start := len(data)
+		data = append(data, arg...)
+		cloned[i] = data[start:len(data):len(data)]
+	}
+	return cloned
+}
@@ -0,0 +1,238 @@
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// readAll parses every command of input, the arguments are copied as the parser reuses its buffer
+func readAll(p *Parser) ([][]string, error) {
+	var commands [][]string
+	for {
+		args, err := p.Next()
+		if err != nil {
+			return commands, err
+		}
+		command := make([]string, len(args))
+		for i, arg := range args {
+			command[i] = string(arg)
+		}
+		commands = append(commands, command)
+	}
+}
+
+func encodeCommand(args [][]byte) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&buf, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	return buf.Bytes()
+}
+
+func TestParserNext(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected [][]string
+	}{
+		{
+			name:     "multi bulk",
+			input:    "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nvalue\r\n",
+			expected: [][]string{{"SET", "key", "value"}},
+		},
+		{
+			name:     "binary and empty arguments",
+			input:    "*3\r\n$3\r\nSET\r\n$4\r\na\r\nb\r\n$0\r\n\r\n",
+			expected: [][]string{{"SET", "a\r\nb", ""}},
+		},
+		{
+			name:     "pipelined",
+			input:    "*1\r\n$4\r\nPING\r\nPING\r\n*2\r\n$4\r\nECHO\r\n$2\r\nhi\r\n",
+			expected: [][]string{{"PING"}, {"PING"}, {"ECHO", "hi"}},
+		},
+		{
+			name:     "empty commands are skipped",
+			input:    "\r\n*0\r\n*-1\r\n   \r\nPING\r\n",
+			expected: [][]string{{"PING"}},
+		},
+		{
+			name:     "inline",
+			input:    "SET  key\tvalue\r\nGET key\n",
+			expected: [][]string{{"SET", "key", "value"}, {"GET", "key"}},
+		},
+		{
+			name:     "inline quotes",
+			input:    "SET \"a b\\n\\x41\\\"\" 'it\\'s' \"\"\r\n",
+			expected: [][]string{{"SET", "a b\nA\"", "it's", ""}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// one byte at a time, so that every command is received in several parts
+			for _, reader := range []io.Reader{strings.NewReader(tt.input), iotest.OneByteReader(strings.NewReader(tt.input))} {
+				commands, err := readAll(NewParser(reader))
+				if err != io.EOF {
+					t.Fatalf("expected io.EOF, got %v", err)
+				}
+				if fmt.Sprint(commands) != fmt.Sprint(tt.expected) {
+					t.Errorf("expected %q, got %q", tt.expected, commands)
+				}
+			}
+		})
+	}
+}
+
+func TestParserErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"bulk too long", "*1\r\n$1025\r\n", "Protocol error: invalid bulk length"},
+		{"negative bulk length", "*1\r\n$-1\r\n", "Protocol error: invalid bulk length"},
+		{"array too long", "*1025\r\n", "Protocol error: invalid multibulk length"},
+		{"invalid array length", "*x\r\n", "Protocol error: invalid multibulk length"},
+		{"missing bulk", "*2\r\n$3\r\nGET\r\n:1\r\n", "Protocol error: expected '$', got ':'"},
+		{"bulk longer than its length", "*1\r\n$3\r\nGETS\r\n", "Protocol error: invalid bulk length"},
+		{"unbalanced quotes", "SET \"key value\r\n", "Protocol error: unbalanced quotes in request"},
+		{"text after quotes", "SET \"key\"value\r\n", "Protocol error: unbalanced quotes in request"},
+		{"inline too long", strings.Repeat("a", 2048), "Protocol error: too big inline request"},
+		{"header too long", "*1\r\n$" + strings.Repeat("1", 2048), "Protocol error: too big inline request"},
+		{"query buffer limit", "*1000\r\n" + strings.Repeat("$1\r\na\r\n", 1000), "Protocol error: query buffer limit reached"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewParser(strings.NewReader(tt.input))
+			p.MaxBulkLen, p.MaxArrayLen, p.MaxInlineLen, p.MaxQueryBufferLen = 1024, 1024, 1024, 4096
+			_, err := p.Next()
+			var protocolErr *ProtocolError
+			if !errors.As(err, &protocolErr) || err.Error() != tt.expected {
+				t.Errorf("expected %q, got %v", tt.expected, err)
+			}
+		})
+	}
+
+	_, err := NewParser(strings.NewReader("*2\r\n$3\r\nGET\r\n")).Next()
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("expected io.ErrUnexpectedEOF for a truncated command, got %v", err)
+	}
+}
+
+// a request is rejected from its header, without waiting for nor buffering the announced data
+func TestParserRejectsBeforeReading(t *testing.T) {
+	input := io.MultiReader(strings.NewReader("*1\r\n$1000000000\r\n"), iotest.ErrReader(errors.New("read past the header")))
+	p := NewParser(input)
+	p.MaxBulkLen = 1024
+	if _, err := p.Next(); err == nil || err.Error() != "Protocol error: invalid bulk length" {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(p.buf) != defaultBufferSize {
+		t.Errorf("the buffer grew to %d bytes", len(p.buf))
+	}
+}
+
+func TestParserLargeArgument(t *testing.T) {
+	value := bytes.Repeat([]byte("0123456789"), 10*defaultBufferSize)
+	input := append(encodeCommand([][]byte{[]byte("SET"), []byte("key"), value}), "PING\r\n"...)
+	p := NewParser(bytes.NewReader(input))
+	args, err := p.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(args) != 3 || !bytes.Equal(args[2], value) {
+		t.Fatalf("unexpected arguments of length %d", len(args))
+	}
+	cloned := CloneArgs(args)
+	if args, err = p.Next(); err != nil || len(args) != 1 || string(args[0]) != "PING" {
+		t.Fatalf("unexpected command %q, %v", args, err)
+	}
+	if !bytes.Equal(cloned[2], value) {
+		t.Errorf("cloned arguments changed after Next")
+	}
+	if _, err = p.Next(); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+	if len(p.buf) != defaultBufferSize {
+		t.Errorf("the buffer of %d bytes was kept after the large command", len(p.buf))
+	}
+}
+
+func FuzzParser(f *testing.F) {
+	f.Add([]byte("*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nvalue\r\n"))
+	f.Add([]byte("PING\r\nSET \"a\\x41\" 'b'\r\n"))
+	f.Add([]byte("*2\r\n$-1\r\n*1\r\n$100\r\n"))
+	f.Add([]byte("*1\r\n$3\r\nGET\r\n*99999999999999999999\r\n"))
+	f.Fuzz(func(t *testing.T, input []byte) {
+		p := NewParser(iotest.HalfReader(bytes.NewReader(input)))
+		p.MaxBulkLen, p.MaxArrayLen, p.MaxInlineLen = 64, 16, 128
+		for {
+			args, err := p.Next()
+			if err != nil {
+				var protocolErr *ProtocolError
+				if err != io.EOF && err != io.ErrUnexpectedEOF && !errors.As(err, &protocolErr) {
+					t.Fatalf("unexpected error %v", err)
+				}
+				return
+			}
+			if len(args) == 0 || int64(len(args)) > p.MaxArrayLen {
+				t.Fatalf("unexpected number of arguments %d", len(args))
+			}
+			for _, arg := range args {
+				if int64(len(arg)) > p.MaxBulkLen && len(arg) > p.MaxInlineLen {
+					t.Fatalf("argument of %d bytes over the limits", len(arg))
+				}
+			}
+		}
+	})
+}
+
+func FuzzParserRoundTrip(f *testing.F) {
+	f.Add([]byte("SET"), []byte("key"), []byte("value"))
+	f.Add([]byte(""), []byte("\r\n"), []byte("*1\r\n$3\r\n"))
+	f.Fuzz(func(t *testing.T, a []byte, b []byte, c []byte) {
+		command := [][]byte{a, b, c}
+		input := encodeCommand(command)
+		p := NewParser(iotest.OneByteReader(bytes.NewReader(append(input, input...))))
+		for range 2 {
+			args, err := p.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(args) != len(command) {
+				t.Fatalf("expected %d arguments, got %d", len(command), len(args))
+			}
+			for i := range args {
+				if !bytes.Equal(args[i], command[i]) {
+					t.Fatalf("argument %d: expected %q, got %q", i, command[i], args[i])
+				}
+			}
+		}
+		if _, err := p.Next(); err != io.EOF {
+			t.Fatalf("expected io.EOF, got %v", err)
+		}
+	})
+}
+
+func BenchmarkParser(b *testing.B) {
+	command := encodeCommand([][]byte{[]byte("SET"), []byte("key:000000"), []byte("value")})
+	input := bytes.Repeat(command, 1000)
+	reader := bytes.NewReader(input)
+	p := NewParser(reader)
+	b.SetBytes(int64(len(command)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if reader.Len() == 0 && p.Buffered() == 0 {
+			reader.Reset(input)
+		}
+		if _, err := p.Next(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
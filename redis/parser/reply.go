@@ -0,0 +1,203 @@
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+)
+
+// ReplyError is an error reply of the server
+// it's returned as a value, so that the replies following it in a pipeline can still be read
+type ReplyError string
+
+func (e ReplyError) Error() string {
+	return string(e)
+}
+
+// Push is an out-of-band RESP3 reply, like a pub/sub message or a key invalidation
+type Push []any
+
+// SimpleString is a simple string reply like OK, returned instead of a string when SimpleStrings is set
+type SimpleString string
+
+// ReplyReader decodes RESP2 and RESP3 replies into Go values:
+// simple, bulk and verbatim strings are string, integers int64, doubles float64, booleans bool,
+// big numbers *big.Int, errors ReplyError, nulls nil, arrays and sets []any, maps map[string]any
+// and pushes Push
+// attributes are skipped, the reply they describe is returned
+type ReplyReader struct {
+	reader *bufio.Reader
+
+	MaxBulkLen  int64
+	MaxArrayLen int64
+	// SimpleStrings tells simple strings apart from bulk strings, to display replies like redis-cli
+	SimpleStrings bool
+}
+
+func NewReplyReader(reader io.Reader) *ReplyReader {
+	buffered, ok := reader.(*bufio.Reader)
+	if !ok {
+		buffered = bufio.NewReader(reader)
+	}
+	return &ReplyReader{
+		reader:      buffered,
+		MaxBulkLen:  DefaultMaxBulkLen,
+		MaxArrayLen: DefaultMaxArrayLen,
+	}
+}
+
+// Buffered returns the number of bytes received and not decoded yet
+func (r *ReplyReader) Buffered() int {
+	return r.reader.Buffered()
+}
+
+// Next reads the next reply
+func (r *ReplyReader) Next() (any, error) {
+	line, err := r.line()
+	if err != nil {
+		return nil, err
+	}
+	switch line[0] {
+	case '+':
+		if r.SimpleStrings {
+			return SimpleString(line[1:]), nil
+		}
+		return string(line[1:]), nil
+	case '-':
+		return ReplyError(line[1:]), nil
+	case ':':
+		n, err := strconv.ParseInt(string(line[1:]), 10, 64)
+		if err != nil {
+			return nil, protocolErr("invalid integer " + strconv.Quote(string(line[1:])))
+		}
+		return n, nil
+	case '_':
+		return nil, nil
+	case ',':
+		f, err := strconv.ParseFloat(string(line[1:]), 64)
+		if err != nil {
+			return nil, protocolErr("invalid double " + strconv.Quote(string(line[1:])))
+		}
+		return f, nil
+	case '#':
+		switch string(line[1:]) {
+		case "t":
+			return true, nil
+		case "f":
+			return false, nil
+		}
+		return nil, protocolErr("invalid boolean " + strconv.Quote(string(line[1:])))
+	case '(':
+		n, ok := new(big.Int).SetString(string(line[1:]), 10)
+		if !ok {
+			return nil, protocolErr("invalid big number " + strconv.Quote(string(line[1:])))
+		}
+		return n, nil
+	case '$', '!', '=':
+		n, ok := parseLength(line[1:])
+		if !ok || n > r.MaxBulkLen {
+			return nil, protocolErr("invalid bulk length")
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		body := make([]byte, n+2)
+		if _, err := io.ReadFull(r.reader, body); err != nil {
+			return nil, err
+		}
+		if body[n] != '\r' || body[n+1] != '\n' {
+			return nil, protocolErr("invalid bulk length")
+		}
+		switch line[0] {
+		case '!':
+			return ReplyError(body[:n]), nil
+		case '=':
+			// the encoding, like txt:, is dropped
+			if n < 4 {
+				return nil, protocolErr("invalid verbatim string")
+			}
+			return string(body[4:n]), nil
+		}
+		return string(body[:n]), nil
+	case '*', '~', '>':
+		n, err := r.length(line)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		elements, err := r.elements(n)
+		if err != nil {
+			return nil, err
+		}
+		if line[0] == '>' {
+			return Push(elements), nil
+		}
+		return elements, nil
+	case '%', '|':
+		n, err := r.length(line)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		elements, err := r.elements(2 * n)
+		if err != nil {
+			return nil, err
+		}
+		pairs := make(map[string]any, n)
+		for i := 0; i < len(elements); i += 2 {
+			key, ok := elements[i].(string)
+			if !ok {
+				key = fmt.Sprint(elements[i])
+			}
+			pairs[key] = elements[i+1]
+		}
+		if line[0] == '|' {
+			return r.Next()
+		}
+		return pairs, nil
+	}
+	return nil, protocolErr("unexpected reply type " + strconv.Quote(string(line[:1])))
+}
+
+// line reads a line, "\r\n" excluded
+func (r *ReplyReader) line() ([]byte, error) {
+	line, err := r.reader.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return nil, protocolErr("too long reply line")
+	}
+	if err != nil {
+		if err == io.EOF && len(line) > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, protocolErr("invalid reply line " + strconv.Quote(string(line)))
+	}
+	return line[:len(line)-2], nil
+}
+
+// length parses the number of elements of an aggregate, -1 for a null array
+func (r *ReplyReader) length(line []byte) (int, error) {
+	n, ok := parseLength(line[1:])
+	if !ok || n > r.MaxArrayLen || n < -1 {
+		return 0, protocolErr("invalid multibulk length")
+	}
+	return int(n), nil
+}
+
+func (r *ReplyReader) elements(n int) ([]any, error) {
+	// the length isn't trusted for the allocation, elements are appended as they're received
+	elements := make([]any, 0, min(n, 1024))
+	for i := 0; i < n; i++ {
+		element, err := r.Next()
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, element)
+	}
+	return elements, nil
+}
@@ -0,0 +1,102 @@
+package parser
+
+import (
+	"errors"
+	"io"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReplyReader(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected any
+	}{
+		{"simple string", "+OK\r\n", "OK"},
+		{"error", "-ERR unknown command\r\n", ReplyError("ERR unknown command")},
+		{"integer", ":-42\r\n", int64(-42)},
+		{"bulk string", "$5\r\na\r\nbc\r\n", "a\r\nbc"},
+		{"null bulk string", "$-1\r\n", nil},
+		{"null array", "*-1\r\n", nil},
+		{"nested array", "*3\r\n:1\r\n*1\r\n+a\r\n$-1\r\n", []any{int64(1), []any{"a"}, nil}},
+		{"null", "_\r\n", nil},
+		{"double", ",1.5\r\n", 1.5},
+		{"infinity", ",-inf\r\n", math.Inf(-1)},
+		{"boolean", "#t\r\n", true},
+		{"big number", "(3492890328409238509324850943850943825024385\r\n", bigNumber("3492890328409238509324850943850943825024385")},
+		{"bulk error", "!10\r\nERR failed\r\n", ReplyError("ERR failed")},
+		{"verbatim string", "=8\r\ntxt:text\r\n", "text"},
+		{"set", "~2\r\n+a\r\n+b\r\n", []any{"a", "b"}},
+		{"map", "%2\r\n+key\r\n:1\r\n:2\r\n*0\r\n", map[string]any{"key": int64(1), "2": []any{}}},
+		{"push", ">2\r\n+invalidate\r\n*1\r\n+key\r\n", Push{"invalidate", []any{"key"}}},
+		{"attribute", "|1\r\n+ttl\r\n:10\r\n+value\r\n", "value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reply, err := NewReplyReader(strings.NewReader(tt.input)).Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(reply, tt.expected) {
+				t.Errorf("expected %#v, got %#v", tt.expected, reply)
+			}
+		})
+	}
+}
+
+func TestReplyReaderSimpleStrings(t *testing.T) {
+	r := NewReplyReader(strings.NewReader("+OK\r\n$2\r\nOK\r\n"))
+	r.SimpleStrings = true
+	for _, expected := range []any{SimpleString("OK"), "OK"} {
+		if reply, err := r.Next(); err != nil || reply != expected {
+			t.Errorf("expected %#v, got %#v, %v", expected, reply, err)
+		}
+	}
+}
+
+func bigNumber(s string) *big.Int {
+	n, _ := new(big.Int).SetString(s, 10)
+	return n
+}
+
+func TestReplyReaderErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"unknown type", "?1\r\n"},
+		{"invalid integer", ":one\r\n"},
+		{"missing CR", "+OK\n"},
+		{"bulk longer than its length", "$1\r\nab\r\n"},
+		{"bulk too long", "$2000\r\n"},
+		{"array too long", "*2000\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewReplyReader(strings.NewReader(tt.input))
+			r.MaxBulkLen, r.MaxArrayLen = 1024, 1024
+			_, err := r.Next()
+			var protocolErr *ProtocolError
+			if !errors.As(err, &protocolErr) {
+				t.Errorf("expected a protocol error, got %v", err)
+			}
+		})
+	}
+
+	_, err := NewReplyReader(strings.NewReader("")).Next()
+	if err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+	_, err = NewReplyReader(strings.NewReader("*2\r\n:1\r\n")).Next()
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+	_, err = NewReplyReader(strings.NewReader("$5\r\nab")).Next()
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+}
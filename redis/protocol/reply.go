@@ -13,6 +13,7 @@ var (
 	theOkReply          = new(OkReply)
 	nullBulkBytes       = []byte("$-1\r\n")
 	emptyMultiBulkBytes = []byte("*0\r\n")
+	nullMultiBulkBytes  = []byte("*-1\r\n")
 )
 
 type Reply interface {
@@ -49,6 +50,10 @@ func (r *NullBulkReply) ToBytes() []byte {
 	return nullBulkBytes
 }
 
+func (r *NullBulkReply) ToResp3Bytes() []byte {
+	return nullBytes
+}
+
 // MakeNullBulkReply creates a new NullBulkReply
 func MakeNullBulkReply() *NullBulkReply {
 	return &NullBulkReply{}
@@ -68,6 +73,40 @@ func MakeEmptyMultiBulkReply() *EmptyMultiBulkReply {
 	return &EmptyMultiBulkReply{}
 }
 
+/* ---- Null Multi Bulk Reply ---- */
+// NullMultiBulkReply is a nil list, e.g. a blocking pop timed out
+type NullMultiBulkReply struct{}
+
+// ToBytes marshal redis.Reply
+func (r *NullMultiBulkReply) ToBytes() []byte {
+	return nullMultiBulkBytes
+}
+
+func (r *NullMultiBulkReply) ToResp3Bytes() []byte {
+	return nullBytes
+}
+
+// MakeNullMultiBulkReply creates NullMultiBulkReply
+func MakeNullMultiBulkReply() *NullMultiBulkReply {
+	return &NullMultiBulkReply{}
+}
+
+/* ---- No Reply ---- */
+// NoReply writes nothing, it is returned by commands which already wrote their replies to the connection
+type NoReply struct{}
+
+var noBytes = []byte("")
+
+// ToBytes marshal redis.Reply
+func (r *NoReply) ToBytes() []byte {
+	return noBytes
+}
+
+// MakeNoReply creates NoReply
+func MakeNoReply() *NoReply {
+	return &NoReply{}
+}
+
 /* -- Status Reply  -- */
 // StatusReply stores a simple status string
 type StatusReply struct {
@@ -113,6 +152,13 @@ func (r *BulkReply) ToBytes() []byte {
 	return []byte("$" + strconv.Itoa(len(r.Arg)) + CRLF + string(r.Arg) + CRLF)
 }
 
+func (r *BulkReply) ToResp3Bytes() []byte {
+	if r.Arg == nil {
+		return nullBytes
+	}
+	return r.ToBytes()
+}
+
 /* ---- Error Reply ---- */
 
 // ErrorReply is an error and redis.Reply
@@ -182,12 +228,15 @@ func MakeMultiRawReply(replies []Reply) *MultiRawReply {
 
 // ToBytes marshal redis.Reply
 func (r *MultiRawReply) ToBytes() []byte {
-	argLen := len(r.Replies)
 	var buf bytes.Buffer
-	buf.WriteString("*" + strconv.Itoa(argLen) + CRLF)
-	for _, arg := range r.Replies {
-		buf.Write(arg.ToBytes())
-	}
+	writeAggregate(&buf, '*', len(r.Replies), r.Replies, RESP2)
+	return buf.Bytes()
+}
+
+// ToResp3Bytes encodes the elements for RESP3 as well
+func (r *MultiRawReply) ToResp3Bytes() []byte {
+	var buf bytes.Buffer
+	writeAggregate(&buf, '*', len(r.Replies), r.Replies, RESP3)
 	return buf.Bytes()
 }
 
@@ -207,13 +256,23 @@ func MakeMultiBulkReply(args [][]byte) *MultiBulkReply {
 
 // ToBytes marshal redis.Reply
 func (r *MultiBulkReply) ToBytes() []byte {
+	return r.encode("$-1")
+}
+
+// ToResp3Bytes encodes nil elements as RESP3 nulls
+func (r *MultiBulkReply) ToResp3Bytes() []byte {
+	return r.encode("_")
+}
+
+// encode writes the list, with null in place of nil elements
+func (r *MultiBulkReply) encode(null string) []byte {
 	var buf bytes.Buffer
 	//Calculate the length of buffer
 	argLen := len(r.Args)
 	bufLen := 1 + len(strconv.Itoa(argLen)) + 2
 	for _, arg := range r.Args {
 		if arg == nil {
-			bufLen += 3 + 2
+			bufLen += len(null) + 2
 		} else {
 			bufLen += 1 + len(strconv.Itoa(len(arg))) + 2 + len(arg) + 2
 		}
@@ -226,7 +285,7 @@ func (r *MultiBulkReply) ToBytes() []byte {
 	buf.WriteString(CRLF)
 	for _, arg := range r.Args {
 		if arg == nil {
-			buf.WriteString("$-1")
+			buf.WriteString(null)
 			buf.WriteString(CRLF)
 		} else {
 			buf.WriteString("$")
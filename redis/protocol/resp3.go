@@ -0,0 +1,298 @@
+package protocol
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// protocol versions negotiated with HELLO
+const (
+	RESP2 = 2
+	RESP3 = 3
+)
+
+var nullBytes = []byte("_\r\n")
+
+// Resp3Reply is a reply which is encoded differently for RESP3 clients
+// its ToBytes() gives the RESP2 encoding
+type Resp3Reply interface {
+	Reply
+	ToResp3Bytes() []byte
+}
+
+// Encode marshals reply for a client speaking the given protocol version
+func Encode(reply Reply, version int) []byte {
+	if version == RESP3 {
+		if r, ok := reply.(Resp3Reply); ok {
+			return r.ToResp3Bytes()
+		}
+	}
+	return reply.ToBytes()
+}
+
+// writeAggregate writes the header of an aggregate type followed by its elements
+func writeAggregate(buf *bytes.Buffer, prefix byte, n int, replies []Reply, version int) {
+	buf.WriteByte(prefix)
+	buf.WriteString(strconv.Itoa(n))
+	buf.WriteString(CRLF)
+	for _, reply := range replies {
+		buf.Write(Encode(reply, version))
+	}
+}
+
+/* ---- Null Reply ---- */
+
+// NullReply is the null of RESP3, it's a null bulk string for RESP2 clients
+type NullReply struct{}
+
+// ToBytes marshal redis.Reply
+func (r *NullReply) ToBytes() []byte {
+	return nullBulkBytes
+}
+
+func (r *NullReply) ToResp3Bytes() []byte {
+	return nullBytes
+}
+
+// MakeNullReply creates NullReply
+func MakeNullReply() *NullReply {
+	return &NullReply{}
+}
+
+/* ---- Double Reply ---- */
+
+// DoubleReply stores a floating point number, RESP2 clients receive it as a bulk string
+type DoubleReply struct {
+	Value float64
+}
+
+// MakeDoubleReply creates DoubleReply
+func MakeDoubleReply(value float64) *DoubleReply {
+	return &DoubleReply{
+		Value: value,
+	}
+}
+
+func formatDouble(value float64, prec int) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "inf"
+	case math.IsInf(value, -1):
+		return "-inf"
+	case math.IsNaN(value):
+		return "nan"
+	}
+	return strconv.FormatFloat(value, 'g', prec, 64)
+}
+
+// ToBytes marshal redis.Reply
+func (r *DoubleReply) ToBytes() []byte {
+	return MakeBulkReply([]byte(formatDouble(r.Value, 10))).ToBytes()
+}
+
+func (r *DoubleReply) ToResp3Bytes() []byte {
+	return []byte("," + formatDouble(r.Value, -1) + CRLF)
+}
+
+/* ---- Boolean Reply ---- */
+
+// BooleanReply is true or false, RESP2 clients receive 1 or 0
+type BooleanReply struct {
+	Value bool
+}
+
+// MakeBooleanReply creates BooleanReply
+func MakeBooleanReply(value bool) *BooleanReply {
+	return &BooleanReply{
+		Value: value,
+	}
+}
+
+// ToBytes marshal redis.Reply
+func (r *BooleanReply) ToBytes() []byte {
+	if r.Value {
+		return []byte(":1\r\n")
+	}
+	return []byte(":0\r\n")
+}
+
+func (r *BooleanReply) ToResp3Bytes() []byte {
+	if r.Value {
+		return []byte("#t\r\n")
+	}
+	return []byte("#f\r\n")
+}
+
+/* ---- Big Number Reply ---- */
+
+// BigNumberReply stores an integer out of the range of int64, RESP2 clients receive it as a bulk string
+type BigNumberReply struct {
+	Value *big.Int
+}
+
+// MakeBigNumberReply creates BigNumberReply
+func MakeBigNumberReply(value *big.Int) *BigNumberReply {
+	return &BigNumberReply{
+		Value: value,
+	}
+}
+
+// ToBytes marshal redis.Reply
+func (r *BigNumberReply) ToBytes() []byte {
+	return MakeBulkReply([]byte(r.Value.String())).ToBytes()
+}
+
+func (r *BigNumberReply) ToResp3Bytes() []byte {
+	return []byte("(" + r.Value.String() + CRLF)
+}
+
+/* ---- Verbatim String Reply ---- */
+
+// VerbatimReply is a string with a three letters format, like "txt" or "mkd"
+// RESP2 clients receive the text as a bulk string
+type VerbatimReply struct {
+	Format string
+	Text   []byte
+}
+
+// MakeVerbatimReply creates VerbatimReply
+func MakeVerbatimReply(format string, text []byte) *VerbatimReply {
+	return &VerbatimReply{
+		Format: format,
+		Text:   text,
+	}
+}
+
+// ToBytes marshal redis.Reply
+func (r *VerbatimReply) ToBytes() []byte {
+	return MakeBulkReply(r.Text).ToBytes()
+}
+
+func (r *VerbatimReply) ToResp3Bytes() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("=" + strconv.Itoa(len(r.Format)+1+len(r.Text)) + CRLF)
+	buf.WriteString(r.Format + ":")
+	buf.Write(r.Text)
+	buf.WriteString(CRLF)
+	return buf.Bytes()
+}
+
+/* ---- Map Reply ---- */
+
+// MapReply stores key-value pairs flattened as key, value, key, value...
+// RESP2 clients receive the flat list
+type MapReply struct {
+	Pairs []Reply
+}
+
+// MakeMapReply creates MapReply, pairs alternates keys and values
+func MakeMapReply(pairs []Reply) *MapReply {
+	return &MapReply{
+		Pairs: pairs,
+	}
+}
+
+// MakeBulkMapReply creates a MapReply whose keys and values are all strings
+func MakeBulkMapReply(pairs [][]byte) *MapReply {
+	replies := make([]Reply, len(pairs))
+	for i, arg := range pairs {
+		replies[i] = MakeBulkReply(arg)
+	}
+	return MakeMapReply(replies)
+}
+
+// ToBytes marshal redis.Reply
+func (r *MapReply) ToBytes() []byte {
+	var buf bytes.Buffer
+	writeAggregate(&buf, '*', len(r.Pairs), r.Pairs, RESP2)
+	return buf.Bytes()
+}
+
+func (r *MapReply) ToResp3Bytes() []byte {
+	var buf bytes.Buffer
+	writeAggregate(&buf, '%', len(r.Pairs)/2, r.Pairs, RESP3)
+	return buf.Bytes()
+}
+
+/* ---- Set Reply ---- */
+
+// SetReply stores unordered unique strings, RESP2 clients receive a list
+type SetReply struct {
+	Members [][]byte
+}
+
+// MakeSetReply creates SetReply
+func MakeSetReply(members [][]byte) *SetReply {
+	return &SetReply{
+		Members: members,
+	}
+}
+
+// ToBytes marshal redis.Reply
+func (r *SetReply) ToBytes() []byte {
+	return MakeMultiBulkReply(r.Members).ToBytes()
+}
+
+func (r *SetReply) ToResp3Bytes() []byte {
+	b := MakeMultiBulkReply(r.Members).ToResp3Bytes()
+	b[0] = '~'
+	return b
+}
+
+/* ---- Push Reply ---- */
+
+// PushReply is out of band data like pub/sub messages, RESP2 clients receive a list
+type PushReply struct {
+	Replies []Reply
+}
+
+// MakePushReply creates PushReply
+func MakePushReply(replies []Reply) *PushReply {
+	return &PushReply{
+		Replies: replies,
+	}
+}
+
+// ToBytes marshal redis.Reply
+func (r *PushReply) ToBytes() []byte {
+	var buf bytes.Buffer
+	writeAggregate(&buf, '*', len(r.Replies), r.Replies, RESP2)
+	return buf.Bytes()
+}
+
+func (r *PushReply) ToResp3Bytes() []byte {
+	var buf bytes.Buffer
+	writeAggregate(&buf, '>', len(r.Replies), r.Replies, RESP3)
+	return buf.Bytes()
+}
+
+/* ---- Attribute Reply ---- */
+
+// AttributeReply attaches auxiliary key-value pairs to a reply
+// RESP2 clients only receive the reply
+type AttributeReply struct {
+	Attributes *MapReply
+	Reply      Reply
+}
+
+// MakeAttributeReply creates AttributeReply
+func MakeAttributeReply(attributes *MapReply, reply Reply) *AttributeReply {
+	return &AttributeReply{
+		Attributes: attributes,
+		Reply:      reply,
+	}
+}
+
+// ToBytes marshal redis.Reply
+func (r *AttributeReply) ToBytes() []byte {
+	return r.Reply.ToBytes()
+}
+
+func (r *AttributeReply) ToResp3Bytes() []byte {
+	var buf bytes.Buffer
+	writeAggregate(&buf, '|', len(r.Attributes.Pairs)/2, r.Attributes.Pairs, RESP3)
+	buf.Write(Encode(r.Reply, RESP3))
+	return buf.Bytes()
+}
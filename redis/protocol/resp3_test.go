@@ -0,0 +1,103 @@
+package protocol
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	bigNumber, _ := new(big.Int).SetString("3492890328409238509324850943850943825024385", 10)
+	tests := []struct {
+		name  string
+		reply Reply
+		resp2 string
+		resp3 string
+	}{
+		{
+			name:  "null",
+			reply: MakeNullReply(),
+			resp2: "$-1\r\n",
+			resp3: "_\r\n",
+		},
+		{
+			name:  "null bulk",
+			reply: MakeNullBulkReply(),
+			resp2: "$-1\r\n",
+			resp3: "_\r\n",
+		},
+		{
+			name:  "double",
+			reply: MakeDoubleReply(1.5),
+			resp2: "$3\r\n1.5\r\n",
+			resp3: ",1.5\r\n",
+		},
+		{
+			name:  "infinite double",
+			reply: MakeDoubleReply(math.Inf(-1)),
+			resp2: "$4\r\n-inf\r\n",
+			resp3: ",-inf\r\n",
+		},
+		{
+			name:  "boolean",
+			reply: MakeBooleanReply(true),
+			resp2: ":1\r\n",
+			resp3: "#t\r\n",
+		},
+		{
+			name:  "big number",
+			reply: MakeBigNumberReply(bigNumber),
+			resp2: "$43\r\n3492890328409238509324850943850943825024385\r\n",
+			resp3: "(3492890328409238509324850943850943825024385\r\n",
+		},
+		{
+			name:  "verbatim",
+			reply: MakeVerbatimReply("txt", []byte("Some string")),
+			resp2: "$11\r\nSome string\r\n",
+			resp3: "=15\r\ntxt:Some string\r\n",
+		},
+		{
+			name:  "map",
+			reply: MakeBulkMapReply([][]byte{[]byte("first"), []byte("1")}),
+			resp2: "*2\r\n$5\r\nfirst\r\n$1\r\n1\r\n",
+			resp3: "%1\r\n$5\r\nfirst\r\n$1\r\n1\r\n",
+		},
+		{
+			name:  "set",
+			reply: MakeSetReply([][]byte{[]byte("a"), nil}),
+			resp2: "*2\r\n$1\r\na\r\n$-1\r\n",
+			resp3: "~2\r\n$1\r\na\r\n_\r\n",
+		},
+		{
+			name:  "push",
+			reply: MakePushReply([]Reply{MakeBulkReply([]byte("message")), MakeIntReply(1)}),
+			resp2: "*2\r\n$7\r\nmessage\r\n:1\r\n",
+			resp3: ">2\r\n$7\r\nmessage\r\n:1\r\n",
+		},
+		{
+			name: "attribute",
+			reply: MakeAttributeReply(
+				MakeMapReply([]Reply{MakeBulkReply([]byte("ttl")), MakeIntReply(3600)}),
+				MakeBulkReply([]byte("value")),
+			),
+			resp2: "$5\r\nvalue\r\n",
+			resp3: "|1\r\n$3\r\nttl\r\n:3600\r\n$5\r\nvalue\r\n",
+		},
+		{
+			name:  "nested in a raw list",
+			reply: MakeMultiRawReply([]Reply{MakeDoubleReply(2), MakeNullMultiBulkReply()}),
+			resp2: "*2\r\n$1\r\n2\r\n*-1\r\n",
+			resp3: "*2\r\n,2\r\n_\r\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(Encode(tt.reply, RESP2)); got != tt.resp2 {
+				t.Errorf("RESP2: expected %q, got %q", tt.resp2, got)
+			}
+			if got := string(Encode(tt.reply, RESP3)); got != tt.resp3 {
+				t.Errorf("RESP3: expected %q, got %q", tt.resp3, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+// Package stats counts what the server does, reported by INFO and reset by CONFIG RESETSTAT
+package stats
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"math/bits"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LatencyBuckets is the number of buckets of Command.Latency: bucket i counts the calls of at most 2^i microseconds,
+// the last one also counts the slower calls
+const LatencyBuckets = 34
+
+// Command counts the calls of a command
+type Command struct {
+	Calls atomic.Int64
+	// microseconds spent running the command
+	Usec atomic.Int64
+	// calls refused before running, like without AUTH
+	RejectedCalls atomic.Int64
+	// calls replying an error
+	FailedCalls atomic.Int64
+	// calls by duration
+	Latency [LatencyBuckets]atomic.Int64
+}
+
+// latencyBucket returns the bucket of Command.Latency counting calls of usec microseconds
+func latencyBucket(usec int64) int {
+	if usec <= 1 {
+		return 0
+	}
+	return min(bits.Len64(uint64(usec-1)), LatencyBuckets-1)
+}
+
+// Stats are the counters of a server, updated atomically
+type Stats struct {
+	StartTime time.Time
+	// random identifier of the server process, changed on every start
+	RunID string
+
+	// gauge of the clients connected, not reset
+	ConnectedClients atomic.Int64
+
+	ConnectionsReceived atomic.Int64
+	RejectedConnections atomic.Int64
+	CommandsProcessed   atomic.Int64
+	NetInputBytes       atomic.Int64
+	NetOutputBytes      atomic.Int64
+	// lookups of keys by read-only commands
+	KeyspaceHits   atomic.Int64
+	KeyspaceMisses atomic.Int64
+	// nothing expires nor is evicted yet, they're reported for the tools expecting them
+	ExpiredKeys  atomic.Int64
+	EvictedKeys  atomic.Int64
+	ErrorReplies atomic.Int64
+
+	// *Command by name
+	commands sync.Map
+}
+
+func New() *Stats {
+	id := make([]byte, 20)
+	_, _ = rand.Read(id)
+	return &Stats{
+		StartTime: time.Now(),
+		RunID:     hex.EncodeToString(id),
+	}
+}
+
+// Command returns the counters of the command named name
+func (s *Stats) Command(name string) *Command {
+	if c, ok := s.commands.Load(name); ok {
+		return c.(*Command)
+	}
+	c, _ := s.commands.LoadOrStore(name, &Command{})
+	return c.(*Command)
+}
+
+// LoadCommand returns the counters of the command named name, if it was called since the last reset
+func (s *Stats) LoadCommand(name string) (*Command, bool) {
+	c, ok := s.commands.Load(name)
+	if !ok {
+		return nil, false
+	}
+	return c.(*Command), true
+}
+
+// Record counts a call of a command that ran for elapsed
+func (s *Stats) Record(name string, elapsed time.Duration, failed bool) {
+	s.CommandsProcessed.Add(1)
+	c := s.Command(name)
+	c.Calls.Add(1)
+	usec := elapsed.Microseconds()
+	c.Usec.Add(usec)
+	c.Latency[latencyBucket(usec)].Add(1)
+	if failed {
+		c.FailedCalls.Add(1)
+	}
+}
+
+// CommandNames returns the names of the commands called since the last reset, sorted
+func (s *Stats) CommandNames() []string {
+	var names []string
+	s.commands.Range(func(name, _ any) bool {
+		names = append(names, name.(string))
+		return true
+	})
+	sort.Strings(names)
+	return names
+}
+
+// Reset sets the counters back to zero, the gauges are kept
+func (s *Stats) Reset() {
+	s.ConnectionsReceived.Store(0)
+	s.RejectedConnections.Store(0)
+	s.CommandsProcessed.Store(0)
+	s.NetInputBytes.Store(0)
+	s.NetOutputBytes.Store(0)
+	s.KeyspaceHits.Store(0)
+	s.KeyspaceMisses.Store(0)
+	s.ExpiredKeys.Store(0)
+	s.EvictedKeys.Store(0)
+	s.ErrorReplies.Store(0)
+	s.commands.Clear()
+}
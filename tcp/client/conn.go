@@ -2,6 +2,7 @@ package client
 
 import (
 	gsync "godis/lib/sync"
+	"godis/redis/protocol"
 	"log"
 	"net"
 	"sync"
@@ -9,65 +10,314 @@ import (
 	"time"
 )
 
+// pushQueueSize is the number of messages a subscriber may lag behind
+// before it's disconnected
+const pushQueueSize = 1024
+
+// defaultCloseTimeout is the default of client-close-timeout
+const defaultCloseTimeout = 10 * time.Second
+
+// outBufferSize is the size from which buffered replies are written without waiting for Flush
+const outBufferSize = 16 * 1024
+
 type Connection struct {
 	conn net.Conn
 	// wait until finishing sending data for graceful shutdown
 	sending gsync.Wait
-	// lock while server sending response
+	// lock while server sending response, guards out
 	mu   sync.Mutex
 	flag uint
+	// replies waiting for Flush
+	out []byte
 	// atomic flag for connection state
 	closed atomic.Bool
-}
 
-// connPool is initialized when the package is loaded
-// which is a package-level variable
-// before main function starts
-var connPool = sync.Pool{
-	// New() will be called when:
-	// the pool is empty or,
-	// Get() is called
-	New: func() any {
-		return &Connection{}
-	},
+	// unique id of the client, assigned when the connection is accepted
+	id int64
+	// RESP version negotiated with HELLO, read by publishers too
+	protocol atomic.Int32
+	// set with HELLO SETNAME
+	name atomic.Value
+	// set by AUTH, or when the connection is accepted if no password is required
+	authenticated atomic.Bool
+	// how long Close waits for the replies being sent
+	closeTimeout time.Duration
+
+	// when the connection was accepted
+	createdAt time.Time
+	// name of the last command, and when it was received in unix nanoseconds
+	lastCommand     atomic.Value
+	lastInteraction atomic.Int64
+	// bytes of the query buffer and of the replies buffered, reported by CLIENT LIST
+	queryBufferLen atomic.Int64
+	outLen         atomic.Int64
+	// set with CLIENT NO-EVICT
+	noEvict atomic.Bool
+	// set by MONITOR
+	monitoring atomic.Bool
+	// set by the handler while a command runs, blocked commands included
+	busy atomic.Bool
+	// set with CLIENT REPLY, only used by the goroutine serving the connection
+	replyOff    bool
+	skipNext    bool
+	skipCurrent bool
+	// set when the client kills itself with CLIENT KILL or QUITs, read by writeLoop too
+	closeAfterReply atomic.Bool
+
+	// channels, patterns and shard channels subscribed to
+	subs          sync.Mutex
+	channels      map[string]struct{}
+	patterns      map[string]struct{}
+	shardChannels map[string]struct{}
+
+	// once something is pushed to the connection, every write goes through pushCh
+	// and is written by writeLoop, so replies and messages keep their order
+	// and publishers never wait for a slow subscriber
+	pushing  atomic.Bool
+	pushOnce sync.Once
+	pushCh   chan []byte
+	quit     chan struct{}
+	// closed when writeLoop exits
+	pushDone chan struct{}
 }
 
+// nextID is the id of the next accepted connection
+var nextID atomic.Int64
+
 func (c *Connection) RemoteAddr() string {
 	return c.conn.RemoteAddr().String()
 }
 
+func (c *Connection) LocalAddr() string {
+	return c.conn.LocalAddr().String()
+}
+
 func (c *Connection) Close() error {
-	c.closed.Store(true)
+	if c.closed.Swap(true) {
+		// already closed, by the handler shutting down for example
+		return nil
+	}
+	// a write to a peer which stopped reading, like a slow subscriber, would block Close forever,
+	// the deadline lets the writes in progress finish within closeTimeout at most
+	_ = c.conn.SetWriteDeadline(time.Now().Add(c.closeTimeout))
+	// a Push in progress starts writeLoop before this goes on, and none is started afterwards
+	c.pushOnce.Do(func() {})
+	if c.pushing.Load() {
+		close(c.quit)
+		<-c.pushDone
+	}
 	// wait a few seconds for finishing sending data
-	if c.sending.WaitWithTimeout(10 * time.Second) {
+	if c.sending.WaitWithTimeout(c.closeTimeout) {
 		log.Printf("closing connection timed out")
 	}
 	c.conn.Close()
-	// put the connection instance back to pool
-	connPool.Put(c)
 	return nil
 }
 
 func NewConn(conn net.Conn) *Connection {
-	// get an instant connection from pool
-	c, ok := connPool.Get().(*Connection)
-	if !ok {
-		log.Printf("connPool gives a wrong type")
-		return &Connection{
-			conn: conn,
-		}
+	c := &Connection{
+		conn:         conn,
+		id:           nextID.Add(1),
+		closeTimeout: defaultCloseTimeout,
+		createdAt:    time.Now(),
 	}
-	c.conn = conn
+	c.protocol.Store(protocol.RESP2)
+	c.name.Store("")
+	c.lastCommand.Store("NULL")
+	c.lastInteraction.Store(c.createdAt.UnixNano())
 	return c
 }
 
+func (c *Connection) ID() int64 {
+	return c.id
+}
+
+// ProtocolVersion returns the RESP version replies are encoded with
+func (c *Connection) ProtocolVersion() int {
+	return int(c.protocol.Load())
+}
+
+func (c *Connection) SetProtocolVersion(version int) {
+	c.protocol.Store(int32(version))
+}
+
+func (c *Connection) Name() string {
+	return c.name.Load().(string)
+}
+
+func (c *Connection) SetName(name string) {
+	c.name.Store(name)
+}
+
+// SetCloseTimeout sets how long Close waits for the replies being sent, client-close-timeout
+func (c *Connection) SetCloseTimeout(timeout time.Duration) {
+	c.closeTimeout = timeout
+}
+
+func (c *Connection) CreatedAt() time.Time {
+	return c.createdAt
+}
+
+// LastCommand returns the name of the last command run, like client|list, and when it was received
+func (c *Connection) LastCommand() (string, time.Time) {
+	return c.lastCommand.Load().(string), time.Unix(0, c.lastInteraction.Load())
+}
+
+func (c *Connection) SetLastCommand(name string) {
+	c.lastCommand.Store(name)
+	c.lastInteraction.Store(time.Now().UnixNano())
+}
+
+// SetQueryBufferLen records the bytes received and not parsed yet, set by the handler before running a command
+func (c *Connection) SetQueryBufferLen(n int) {
+	c.queryBufferLen.Store(int64(n))
+}
+
+// Buffers returns the bytes of the query buffer, of the replies buffered, and the number of writes queued
+func (c *Connection) Buffers() (query int, output int, queued int) {
+	if c.pushing.Load() {
+		queued = len(c.pushCh)
+	}
+	return int(c.queryBufferLen.Load()), int(c.outLen.Load()), queued
+}
+
+func (c *Connection) NoEvict() bool {
+	return c.noEvict.Load()
+}
+
+func (c *Connection) SetNoEvict(noEvict bool) {
+	c.noEvict.Store(noEvict)
+}
+
+func (c *Connection) Monitoring() bool {
+	return c.monitoring.Load()
+}
+
+func (c *Connection) SetMonitoring(monitoring bool) {
+	c.monitoring.Store(monitoring)
+}
+
+// Busy reports whether a command of the client is running, such a client isn't idle
+func (c *Connection) Busy() bool {
+	return c.busy.Load()
+}
+
+func (c *Connection) SetBusy(busy bool) {
+	c.busy.Store(busy)
+}
+
+// SetReplyMode sets whether the replies are sent, like CLIENT REPLY:
+// "on", "off", or "skip" to drop the reply of the next command
+// the reply of the command setting off or skip is dropped too
+func (c *Connection) SetReplyMode(mode string) {
+	switch mode {
+	case "on":
+		c.replyOff, c.skipNext = false, false
+	case "off":
+		c.replyOff = true
+	case "skip":
+		c.skipNext = true
+	}
+}
+
+// CloseAfterReply asks the handler to close the connection once the reply of the current command is written
+func (c *Connection) CloseAfterReply() {
+	c.closeAfterReply.Store(true)
+}
+
+// ClosingAfterReply reports whether CloseAfterReply was called
+func (c *Connection) ClosingAfterReply() bool {
+	return c.closeAfterReply.Load()
+}
+
+// ShouldReply reports whether the reply of the command that just ran is sent, called once per command
+func (c *Connection) ShouldReply() bool {
+	if c.skipCurrent {
+		c.skipCurrent = false
+		return false
+	}
+	if c.skipNext {
+		// the reply of CLIENT REPLY SKIP itself, then the one of the next command
+		c.skipNext, c.skipCurrent = false, true
+		return false
+	}
+	return !c.replyOff
+}
+
+func (c *Connection) Authenticated() bool {
+	return c.authenticated.Load()
+}
+
+func (c *Connection) SetAuthenticated(authenticated bool) {
+	c.authenticated.Store(authenticated)
+}
+
+// Write writes b right away, after the buffered replies
 func (c *Connection) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.flush(); err != nil {
+		return 0, err
+	}
+	return c.write(b, c.pushing.Load())
+}
+
+// Buffer appends b to the replies written by the next Flush,
+// so that the replies to pipelined commands are written with a single syscall
+func (c *Connection) Buffer(b []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.out = append(c.out, b...)
+	c.outLen.Store(int64(len(c.out)))
+	if len(c.out) < outBufferSize {
+		return nil
+	}
+	return c.flush()
+}
+
+// Flush writes the buffered replies
+func (c *Connection) Flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.flush()
+}
+
+func (c *Connection) flush() error {
+	if len(c.out) == 0 {
+		return nil
+	}
+	out := c.out
+	// read once, a Push may start concurrently: the buffer must be handed to writeLoop
+	// only if it isn't reused
+	pushing := c.pushing.Load()
+	if pushing {
+		// the buffer now belongs to writeLoop
+		c.out = nil
+	} else {
+		// written synchronously, the buffer can be reused right after
+		c.out = c.out[:0]
+	}
+	c.outLen.Store(0)
+	_, err := c.write(out, pushing)
+	return err
+}
+
+// write writes b through writeLoop if pushing, or synchronously
+func (c *Connection) write(b []byte, pushing bool) (int, error) {
 	if c.closed.Load() {
 		return 0, net.ErrClosed
 	}
 	if len(b) == 0 {
 		return 0, nil
 	}
+	if pushing {
+		select {
+		case c.pushCh <- b:
+			return len(b), nil
+		case <-c.pushDone:
+			return 0, net.ErrClosed
+		}
+	}
 	c.sending.Add(1)
 	defer func() {
 		// clean up
@@ -76,3 +326,179 @@ func (c *Connection) Write(b []byte) (int, error) {
 
 	return c.conn.Write(b)
 }
+
+// Push queues b to be written asynchronously without blocking the caller
+// a client that doesn't read fast enough to keep its queue from filling up is disconnected,
+// and false is returned
+func (c *Connection) Push(b []byte) bool {
+	if c.closed.Load() {
+		return false
+	}
+	c.pushOnce.Do(func() {
+		c.pushCh = make(chan []byte, pushQueueSize)
+		c.quit = make(chan struct{})
+		c.pushDone = make(chan struct{})
+		go c.writeLoop()
+		c.pushing.Store(true)
+	})
+	if !c.pushing.Load() {
+		// closed before anything was pushed
+		return false
+	}
+	// the replies buffered before b are sent first, so a client reading a key gets the value
+	// before its invalidation for example
+	// if the lock is taken, the handler is flushing them concurrently, and ordering doesn't matter
+	if c.mu.TryLock() {
+		defer c.mu.Unlock()
+		if len(c.out) > 0 {
+			out := c.out
+			c.out = nil
+			c.outLen.Store(0)
+			if !c.enqueue(out) {
+				return false
+			}
+		}
+	}
+	return c.enqueue(b)
+}
+
+// enqueue queues b for writeLoop, the connection is closed if the queue is full
+func (c *Connection) enqueue(b []byte) bool {
+	select {
+	case c.pushCh <- b:
+		return true
+	default:
+		log.Printf("closing slow client %s", c.RemoteAddr())
+		// the handler notices the closed connection and cleans up
+		_ = c.conn.Close()
+		return false
+	}
+}
+
+func (c *Connection) writeLoop() {
+	defer close(c.pushDone)
+	for {
+		select {
+		case b := <-c.pushCh:
+			c.sending.Add(1)
+			_, err := c.conn.Write(b)
+			c.sending.Done()
+			if err != nil {
+				return
+			}
+		case <-c.quit:
+			if c.closeAfterReply.Load() {
+				c.drain()
+			}
+			return
+		}
+	}
+}
+
+// drain writes what is queued when the connection closes after a reply, like the OK of QUIT
+// Close set a write deadline, a peer which doesn't read can't hold it
+func (c *Connection) drain() {
+	for {
+		select {
+		case b := <-c.pushCh:
+			c.sending.Add(1)
+			_, err := c.conn.Write(b)
+			c.sending.Done()
+			if err != nil {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (c *Connection) Subscribe(channel string) {
+	c.subs.Lock()
+	defer c.subs.Unlock()
+	if c.channels == nil {
+		c.channels = make(map[string]struct{})
+	}
+	c.channels[channel] = struct{}{}
+}
+
+func (c *Connection) Unsubscribe(channel string) {
+	c.subs.Lock()
+	defer c.subs.Unlock()
+	delete(c.channels, channel)
+}
+
+func (c *Connection) Channels() []string {
+	c.subs.Lock()
+	defer c.subs.Unlock()
+	channels := make([]string, 0, len(c.channels))
+	for channel := range c.channels {
+		channels = append(channels, channel)
+	}
+	return channels
+}
+
+func (c *Connection) Subscribed(channel string) bool {
+	c.subs.Lock()
+	defer c.subs.Unlock()
+	_, ok := c.channels[channel]
+	return ok
+}
+
+func (c *Connection) PSubscribe(pattern string) {
+	c.subs.Lock()
+	defer c.subs.Unlock()
+	if c.patterns == nil {
+		c.patterns = make(map[string]struct{})
+	}
+	c.patterns[pattern] = struct{}{}
+}
+
+func (c *Connection) PUnsubscribe(pattern string) {
+	c.subs.Lock()
+	defer c.subs.Unlock()
+	delete(c.patterns, pattern)
+}
+
+func (c *Connection) Patterns() []string {
+	c.subs.Lock()
+	defer c.subs.Unlock()
+	patterns := make([]string, 0, len(c.patterns))
+	for pattern := range c.patterns {
+		patterns = append(patterns, pattern)
+	}
+	return patterns
+}
+
+func (c *Connection) SSubscribe(channel string) {
+	c.subs.Lock()
+	defer c.subs.Unlock()
+	if c.shardChannels == nil {
+		c.shardChannels = make(map[string]struct{})
+	}
+	c.shardChannels[channel] = struct{}{}
+}
+
+func (c *Connection) SUnsubscribe(channel string) {
+	c.subs.Lock()
+	defer c.subs.Unlock()
+	delete(c.shardChannels, channel)
+}
+
+func (c *Connection) ShardChannels() []string {
+	c.subs.Lock()
+	defer c.subs.Unlock()
+	channels := make([]string, 0, len(c.shardChannels))
+	for channel := range c.shardChannels {
+		channels = append(channels, channel)
+	}
+	return channels
+}
+
+// SubsCount returns the number of channels, patterns and shard channels subscribed to
+// the connection is in subscriber mode while it's positive
+func (c *Connection) SubsCount() int {
+	c.subs.Lock()
+	defer c.subs.Unlock()
+	return len(c.channels) + len(c.patterns) + len(c.shardChannels)
+}
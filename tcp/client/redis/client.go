@@ -0,0 +1,122 @@
+// Package redis is a client of godis, and of any server speaking RESP
+//
+//	client := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+//	defer client.Close()
+//	err := client.Set(ctx, "key", "value")
+//	value, err := client.Get(ctx, "key")
+package redis
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+// Client is safe for concurrent use, commands are sent over a pool of connections
+// a command failing because its connection broke is sent again on a new connection, up to MaxRetries times
+type Client struct {
+	opts Options
+	pool *pool
+}
+
+func NewClient(opts *Options) *Client {
+	c := &Client{}
+	if opts != nil {
+		c.opts = *opts
+	}
+	c.opts.init()
+	c.pool = newPool(&c.opts)
+	return c
+}
+
+// Close closes the connections of the pool
+func (c *Client) Close() error {
+	return c.pool.close()
+}
+
+// withConn runs fn with a connection of the pool, again on another connection if it broke
+func (c *Client) withConn(ctx context.Context, fn func(conn *Conn) error) error {
+	var err error
+	for attempt := 0; attempt <= max(c.opts.MaxRetries, 0); attempt++ {
+		if attempt > 0 {
+			// the server may be restarting
+			select {
+			case <-time.After(time.Duration(attempt) * 8 * time.Millisecond):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		var conn *Conn
+		conn, err = c.pool.get(ctx)
+		if err != nil {
+			// dial errors are retried too, until the server is back
+			if !retryable(err) && !isDialError(err) {
+				return err
+			}
+			continue
+		}
+		err = fn(conn)
+		c.pool.put(conn)
+		if err == nil || !conn.broken.Load() || !retryable(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// Do sends a command and returns its reply
+// an error reply is returned as a parser.ReplyError error, a null reply as a nil value
+func (c *Client) Do(ctx context.Context, args ...any) (any, error) {
+	return c.do(ctx, 0, args)
+}
+
+func (c *Client) do(ctx context.Context, block time.Duration, args []any) (any, error) {
+	var reply any
+	err := c.withConn(ctx, func(conn *Conn) error {
+		var err error
+		reply, err = conn.do(ctx, block, args)
+		return err
+	})
+	return reply, err
+}
+
+// Pipeline buffers commands, Exec sends them at once over a single connection
+func (c *Client) Pipeline() *Pipeline {
+	return &Pipeline{client: c}
+}
+
+type Pipeline struct {
+	client   *Client
+	commands [][]any
+}
+
+// Do queues a command
+func (p *Pipeline) Do(args ...any) {
+	p.commands = append(p.commands, args)
+}
+
+// Len returns the number of queued commands
+func (p *Pipeline) Len() int {
+	return len(p.commands)
+}
+
+// Exec sends the queued commands and returns their replies in order, the pipeline is emptied
+// error replies are parser.ReplyError values, the error is only set if the replies couldn't be read
+func (p *Pipeline) Exec(ctx context.Context) ([]any, error) {
+	commands := p.commands
+	p.commands = nil
+	var replies []any
+	err := p.client.withConn(ctx, func(conn *Conn) error {
+		var err error
+		replies, err = conn.Pipeline(ctx, commands)
+		return err
+	})
+	return replies, err
+}
+
+// isDialError tells whether err happened while connecting, before anything was sent
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
@@ -0,0 +1,459 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Z is a member of a sorted set with its score
+type Z struct {
+	Score  float64
+	Member string
+}
+
+// GeoLocation is a member of a geospatial index
+type GeoLocation struct {
+	Longitude float64
+	Latitude  float64
+	Member    string
+}
+
+// XMessage is an entry of a stream
+type XMessage struct {
+	ID     string
+	Values map[string]string
+}
+
+func (c *Client) Ping(ctx context.Context) (string, error) {
+	return toString(c.Do(ctx, "PING"))
+}
+
+func (c *Client) Get(ctx context.Context, key string) (string, error) {
+	return toString(c.Do(ctx, "GET", key))
+}
+
+func (c *Client) Set(ctx context.Context, key string, value any) error {
+	_, err := c.Do(ctx, "SET", key, value)
+	return err
+}
+
+func (c *Client) Del(ctx context.Context, keys ...string) (int64, error) {
+	return toInt(c.Do(ctx, withStrings([]any{"DEL"}, keys)...))
+}
+
+func (c *Client) LPush(ctx context.Context, key string, values ...any) (int64, error) {
+	return toInt(c.Do(ctx, append([]any{"LPUSH", key}, values...)...))
+}
+
+func (c *Client) RPush(ctx context.Context, key string, values ...any) (int64, error) {
+	return toInt(c.Do(ctx, append([]any{"RPUSH", key}, values...)...))
+}
+
+func (c *Client) LPop(ctx context.Context, key string) (string, error) {
+	return toString(c.Do(ctx, "LPOP", key))
+}
+
+func (c *Client) RPop(ctx context.Context, key string) (string, error) {
+	return toString(c.Do(ctx, "RPOP", key))
+}
+
+func (c *Client) LLen(ctx context.Context, key string) (int64, error) {
+	return toInt(c.Do(ctx, "LLEN", key))
+}
+
+func (c *Client) LIndex(ctx context.Context, key string, index int64) (string, error) {
+	return toString(c.Do(ctx, "LINDEX", key, index))
+}
+
+func (c *Client) LRange(ctx context.Context, key string, start int64, stop int64) ([]string, error) {
+	return toStrings(c.Do(ctx, "LRANGE", key, start, stop))
+}
+
+// HSet sets fields of a hash, pairs alternates fields and values
+func (c *Client) HSet(ctx context.Context, key string, pairs ...any) (int64, error) {
+	return toInt(c.Do(ctx, append([]any{"HSET", key}, pairs...)...))
+}
+
+func (c *Client) HGet(ctx context.Context, key string, field string) (string, error) {
+	return toString(c.Do(ctx, "HGET", key, field))
+}
+
+func (c *Client) HDel(ctx context.Context, key string, fields ...string) (int64, error) {
+	return toInt(c.Do(ctx, withStrings([]any{"HDEL", key}, fields)...))
+}
+
+func (c *Client) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	return toStringMap(c.Do(ctx, "HGETALL", key))
+}
+
+func (c *Client) HExists(ctx context.Context, key string, field string) (bool, error) {
+	return toBool(c.Do(ctx, "HEXISTS", key, field))
+}
+
+func (c *Client) HLen(ctx context.Context, key string) (int64, error) {
+	return toInt(c.Do(ctx, "HLEN", key))
+}
+
+func (c *Client) SAdd(ctx context.Context, key string, members ...any) (int64, error) {
+	return toInt(c.Do(ctx, append([]any{"SADD", key}, members...)...))
+}
+
+func (c *Client) SRem(ctx context.Context, key string, members ...any) (int64, error) {
+	return toInt(c.Do(ctx, append([]any{"SREM", key}, members...)...))
+}
+
+func (c *Client) SIsMember(ctx context.Context, key string, member any) (bool, error) {
+	return toBool(c.Do(ctx, "SISMEMBER", key, member))
+}
+
+func (c *Client) SMembers(ctx context.Context, key string) ([]string, error) {
+	return toStrings(c.Do(ctx, "SMEMBERS", key))
+}
+
+func (c *Client) SCard(ctx context.Context, key string) (int64, error) {
+	return toInt(c.Do(ctx, "SCARD", key))
+}
+
+func (c *Client) SInter(ctx context.Context, keys ...string) ([]string, error) {
+	return toStrings(c.Do(ctx, withStrings([]any{"SINTER"}, keys)...))
+}
+
+func (c *Client) SUnion(ctx context.Context, keys ...string) ([]string, error) {
+	return toStrings(c.Do(ctx, withStrings([]any{"SUNION"}, keys)...))
+}
+
+func (c *Client) SDiff(ctx context.Context, keys ...string) ([]string, error) {
+	return toStrings(c.Do(ctx, withStrings([]any{"SDIFF"}, keys)...))
+}
+
+func (c *Client) ZAdd(ctx context.Context, key string, members ...Z) (int64, error) {
+	args := []any{"ZADD", key}
+	for _, member := range members {
+		args = append(args, member.Score, member.Member)
+	}
+	return toInt(c.Do(ctx, args...))
+}
+
+func (c *Client) ZRem(ctx context.Context, key string, members ...string) (int64, error) {
+	return toInt(c.Do(ctx, withStrings([]any{"ZREM", key}, members)...))
+}
+
+// ZRange returns the members from rank start to stop, negative ranks count from the end
+func (c *Client) ZRange(ctx context.Context, key string, start int64, stop int64) ([]string, error) {
+	return toStrings(c.Do(ctx, "ZRANGE", key, start, stop))
+}
+
+// ZRangeByScore returns the members with a score between min and max, like "(1" or "+inf"
+func (c *Client) ZRangeByScore(ctx context.Context, key string, min string, max string) ([]string, error) {
+	return toStrings(c.Do(ctx, "ZRANGE", key, min, max, "BYSCORE"))
+}
+
+func (c *Client) ZCard(ctx context.Context, key string) (int64, error) {
+	return toInt(c.Do(ctx, "ZCARD", key))
+}
+
+func (c *Client) ZScore(ctx context.Context, key string, member string) (float64, error) {
+	return toFloat(c.Do(ctx, "ZSCORE", key, member))
+}
+
+func (c *Client) ZRank(ctx context.Context, key string, member string) (int64, error) {
+	return toInt(c.Do(ctx, "ZRANK", key, member))
+}
+
+func (c *Client) ZPopMin(ctx context.Context, key string, count int64) ([]Z, error) {
+	return toZs(c.Do(ctx, "ZPOPMIN", key, count))
+}
+
+func (c *Client) ZPopMax(ctx context.Context, key string, count int64) ([]Z, error) {
+	return toZs(c.Do(ctx, "ZPOPMAX", key, count))
+}
+
+// BZPopMin pops the member with the lowest score of the first non-empty key, waiting up to timeout
+// Nil is returned on timeout
+func (c *Client) BZPopMin(ctx context.Context, timeout time.Duration, keys ...string) (string, Z, error) {
+	return c.bzPop(ctx, "BZPOPMIN", timeout, keys)
+}
+
+// BZPopMax is BZPopMin for the member with the highest score
+func (c *Client) BZPopMax(ctx context.Context, timeout time.Duration, keys ...string) (string, Z, error) {
+	return c.bzPop(ctx, "BZPOPMAX", timeout, keys)
+}
+
+func (c *Client) bzPop(ctx context.Context, cmdName string, timeout time.Duration, keys []string) (string, Z, error) {
+	block := timeout
+	if timeout == 0 {
+		// waits forever
+		block = -1
+	}
+	reply, err := c.do(ctx, block, append(withStrings([]any{cmdName}, keys), timeout))
+	values, err := toStrings(reply, err)
+	if err != nil {
+		return "", Z{}, err
+	}
+	if len(values) != 3 {
+		return "", Z{}, fmt.Errorf("redis: unexpected %s reply %q", cmdName, values)
+	}
+	zs, err := toZs(values[1:], nil)
+	if err != nil {
+		return "", Z{}, err
+	}
+	return values[0], zs[0], nil
+}
+
+func (c *Client) GeoAdd(ctx context.Context, key string, locations ...GeoLocation) (int64, error) {
+	args := []any{"GEOADD", key}
+	for _, location := range locations {
+		args = append(args, location.Longitude, location.Latitude, location.Member)
+	}
+	return toInt(c.Do(ctx, args...))
+}
+
+// GeoPos returns the positions of members, nil for a missing member
+func (c *Client) GeoPos(ctx context.Context, key string, members ...string) ([]*GeoLocation, error) {
+	reply, err := c.Do(ctx, withStrings([]any{"GEOPOS", key}, members)...)
+	if err != nil {
+		return nil, err
+	}
+	values, ok := reply.([]any)
+	if !ok {
+		return nil, unexpected(reply)
+	}
+	positions := make([]*GeoLocation, len(values))
+	for i, value := range values {
+		if value == nil {
+			continue
+		}
+		coordinates, err := toStrings(value, nil)
+		if err != nil || len(coordinates) != 2 {
+			return nil, unexpected(value)
+		}
+		position := &GeoLocation{Member: members[i]}
+		if position.Longitude, err = strconv.ParseFloat(coordinates[0], 64); err != nil {
+			return nil, err
+		}
+		if position.Latitude, err = strconv.ParseFloat(coordinates[1], 64); err != nil {
+			return nil, err
+		}
+		positions[i] = position
+	}
+	return positions, nil
+}
+
+// GeoDist returns the distance between two members in unit, m, km, ft or mi
+func (c *Client) GeoDist(ctx context.Context, key string, member1 string, member2 string, unit string) (float64, error) {
+	return toFloat(c.Do(ctx, "GEODIST", key, member1, member2, unit))
+}
+
+func (c *Client) GeoHash(ctx context.Context, key string, members ...string) ([]string, error) {
+	return toStrings(c.Do(ctx, withStrings([]any{"GEOHASH", key}, members)...))
+}
+
+// XAdd appends an entry to a stream, id is usually "*", values alternates fields and values
+func (c *Client) XAdd(ctx context.Context, key string, id string, values ...any) (string, error) {
+	return toString(c.Do(ctx, append([]any{"XADD", key, id}, values...)...))
+}
+
+func (c *Client) XLen(ctx context.Context, key string) (int64, error) {
+	return toInt(c.Do(ctx, "XLEN", key))
+}
+
+func (c *Client) XDel(ctx context.Context, key string, ids ...string) (int64, error) {
+	return toInt(c.Do(ctx, withStrings([]any{"XDEL", key}, ids)...))
+}
+
+// XRange returns the entries with an id between start and stop, "-" and "+" being the ends of the stream
+func (c *Client) XRange(ctx context.Context, key string, start string, stop string) ([]XMessage, error) {
+	return toXMessages(c.Do(ctx, "XRANGE", key, start, stop))
+}
+
+func (c *Client) XRevRange(ctx context.Context, key string, stop string, start string) ([]XMessage, error) {
+	return toXMessages(c.Do(ctx, "XREVRANGE", key, stop, start))
+}
+
+// Publish sends message to the subscribers of channel, returns how many received it
+func (c *Client) Publish(ctx context.Context, channel string, message any) (int64, error) {
+	return toInt(c.Do(ctx, "PUBLISH", channel, message))
+}
+
+func (c *Client) ConfigGet(ctx context.Context, pattern string) (map[string]string, error) {
+	return toStringMap(c.Do(ctx, "CONFIG", "GET", pattern))
+}
+
+func (c *Client) ConfigSet(ctx context.Context, parameter string, value string) error {
+	_, err := c.Do(ctx, "CONFIG", "SET", parameter, value)
+	return err
+}
+
+/* ---- conversion of replies ---- */
+
+func withStrings(args []any, values []string) []any {
+	for _, value := range values {
+		args = append(args, value)
+	}
+	return args
+}
+
+func unexpected(reply any) error {
+	return fmt.Errorf("redis: unexpected reply %#v", reply)
+}
+
+func toString(reply any, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	switch v := reply.(type) {
+	case nil:
+		return "", Nil
+	case string:
+		return v, nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	}
+	return "", unexpected(reply)
+}
+
+func toInt(reply any, err error) (int64, error) {
+	if err != nil {
+		return 0, err
+	}
+	switch v := reply.(type) {
+	case nil:
+		return 0, Nil
+	case int64:
+		return v, nil
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	}
+	return 0, unexpected(reply)
+}
+
+func toFloat(reply any, err error) (float64, error) {
+	if err != nil {
+		return 0, err
+	}
+	switch v := reply.(type) {
+	case nil:
+		return 0, Nil
+	case float64:
+		return v, nil
+	case int64:
+		return float64(v), nil
+	case string:
+		return strconv.ParseFloat(v, 64)
+	}
+	return 0, unexpected(reply)
+}
+
+func toBool(reply any, err error) (bool, error) {
+	if err != nil {
+		return false, err
+	}
+	switch v := reply.(type) {
+	case bool:
+		return v, nil
+	case int64:
+		return v != 0, nil
+	}
+	return false, unexpected(reply)
+}
+
+// toStrings converts arrays and sets, Nil is returned for a null array
+func toStrings(reply any, err error) ([]string, error) {
+	if err != nil {
+		return nil, err
+	}
+	switch v := reply.(type) {
+	case nil:
+		return nil, Nil
+	case []string:
+		return v, nil
+	case []any:
+		values := make([]string, len(v))
+		for i, element := range v {
+			if element == nil {
+				continue
+			}
+			if values[i], err = toString(element, nil); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	}
+	return nil, unexpected(reply)
+}
+
+// toStringMap converts maps, and their flattened RESP2 form
+func toStringMap(reply any, err error) (map[string]string, error) {
+	if err != nil {
+		return nil, err
+	}
+	if pairs, ok := reply.(map[string]any); ok {
+		values := make(map[string]string, len(pairs))
+		for key, value := range pairs {
+			if values[key], err = toString(value, nil); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	}
+	flat, err := toStrings(reply, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(flat)%2 != 0 {
+		return nil, unexpected(reply)
+	}
+	values := make(map[string]string, len(flat)/2)
+	for i := 0; i < len(flat); i += 2 {
+		values[flat[i]] = flat[i+1]
+	}
+	return values, nil
+}
+
+// toZs converts member, score, member, score... replies
+func toZs(reply any, err error) ([]Z, error) {
+	flat, err := toStrings(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	if len(flat)%2 != 0 {
+		return nil, unexpected(reply)
+	}
+	zs := make([]Z, len(flat)/2)
+	for i := range zs {
+		zs[i].Member = flat[2*i]
+		if zs[i].Score, err = strconv.ParseFloat(flat[2*i+1], 64); err != nil {
+			return nil, err
+		}
+	}
+	return zs, nil
+}
+
+// toXMessages converts [[id, [field, value...]]...] replies
+func toXMessages(reply any, err error) ([]XMessage, error) {
+	if err != nil {
+		return nil, err
+	}
+	entries, ok := reply.([]any)
+	if !ok {
+		return nil, unexpected(reply)
+	}
+	messages := make([]XMessage, len(entries))
+	for i, entry := range entries {
+		parts, ok := entry.([]any)
+		if !ok || len(parts) != 2 {
+			return nil, unexpected(entry)
+		}
+		if messages[i].ID, err = toString(parts[0], nil); err != nil {
+			return nil, err
+		}
+		if messages[i].Values, err = toStringMap(parts[1], nil); err != nil {
+			return nil, err
+		}
+	}
+	return messages, nil
+}
@@ -0,0 +1,299 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"godis/redis/parser"
+	"io"
+	"net"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Nil is returned by the typed helpers when the server replies with a null, e.g. GET of a missing key
+var Nil = errors.New("redis: nil")
+
+// Options configures a Client, the zero value of a field picks its default
+type Options struct {
+	// host:port of the server, localhost:6379 by default
+	Addr string
+	// RESP version negotiated with HELLO, 2 by default
+	Protocol int
+	// sent with HELLO AUTH when set
+	Password string
+	// sent with HELLO SETNAME when set
+	ClientName string
+
+	// 5s by default
+	DialTimeout time.Duration
+	// timeouts of each command, 3s by default, -1 disables them
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	// connections kept by the pool, 10 per CPU by default
+	PoolSize int
+	// how long to wait for a connection once PoolSize are in use, ReadTimeout + 1s by default
+	PoolTimeout time.Duration
+	// number of times a command failing on a broken connection is sent again, 3 by default, -1 disables retries
+	MaxRetries int
+
+	// OnPush is called with the RESP3 pushes received by the connections of the pool
+	// like invalidations of CLIENT TRACKING, they're dropped when nil
+	OnPush func(push parser.Push)
+}
+
+func (o *Options) init() {
+	if o.Addr == "" {
+		o.Addr = "localhost:6379"
+	}
+	if o.Protocol == 0 {
+		o.Protocol = 2
+	}
+	if o.DialTimeout == 0 {
+		o.DialTimeout = 5 * time.Second
+	}
+	if o.ReadTimeout == 0 {
+		o.ReadTimeout = 3 * time.Second
+	}
+	if o.WriteTimeout == 0 {
+		o.WriteTimeout = o.ReadTimeout
+	}
+	if o.PoolSize == 0 {
+		o.PoolSize = 10 * runtime.GOMAXPROCS(0)
+	}
+	if o.PoolTimeout == 0 {
+		o.PoolTimeout = max(o.ReadTimeout, 0) + time.Second
+	}
+	if o.MaxRetries == 0 {
+		o.MaxRetries = 3
+	}
+}
+
+// Conn is a single connection to a server, it isn't safe for concurrent use
+// Client pools connections for concurrent callers
+type Conn struct {
+	conn    net.Conn
+	writer  *bufio.Writer
+	replies *parser.ReplyReader
+	opts    *Options
+	// commands sent whose replies weren't read yet
+	pending int
+	// an I/O or protocol error left the connection in an unknown state, it can't be reused
+	broken atomic.Bool
+}
+
+// Dial connects to the server of opts and negotiates the protocol with HELLO if needed
+func Dial(ctx context.Context, opts *Options) (*Conn, error) {
+	o := Options{}
+	if opts != nil {
+		o = *opts
+	}
+	o.init()
+	return dial(ctx, &o)
+}
+
+func dial(ctx context.Context, opts *Options) (*Conn, error) {
+	dialer := net.Dialer{Timeout: opts.DialTimeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", opts.Addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &Conn{
+		conn:    netConn,
+		writer:  bufio.NewWriter(netConn),
+		replies: parser.NewReplyReader(netConn),
+		opts:    opts,
+	}
+	if opts.Protocol != 2 || opts.Password != "" || opts.ClientName != "" {
+		args := []any{"HELLO", opts.Protocol}
+		if opts.Password != "" {
+			args = append(args, "AUTH", "default", opts.Password)
+		}
+		if opts.ClientName != "" {
+			args = append(args, "SETNAME", opts.ClientName)
+		}
+		if _, err := c.Do(ctx, args...); err != nil {
+			_ = c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// Do sends a command and returns its reply, an error reply is returned as a parser.ReplyError error
+func (c *Conn) Do(ctx context.Context, args ...any) (any, error) {
+	return c.do(ctx, 0, args)
+}
+
+// do is Do for commands blocking up to block on the server, which is added to the read timeout
+func (c *Conn) do(ctx context.Context, block time.Duration, args []any) (any, error) {
+	c.Send(args...)
+	if err := c.Flush(ctx); err != nil {
+		return nil, err
+	}
+	reply, err := c.receive(ctx, block)
+	if err != nil {
+		return nil, err
+	}
+	if replyErr, ok := reply.(parser.ReplyError); ok {
+		return nil, replyErr
+	}
+	return reply, nil
+}
+
+// Send buffers a command, it's sent by the next Flush
+func (c *Conn) Send(args ...any) {
+	_, _ = c.writer.Write(appendCommand(nil, args))
+	c.pending++
+}
+
+// Flush sends the buffered commands
+func (c *Conn) Flush(ctx context.Context) error {
+	if err := c.conn.SetWriteDeadline(deadline(ctx, c.opts.WriteTimeout)); err != nil {
+		return c.fail(err)
+	}
+	if err := c.writer.Flush(); err != nil {
+		return c.fail(err)
+	}
+	return nil
+}
+
+// Receive reads the reply of the oldest command sent
+// error replies are returned as parser.ReplyError values, with a nil error
+func (c *Conn) Receive(ctx context.Context) (any, error) {
+	return c.receive(ctx, 0)
+}
+
+// block is how long the command may wait on the server, added to ReadTimeout, a negative block waits forever
+func (c *Conn) receive(ctx context.Context, block time.Duration) (any, error) {
+	timeout := c.opts.ReadTimeout
+	if block < 0 {
+		timeout = 0
+	} else if timeout > 0 {
+		timeout += block
+	}
+	for {
+		reply, err := c.next(ctx, timeout)
+		if err != nil {
+			return nil, err
+		}
+		if push, ok := reply.(parser.Push); ok {
+			// not the reply of a command
+			if c.opts.OnPush != nil {
+				c.opts.OnPush(push)
+			}
+			continue
+		}
+		c.pending--
+		return reply, nil
+	}
+}
+
+// next reads the next reply, pushes included
+func (c *Conn) next(ctx context.Context, timeout time.Duration) (any, error) {
+	if err := c.conn.SetReadDeadline(deadline(ctx, timeout)); err != nil {
+		return nil, c.fail(err)
+	}
+	reply, err := c.replies.Next()
+	if err != nil {
+		return nil, c.fail(err)
+	}
+	return reply, nil
+}
+
+// Pipeline sends commands at once and returns their replies in the same order
+// error replies are parser.ReplyError values, the error is only set if the replies couldn't be read
+func (c *Conn) Pipeline(ctx context.Context, commands [][]any) ([]any, error) {
+	for _, args := range commands {
+		c.Send(args...)
+	}
+	if err := c.Flush(ctx); err != nil {
+		return nil, err
+	}
+	replies := make([]any, len(commands))
+	for i := range commands {
+		reply, err := c.Receive(ctx)
+		if err != nil {
+			return nil, err
+		}
+		replies[i] = reply
+	}
+	return replies, nil
+}
+
+func (c *Conn) fail(err error) error {
+	c.broken.Store(true)
+	return err
+}
+
+// deadline returns the earliest of the deadline of ctx and now + timeout, or no deadline
+func deadline(ctx context.Context, timeout time.Duration) time.Time {
+	var t time.Time
+	if timeout > 0 {
+		t = time.Now().Add(timeout)
+	}
+	if d, ok := ctx.Deadline(); ok && (t.IsZero() || d.Before(t)) {
+		t = d
+	}
+	return t
+}
+
+// retryable tells whether a command may be sent again on another connection after err
+// timeouts aren't retried, as the command may have been executed
+func retryable(err error) bool {
+	return errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE)
+}
+
+// appendCommand encodes args as a multi bulk request
+func appendCommand(buf []byte, args []any) []byte {
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, arg := range args {
+		var b []byte
+		switch v := arg.(type) {
+		case string:
+			b = []byte(v)
+		case []byte:
+			b = v
+		case int:
+			b = strconv.AppendInt(nil, int64(v), 10)
+		case int64:
+			b = strconv.AppendInt(nil, v, 10)
+		case uint64:
+			b = strconv.AppendUint(nil, v, 10)
+		case float64:
+			b = strconv.AppendFloat(nil, v, 'f', -1, 64)
+		case bool:
+			if v {
+				b = []byte("1")
+			} else {
+				b = []byte("0")
+			}
+		case time.Duration:
+			// seconds, like the timeouts of blocking commands
+			b = strconv.AppendFloat(nil, v.Seconds(), 'f', -1, 64)
+		default:
+			b = []byte(fmt.Sprint(v))
+		}
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(b)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, b...)
+		buf = append(buf, '\r', '\n')
+	}
+	return buf
+}
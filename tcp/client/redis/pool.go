@@ -0,0 +1,99 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrPoolTimeout is returned when no connection is released within PoolTimeout
+var ErrPoolTimeout = errors.New("redis: connection pool timeout")
+
+// ErrClosed is returned by a closed Client
+var ErrClosed = errors.New("redis: client is closed")
+
+// pool keeps up to PoolSize connections, dialed on demand
+// broken connections are closed instead of being put back, so the next command reconnects
+type pool struct {
+	opts *Options
+	// a token is taken for every connection in use
+	tokens chan struct{}
+
+	mu     sync.Mutex
+	idle   []*Conn
+	closed bool
+}
+
+func newPool(opts *Options) *pool {
+	return &pool{
+		opts:   opts,
+		tokens: make(chan struct{}, opts.PoolSize),
+	}
+}
+
+// get returns an idle connection, or dials a new one
+func (p *pool) get(ctx context.Context) (*Conn, error) {
+	timer := time.NewTimer(p.opts.PoolTimeout)
+	defer timer.Stop()
+	select {
+	case p.tokens <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timer.C:
+		return nil, ErrPoolTimeout
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		<-p.tokens
+		return nil, ErrClosed
+	}
+	if n := len(p.idle); n > 0 {
+		conn := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mu.Unlock()
+		return conn, nil
+	}
+	p.mu.Unlock()
+
+	conn, err := dial(ctx, p.opts)
+	if err != nil {
+		<-p.tokens
+		return nil, err
+	}
+	return conn, nil
+}
+
+// put releases a connection got from the pool
+func (p *pool) put(conn *Conn) {
+	defer func() { <-p.tokens }()
+	if conn.broken.Load() || conn.pending != 0 {
+		_ = conn.Close()
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		_ = conn.Close()
+		return
+	}
+	p.idle = append(p.idle, conn)
+}
+
+// close closes the idle connections, the ones in use are closed once released
+func (p *pool) close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return ErrClosed
+	}
+	p.closed = true
+	var err error
+	for _, conn := range p.idle {
+		err = errors.Join(err, conn.Close())
+	}
+	p.idle = nil
+	return err
+}
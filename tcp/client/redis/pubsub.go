@@ -0,0 +1,336 @@
+package redis
+
+import (
+	"context"
+	"godis/redis/parser"
+	"sync"
+	"time"
+)
+
+// Message is a message published to a channel
+type Message struct {
+	Channel string
+	// the pattern matching Channel, for the messages received through PSubscribe
+	Pattern string
+	Payload string
+}
+
+// Subscription confirms a change of the subscriptions, like subscribe or punsubscribe
+type Subscription struct {
+	Kind    string
+	Channel string
+	// number of subscriptions left on the connection
+	Count int64
+}
+
+// Pong is the reply of PubSub.Ping
+type Pong struct {
+	Payload string
+}
+
+// PubSub receives messages over a connection of its own
+// the connection is dialed again and the subscriptions restored if it breaks
+type PubSub struct {
+	opts *Options
+
+	// guards the connection, its writes, and the subscriptions
+	mu            sync.Mutex
+	conn          *Conn
+	channels      map[string]struct{}
+	patterns      map[string]struct{}
+	shardChannels map[string]struct{}
+	closed        bool
+	done          chan struct{}
+
+	chOnce sync.Once
+	ch     chan *Message
+}
+
+func (c *Client) newPubSub() *PubSub {
+	return &PubSub{
+		opts:          &c.opts,
+		channels:      make(map[string]struct{}),
+		patterns:      make(map[string]struct{}),
+		shardChannels: make(map[string]struct{}),
+		done:          make(chan struct{}),
+	}
+}
+
+// Subscribe returns a PubSub subscribed to channels
+func (c *Client) Subscribe(ctx context.Context, channels ...string) (*PubSub, error) {
+	ps := c.newPubSub()
+	if err := ps.Subscribe(ctx, channels...); err != nil {
+		_ = ps.Close()
+		return nil, err
+	}
+	return ps, nil
+}
+
+// PSubscribe returns a PubSub subscribed to patterns
+func (c *Client) PSubscribe(ctx context.Context, patterns ...string) (*PubSub, error) {
+	ps := c.newPubSub()
+	if err := ps.PSubscribe(ctx, patterns...); err != nil {
+		_ = ps.Close()
+		return nil, err
+	}
+	return ps, nil
+}
+
+// SSubscribe returns a PubSub subscribed to shard channels
+func (c *Client) SSubscribe(ctx context.Context, channels ...string) (*PubSub, error) {
+	ps := c.newPubSub()
+	if err := ps.SSubscribe(ctx, channels...); err != nil {
+		_ = ps.Close()
+		return nil, err
+	}
+	return ps, nil
+}
+
+func (ps *PubSub) Subscribe(ctx context.Context, channels ...string) error {
+	return ps.subscribe(ctx, "SUBSCRIBE", ps.channels, channels)
+}
+
+func (ps *PubSub) PSubscribe(ctx context.Context, patterns ...string) error {
+	return ps.subscribe(ctx, "PSUBSCRIBE", ps.patterns, patterns)
+}
+
+func (ps *PubSub) SSubscribe(ctx context.Context, channels ...string) error {
+	return ps.subscribe(ctx, "SSUBSCRIBE", ps.shardChannels, channels)
+}
+
+// Unsubscribe unsubscribes from channels, or from every channel if none is given
+func (ps *PubSub) Unsubscribe(ctx context.Context, channels ...string) error {
+	return ps.unsubscribe(ctx, "UNSUBSCRIBE", ps.channels, channels)
+}
+
+func (ps *PubSub) PUnsubscribe(ctx context.Context, patterns ...string) error {
+	return ps.unsubscribe(ctx, "PUNSUBSCRIBE", ps.patterns, patterns)
+}
+
+func (ps *PubSub) SUnsubscribe(ctx context.Context, channels ...string) error {
+	return ps.unsubscribe(ctx, "SUNSUBSCRIBE", ps.shardChannels, channels)
+}
+
+// Ping asks the server for a Pong, received like messages
+func (ps *PubSub) Ping(ctx context.Context) error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	conn, _, err := ps.connLocked(ctx)
+	if err != nil {
+		return err
+	}
+	return ps.sendLocked(ctx, conn, []any{"PING"})
+}
+
+func (ps *PubSub) subscribe(ctx context.Context, cmdName string, subscribed map[string]struct{}, names []string) error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	for _, name := range names {
+		subscribed[name] = struct{}{}
+	}
+	conn, dialed, err := ps.connLocked(ctx)
+	if err != nil || dialed {
+		// a new connection is subscribed to everything already
+		return err
+	}
+	return ps.sendLocked(ctx, conn, withStrings([]any{cmdName}, names))
+}
+
+func (ps *PubSub) unsubscribe(ctx context.Context, cmdName string, subscribed map[string]struct{}, names []string) error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if len(names) == 0 {
+		clear(subscribed)
+	}
+	for _, name := range names {
+		delete(subscribed, name)
+	}
+	if ps.conn == nil {
+		return nil
+	}
+	return ps.sendLocked(ctx, ps.conn, withStrings([]any{cmdName}, names))
+}
+
+func (ps *PubSub) sendLocked(ctx context.Context, conn *Conn, args []any) error {
+	conn.Send(args...)
+	if err := conn.Flush(ctx); err != nil {
+		ps.dropLocked(conn)
+		return err
+	}
+	return nil
+}
+
+// connLocked returns the connection, dialing a new one subscribed to everything if there is none
+func (ps *PubSub) connLocked(ctx context.Context) (*Conn, bool, error) {
+	if ps.closed {
+		return nil, false, ErrClosed
+	}
+	if ps.conn != nil {
+		return ps.conn, false, nil
+	}
+	conn, err := dial(ctx, ps.opts)
+	if err != nil {
+		return nil, false, err
+	}
+	for cmdName, subscribed := range map[string]map[string]struct{}{
+		"SUBSCRIBE":  ps.channels,
+		"PSUBSCRIBE": ps.patterns,
+		"SSUBSCRIBE": ps.shardChannels,
+	} {
+		if len(subscribed) == 0 {
+			continue
+		}
+		args := []any{cmdName}
+		for name := range subscribed {
+			args = append(args, name)
+		}
+		conn.Send(args...)
+	}
+	if err := conn.Flush(ctx); err != nil {
+		_ = conn.Close()
+		return nil, false, err
+	}
+	ps.conn = conn
+	return conn, true, nil
+}
+
+// dropLocked closes a broken connection, the next call dials a new one
+func (ps *PubSub) dropLocked(conn *Conn) {
+	if ps.conn == conn {
+		_ = conn.Close()
+		ps.conn = nil
+	}
+}
+
+// Receive returns the next *Message, *Subscription or *Pong, waiting until the deadline of ctx
+// after an error, the next call reconnects
+func (ps *PubSub) Receive(ctx context.Context) (any, error) {
+	ps.mu.Lock()
+	conn, _, err := ps.connLocked(ctx)
+	ps.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	// the connection is only read here, while the other methods write to it
+	reply, err := conn.next(ctx, 0)
+	if err != nil {
+		ps.mu.Lock()
+		ps.dropLocked(conn)
+		ps.mu.Unlock()
+		return nil, err
+	}
+	return parsePubSubReply(reply)
+}
+
+// ReceiveMessage is Receive skipping everything but messages
+func (ps *PubSub) ReceiveMessage(ctx context.Context) (*Message, error) {
+	for {
+		reply, err := ps.Receive(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if msg, ok := reply.(*Message); ok {
+			return msg, nil
+		}
+	}
+}
+
+// Channel returns a channel of the messages received, closed by Close
+// a broken connection is replaced without the messages published in between
+func (ps *PubSub) Channel() <-chan *Message {
+	ps.chOnce.Do(func() {
+		ps.ch = make(chan *Message, 100)
+		go ps.deliver()
+	})
+	return ps.ch
+}
+
+func (ps *PubSub) deliver() {
+	defer close(ps.ch)
+	failures := 0
+	for {
+		// Close interrupts the read by closing the connection
+		msg, err := ps.ReceiveMessage(context.Background())
+		if err != nil {
+			if err == ErrClosed {
+				return
+			}
+			// wait for the server to come back
+			failures++
+			select {
+			case <-time.After(time.Duration(min(failures, 100)) * 10 * time.Millisecond):
+			case <-ps.done:
+				return
+			}
+			continue
+		}
+		failures = 0
+		select {
+		case ps.ch <- msg:
+		case <-ps.done:
+			return
+		}
+	}
+}
+
+// Close closes the connection, Receive returns ErrClosed afterwards
+func (ps *PubSub) Close() error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if ps.closed {
+		return ErrClosed
+	}
+	ps.closed = true
+	close(ps.done)
+	if ps.conn != nil {
+		err := ps.conn.Close()
+		ps.conn = nil
+		return err
+	}
+	return nil
+}
+
+// parsePubSubReply converts the arrays of RESP2 and the pushes of RESP3
+func parsePubSubReply(reply any) (any, error) {
+	var parts []any
+	switch v := reply.(type) {
+	case parser.ReplyError:
+		return nil, v
+	case parser.Push:
+		parts = v
+	case []any:
+		parts = v
+	case string:
+		// PING in RESP3
+		return &Pong{}, nil
+	}
+	if len(parts) < 2 {
+		return nil, unexpected(reply)
+	}
+	fields, err := toStrings(parts[:len(parts)-1], nil)
+	if err != nil {
+		return nil, err
+	}
+	switch fields[0] {
+	case "message", "smessage":
+		if len(parts) == 3 {
+			payload, err := toString(parts[2], nil)
+			return &Message{Channel: fields[1], Payload: payload}, err
+		}
+	case "pmessage":
+		if len(parts) == 4 {
+			payload, err := toString(parts[3], nil)
+			return &Message{Pattern: fields[1], Channel: fields[2], Payload: payload}, err
+		}
+	case "subscribe", "unsubscribe", "psubscribe", "punsubscribe", "ssubscribe", "sunsubscribe":
+		if len(parts) == 3 {
+			count, err := toInt(parts[2], nil)
+			return &Subscription{Kind: fields[0], Channel: fields[1], Count: count}, err
+		}
+	case "pong":
+		payload, err := toString(parts[len(parts)-1], nil)
+		return &Pong{Payload: payload}, err
+	}
+	return nil, unexpected(reply)
+}
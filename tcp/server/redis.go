@@ -2,105 +2,248 @@ package server
 
 import (
 	"context"
+	"errors"
 	"godis/db"
 	"godis/interfaces"
 	gsync "godis/lib/sync"
+	"godis/metrics"
 	"godis/redis/parser"
 	"godis/redis/protocol"
+	"godis/stats"
 	"godis/tcp/client"
 	"io"
 	"log"
 	"net"
 	"strings"
 	"sync"
+	"time"
 )
 
 var (
-	unknownErrReplyBytes = []byte("-ERR unknown\r\n")
+	unknownErrReplyBytes    = []byte("-ERR unknown\r\n")
+	maxClientsErrReplyBytes = []byte("-ERR max number of clients reached\r\n")
 )
 
+// idleSweepInterval is how often the idle clients are looked for, timeout is set in seconds
+const idleSweepInterval = time.Second
+
+// commands allowed while a connection has subscriptions
+var subscriberCommands = map[string]bool{
+	"subscribe":    true,
+	"unsubscribe":  true,
+	"psubscribe":   true,
+	"punsubscribe": true,
+	"ssubscribe":   true,
+	"sunsubscribe": true,
+	"ping":         true,
+	"quit":         true,
+	"reset":        true,
+}
+
 // implements the handler that listens and serves the connection
 // closed is set to True when closing the connection
 // stops the handler from handling new connections
 // db is the key part for Redis
 // activeConn is a Map for connections alive
+// done stops the sweeper of the idle clients
 type RedisHandler struct {
 	closed     gsync.Boolean
 	db         interfaces.DB
 	activeConn sync.Map
+	done       chan struct{}
+	closeOnce  sync.Once
 }
 
 func NewRedisHandler() *RedisHandler {
-	return &RedisHandler{
-		db: db.NewStandAloneDb(),
+	r := &RedisHandler{
+		db:   db.NewStandAloneDb(),
+		done: make(chan struct{}),
 	}
+	go r.sweepIdle()
+	return r
 }
 
+// Close disconnects the clients, then closes the db, it's called again by ServeAll on exit
 func (r *RedisHandler) Close() error {
-	log.Printf("handler closing...")
+	r.closeOnce.Do(func() {
+		log.Printf("handler closing...")
+
+		r.closed.Set(true)
+		close(r.done)
+		r.activeConn.Range(func(key any, value any) bool {
+			client := key.(*client.Connection)
+			_ = client.Close()
+			return true
+		})
+		r.db.Close()
+	})
+	return nil
+}
+
+// Collect writes the metrics of the database, served on /metrics
+func (r *RedisHandler) Collect(w *metrics.Writer) {
+	r.db.Collect(w)
+}
+
+// KeepAlive returns the period of the TCP keepalive probes, set by tcp-keepalive
+func (r *RedisHandler) KeepAlive() time.Duration {
+	return time.Duration(r.db.Config().TCPKeepAlive) * time.Second
+}
+
+// sweepIdle closes the clients idle for longer than timeout until the handler is closed
+func (r *RedisHandler) sweepIdle() {
+	ticker := time.NewTicker(idleSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case now := <-ticker.C:
+			r.closeIdle(now)
+		}
+	}
+}
 
-	r.closed.Set(true)
+// closeIdle closes the clients which sent no command for longer than timeout
+// like Redis, subscribers, monitors and clients waiting for a blocking command aren't idle
+func (r *RedisHandler) closeIdle(now time.Time) {
+	timeout := time.Duration(r.db.Config().Timeout) * time.Second
+	if timeout == 0 {
+		return
+	}
 	r.activeConn.Range(func(key any, value any) bool {
-		client := key.(*client.Client)
-		_ = client.Close()
+		client := key.(*client.Connection)
+		if client.SubsCount() > 0 || client.Monitoring() || client.Busy() {
+			return true
+		}
+		if _, lastInteraction := client.LastCommand(); now.Sub(lastInteraction) > timeout {
+			log.Printf("closing idle client %s", client.RemoteAddr())
+			_ = client.Close()
+		}
 		return true
 	})
-	return nil
 }
 
 func (r *RedisHandler) closeClient(client *client.Connection) {
+	r.db.AfterClientClose(client)
 	client.Close()
 	r.activeConn.Delete(client)
 }
 
+// execSubscriber runs a command of a connection in subscriber mode
+// only the subscribe family and PING are allowed in this mode
+func (r *RedisHandler) execSubscriber(client *client.Connection, args [][]byte) protocol.Reply {
+	cmdName := strings.ToLower(string(args[0]))
+	if !subscriberCommands[cmdName] {
+		return protocol.MakeErrReply("ERR Can't execute '" + cmdName + "': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context")
+	}
+	if cmdName == "ping" {
+		// subscribers get PING replies in the same shape as messages
+		message := []byte("")
+		if len(args) > 1 {
+			message = args[1]
+		}
+		return protocol.MakeMultiBulkReply([][]byte{[]byte("pong"), message})
+	}
+	return r.db.Exec(client, args)
+}
+
 func (r *RedisHandler) HandleF(ctx context.Context, conn net.Conn) {
+	stats := r.db.Stats()
 	if r.closed.Get() {
+		stats.RejectedConnections.Add(1)
+		_ = conn.Close()
+		return
+	}
+	stats.ConnectionsReceived.Add(1)
+	if stats.ConnectedClients.Load() >= int64(r.db.Config().MaxClients) {
+		stats.RejectedConnections.Add(1)
+		// the client may not read the error, it mustn't hold the handler
+		_ = conn.SetWriteDeadline(time.Now().Add(time.Second))
+		_, _ = conn.Write(maxClientsErrReplyBytes)
 		_ = conn.Close()
 		return
 	}
 
+	conn = &meteredConn{Conn: conn, stats: stats}
 	client := client.NewConn(conn)
+	client.SetCloseTimeout(time.Duration(r.db.Config().ClientCloseTimeout) * time.Second)
 	r.activeConn.Store(client, struct{}{})
-
-	payloadCh := parser.ParseStream(conn)
-
-	for payload := range payloadCh {
-
-		if payload.Err != nil {
-			if payload.Err == io.EOF ||
-				payload.Err == io.ErrUnexpectedEOF ||
-				strings.Contains(payload.Err.Error(), "use of closed network connection") {
-				// should close the connection
-				return
-			}
-			// protocol error
-			errReply := protocol.MakeErrReply(payload.Err.Error())
-			_, err := client.Write(errReply.ToBytes())
-			if err != nil {
-				r.closeClient(client)
-				log.Printf("connection closed: %s", client.RemoteAddr())
-				return
+	r.db.AfterClientOpen(client)
+	defer r.closeClient(client)
+
+	commands := parser.NewParser(&flushBeforeRead{reader: conn, client: client})
+	for {
+		// limits changed with CONFIG SET apply to the next command of every client
+		props := r.db.Config()
+		commands.MaxBulkLen, commands.MaxQueryBufferLen = props.ProtoMaxBulkLen, props.ClientQueryBufferLimit
+		args, err := commands.Next()
+		if err != nil {
+			var protocolErr *parser.ProtocolError
+			if errors.As(err, &protocolErr) {
+				log.Printf("closing client %s: %v", client.RemoteAddr(), err)
+				// the rest of the input can't be parsed, the connection is closed after replying
+				_ = client.Buffer(protocol.MakeErrReply("ERR " + protocolErr.Error()).ToBytes())
+				_ = client.Flush()
 			}
-			continue
+			return
 		}
-
-		if payload.Data == nil {
-			log.Printf("empty payload")
-			continue
+		// commands may keep their arguments, e.g. the value of SET, while the parser reuses its buffer
+		args = parser.CloneArgs(args)
+		client.SetQueryBufferLen(commands.Buffered())
+
+		var resp protocol.Reply
+		client.SetBusy(true)
+		// RESP3 clients may run any command while subscribed, as messages are push frames
+		if client.SubsCount() > 0 && client.ProtocolVersion() == protocol.RESP2 {
+			resp = r.execSubscriber(client, args)
+		} else {
+			resp = r.db.Exec(client, args)
 		}
-
-		reply, ok := payload.Data.(*protocol.MultiBulkReply)
-		if !ok {
-			log.Printf("requires multi bulk protocol")
-			continue
+		client.SetBusy(false)
+		// replies are dropped after CLIENT REPLY OFF or SKIP
+		if client.ShouldReply() {
+			if resp != nil {
+				_ = client.Buffer(protocol.Encode(resp, client.ProtocolVersion()))
+			} else {
+				_ = client.Buffer(unknownErrReplyBytes)
+			}
 		}
-		resp := r.db.Exec(client, reply.Args)
-		if resp != nil {
-			_, _ = client.Write(resp.ToBytes())
-		} else {
-			_, _ = client.Write(unknownErrReplyBytes)
+		if client.ClosingAfterReply() {
+			_ = client.Flush()
+			return
 		}
+	}
+}
+
+// flushBeforeRead writes the buffered replies before waiting for more commands
+// every pipelined command received so far is executed by then, their replies are written at once
+type flushBeforeRead struct {
+	reader io.Reader
+	client *client.Connection
+}
 
+func (f *flushBeforeRead) Read(p []byte) (int, error) {
+	if err := f.client.Flush(); err != nil {
+		return 0, err
 	}
+	return f.reader.Read(p)
+}
+
+// meteredConn counts the bytes read and written for INFO
+type meteredConn struct {
+	net.Conn
+	stats *stats.Stats
+}
+
+func (m *meteredConn) Read(p []byte) (int, error) {
+	n, err := m.Conn.Read(p)
+	m.stats.NetInputBytes.Add(int64(n))
+	return n, err
+}
 
+func (m *meteredConn) Write(p []byte) (int, error) {
+	n, err := m.Conn.Write(p)
+	m.stats.NetOutputBytes.Add(int64(n))
+	return n, err
 }
@@ -2,12 +2,15 @@ package server
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"syscall"
+	"time"
 )
 
 // this handler should be implemented by
@@ -18,6 +21,31 @@ type Handler interface {
 	HandleF(ctx context.Context, conn net.Conn)
 }
 
+// KeepAliver is implemented by the handlers setting the TCP keepalive of the connections accepted
+type KeepAliver interface {
+	// KeepAlive returns the period of the keepalive probes, 0 to send none
+	KeepAlive() time.Duration
+}
+
+// setKeepAlive probes conn every period once it's idle, so that the connections of the clients
+// which vanished are closed, like Redis a peer is dead after 3 unanswered probes
+func setKeepAlive(conn net.Conn, period time.Duration) {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return
+	}
+	if period <= 0 {
+		_ = tcpConn.SetKeepAlive(false)
+		return
+	}
+	_ = tcpConn.SetKeepAliveConfig(net.KeepAliveConfig{
+		Enable:   true,
+		Idle:     period,
+		Interval: max(period/3, time.Second),
+		Count:    3,
+	})
+}
+
 func ListenAndServe(addr string, closeCh chan struct{},
 	errCh chan error) (net.Listener, error) {
 	listener, err := net.Listen("tcp", addr)
@@ -30,10 +58,40 @@ func ListenAndServe(addr string, closeCh chan struct{},
 }
 
 func Serve(addr string, handler Handler) error {
+	if err := ServeAll([]string{addr}, handler); err != nil {
+		log.Fatal(err)
+		return err
+	}
+	return nil
+}
+
+// ServeAll serves handler on every address until a signal asks to exit
+// an address prefixed with "-" is skipped if it can't be listened on, like in the bind directive of redis.conf
+func ServeAll(addrs []string, handler Handler) error {
 	closeCh := make(chan struct{})
 	errCh := make(chan error)
 	sigCh := make(chan os.Signal, 1)
 
+	var listeners []net.Listener
+	for _, addr := range addrs {
+		optional := strings.HasPrefix(addr, "-")
+		listener, err := ListenAndServe(strings.TrimPrefix(addr, "-"), closeCh, errCh)
+		if err != nil && optional {
+			log.Printf("skipping %s: %v", addr[1:], err)
+			continue
+		}
+		if err != nil {
+			for _, listener := range listeners {
+				listener.Close()
+			}
+			return err
+		}
+		listeners = append(listeners, listener)
+	}
+	if len(listeners) == 0 {
+		return errors.New("no address to listen on")
+	}
+
 	signal.Notify(sigCh, syscall.SIGHUP, syscall.SIGQUIT, syscall.SIGTERM, syscall.SIGINT)
 
 	go func() {
@@ -45,10 +103,10 @@ func Serve(addr string, handler Handler) error {
 		}
 	}()
 
-	listener, err := ListenAndServe(addr, closeCh, errCh)
-	if err != nil {
-		log.Fatal(err)
-		return err
+	closeListeners := func() {
+		for _, listener := range listeners {
+			listener.Close()
+		}
 	}
 
 	go func() {
@@ -60,28 +118,39 @@ func Serve(addr string, handler Handler) error {
 		}
 		log.Printf("shutting down...")
 
-		listener.Close()
+		closeListeners()
 		_ = handler.Close()
 	}()
 
 	defer func() {
-		listener.Close()
+		closeListeners()
 		_ = handler.Close()
 	}()
 
 	ctx := context.Background()
 	var waitDone sync.WaitGroup
-	for {
-		conn, err := listener.Accept()
-		if err != nil {
-			break
-		}
-		waitDone.Add(1)
+	var accepting sync.WaitGroup
+	for _, listener := range listeners {
+		accepting.Add(1)
 		go func() {
-			defer waitDone.Done()
-			handler.HandleF(ctx, conn)
+			defer accepting.Done()
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					break
+				}
+				if k, ok := handler.(KeepAliver); ok {
+					setKeepAlive(conn, k.KeepAlive())
+				}
+				waitDone.Add(1)
+				go func() {
+					defer waitDone.Done()
+					handler.HandleF(ctx, conn)
+				}()
+			}
 		}()
 	}
+	accepting.Wait()
 	waitDone.Wait()
 	return nil
 }
@@ -0,0 +1,62 @@
+package authtcp
+
+import (
+	"godis/tcp/server"
+	"godis/tests/internal/testconn"
+	"io"
+	"log"
+	"strings"
+	"testing"
+)
+
+func init() {
+	// the commands log a lot
+	log.SetOutput(io.Discard)
+}
+
+func TestRequirePass(t *testing.T) {
+	handler := server.NewRedisHandler()
+	defer handler.Close()
+	admin := testconn.Connect(t, handler)
+	defer admin.Close()
+
+	if reply := admin.Send(t, "AUTH secret\r\n"); !strings.HasPrefix(reply, "-ERR AUTH <password> called without any password") {
+		t.Fatalf("unexpected reply %q", reply)
+	}
+	if reply := admin.Send(t, "CONFIG SET requirepass secret\r\n"); reply != "+OK\r\n" {
+		t.Fatalf("unexpected reply %q", reply)
+	}
+	// connected before the password was set
+	if reply := admin.Send(t, "SET key value\r\n"); reply != "+OK\r\n" {
+		t.Fatalf("unexpected reply %q", reply)
+	}
+
+	conn := testconn.Connect(t, handler)
+	defer conn.Close()
+	tests := []struct {
+		command  string
+		expected string
+	}{
+		{"GET key\r\n", "-NOAUTH Authentication required.\r\n"},
+		{"HELLO 3\r\n", "-NOAUTH HELLO must be called with the client already authenticated, otherwise the HELLO <proto> AUTH <user> <pass> option can be used to authenticate the client and select the RESP protocol version at the same time\r\n"},
+		{"HELLO 3 AUTH default wrong\r\n", "-WRONGPASS invalid username-password pair or user is disabled.\r\n"},
+		{"AUTH wrong\r\n", "-WRONGPASS invalid username-password pair or user is disabled.\r\n"},
+		{"AUTH someone secret\r\n", "-WRONGPASS invalid username-password pair or user is disabled.\r\n"},
+		{"AUTH default secret\r\n", "+OK\r\n"},
+		{"GET key\r\n", "$5\r\nvalue\r\n"},
+	}
+	for _, tt := range tests {
+		if reply := conn.Send(t, tt.command); reply != tt.expected {
+			t.Fatalf("%q: expected %q, got %q", tt.command, tt.expected, reply)
+		}
+	}
+
+	other := testconn.Connect(t, handler)
+	defer other.Close()
+	if reply := other.Send(t, "HELLO 2 AUTH default secret\r\n"); !strings.HasPrefix(reply, "*14\r\n") {
+		t.Fatalf("unexpected reply %q", reply)
+	}
+	if reply := other.Send(t, "GET key\r\n"); reply != "$5\r\nvalue\r\n" {
+		t.Fatalf("unexpected reply %q", reply)
+	}
+}
@@ -0,0 +1,208 @@
+package clientcommandstcp
+
+import (
+	"godis/redis/parser"
+	"godis/tcp/server"
+	"godis/tests/internal/testconn"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func init() {
+	// the commands log a lot
+	log.SetOutput(io.Discard)
+}
+
+// fields parses a line of CLIENT LIST
+func fields(line string) map[string]string {
+	fields := make(map[string]string)
+	for _, field := range strings.Fields(line) {
+		name, value, _ := strings.Cut(field, "=")
+		fields[name] = value
+	}
+	return fields
+}
+
+func TestClientInfo(t *testing.T) {
+	handler := server.NewRedisHandler()
+	defer handler.Close()
+	c := testconn.Connect(t, handler)
+
+	if reply := c.Do(t, "CLIENT", "GETNAME"); reply != nil {
+		t.Errorf("expected no name, got %v", reply)
+	}
+	if reply := c.Do(t, "CLIENT", "SETNAME", "a name"); reply != parser.ReplyError("ERR Client names cannot contain spaces, newlines or special characters.") {
+		t.Errorf("unexpected reply %v", reply)
+	}
+	c.Do(t, "CLIENT", "SETNAME", "tester")
+	if reply := c.Do(t, "CLIENT", "GETNAME"); reply != "tester" {
+		t.Errorf("unexpected name %v", reply)
+	}
+	c.Do(t, "CLIENT", "NO-EVICT", "on")
+
+	id := c.Do(t, "CLIENT", "ID").(int64)
+	info := fields(c.Do(t, "CLIENT", "INFO").(string))
+	for name, value := range map[string]string{
+		"id":    strconv.FormatInt(id, 10),
+		"addr":  "pipe",
+		"name":  "tester",
+		"age":   "0",
+		"idle":  "0",
+		"flags": "e",
+		"db":    "0",
+		"sub":   "0",
+		"cmd":   "client|info",
+		"user":  "default",
+		"redir": "-1",
+		"resp":  "2",
+	} {
+		if info[name] != value {
+			t.Errorf("%s: expected %q, got %q", name, value, info[name])
+		}
+	}
+
+	subscriber := testconn.Connect(t, handler)
+	subscriber.Do(t, "SUBSCRIBE", "news")
+	lines := strings.Split(strings.TrimSuffix(c.Do(t, "CLIENT", "LIST").(string), "\n"), "\n")
+	if len(lines) != 2 || fields(lines[0])["id"] != strconv.FormatInt(id, 10) {
+		t.Fatalf("unexpected list %q", lines)
+	}
+	if f := fields(lines[1]); f["flags"] != "P" || f["sub"] != "1" || f["cmd"] != "subscribe" {
+		t.Errorf("unexpected subscriber %q", lines[1])
+	}
+	if list := c.Do(t, "CLIENT", "LIST", "TYPE", "pubsub").(string); fields(list)["cmd"] != "subscribe" || strings.Count(list, "\n") != 1 {
+		t.Errorf("unexpected list of subscribers %q", list)
+	}
+	if list := c.Do(t, "CLIENT", "LIST", "ID", strconv.FormatInt(id, 10), "12345").(string); strings.Count(list, "\n") != 1 {
+		t.Errorf("unexpected list by id %q", list)
+	}
+	if reply := c.Do(t, "CLIENT", "LIST", "TYPE", "nosuchtype"); reply != parser.ReplyError("ERR Unknown client type 'nosuchtype'") {
+		t.Errorf("unexpected reply %v", reply)
+	}
+}
+
+func TestClientKill(t *testing.T) {
+	handler := server.NewRedisHandler()
+	defer handler.Close()
+	c := testconn.Connect(t, handler)
+	other := testconn.Connect(t, handler)
+	otherID := other.Do(t, "CLIENT", "ID").(int64)
+
+	if reply := c.Do(t, "CLIENT", "KILL", "10.0.0.1:6379"); reply != parser.ReplyError("ERR No such client") {
+		t.Errorf("unexpected reply %v", reply)
+	}
+	if reply := c.Do(t, "CLIENT", "KILL", "USER", "nobody"); reply != parser.ReplyError("ERR No such user 'nobody'") {
+		t.Errorf("unexpected reply %v", reply)
+	}
+	// the client itself is skipped by default
+	if reply := c.Do(t, "CLIENT", "KILL", "TYPE", "normal", "ID", strconv.FormatInt(otherID, 10)); reply != int64(1) {
+		t.Errorf("expected 1 client killed, got %v", reply)
+	}
+	_ = other.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := other.Replies.Next(); err == nil {
+		t.Error("the client killed is still connected")
+	}
+
+	// the client killed is listed until the handler notices it's closed
+	for deadline := time.Now().Add(time.Second); strings.Count(c.Do(t, "CLIENT", "LIST").(string), "\n") > 1; {
+		if time.Now().After(deadline) {
+			t.Fatal("the client killed is still listed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// the client killing itself gets its reply first
+	if reply := c.Do(t, "CLIENT", "KILL", "ADDR", "pipe", "SKIPME", "no"); reply != int64(1) {
+		t.Errorf("expected 1 client killed, got %v", reply)
+	}
+	if _, err := c.Replies.Next(); err == nil {
+		t.Error("the client is still connected")
+	}
+}
+
+func TestClientReply(t *testing.T) {
+	handler := server.NewRedisHandler()
+	defer handler.Close()
+	c := testconn.Connect(t, handler)
+
+	c.SendArgs(t, "CLIENT", "REPLY", "OFF")
+	c.SendArgs(t, "PING", "dropped")
+	if reply := c.Do(t, "CLIENT", "REPLY", "ON"); reply != "OK" {
+		t.Errorf("unexpected reply %v", reply)
+	}
+	c.SendArgs(t, "CLIENT", "REPLY", "SKIP")
+	c.SendArgs(t, "PING", "dropped")
+	if reply := c.Do(t, "PING", "sent"); reply != "sent" {
+		t.Errorf("unexpected reply %v", reply)
+	}
+	if reply := c.Do(t, "CLIENT", "REPLY", "MAYBE"); reply != parser.ReplyError("ERR syntax error") {
+		t.Errorf("unexpected reply %v", reply)
+	}
+}
+
+func TestClientPause(t *testing.T) {
+	handler := server.NewRedisHandler()
+	defer handler.Close()
+	c := testconn.Connect(t, handler)
+	other := testconn.Connect(t, handler)
+
+	c.Do(t, "CLIENT", "PAUSE", "200", "WRITE")
+	start := time.Now()
+	// reads aren't paused
+	other.Do(t, "GET", "a")
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("GET was paused for %v", elapsed)
+	}
+	other.Do(t, "SET", "a", "1")
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("SET was paused for %v only", elapsed)
+	}
+
+	c.Do(t, "CLIENT", "PAUSE", "10000")
+	start = time.Now()
+	other.SendArgs(t, "GET", "a")
+	time.Sleep(50 * time.Millisecond)
+	c.Do(t, "CLIENT", "UNPAUSE")
+	if reply := other.Decode(t); reply != "1" {
+		t.Errorf("unexpected reply %v", reply)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond || elapsed > time.Second {
+		t.Errorf("GET was paused for %v", elapsed)
+	}
+	if reply := c.Do(t, "CLIENT", "PAUSE", "-1"); reply != parser.ReplyError("ERR timeout is negative") {
+		t.Errorf("unexpected reply %v", reply)
+	}
+}
+
+func TestClientPauseOverlap(t *testing.T) {
+	handler := server.NewRedisHandler()
+	defer handler.Close()
+	c := testconn.Connect(t, handler)
+	other := testconn.Connect(t, handler)
+
+	// the reads are paused until the pause of every command ends, the writes until the later pause of the writes
+	c.Do(t, "CLIENT", "PAUSE", "100", "ALL")
+	c.Do(t, "CLIENT", "PAUSE", "400", "WRITE")
+	start := time.Now()
+	other.Do(t, "GET", "a")
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond || elapsed > 300*time.Millisecond {
+		t.Errorf("GET was paused for %v", elapsed)
+	}
+	other.Do(t, "SET", "a", "1")
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Errorf("SET was paused for %v only", elapsed)
+	}
+
+	// a later pause of the writes doesn't shorten the pause of every command
+	c.Do(t, "CLIENT", "PAUSE", "400", "ALL")
+	c.Do(t, "CLIENT", "PAUSE", "100", "WRITE")
+	start = time.Now()
+	other.Do(t, "GET", "a")
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Errorf("GET was paused for %v only", elapsed)
+	}
+}
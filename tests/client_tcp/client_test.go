@@ -0,0 +1,288 @@
+package clienttcp
+
+import (
+	"context"
+	"errors"
+	"godis/redis/parser"
+	"godis/tcp/client/redis"
+	"godis/tcp/server"
+	"io"
+	"log"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func init() {
+	// the commands log a lot
+	log.SetOutput(io.Discard)
+}
+
+type testServer struct {
+	addr     string
+	listener net.Listener
+	handler  *server.RedisHandler
+}
+
+// listen serves a new handler on addr, a free local port if addr is empty
+func listen(tb testing.TB, addr string) *testServer {
+	if addr == "" {
+		addr = "127.0.0.1:0"
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	s := &testServer{addr: listener.Addr().String(), listener: listener, handler: server.NewRedisHandler()}
+	tb.Cleanup(s.stop)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.handler.HandleF(context.Background(), conn)
+		}
+	}()
+	return s
+}
+
+// stop closes the listener and every connection
+func (s *testServer) stop() {
+	_ = s.listener.Close()
+	_ = s.handler.Close()
+}
+
+func check(t *testing.T, name string, got any, err error, expected any) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("%s: expected %#v, got %#v", name, expected, got)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	s := listen(t, "")
+	for _, protocol := range []int{2, 3} {
+		t.Run("RESP"+strconv.Itoa(protocol), func(t *testing.T) {
+			ctx := context.Background()
+			client := redis.NewClient(&redis.Options{Addr: s.addr, Protocol: protocol})
+			defer client.Close()
+			_, _ = client.Del(ctx, "string", "list", "hash", "set", "zset", "geo", "stream")
+
+			pong, err := client.Ping(ctx)
+			check(t, "PING", pong, err, "PONG")
+			check(t, "SET", nil, client.Set(ctx, "string", 42), nil)
+			value, err := client.Get(ctx, "string")
+			check(t, "GET", value, err, "42")
+			if _, err := client.Get(ctx, "missing"); err != redis.Nil {
+				t.Fatalf("GET of a missing key: expected redis.Nil, got %v", err)
+			}
+			if _, err := client.Do(ctx, "NOSUCHCOMMAND"); err == nil || err.Error() != "ERR unknown command 'nosuchcommand'" {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			n, err := client.RPush(ctx, "list", "a", "b", 3)
+			check(t, "RPUSH", n, err, int64(3))
+			values, err := client.LRange(ctx, "list", 0, -1)
+			check(t, "LRANGE", values, err, []string{"a", "b", "3"})
+			value, err = client.LPop(ctx, "list")
+			check(t, "LPOP", value, err, "a")
+
+			n, err = client.HSet(ctx, "hash", "f1", "v1", "f2", "v2")
+			check(t, "HSET", n, err, int64(2))
+			hash, err := client.HGetAll(ctx, "hash")
+			check(t, "HGETALL", hash, err, map[string]string{"f1": "v1", "f2": "v2"})
+			exists, err := client.HExists(ctx, "hash", "f3")
+			check(t, "HEXISTS", exists, err, false)
+
+			n, err = client.SAdd(ctx, "set", "x", "y")
+			check(t, "SADD", n, err, int64(2))
+			members, err := client.SMembers(ctx, "set")
+			sort.Strings(members)
+			check(t, "SMEMBERS", members, err, []string{"x", "y"})
+
+			n, err = client.ZAdd(ctx, "zset", redis.Z{Score: 1.5, Member: "a"}, redis.Z{Score: 2, Member: "b"})
+			check(t, "ZADD", n, err, int64(2))
+			score, err := client.ZScore(ctx, "zset", "a")
+			check(t, "ZSCORE", score, err, 1.5)
+			values, err = client.ZRange(ctx, "zset", 0, -1)
+			check(t, "ZRANGE", values, err, []string{"a", "b"})
+			zs, err := client.ZPopMin(ctx, "zset", 1)
+			check(t, "ZPOPMIN", zs, err, []redis.Z{{Score: 1.5, Member: "a"}})
+			key, z, err := client.BZPopMin(ctx, time.Second, "zset")
+			check(t, "BZPOPMIN", []any{key, z}, err, []any{"zset", redis.Z{Score: 2, Member: "b"}})
+			if _, _, err := client.BZPopMin(ctx, 100*time.Millisecond, "zset"); err != redis.Nil {
+				t.Fatalf("BZPOPMIN timeout: expected redis.Nil, got %v", err)
+			}
+
+			n, err = client.GeoAdd(ctx, "geo", redis.GeoLocation{Longitude: 13.361389, Latitude: 38.115556, Member: "Palermo"},
+				redis.GeoLocation{Longitude: 15.087269, Latitude: 37.502669, Member: "Catania"})
+			check(t, "GEOADD", n, err, int64(2))
+			distance, err := client.GeoDist(ctx, "geo", "Palermo", "Catania", "km")
+			check(t, "GEODIST", distance, err, 166.2742)
+			positions, err := client.GeoPos(ctx, "geo", "Palermo", "missing")
+			if err != nil || len(positions) != 2 || positions[0] == nil || positions[1] != nil {
+				t.Fatalf("GEOPOS: unexpected positions %v, %v", positions, err)
+			}
+
+			id, err := client.XAdd(ctx, "stream", "1-1", "field", "value")
+			check(t, "XADD", id, err, "1-1")
+			messages, err := client.XRange(ctx, "stream", "-", "+")
+			check(t, "XRANGE", messages, err, []redis.XMessage{{ID: "1-1", Values: map[string]string{"field": "value"}}})
+
+			config, err := client.ConfigGet(ctx, "notify-keyspace-events")
+			check(t, "CONFIG GET", config, err, map[string]string{"notify-keyspace-events": ""})
+		})
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	s := listen(t, "")
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{Addr: s.addr})
+	defer client.Close()
+
+	pipeline := client.Pipeline()
+	for i := 0; i < 1000; i++ {
+		pipeline.Do("RPUSH", "list", i)
+	}
+	pipeline.Do("GET", "list")
+	pipeline.Do("LLEN", "list")
+	replies, err := pipeline.Exec(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replies) != 1002 || replies[999] != int64(1000) {
+		t.Fatalf("unexpected replies %v", replies[len(replies)-3:])
+	}
+	var replyErr parser.ReplyError
+	if !errors.As(replies[1000].(error), &replyErr) {
+		t.Fatalf("expected an error reply for GET, got %#v", replies[1000])
+	}
+	if replies[1001] != int64(1000) {
+		t.Fatalf("expected the replies after the error to be read, got %#v", replies[1001])
+	}
+	if pipeline.Len() != 0 {
+		t.Fatalf("the pipeline wasn't emptied")
+	}
+}
+
+func TestPubSub(t *testing.T) {
+	s := listen(t, "")
+	for _, protocol := range []int{2, 3} {
+		t.Run("RESP"+strconv.Itoa(protocol), func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			client := redis.NewClient(&redis.Options{Addr: s.addr, Protocol: protocol})
+			defer client.Close()
+
+			ps, err := client.Subscribe(ctx, "news")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ps.Close()
+			reply, err := ps.Receive(ctx)
+			check(t, "SUBSCRIBE", reply, err, &redis.Subscription{Kind: "subscribe", Channel: "news", Count: 1})
+			if err := ps.PSubscribe(ctx, "n*"); err != nil {
+				t.Fatal(err)
+			}
+			reply, err = ps.Receive(ctx)
+			check(t, "PSUBSCRIBE", reply, err, &redis.Subscription{Kind: "psubscribe", Channel: "n*", Count: 2})
+
+			n, err := client.Publish(ctx, "news", "hello")
+			check(t, "PUBLISH", n, err, int64(2))
+			msg, err := ps.ReceiveMessage(ctx)
+			check(t, "message", msg, err, &redis.Message{Channel: "news", Payload: "hello"})
+			msg, err = ps.ReceiveMessage(ctx)
+			check(t, "pmessage", msg, err, &redis.Message{Pattern: "n*", Channel: "news", Payload: "hello"})
+
+			if err := ps.Unsubscribe(ctx); err != nil {
+				t.Fatal(err)
+			}
+			reply, err = ps.Receive(ctx)
+			check(t, "UNSUBSCRIBE", reply, err, &redis.Subscription{Kind: "unsubscribe", Channel: "news", Count: 1})
+			if err := ps.Ping(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if reply, err = ps.Receive(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := reply.(*redis.Pong); !ok {
+				t.Fatalf("expected a pong, got %#v", reply)
+			}
+		})
+	}
+}
+
+// the pool dials a new connection once the server is back, the pub/sub connection subscribes again
+func TestReconnect(t *testing.T) {
+	s := listen(t, "")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client := redis.NewClient(&redis.Options{Addr: s.addr, MaxRetries: 10})
+	defer client.Close()
+	if _, err := client.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+	ps, err := client.Subscribe(ctx, "news")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ps.Close()
+	messages := ps.Channel()
+
+	s.stop()
+	s = listen(t, s.addr)
+	if _, err := client.Ping(ctx); err != nil {
+		t.Fatalf("PING after a restart: %v", err)
+	}
+	// published until the subscriber is back
+	for {
+		if _, err := client.Publish(ctx, "news", "back"); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case msg := <-messages:
+			check(t, "message", msg, nil, &redis.Message{Channel: "news", Payload: "back"})
+			return
+		case <-time.After(20 * time.Millisecond):
+		case <-ctx.Done():
+			t.Fatal("no message after a restart")
+		}
+	}
+}
+
+func TestTimeouts(t *testing.T) {
+	s := listen(t, "")
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{Addr: s.addr, PoolSize: 1, ReadTimeout: 100 * time.Millisecond, PoolTimeout: 50 * time.Millisecond})
+	defer client.Close()
+
+	// the read timeout applies to the raw command, not knowing it blocks
+	_, err := client.Do(ctx, "BZPOPMIN", "zset", 1)
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+
+	// the only connection is busy
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _, _ = client.BZPopMin(ctx, 300*time.Millisecond, "zset")
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if _, err := client.Ping(ctx); err != redis.ErrPoolTimeout {
+		t.Fatalf("expected a pool timeout, got %v", err)
+	}
+	<-done
+	if _, err := client.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
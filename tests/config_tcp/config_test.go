@@ -0,0 +1,77 @@
+package configtcp
+
+import (
+	"godis/config"
+	"godis/tcp/server"
+	"godis/tests/internal/testconn"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func init() {
+	// the commands log a lot
+	log.SetOutput(io.Discard)
+}
+
+func TestConfigGetSet(t *testing.T) {
+	handler := server.NewRedisHandler()
+	defer handler.Close()
+	c := testconn.Connect(t, handler)
+
+	c.Expect(t, "CONFIG GET port shards\r\n", "*4\r\n$4\r\nport\r\n$4\r\n6379\r\n$6\r\nshards\r\n$2\r\n16\r\n")
+	c.Expect(t, "CONFIG GET append*\r\n",
+		"*6\r\n$10\r\nappendonly\r\n$2\r\nno\r\n$11\r\nappendfsync\r\n$8\r\neverysec\r\n$14\r\nappendfilename\r\n$14\r\nappendonly.aof\r\n")
+	c.Expect(t, "CONFIG GET nosuchparameter\r\n", "*0\r\n")
+
+	c.Expect(t, "CONFIG SET maxmemory 10mb appendfsync ALWAYS\r\n", "+OK\r\n")
+	c.Expect(t, "CONFIG GET maxmemory appendfsync\r\n", "*4\r\n$11\r\nappendfsync\r\n$6\r\nalways\r\n$9\r\nmaxmemory\r\n$8\r\n10485760\r\n")
+
+	// nothing is set unless every value is valid
+	c.Expect(t, "CONFIG SET maxmemory 20mb appendfsync sometimes\r\n",
+		"-ERR CONFIG SET failed (possibly related to argument 'appendfsync') - argument(s) must be one of the following: always, everysec, no\r\n")
+	c.Expect(t, "CONFIG SET maxmemory 20mb port 7000\r\n",
+		"-ERR CONFIG SET failed (possibly related to argument 'port') - can't set immutable config\r\n")
+	c.Expect(t, "CONFIG SET maxmemory 20mb maxmemory 30mb\r\n",
+		"-ERR CONFIG SET failed (possibly related to argument 'maxmemory') - duplicate parameter\r\n")
+	c.Expect(t, "CONFIG SET maxmemory 20mb nosuchparameter 1\r\n",
+		"-ERR Unknown option or number of arguments for CONFIG SET - 'nosuchparameter'\r\n")
+	c.Expect(t, "CONFIG SET maxmemory\r\n", "-ERR wrong number of arguments for 'config|set' command\r\n")
+	c.Expect(t, "CONFIG GET maxmemory\r\n", "*2\r\n$9\r\nmaxmemory\r\n$8\r\n10485760\r\n")
+
+	c.Expect(t, "CONFIG SET notify-keyspace-events KEA client-close-timeout 1\r\n", "+OK\r\n")
+	c.Expect(t, "CONFIG GET notify-keyspace-events\r\n", "*2\r\n$22\r\nnotify-keyspace-events\r\n$3\r\nAKE\r\n")
+	c.Expect(t, "CONFIG RESETSTAT\r\n", "+OK\r\n")
+	c.Expect(t, "CONFIG REWRITE\r\n", "-ERR The server is running without a config file\r\n")
+}
+
+func TestConfigRewrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "redis.conf")
+	if err := os.WriteFile(path, []byte("# memory\nmaxmemory 1gb\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	properties := config.Defaults()
+	if err := properties.LoadFile(path); err != nil {
+		t.Fatal(err)
+	}
+	defer func(previous *config.ServerProperties) { config.Properties = previous }(config.Properties)
+	config.Properties = properties
+
+	handler := server.NewRedisHandler()
+	defer handler.Close()
+	c := testconn.Connect(t, handler)
+	c.Expect(t, "CONFIG GET maxmemory\r\n", "*2\r\n$9\r\nmaxmemory\r\n$10\r\n1073741824\r\n")
+	c.Expect(t, "CONFIG SET maxmemory 100mb requirepass secret\r\n", "+OK\r\n")
+	c.Expect(t, "CONFIG REWRITE\r\n", "+OK\r\n")
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "# memory\nmaxmemory 100mb\n# Generated by CONFIG REWRITE\nrequirepass secret\n"
+	if string(content) != expected {
+		t.Errorf("expected %q, got %q", expected, content)
+	}
+}
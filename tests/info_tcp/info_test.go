@@ -0,0 +1,119 @@
+package infotcp
+
+import (
+	"godis/tcp/server"
+	"godis/tests/internal/testconn"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func init() {
+	// the commands log a lot
+	log.SetOutput(io.Discard)
+}
+
+// info runs INFO with args and returns its fields by name, and the section headers
+func info(t *testing.T, c *testconn.Conn, args string) (map[string]string, []string) {
+	t.Helper()
+	// the lines of the bulk string are read as they are, ParseRESP reads bulk strings of a single line
+	if _, err := c.Write([]byte("INFO" + args + "\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	_ = c.SetReadDeadline(time.Now().Add(time.Second))
+	header, err := c.Reader.ReadString('\n')
+	if err != nil || !strings.HasPrefix(header, "$") {
+		t.Fatalf("INFO%s: unexpected reply %q, %v", args, header, err)
+	}
+	size, _ := strconv.Atoi(strings.TrimSpace(header[1:]))
+	buf := make([]byte, size+2)
+	if _, err := io.ReadFull(c.Reader, buf); err != nil {
+		t.Fatal(err)
+	}
+	body := string(buf[:size])
+	fields := make(map[string]string)
+	var sections []string
+	for _, line := range strings.Split(strings.TrimSuffix(body, "\r\n"), "\r\n") {
+		if strings.HasPrefix(line, "# ") {
+			sections = append(sections, line[2:])
+		} else if name, value, ok := strings.Cut(line, ":"); ok {
+			fields[name] = value
+		}
+	}
+	return fields, sections
+}
+
+func TestInfo(t *testing.T) {
+	handler := server.NewRedisHandler()
+	defer handler.Close()
+	c := testconn.Connect(t, handler)
+
+	_, sections := info(t, c, "")
+	expected := "Server Clients Memory Persistence Stats Replication Keyspace"
+	if got := strings.Join(sections, " "); got != expected {
+		t.Errorf("expected the sections %q, got %q", expected, got)
+	}
+
+	c.Send(t, "SET a 1\r\n")
+	c.Send(t, "SET b 2\r\n")
+	c.Send(t, "GET a\r\n")
+	c.Send(t, "GET missing\r\n")
+	c.Send(t, "LPUSH a x\r\n")
+	c.Send(t, "NOSUCHCOMMAND\r\n")
+
+	fields, sections := info(t, c, " keyspace stats Clients")
+	if got := strings.Join(sections, " "); got != "Clients Stats Keyspace" {
+		t.Errorf("unexpected sections %q", got)
+	}
+	for name, value := range map[string]string{
+		"db0":                        "keys=2,expires=0,avg_ttl=0",
+		"connected_clients":          "1",
+		"total_connections_received": "1",
+		"total_commands_processed":   "6", // the first INFO included
+		"keyspace_hits":              "1",
+		"keyspace_misses":            "1",
+		"total_error_replies":        "2",
+	} {
+		if fields[name] != value {
+			t.Errorf("%s: expected %q, got %q", name, value, fields[name])
+		}
+	}
+	if fields["total_net_input_bytes"] == "0" || fields["total_net_output_bytes"] == "0" {
+		t.Errorf("the bytes exchanged aren't counted: %v", fields)
+	}
+
+	fields, _ = info(t, c, " commandstats")
+	if !strings.HasPrefix(fields["cmdstat_set"], "calls=2,usec=") || !strings.HasSuffix(fields["cmdstat_set"], ",rejected_calls=0,failed_calls=0") {
+		t.Errorf("unexpected set stats %q", fields["cmdstat_set"])
+	}
+	if !strings.HasSuffix(fields["cmdstat_lpush"], ",failed_calls=1") {
+		t.Errorf("unexpected lpush stats %q", fields["cmdstat_lpush"])
+	}
+
+	c.Send(t, "CONFIG RESETSTAT\r\n")
+	fields, _ = info(t, c, " everything")
+	if fields["keyspace_hits"] != "0" || fields["connected_clients"] != "1" || fields["cmdstat_set"] != "" {
+		t.Errorf("the counters weren't reset: %v", fields)
+	}
+	if fields["cmdstat_config"] == "" || fields["redis_version"] == "" {
+		t.Errorf("missing fields: %v", fields)
+	}
+}
+
+func TestInfoRejectedCalls(t *testing.T) {
+	handler := server.NewRedisHandler()
+	defer handler.Close()
+	c := testconn.Connect(t, handler)
+	c.Send(t, "CONFIG SET requirepass secret\r\n")
+
+	other := testconn.Connect(t, handler)
+	other.Send(t, "GET a\r\n")
+	other.Send(t, "AUTH secret\r\n")
+	fields, _ := info(t, other, " commandstats")
+	if !strings.HasSuffix(fields["cmdstat_get"], ",rejected_calls=1,failed_calls=0") {
+		t.Errorf("unexpected get stats %q", fields["cmdstat_get"])
+	}
+}
@@ -0,0 +1,110 @@
+// Package testconn serves test clients with a RedisHandler through in-memory connections
+package testconn
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"godis/lib/utils"
+	"godis/redis/parser"
+	"godis/tcp/server"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Timeout is how long a reply is waited for
+const Timeout = time.Second
+
+// Conn is the client end of an in-memory connection served by a handler
+// the replies may be read as raw strings, as utils.ParseRESP formats them, or decoded by Replies,
+// both read from Reader
+type Conn struct {
+	net.Conn
+	Reader  *bufio.Reader
+	Replies *parser.ReplyReader
+}
+
+// Connect serves one end of an in-memory connection with handler, the client end is closed with the test
+func Connect(t testing.TB, handler *server.RedisHandler) *Conn {
+	serverConn, clientConn := net.Pipe()
+	go handler.HandleF(context.Background(), serverConn)
+	t.Cleanup(func() { _ = clientConn.Close() })
+	reader := bufio.NewReader(clientConn)
+	return &Conn{Conn: clientConn, Reader: reader, Replies: parser.NewReplyReader(reader)}
+}
+
+// Send writes command, a RESP request or an inline command, and returns the raw reply
+func (c *Conn) Send(t testing.TB, command string) string {
+	t.Helper()
+	if _, err := c.Write([]byte(command)); err != nil {
+		t.Fatal(err)
+	}
+	return c.Receive(t)
+}
+
+// Receive reads a raw reply
+func (c *Conn) Receive(t testing.TB) string {
+	t.Helper()
+	_ = c.SetReadDeadline(time.Now().Add(Timeout))
+	reply, err := utils.ParseRESP(c.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reply
+}
+
+// Expect sends command and fails the test unless the raw reply is expected
+func (c *Conn) Expect(t testing.TB, command string, expected string) {
+	t.Helper()
+	if reply := c.Send(t, command); reply != expected {
+		t.Fatalf("%q: expected %q, got %q", command, expected, reply)
+	}
+}
+
+// SendArgs writes a command made of args without waiting for its reply
+func (c *Conn) SendArgs(t testing.TB, args ...string) {
+	t.Helper()
+	command := "*" + strconv.Itoa(len(args)) + "\r\n"
+	for _, arg := range args {
+		command += "$" + strconv.Itoa(len(arg)) + "\r\n" + arg + "\r\n"
+	}
+	if _, err := c.Write([]byte(command)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Next waits for a decoded reply for at most wait
+func (c *Conn) Next(wait time.Duration) (any, error) {
+	_ = c.SetReadDeadline(time.Now().Add(wait))
+	return c.Replies.Next()
+}
+
+// Decode reads a decoded reply
+func (c *Conn) Decode(t testing.TB) any {
+	t.Helper()
+	reply, err := c.Next(Timeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reply
+}
+
+// Do sends a command made of args and returns its decoded reply
+func (c *Conn) Do(t testing.TB, args ...string) any {
+	t.Helper()
+	c.SendArgs(t, args...)
+	return c.Decode(t)
+}
+
+// Closed reports whether the server closed the connection within wait
+func (c *Conn) Closed(wait time.Duration) bool {
+	_ = c.SetReadDeadline(time.Now().Add(wait))
+	_, err := c.Reader.ReadByte()
+	if err == nil {
+		_ = c.Reader.UnreadByte()
+	}
+	return errors.Is(err, io.EOF)
+}
@@ -0,0 +1,64 @@
+package metricshttp
+
+import (
+	"godis/metrics"
+	"godis/tcp/server"
+	"godis/tests/internal/testconn"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func init() {
+	// the commands log a lot
+	log.SetOutput(io.Discard)
+}
+
+func TestMetrics(t *testing.T) {
+	handler := server.NewRedisHandler()
+	defer handler.Close()
+	c := testconn.Connect(t, handler)
+	c.Send(t, "SET a 1\r\n")
+	c.Send(t, "SET b 2\r\n")
+	c.Send(t, "LPUSH a x\r\n")
+
+	httpServer := httptest.NewServer(metrics.Handler(handler))
+	defer httpServer.Close()
+	resp, err := http.Get(httpServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != metrics.ContentType {
+		t.Errorf("unexpected content type %q", resp.Header.Get("Content-Type"))
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := make(map[string]bool)
+	for _, line := range strings.Split(string(body), "\n") {
+		lines[line] = true
+	}
+	for _, line := range []string{
+		"# TYPE godis_command_duration_seconds histogram",
+		`godis_command_duration_seconds_count{cmd="set"} 2`,
+		`godis_command_duration_seconds_bucket{cmd="set",le="+Inf"} 2`,
+		// commands never called are reported too
+		`godis_command_duration_seconds_count{cmd="zadd"} 0`,
+		`godis_command_failed_calls_total{cmd="lpush"} 1`,
+		"godis_connected_clients 1",
+		"godis_connections_received_total 1",
+		`godis_db_keys{db="db0"} 2`,
+		"godis_commands_processed_total 3",
+		"godis_aof_enabled 0",
+	} {
+		if !lines[line] {
+			t.Errorf("missing %q in\n%s", line, body)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package monitortcp
+
+import (
+	"godis/tcp/server"
+	"godis/tests/internal/testconn"
+	"io"
+	"log"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func init() {
+	// the commands log a lot
+	log.SetOutput(io.Discard)
+}
+
+func TestMonitor(t *testing.T) {
+	handler := server.NewRedisHandler()
+	defer handler.Close()
+	monitor := testconn.Connect(t, handler)
+	c := testconn.Connect(t, handler)
+
+	if reply := monitor.Send(t, "MONITOR\r\n"); reply != "+OK\r\n" {
+		t.Fatalf("unexpected reply %q", reply)
+	}
+	c.Send(t, "AUTH secret\r\n")
+	c.Send(t, "CONFIG SET requirepass secret\r\n")
+	c.Send(t, "*3\r\n$3\r\nSET\r\n$1\r\na\r\n$7\r\n\"x\" y\n\x01\r\n")
+	c.Send(t, "GET b\r\n")
+
+	// the commands holding passwords aren't shown
+	line := monitor.Receive(t)
+	expected := regexp.MustCompile(`^\+\d+\.\d{6} \[0 pipe\] "SET" "a" "\\"x\\" y\\n\\x01"\r\n$`)
+	if !expected.MatchString(line) {
+		t.Errorf("unexpected line %q", line)
+	}
+	if line := monitor.Receive(t); !strings.HasSuffix(line, `] "GET" "b"`+"\r\n") {
+		t.Errorf("unexpected line %q", line)
+	}
+}
+
+func TestSlowMonitor(t *testing.T) {
+	handler := server.NewRedisHandler()
+	defer handler.Close()
+	monitor := testconn.Connect(t, handler)
+	c := testconn.Connect(t, handler)
+	monitor.Send(t, "MONITOR\r\n")
+
+	// the monitor never reads, the client isn't blocked and the monitor is disconnected
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 5000; i++ {
+			c.Send(t, "PING\r\n")
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the client is blocked by the monitor")
+	}
+}
@@ -0,0 +1,139 @@
+package pipelinetcp
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"godis/lib/utils"
+	"godis/tcp/server"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func init() {
+	// the parser and the commands log a lot
+	log.SetOutput(io.Discard)
+}
+
+// listen serves handler on a free local port, the listener is closed at the end of the test
+func listen(tb testing.TB) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	handler := server.NewRedisHandler()
+	tb.Cleanup(func() {
+		_ = listener.Close()
+		_ = handler.Close()
+	})
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go handler.HandleF(context.Background(), conn)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// pipeline sends commands in batches of depth, and reads all their replies after each batch
+// the replies are returned in order
+func pipeline(tb testing.TB, conn net.Conn, reader *bufio.Reader, commands []string, depth int) []string {
+	replies := make([]string, 0, len(commands))
+	for start := 0; start < len(commands); start += depth {
+		end := min(start+depth, len(commands))
+		var batch []byte
+		for _, command := range commands[start:end] {
+			batch = append(batch, command...)
+		}
+		// written concurrently, as a real client would, so a server writing replies
+		// while commands are still sent doesn't deadlock the test
+		errCh := make(chan error, 1)
+		go func() {
+			_, err := conn.Write(batch)
+			errCh <- err
+		}()
+		_ = conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+		for range commands[start:end] {
+			reply, err := utils.ParseRESP(reader)
+			if err != nil {
+				tb.Fatal(err)
+			}
+			replies = append(replies, reply)
+		}
+		if err := <-errCh; err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return replies
+}
+
+func makeCommands(n int) []string {
+	commands := make([]string, n)
+	for i := range commands {
+		key := "key:" + strconv.Itoa(i)
+		commands[i] = fmt.Sprintf("*3\r\n$3\r\nSET\r\n$%d\r\n%s\r\n$5\r\nvalue\r\n", len(key), key)
+	}
+	return commands
+}
+
+func TestPipelinedReplies(t *testing.T) {
+	conn, err := net.Dial("tcp", listen(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	// large enough for the replies to be flushed before the input is drained
+	const n = 10000
+	commands := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		commands = append(commands, "RPUSH list "+strconv.Itoa(i)+"\r\n")
+	}
+	replies := pipeline(t, conn, reader, commands, n)
+	for i, reply := range replies {
+		if expected := ":" + strconv.Itoa(i+1) + "\r\n"; reply != expected {
+			t.Fatalf("reply %d: expected %q, got %q", i, expected, reply)
+		}
+	}
+
+	// a blocking command gets the replies of the commands before it written first
+	if _, err := conn.Write([]byte("PING\r\nBZPOPMIN zset 0.2\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if reply, err := utils.ParseRESP(reader); err != nil || reply != "+PONG\r\n" {
+		t.Fatalf("expected +PONG before the timeout, got %q, %v", reply, err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	if reply, err := utils.ParseRESP(reader); err != nil || reply != "*-1\r\n" {
+		t.Fatalf("expected a timeout, got %q, %v", reply, err)
+	}
+}
+
+func benchmarkPipeline(b *testing.B, depth int) {
+	conn, err := net.Dial("tcp", listen(b))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	commands := makeCommands(b.N)
+
+	b.ResetTimer()
+	pipeline(b, conn, reader, commands, depth)
+	b.StopTimer()
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "ops/s")
+}
+
+func BenchmarkPipelineDepth1(b *testing.B)    { benchmarkPipeline(b, 1) }
+func BenchmarkPipelineDepth16(b *testing.B)   { benchmarkPipeline(b, 16) }
+func BenchmarkPipelineDepth128(b *testing.B)  { benchmarkPipeline(b, 128) }
+func BenchmarkPipelineDepth1024(b *testing.B) { benchmarkPipeline(b, 1024) }
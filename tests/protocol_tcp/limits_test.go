@@ -0,0 +1,111 @@
+package protocoltcp
+
+import (
+	"godis/lib/utils"
+	"godis/tcp/server"
+	"godis/tests/internal/testconn"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// send writes command without waiting for the server to read all of it
+// a rejected request isn't read any further, so the write would never end
+func send(t *testing.T, c *testconn.Conn, command string) string {
+	t.Helper()
+	go func() {
+		_, _ = c.Write([]byte(command))
+	}()
+	_ = c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	reply, err := utils.ParseRESP(c.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reply
+}
+
+func expect(t *testing.T, c *testconn.Conn, command string, expected string) {
+	t.Helper()
+	if reply := send(t, c, command); reply != expected {
+		t.Fatalf("%.40q: expected %q, got %q", command, expected, reply)
+	}
+}
+
+func bulkHeader(length int) string {
+	return "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$" + strconv.Itoa(length) + "\r\n"
+}
+
+func TestRejectedRequests(t *testing.T) {
+	tests := []struct {
+		name     string
+		request  string
+		expected string
+	}{
+		{"huge bulk", bulkHeader(9999999999), "-ERR Protocol error: invalid bulk length\r\n"},
+		{"huge multibulk", "*2000000\r\n", "-ERR Protocol error: invalid multibulk length\r\n"},
+		{"invalid bulk header", "*1\r\n:1\r\n", "-ERR Protocol error: expected '$', got ':'\r\n"},
+		{"huge inline", strings.Repeat("a", 100*1024), "-ERR Protocol error: too big inline request\r\n"},
+		{"unbalanced quotes", "SET \"key value\r\n", "-ERR Protocol error: unbalanced quotes in request\r\n"},
+	}
+	handler := server.NewRedisHandler()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testconn.Connect(t, handler)
+			expect(t, c, "PING\r\n", "+PONG\r\n")
+			expect(t, c, tt.request, tt.expected)
+			if !c.Closed(time.Second) {
+				t.Fatal("expected the connection to be closed")
+			}
+		})
+	}
+	// other clients are served as usual
+	expect(t, testconn.Connect(t, handler), "PING\r\n", "+PONG\r\n")
+}
+
+func TestProtoMaxBulkLen(t *testing.T) {
+	handler := server.NewRedisHandler()
+	admin := testconn.Connect(t, handler)
+	expect(t, admin, "CONFIG GET proto-max-bulk-len\r\n", "*2\r\n$18\r\nproto-max-bulk-len\r\n$9\r\n536870912\r\n")
+	expect(t, admin, "CONFIG SET proto-max-bulk-len 1000\r\n",
+		"-ERR CONFIG SET failed (possibly related to argument 'proto-max-bulk-len') - argument must be between 1048576 and 9223372036854775807 inclusive\r\n")
+	expect(t, admin, "CONFIG SET proto-max-bulk-len lots\r\n",
+		"-ERR CONFIG SET failed (possibly related to argument 'proto-max-bulk-len') - argument must be a memory value\r\n")
+	expect(t, admin, "CONFIG SET proto-max-bulk-len 1mb\r\n", "+OK\r\n")
+
+	// the limit applies to connections opened before it was set
+	value := strings.Repeat("v", 1024*1024)
+	expect(t, admin, bulkHeader(len(value))+value+"\r\n", "+OK\r\n")
+	expect(t, admin, bulkHeader(len(value)+1), "-ERR Protocol error: invalid bulk length\r\n")
+	if !admin.Closed(time.Second) {
+		t.Fatal("expected the connection to be closed")
+	}
+}
+
+func TestClientQueryBufferLimit(t *testing.T) {
+	handler := server.NewRedisHandler()
+	admin, c := testconn.Connect(t, handler), testconn.Connect(t, handler)
+	expect(t, admin, "CONFIG SET client-query-buffer-limit 1mb\r\n", "+OK\r\n")
+	expect(t, admin, "CONFIG GET client-query-*\r\n", "*2\r\n$25\r\nclient-query-buffer-limit\r\n$7\r\n1048576\r\n")
+
+	// under the limit
+	value := strings.Repeat("v", 512*1024)
+	expect(t, c, bulkHeader(len(value))+value+"\r\n", "+OK\r\n")
+	// a command which can't fit is rejected from its header
+	expect(t, c, bulkHeader(2*1024*1024), "-ERR Protocol error: query buffer limit reached\r\n")
+	if !c.Closed(time.Second) {
+		t.Fatal("expected the connection to be closed")
+	}
+
+	// as is a command made of many small arguments
+	c = testconn.Connect(t, handler)
+	var request strings.Builder
+	request.WriteString("*100000\r\n$5\r\nRPUSH\r\n$4\r\nlist\r\n")
+	for i := 0; i < 100000-2; i++ {
+		request.WriteString("$10\r\n0123456789\r\n")
+	}
+	expect(t, c, request.String(), "-ERR Protocol error: query buffer limit reached\r\n")
+	if !c.Closed(time.Second) {
+		t.Fatal("expected the connection to be closed")
+	}
+}
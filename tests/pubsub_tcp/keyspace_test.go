@@ -0,0 +1,110 @@
+package pubsubtcp
+
+import (
+	"godis/tcp/server"
+	"godis/tests/internal/testconn"
+	"testing"
+)
+
+func TestKeyspaceNotifications(t *testing.T) {
+	handler := server.NewRedisHandler()
+	subscriber := testconn.Connect(t, handler)
+	defer subscriber.Close()
+	client := testconn.Connect(t, handler)
+	defer client.Close()
+
+	// disabled by default
+	expected := "*2\r\n$22\r\nnotify-keyspace-events\r\n$0\r\n\r\n"
+	if reply := client.Send(t, "CONFIG GET notify-keyspace-events\r\n"); reply != expected {
+		t.Fatalf("expected %q, got %q", expected, reply)
+	}
+	expected = "-ERR CONFIG SET failed (possibly related to argument 'notify-keyspace-events') - Invalid event class character. Use 'Ag$lshzxeKEt'.\r\n"
+	if reply := client.Send(t, "CONFIG SET notify-keyspace-events KQ\r\n"); reply != expected {
+		t.Fatalf("expected %q, got %q", expected, reply)
+	}
+
+	expected = "*3\r\n$10\r\npsubscribe\r\n$12\r\n__key*@0__:*\r\n:1\r\n"
+	if reply := subscriber.Send(t, "PSUBSCRIBE __key*@0__:*\r\n"); reply != expected {
+		t.Fatalf("expected %q, got %q", expected, reply)
+	}
+
+	steps := []struct {
+		name     string
+		config   string
+		command  string
+		reply    string
+		messages []string
+	}{
+		{
+			name:    "keyspace and keyevent of every class",
+			config:  "KEA",
+			command: "SET foo bar\r\n",
+			reply:   "+OK\r\n",
+			messages: []string{
+				"*4\r\n$8\r\npmessage\r\n$12\r\n__key*@0__:*\r\n$18\r\n__keyspace@0__:foo\r\n$3\r\nset\r\n",
+				"*4\r\n$8\r\npmessage\r\n$12\r\n__key*@0__:*\r\n$18\r\n__keyevent@0__:set\r\n$3\r\nfoo\r\n",
+			},
+		},
+		{
+			name:    "disabled class is skipped",
+			config:  "Kl",
+			command: "HSET hash field value\r\n",
+			reply:   ":1\r\n",
+		},
+		{
+			name:    "keyspace only",
+			config:  "Kl",
+			command: "LPUSH list a b\r\n",
+			reply:   ":2\r\n",
+			messages: []string{
+				"*4\r\n$8\r\npmessage\r\n$12\r\n__key*@0__:*\r\n$19\r\n__keyspace@0__:list\r\n$5\r\nlpush\r\n",
+			},
+		},
+		{
+			name:    "keyevent only",
+			config:  "Eg",
+			command: "DEL foo\r\n",
+			reply:   "+OK\r\n",
+			messages: []string{
+				"*4\r\n$8\r\npmessage\r\n$12\r\n__key*@0__:*\r\n$18\r\n__keyevent@0__:del\r\n$3\r\nfoo\r\n",
+			},
+		},
+		{
+			name:    "sorted sets",
+			config:  "Ez",
+			command: "ZADD zset 1 a\r\n",
+			reply:   ":1\r\n",
+			messages: []string{
+				"*4\r\n$8\r\npmessage\r\n$12\r\n__key*@0__:*\r\n$19\r\n__keyevent@0__:zadd\r\n$4\r\nzset\r\n",
+			},
+		},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			if reply := client.Send(t, "CONFIG SET notify-keyspace-events "+step.config+"\r\n"); reply != "+OK\r\n" {
+				t.Fatalf("unexpected CONFIG SET reply %q", reply)
+			}
+			if reply := client.Send(t, step.command); reply != step.reply {
+				t.Fatalf("expected %q, got %q", step.reply, reply)
+			}
+			for _, message := range step.messages {
+				if reply := subscriber.Receive(t); reply != message {
+					t.Errorf("expected %q, got %q", message, reply)
+				}
+			}
+		})
+	}
+
+	// nothing was published for the skipped HSET, the next message is the one of this PUBLISH
+	client.Send(t, "PUBLISH __keyspace@0__:marker done\r\n")
+	expected = "*4\r\n$8\r\npmessage\r\n$12\r\n__key*@0__:*\r\n$21\r\n__keyspace@0__:marker\r\n$4\r\ndone\r\n"
+	if reply := subscriber.Receive(t); reply != expected {
+		t.Errorf("expected %q, got %q", expected, reply)
+	}
+
+	client.Send(t, "CONFIG SET notify-keyspace-events KEA\r\n")
+	expected = "*2\r\n$22\r\nnotify-keyspace-events\r\n$3\r\nAKE\r\n"
+	if reply := client.Send(t, "CONFIG GET notify-*\r\n"); reply != expected {
+		t.Errorf("expected %q, got %q", expected, reply)
+	}
+}
@@ -0,0 +1,173 @@
+package pubsubtcp
+
+import (
+	"godis/lib/utils"
+	"godis/tcp/server"
+	"godis/tests/internal/testconn"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSubscriberMode(t *testing.T) {
+	handler := server.NewRedisHandler()
+	subscriber := testconn.Connect(t, handler)
+	defer subscriber.Close()
+	publisher := testconn.Connect(t, handler)
+	defer publisher.Close()
+
+	steps := []struct {
+		name     string
+		conn     *testconn.Conn
+		command  string
+		expected string
+	}{
+		{
+			name:     "subscribe",
+			conn:     subscriber,
+			command:  "SUBSCRIBE news\r\n",
+			expected: "*3\r\n$9\r\nsubscribe\r\n$4\r\nnews\r\n:1\r\n",
+		},
+		{
+			name:     "psubscribe",
+			conn:     subscriber,
+			command:  "PSUBSCRIBE ne*\r\n",
+			expected: "*3\r\n$10\r\npsubscribe\r\n$3\r\nne*\r\n:2\r\n",
+		},
+		{
+			name:     "other commands are rejected",
+			conn:     subscriber,
+			command:  "GET key\r\n",
+			expected: "-ERR Can't execute 'get': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context\r\n",
+		},
+		{
+			name:     "ping in subscriber mode",
+			conn:     subscriber,
+			command:  "PING\r\n",
+			expected: "*2\r\n$4\r\npong\r\n$0\r\n\r\n",
+		},
+		{
+			name:     "publish",
+			conn:     publisher,
+			command:  "PUBLISH news hello\r\n",
+			expected: ":2\r\n",
+		},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			if actual := step.conn.Send(t, step.command); actual != step.expected {
+				t.Errorf("expected %q, got %q", step.expected, actual)
+			}
+		})
+	}
+
+	expected := "*3\r\n$7\r\nmessage\r\n$4\r\nnews\r\n$5\r\nhello\r\n"
+	if actual := subscriber.Receive(t); actual != expected {
+		t.Errorf("expected %q, got %q", expected, actual)
+	}
+	expected = "*4\r\n$8\r\npmessage\r\n$3\r\nne*\r\n$4\r\nnews\r\n$5\r\nhello\r\n"
+	if actual := subscriber.Receive(t); actual != expected {
+		t.Errorf("expected %q, got %q", expected, actual)
+	}
+
+	// leaving subscriber mode
+	subscriber.Send(t, "UNSUBSCRIBE\r\n")
+	subscriber.Send(t, "PUNSUBSCRIBE\r\n")
+	if actual := subscriber.Send(t, "PING\r\n"); actual != "+PONG\r\n" {
+		t.Errorf("expected PONG, got %q", actual)
+	}
+}
+
+func TestSlowSubscriberDoesNotBlockPublisher(t *testing.T) {
+	handler := server.NewRedisHandler()
+	subscriber := testconn.Connect(t, handler)
+	defer subscriber.Close()
+	publisher := testconn.Connect(t, handler)
+	defer publisher.Close()
+
+	subscriber.Send(t, "SUBSCRIBE news\r\n")
+	// the subscriber never reads, its queue fills up and it gets disconnected
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 5000; i++ {
+			if _, err := publisher.Write([]byte("PUBLISH news hello\r\n")); err != nil {
+				t.Error(err)
+				return
+			}
+			if _, err := utils.ParseRESP(publisher.Reader); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("publisher was blocked by a slow subscriber")
+	}
+	if actual := publisher.Send(t, "PUBSUB NUMSUB news\r\n"); actual != "*2\r\n$4\r\nnews\r\n:0\r\n" {
+		t.Errorf("expected the slow subscriber to be gone, got %q", actual)
+	}
+}
+
+func TestKillStuckSubscriber(t *testing.T) {
+	handler := server.NewRedisHandler()
+	defer handler.Close()
+	publisher := testconn.Connect(t, handler)
+	defer publisher.Close()
+	publisher.Send(t, "CONFIG SET client-close-timeout 1\r\n")
+	subscriber := testconn.Connect(t, handler)
+	defer subscriber.Close()
+
+	id := strings.TrimSuffix(strings.TrimPrefix(subscriber.Send(t, "CLIENT ID\r\n"), ":"), "\r\n")
+	subscriber.Send(t, "SUBSCRIBE news\r\n")
+	// the subscriber never reads the message, writing it blocks
+	publisher.Send(t, "PUBLISH news hello\r\n")
+
+	done := make(chan string)
+	go func() {
+		_ = publisher.SetReadDeadline(time.Time{})
+		_, _ = publisher.Write([]byte("CLIENT KILL ID " + id + "\r\n"))
+		reply, _ := utils.ParseRESP(publisher.Reader)
+		done <- reply
+	}()
+	select {
+	case reply := <-done:
+		if reply != ":1\r\n" {
+			t.Errorf("expected the subscriber to be killed, got %q", reply)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("CLIENT KILL was blocked by a subscriber stuck writing")
+	}
+}
+
+func TestSubscriberResetAndQuit(t *testing.T) {
+	handler := server.NewRedisHandler()
+	defer handler.Close()
+	subscriber := testconn.Connect(t, handler)
+	defer subscriber.Close()
+	publisher := testconn.Connect(t, handler)
+	defer publisher.Close()
+
+	subscriber.Send(t, "CLIENT SETNAME sub\r\n")
+	subscriber.Send(t, "SUBSCRIBE news\r\n")
+	if actual := subscriber.Send(t, "RESET\r\n"); actual != "+RESET\r\n" {
+		t.Fatalf("expected RESET, got %q", actual)
+	}
+	// the subscriptions and the name are gone
+	if actual := publisher.Send(t, "PUBLISH news hello\r\n"); actual != ":0\r\n" {
+		t.Errorf("expected no subscriber, got %q", actual)
+	}
+	if actual := subscriber.Send(t, "CLIENT GETNAME\r\n"); actual != "$-1\r\n" {
+		t.Errorf("expected no name, got %q", actual)
+	}
+
+	subscriber.Send(t, "SUBSCRIBE news\r\n")
+	if actual := subscriber.Send(t, "QUIT\r\n"); actual != "+OK\r\n" {
+		t.Fatalf("expected OK, got %q", actual)
+	}
+	if !subscriber.Closed(time.Second) {
+		t.Error("expected the connection to be closed")
+	}
+}
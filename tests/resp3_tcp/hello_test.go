@@ -0,0 +1,140 @@
+package resp3tcp
+
+import (
+	"godis/lib/utils"
+	"godis/tcp/server"
+	"godis/tests/internal/testconn"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHello(t *testing.T) {
+	handler := server.NewRedisHandler()
+	conn := testconn.Connect(t, handler)
+	defer conn.Close()
+
+	tests := []struct {
+		name     string
+		command  string
+		expected string
+	}{
+		{
+			name:     "unsupported version",
+			command:  "HELLO 4\r\n",
+			expected: "-NOPROTO unsupported protocol version\r\n",
+		},
+		{
+			name:     "invalid name",
+			command:  "*4\r\n$5\r\nHELLO\r\n$1\r\n3\r\n$7\r\nSETNAME\r\n$9\r\nmy client\r\n",
+			expected: "-ERR Client names cannot contain spaces, newlines or special characters.\r\n",
+		},
+		{
+			name:     "RESP2 is kept after an error",
+			command:  "HSET hash field value\r\n",
+			expected: ":1\r\n",
+		},
+		{
+			name:     "RESP2 flat list",
+			command:  "HGETALL hash\r\n",
+			expected: "*2\r\n$5\r\nfield\r\n$5\r\nvalue\r\n",
+		},
+		{
+			name:     "switch to RESP3",
+			command:  "HELLO 3 AUTH default secret SETNAME worker\r\n",
+			expected: "%7\r\n$6\r\nserver\r\n$5\r\nredis\r\n$7\r\nversion\r\n$5\r\n7.2.0\r\n$5\r\nproto\r\n:3\r\n",
+		},
+		{
+			name:     "map",
+			command:  "HGETALL hash\r\n",
+			expected: "%1\r\n$5\r\nfield\r\n$5\r\nvalue\r\n",
+		},
+		{
+			name:     "double",
+			command:  "ZADD zset 1.5 a\r\n",
+			expected: ":1\r\n",
+		},
+		{
+			name:     "zscore",
+			command:  "ZSCORE zset a\r\n",
+			expected: ",1.5\r\n",
+		},
+		{
+			name:     "null",
+			command:  "ZSCORE zset b\r\n",
+			expected: "_\r\n",
+		},
+		{
+			name:     "set",
+			command:  "SADD set a\r\n",
+			expected: ":1\r\n",
+		},
+		{
+			name:     "smembers",
+			command:  "SMEMBERS set\r\n",
+			expected: "~1\r\n$1\r\na\r\n",
+		},
+		{
+			name:     "back to RESP2",
+			command:  "HELLO 2\r\n",
+			expected: "*14\r\n$6\r\nserver\r\n$5\r\nredis\r\n",
+		},
+		{
+			name:     "RESP2 bulk score",
+			command:  "ZSCORE zset a\r\n",
+			expected: "$3\r\n1.5\r\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the whole HELLO reply includes the id of the connection, only its start is compared
+			if reply := conn.Send(t, tt.command); !strings.HasPrefix(reply, tt.expected) {
+				t.Errorf("expected %q, got %q", tt.expected, reply)
+			}
+		})
+	}
+}
+
+func TestResp3PubSub(t *testing.T) {
+	handler := server.NewRedisHandler()
+	subscriber := testconn.Connect(t, handler)
+	defer subscriber.Close()
+	publisher := testconn.Connect(t, handler)
+	defer publisher.Close()
+
+	subscriber.Send(t, "HELLO 3\r\n")
+	expected := ">3\r\n$9\r\nsubscribe\r\n$4\r\nnews\r\n:1\r\n"
+	if reply := subscriber.Send(t, "SUBSCRIBE news\r\n"); reply != expected {
+		t.Fatalf("expected %q, got %q", expected, reply)
+	}
+	// RESP3 subscribers aren't restricted to the subscribe commands
+	if reply := subscriber.Send(t, "SET key value\r\n"); reply != "+OK\r\n" {
+		t.Fatalf("expected +OK, got %q", reply)
+	}
+
+	// a RESP2 subscriber of the same channel still gets a list
+	publisher.Send(t, "SUBSCRIBE news\r\n")
+	other := testconn.Connect(t, handler)
+	defer other.Close()
+	if reply := other.Send(t, "PUBLISH news hello\r\n"); reply != ":2\r\n" {
+		t.Fatalf("expected 2 receivers, got %q", reply)
+	}
+	_ = subscriber.SetReadDeadline(time.Now().Add(time.Second))
+	reply, err := utils.ParseRESP(subscriber.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = ">3\r\n$7\r\nmessage\r\n$4\r\nnews\r\n$5\r\nhello\r\n"
+	if reply != expected {
+		t.Errorf("expected %q, got %q", expected, reply)
+	}
+	_ = publisher.SetReadDeadline(time.Now().Add(time.Second))
+	reply, err = utils.ParseRESP(publisher.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = "*3\r\n$7\r\nmessage\r\n$4\r\nnews\r\n$5\r\nhello\r\n"
+	if reply != expected {
+		t.Errorf("expected %q, got %q", expected, reply)
+	}
+}
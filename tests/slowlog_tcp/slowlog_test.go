@@ -0,0 +1,95 @@
+package slowlogtcp
+
+import (
+	"godis/redis/parser"
+	"godis/tcp/server"
+	"godis/tests/internal/testconn"
+	"io"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func init() {
+	// the commands log a lot
+	log.SetOutput(io.Discard)
+}
+
+// loggedArgs returns the arguments of the entries of SLOWLOG GET, newest first
+func loggedArgs(t *testing.T, reply any) [][]any {
+	t.Helper()
+	entries, ok := reply.([]any)
+	if !ok {
+		t.Fatalf("unexpected reply %#v", reply)
+	}
+	args := make([][]any, len(entries))
+	for i, entry := range entries {
+		fields := entry.([]any)
+		if len(fields) != 6 {
+			t.Fatalf("unexpected entry %#v", entry)
+		}
+		args[i] = fields[3].([]any)
+	}
+	return args
+}
+
+func TestSlowLog(t *testing.T) {
+	handler := server.NewRedisHandler()
+	defer handler.Close()
+	c := testconn.Connect(t, handler)
+
+	c.Do(t, "HELLO", "2", "SETNAME", "tester")
+	c.Do(t, "CONFIG", "SET", "slowlog-log-slower-than", "0", "slowlog-max-len", "3")
+	c.Do(t, "SET", "a", "1")
+	c.Do(t, "GET", "a")
+	c.Do(t, "AUTH", "secret")
+
+	// AUTH isn't logged, SLOWLOG LEN is logged after replying
+	if n := c.Do(t, "SLOWLOG", "LEN"); n != int64(3) {
+		t.Fatalf("expected 3 entries, got %v", n)
+	}
+	reply := c.Do(t, "SLOWLOG", "GET")
+	expected := [][]any{{"SLOWLOG", "LEN"}, {"GET", "a"}, {"SET", "a", "1"}}
+	if args := loggedArgs(t, reply); !reflect.DeepEqual(args, expected) {
+		t.Errorf("expected %v, got %v", expected, args)
+	}
+	entry := reply.([]any)[0].([]any)
+	if entry[4] != "pipe" || entry[5] != "tester" {
+		t.Errorf("unexpected client %v %v", entry[4], entry[5])
+	}
+	ids := []any{reply.([]any)[0].([]any)[0], reply.([]any)[2].([]any)[0]}
+	if ids[0].(int64) != ids[1].(int64)+2 {
+		t.Errorf("unexpected ids %v", ids)
+	}
+
+	if args := loggedArgs(t, c.Do(t, "SLOWLOG", "GET", "1")); !reflect.DeepEqual(args, [][]any{{"SLOWLOG", "GET"}}) {
+		t.Errorf("unexpected latest entry %v", args)
+	}
+	if reply := c.Do(t, "SLOWLOG", "GET", "-2"); reply != parser.ReplyError("ERR count should be greater than or equal to -1") {
+		t.Errorf("unexpected reply %v", reply)
+	}
+
+	long := strings.Repeat("x", 200)
+	manyArgs := []string{"DEL"}
+	for i := 0; i < 40; i++ {
+		manyArgs = append(manyArgs, long)
+	}
+	c.Do(t, manyArgs...)
+	args := loggedArgs(t, c.Do(t, "SLOWLOG", "GET", "1"))
+	if len(args) != 1 || len(args[0]) != 32 {
+		t.Fatalf("unexpected truncated entry %v", args)
+	}
+	if args[0][1] != strings.Repeat("x", 128)+"... (72 more bytes)" || args[0][31] != "... (10 more arguments)" {
+		t.Errorf("unexpected truncated arguments %q %q", args[0][1], args[0][31])
+	}
+
+	if reply := c.Do(t, "SLOWLOG", "RESET"); reply != "OK" {
+		t.Errorf("unexpected reply %v", reply)
+	}
+	// the command disabling the slow log isn't logged either
+	c.Do(t, "CONFIG", "SET", "slowlog-log-slower-than", "-1")
+	if n := c.Do(t, "SLOWLOG", "LEN"); n != int64(1) {
+		t.Errorf("expected only SLOWLOG RESET to be logged, got %v", n)
+	}
+}
@@ -0,0 +1,107 @@
+package timeouttcp
+
+import (
+	"godis/redis/parser"
+	"godis/tcp/server"
+	"godis/tests/internal/testconn"
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+// sweepInterval is how often the server closes the idle clients
+const sweepInterval = time.Second
+
+func init() {
+	// the commands log a lot
+	log.SetOutput(io.Discard)
+}
+
+func TestIdleTimeout(t *testing.T) {
+	handler := server.NewRedisHandler()
+	defer handler.Close()
+	c := testconn.Connect(t, handler)
+
+	if reply := c.Do(t, "CONFIG", "GET", "timeout"); len(reply.([]any)) != 2 || reply.([]any)[1] != "0" {
+		t.Fatalf("expected timeout 0 by default, got %v", reply)
+	}
+	if reply := c.Do(t, "CONFIG", "SET", "timeout", "1"); reply != "OK" {
+		t.Fatalf("expected OK, got %v", reply)
+	}
+
+	idle := testconn.Connect(t, handler)
+	subscriber := testconn.Connect(t, handler)
+	subscriber.Do(t, "SUBSCRIBE", "news")
+	monitor := testconn.Connect(t, handler)
+	if reply := monitor.Do(t, "MONITOR"); reply != "OK" {
+		t.Fatalf("expected OK, got %v", reply)
+	}
+	blocked := testconn.Connect(t, handler)
+	blocked.SendArgs(t, "BZPOPMIN", "zset", "0")
+
+	// idle for longer than timeout, it's closed by the next sweep
+	if !idle.Closed(time.Second + 2*sweepInterval) {
+		t.Fatal("expected the idle client to be closed")
+	}
+	// the client setting timeout was idle for longer, it's closed by the same sweep or an earlier one,
+	// which may still be closing it
+	if !c.Closed(sweepInterval) {
+		t.Error("expected the client setting timeout to be closed")
+	}
+	// the others are left by a sweep more
+	if subscriber.Closed(sweepInterval) {
+		t.Error("expected the subscriber to stay connected")
+	}
+	if blocked.Closed(0) {
+		t.Error("expected the blocked client to stay connected")
+	}
+	c = testconn.Connect(t, handler)
+	if reply := c.Do(t, "CONFIG", "SET", "timeout", "0"); reply != "OK" {
+		t.Fatalf("expected OK, got %v", reply)
+	}
+	if reply := c.Do(t, "ZADD", "zset", "1", "a"); reply != int64(1) {
+		t.Fatalf("expected 1, got %v", reply)
+	}
+	if reply, err := blocked.Next(time.Second); err != nil || len(reply.([]any)) != 3 {
+		t.Errorf("expected the blocked client to pop a, got %v, %v", reply, err)
+	}
+	// the monitor is shown ZADD and BZPOPMIN
+	for i := 0; i < 2; i++ {
+		if _, err := monitor.Next(time.Second); err != nil {
+			t.Fatalf("expected the monitor to stay connected, got %v", err)
+		}
+	}
+}
+
+func TestMaxClients(t *testing.T) {
+	handler := server.NewRedisHandler()
+	defer handler.Close()
+	c := testconn.Connect(t, handler)
+
+	if reply := c.Do(t, "CONFIG", "SET", "maxclients", "0"); reply == "OK" {
+		t.Fatal("expected maxclients 0 to be refused")
+	}
+	if reply := c.Do(t, "CONFIG", "SET", "maxclients", "1"); reply != "OK" {
+		t.Fatalf("expected OK, got %v", reply)
+	}
+
+	rejected := testconn.Connect(t, handler)
+	if reply, err := rejected.Next(time.Second); reply != parser.ReplyError("ERR max number of clients reached") {
+		t.Fatalf("expected the client to be rejected, got %v, %v", reply, err)
+	}
+	if !rejected.Closed(time.Second) {
+		t.Error("expected the rejected client to be closed")
+	}
+	if reply := c.Do(t, "PING"); reply != "PONG" {
+		t.Errorf("expected PONG, got %v", reply)
+	}
+
+	if reply := c.Do(t, "CONFIG", "SET", "maxclients", "2"); reply != "OK" {
+		t.Fatalf("expected OK, got %v", reply)
+	}
+	accepted := testconn.Connect(t, handler)
+	if reply := accepted.Do(t, "PING"); reply != "PONG" {
+		t.Errorf("expected PONG, got %v", reply)
+	}
+}
@@ -0,0 +1,112 @@
+package trackingtcp
+
+import (
+	"godis/tcp/server"
+	"godis/tests/internal/testconn"
+	"strings"
+	"testing"
+)
+
+func TestTrackingDefaultMode(t *testing.T) {
+	handler := server.NewRedisHandler()
+	cache, writer := testconn.Connect(t, handler), testconn.Connect(t, handler)
+
+	if reply := cache.Send(t, "HELLO 3\r\n"); !strings.HasPrefix(reply, "%7\r\n") {
+		t.Fatalf("unexpected HELLO reply %q", reply)
+	}
+	cache.Expect(t, "CLIENT TRACKING ON NOLOOP\r\n", "+OK\r\n")
+	cache.Expect(t, "CLIENT GETREDIR\r\n", ":0\r\n")
+	cache.Expect(t, "GET foo\r\n", "_\r\n")
+	cache.Expect(t, "HGET hash field\r\n", "_\r\n")
+
+	writer.Expect(t, "SET foo bar\r\n", "+OK\r\n")
+	expected := ">2\r\n$10\r\ninvalidate\r\n*1\r\n$3\r\nfoo\r\n"
+	if reply := cache.Receive(t); reply != expected {
+		t.Fatalf("expected %q, got %q", expected, reply)
+	}
+	// foo wasn't read again, there is nothing more to invalidate
+	writer.Expect(t, "SET foo baz\r\n", "+OK\r\n")
+	// NOLOOP: the writes of the client itself aren't reported to it
+	cache.Expect(t, "HSET hash field value\r\n", ":1\r\n")
+	cache.Expect(t, "PING\r\n", "+PONG\r\n")
+
+	cache.Expect(t, "GET foo\r\n", "$3\r\nbaz\r\n")
+	writer.Expect(t, "DEL foo hash\r\n", ":2\r\n")
+	expected = ">2\r\n$10\r\ninvalidate\r\n*1\r\n$3\r\nfoo\r\n"
+	if reply := cache.Receive(t); reply != expected {
+		t.Fatalf("expected %q, got %q", expected, reply)
+	}
+
+	cache.Expect(t, "CLIENT TRACKING OFF\r\n", "+OK\r\n")
+	cache.Expect(t, "CLIENT GETREDIR\r\n", ":-1\r\n")
+}
+
+func TestTrackingRedirectBroadcast(t *testing.T) {
+	handler := server.NewRedisHandler()
+	receiver, cache, writer := testconn.Connect(t, handler), testconn.Connect(t, handler), testconn.Connect(t, handler)
+
+	id := receiver.Send(t, "CLIENT ID\r\n")
+	receiver.Expect(t, "SUBSCRIBE __redis__:invalidate\r\n", "*3\r\n$9\r\nsubscribe\r\n$20\r\n__redis__:invalidate\r\n:1\r\n")
+
+	cache.Expect(t, "CLIENT TRACKING ON REDIRECT 12345\r\n", "-ERR The client ID you want redirect to does not exist\r\n")
+	cache.Expect(t, "CLIENT TRACKING ON PREFIX user:\r\n", "-ERR PREFIX option requires BCAST mode to be enabled\r\n")
+	cache.Expect(t, "CLIENT TRACKING ON BCAST PREFIX user: PREFIX user:1\r\n",
+		"-ERR Prefix 'user:1' overlaps with another provided prefix 'user:'. Prefixes for a single client must not overlap.\r\n")
+	cache.Expect(t, "CLIENT TRACKING ON BCAST PREFIX user: REDIRECT "+strings.Trim(id, ":\r\n")+"\r\n", "+OK\r\n")
+	cache.Expect(t, "CLIENT GETREDIR\r\n", id)
+
+	// keys are broadcast whether they were read or not
+	writer.Expect(t, "SET other 1\r\n", "+OK\r\n")
+	writer.Expect(t, "SADD user:1 a\r\n", ":1\r\n")
+	expected := "*3\r\n$7\r\nmessage\r\n$20\r\n__redis__:invalidate\r\n*1\r\n$6\r\nuser:1\r\n"
+	if reply := receiver.Receive(t); reply != expected {
+		t.Fatalf("expected %q, got %q", expected, reply)
+	}
+}
+
+func TestTrackingRedirectNeedsInvalidateChannel(t *testing.T) {
+	handler := server.NewRedisHandler()
+	receiver, cache, writer := testconn.Connect(t, handler), testconn.Connect(t, handler), testconn.Connect(t, handler)
+
+	id := strings.Trim(receiver.Send(t, "CLIENT ID\r\n"), ":\r\n")
+	receiver.Send(t, "SUBSCRIBE news\r\n")
+	cache.Expect(t, "CLIENT TRACKING ON BCAST REDIRECT "+id+"\r\n", "+OK\r\n")
+
+	// subscribed to another channel, the receiver gets no invalidation
+	writer.Expect(t, "SET a 1\r\n", "+OK\r\n")
+	writer.Expect(t, "PUBLISH news hello\r\n", ":1\r\n")
+	expected := "*3\r\n$7\r\nmessage\r\n$4\r\nnews\r\n$5\r\nhello\r\n"
+	if reply := receiver.Receive(t); reply != expected {
+		t.Fatalf("expected %q, got %q", expected, reply)
+	}
+
+	receiver.Send(t, "SUBSCRIBE __redis__:invalidate\r\n")
+	writer.Expect(t, "SET a 2\r\n", "+OK\r\n")
+	expected = "*3\r\n$7\r\nmessage\r\n$20\r\n__redis__:invalidate\r\n*1\r\n$1\r\na\r\n"
+	if reply := receiver.Receive(t); reply != expected {
+		t.Fatalf("expected %q, got %q", expected, reply)
+	}
+}
+
+func TestTrackingOptIn(t *testing.T) {
+	handler := server.NewRedisHandler()
+	cache, writer := testconn.Connect(t, handler), testconn.Connect(t, handler)
+
+	cache.Send(t, "HELLO 3\r\n")
+	cache.Expect(t, "CLIENT CACHING YES\r\n", "-ERR CLIENT CACHING can be called only when the client is in tracking mode with OPTIN or OPTOUT mode enabled\r\n")
+	cache.Expect(t, "CLIENT TRACKING ON OPTIN\r\n", "+OK\r\n")
+	cache.Expect(t, "CLIENT CACHING NO\r\n", "-ERR CLIENT CACHING NO is only valid when tracking is enabled in OPTOUT mode.\r\n")
+	cache.Expect(t, "SMEMBERS notcached\r\n", "~0\r\n")
+	cache.Expect(t, "CLIENT CACHING YES\r\n", "+OK\r\n")
+	cache.Expect(t, "SMEMBERS cached\r\n", "~0\r\n")
+	// CLIENT CACHING only applies to the next command
+	cache.Expect(t, "LLEN notcached2\r\n", ":0\r\n")
+
+	writer.Expect(t, "SADD notcached a\r\n", ":1\r\n")
+	writer.Expect(t, "RPUSH notcached2 a\r\n", ":1\r\n")
+	writer.Expect(t, "SADD cached a\r\n", ":1\r\n")
+	expected := ">2\r\n$10\r\ninvalidate\r\n*1\r\n$6\r\ncached\r\n"
+	if reply := cache.Receive(t); reply != expected {
+		t.Fatalf("expected %q, got %q", expected, reply)
+	}
+}
@@ -0,0 +1,220 @@
+package tracking
+
+import (
+	"godis/interfaces"
+	"godis/redis/protocol"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// InvalidateChannel is the channel RESP2 clients subscribe to when they get invalidations through a redirection
+const InvalidateChannel = "__redis__:invalidate"
+
+// values of client.caching, set by CLIENT CACHING for the next command only
+const (
+	cachingUnset = iota
+	cachingYes
+	cachingNo
+)
+
+// client is the tracking state of a connection
+type client struct {
+	conn     interfaces.Connection
+	redirect int64
+	bcast    bool
+	prefixes []string
+	optIn    bool
+	optOut   bool
+	noLoop   bool
+	caching  int
+}
+
+// Lookup returns the connection with the given id, used to find the target of REDIRECT
+type Lookup func(id int64) (interfaces.Connection, bool)
+
+// Table remembers which keys clients may have cached, and sends invalidation messages when they're modified
+// like in Redis, clients aren't removed from the keys they read when they stop tracking,
+// ids of clients which aren't tracking anymore are skipped and dropped on the next invalidation
+type Table struct {
+	mu sync.Mutex
+	// tracking clients by id
+	clients map[int64]*client
+	// ids of the clients which read each key, in the default mode
+	keys map[string]map[int64]struct{}
+	// ids of the clients in BCAST mode by prefix, "" matches every key
+	prefixes map[string]map[int64]struct{}
+	// number of tracking clients, commands skip the table while it's zero
+	count  atomic.Int32
+	lookup Lookup
+}
+
+func MakeTable(lookup Lookup) *Table {
+	return &Table{
+		clients:  make(map[int64]*client),
+		keys:     make(map[string]map[int64]struct{}),
+		prefixes: make(map[string]map[int64]struct{}),
+		lookup:   lookup,
+	}
+}
+
+// Enabled reports whether any client is tracking keys
+func (t *Table) Enabled() bool {
+	return t.count.Load() > 0
+}
+
+// Clients returns the number of clients tracking keys
+func (t *Table) Clients() int {
+	return int(t.count.Load())
+}
+
+func (t *Table) enable(c *client) {
+	t.disable(c.conn.ID())
+	t.clients[c.conn.ID()] = c
+	t.count.Add(1)
+	if !c.bcast {
+		return
+	}
+	prefixes := c.prefixes
+	if len(prefixes) == 0 {
+		prefixes = []string{""}
+	}
+	for _, prefix := range prefixes {
+		ids, ok := t.prefixes[prefix]
+		if !ok {
+			ids = make(map[int64]struct{})
+			t.prefixes[prefix] = ids
+		}
+		ids[c.conn.ID()] = struct{}{}
+	}
+}
+
+func (t *Table) disable(id int64) {
+	c, ok := t.clients[id]
+	if !ok {
+		return
+	}
+	delete(t.clients, id)
+	t.count.Add(-1)
+	for prefix, ids := range t.prefixes {
+		delete(ids, id)
+		if len(ids) == 0 {
+			delete(t.prefixes, prefix)
+		}
+	}
+	c.prefixes = nil
+}
+
+// Disable stops tracking for conn, called when it's closed too
+func (t *Table) Disable(conn interfaces.Connection) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.disable(conn.ID())
+}
+
+// Read remembers that conn read keys, unless it doesn't track them in its mode
+func (t *Table) Read(conn interfaces.Connection, keys [][]byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c, ok := t.clients[conn.ID()]
+	if !ok || c.bcast {
+		return
+	}
+	if c.optIn && c.caching != cachingYes || c.optOut && c.caching == cachingNo {
+		return
+	}
+	for _, key := range keys {
+		ids, ok := t.keys[string(key)]
+		if !ok {
+			ids = make(map[int64]struct{})
+			t.keys[string(key)] = ids
+		}
+		ids[c.conn.ID()] = struct{}{}
+	}
+}
+
+// ResetCaching forgets the last CLIENT CACHING of conn, which only applies to the command after it
+func (t *Table) ResetCaching(conn interfaces.Connection) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if c, ok := t.clients[conn.ID()]; ok {
+		c.caching = cachingUnset
+	}
+}
+
+// Invalidate sends invalidation messages about keys modified by conn to the clients which may have cached them
+// the clients which read a key have to read it again to get the next invalidation
+func (t *Table) Invalidate(conn interfaces.Connection, keys [][]byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	invalidated := make(map[int64][][]byte)
+	for _, key := range keys {
+		for id := range t.keys[string(key)] {
+			invalidated[id] = append(invalidated[id], key)
+		}
+		delete(t.keys, string(key))
+		for prefix, ids := range t.prefixes {
+			if !strings.HasPrefix(string(key), prefix) {
+				continue
+			}
+			for id := range ids {
+				invalidated[id] = append(invalidated[id], key)
+			}
+		}
+	}
+	for id, keys := range invalidated {
+		c, ok := t.clients[id]
+		if !ok || c.noLoop && id == conn.ID() {
+			continue
+		}
+		t.send(c, keys)
+	}
+}
+
+// send delivers an invalidation message to c, or to the client it redirects to
+func (t *Table) send(c *client, keys [][]byte) {
+	target := c.conn
+	if c.redirect != 0 {
+		var ok bool
+		target, ok = t.lookup(c.redirect)
+		if !ok {
+			if c.conn.ProtocolVersion() == protocol.RESP3 {
+				c.conn.Push(protocol.MakePushReply([]protocol.Reply{
+					protocol.MakeBulkReply([]byte("tracking-redir-broken")),
+					protocol.MakeIntReply(c.redirect),
+				}).ToResp3Bytes())
+			}
+			return
+		}
+	}
+
+	if target.ProtocolVersion() == protocol.RESP3 {
+		target.Push(protocol.MakePushReply([]protocol.Reply{
+			protocol.MakeBulkReply([]byte("invalidate")),
+			protocol.MakeMultiBulkReply(keys),
+		}).ToResp3Bytes())
+		return
+	}
+	// RESP2 clients can't receive out of band data, the messages go to the redirection target
+	// if it subscribed to the invalidation channel
+	if !target.Subscribed(InvalidateChannel) {
+		return
+	}
+	target.Push(protocol.MakeMultiRawReply([]protocol.Reply{
+		protocol.MakeBulkReply([]byte("message")),
+		protocol.MakeBulkReply([]byte(InvalidateChannel)),
+		protocol.MakeMultiBulkReply(keys),
+	}).ToBytes())
+}
+
+// Redirect returns the id invalidations of conn are sent to, 0 without redirection and -1 if it isn't tracking
+func (t *Table) Redirect(conn interfaces.Connection) int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c, ok := t.clients[conn.ID()]
+	if !ok {
+		return -1
+	}
+	return c.redirect
+}
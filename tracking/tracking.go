@@ -0,0 +1,129 @@
+package tracking
+
+import (
+	"godis/interfaces"
+	"godis/redis/protocol"
+	"strconv"
+	"strings"
+)
+
+// Tracking turns client side caching on or off for conn
+// `CLIENT TRACKING ON|OFF [REDIRECT client-id] [PREFIX prefix [PREFIX prefix ...]] [BCAST] [OPTIN] [OPTOUT] [NOLOOP]`
+func Tracking(table *Table, conn interfaces.Connection, args [][]byte) protocol.Reply {
+	if len(args) < 1 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for 'client|tracking' command")
+	}
+
+	var on bool
+	switch strings.ToUpper(string(args[0])) {
+	case "ON":
+		on = true
+	case "OFF":
+		on = false
+	default:
+		return protocol.MakeErrReply("ERR syntax error")
+	}
+
+	c := &client{conn: conn}
+	for i := 1; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "REDIRECT":
+			if i+1 >= len(args) {
+				return protocol.MakeErrReply("ERR syntax error")
+			}
+			id, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil {
+				return protocol.MakeErrReply("ERR value is not an integer or out of range")
+			}
+			c.redirect = id
+			i++
+		case "PREFIX":
+			if i+1 >= len(args) {
+				return protocol.MakeErrReply("ERR syntax error")
+			}
+			c.prefixes = append(c.prefixes, string(args[i+1]))
+			i++
+		case "BCAST":
+			c.bcast = true
+		case "OPTIN":
+			c.optIn = true
+		case "OPTOUT":
+			c.optOut = true
+		case "NOLOOP":
+			c.noLoop = true
+		default:
+			return protocol.MakeErrReply("ERR syntax error")
+		}
+	}
+
+	table.mu.Lock()
+	defer table.mu.Unlock()
+	if !on {
+		table.disable(conn.ID())
+		return protocol.MakeOkReply()
+	}
+
+	if len(c.prefixes) > 0 && !c.bcast {
+		return protocol.MakeErrReply("ERR PREFIX option requires BCAST mode to be enabled")
+	}
+	if c.optIn && c.optOut {
+		return protocol.MakeErrReply("ERR You can't use both OPTIN and OPTOUT options.")
+	}
+	if c.bcast && (c.optIn || c.optOut) {
+		return protocol.MakeErrReply("ERR OPTIN and OPTOUT are not compatible with BCAST")
+	}
+	for i, prefix := range c.prefixes {
+		for _, other := range c.prefixes[i+1:] {
+			if strings.HasPrefix(prefix, other) || strings.HasPrefix(other, prefix) {
+				return protocol.MakeErrReply("ERR Prefix '" + other + "' overlaps with another provided prefix '" + prefix + "'. Prefixes for a single client must not overlap.")
+			}
+		}
+	}
+	if old, ok := table.clients[conn.ID()]; ok && old.bcast != c.bcast {
+		return protocol.MakeErrReply("ERR You can't switch BCAST mode on/off before disabling tracking for this client, and then re-enabling it with a different mode.")
+	}
+	if c.redirect != 0 {
+		if _, ok := table.lookup(c.redirect); !ok {
+			return protocol.MakeErrReply("ERR The client ID you want redirect to does not exist")
+		}
+	}
+	table.enable(c)
+	return protocol.MakeOkReply()
+}
+
+// Caching decides whether the keys read by the next command of conn are tracked, in the OPTIN and OPTOUT modes
+// `CLIENT CACHING YES|NO`
+func Caching(table *Table, conn interfaces.Connection, args [][]byte) protocol.Reply {
+	if len(args) != 1 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for 'client|caching' command")
+	}
+
+	table.mu.Lock()
+	defer table.mu.Unlock()
+	c, ok := table.clients[conn.ID()]
+	if !ok || !c.optIn && !c.optOut {
+		return protocol.MakeErrReply("ERR CLIENT CACHING can be called only when the client is in tracking mode with OPTIN or OPTOUT mode enabled")
+	}
+	switch strings.ToUpper(string(args[0])) {
+	case "YES":
+		if !c.optIn {
+			return protocol.MakeErrReply("ERR CLIENT CACHING YES is only valid when tracking is enabled in OPTIN mode.")
+		}
+		c.caching = cachingYes
+	case "NO":
+		if !c.optOut {
+			return protocol.MakeErrReply("ERR CLIENT CACHING NO is only valid when tracking is enabled in OPTOUT mode.")
+		}
+		c.caching = cachingNo
+	default:
+		return protocol.MakeErrReply("ERR syntax error")
+	}
+	return protocol.MakeOkReply()
+}
+
+// GetRedir returns the id of the client invalidations are redirected to,
+// 0 if they aren't redirected and -1 if tracking is off
+// `CLIENT GETREDIR`
+func GetRedir(table *Table, conn interfaces.Connection) protocol.Reply {
+	return protocol.MakeIntReply(table.Redirect(conn))
+}